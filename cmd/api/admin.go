@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+)
+
+// workerAliveWindow is how recent a worker heartbeat must be to count as alive
+const workerAliveWindow = time.Minute
+
+// workersHandler: GET /admin/workers lists registered workers and the jobs they are processing
+func (s *APIServer) workersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	workers, err := s.store.ListWorkers(r.Context(), workerAliveWindow)
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	alive := 0
+	for _, wr := range workers {
+		if wr.Alive {
+			alive++
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workers": workers,
+		"alive":   alive,
+	})
+}
+
+// workerControlHandler: POST /admin/workers/{pause|resume}[?worker_id=...]
+// publishes a command on audio.control; without worker_id every worker is targeted.
+func (s *APIServer) workerControlHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	action := filepath.Base(r.URL.Path)
+	if action != queue.ActionPause && action != queue.ActionResume {
+		http.Error(w, "unknown action", http.StatusNotFound)
+		return
+	}
+	cm := queue.ControlMsg{Action: action, WorkerID: r.URL.Query().Get("worker_id")}
+	b, _ := json.Marshal(cm)
+	if err := s.nc.Publish(queue.ControlSubject, b); err != nil {
+		http.Error(w, "nats publish error: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cm)
+}
+
+// purgesHandler: GET /admin/purges[?since=RFC3339][&limit=n] returns the audit of
+// objects deleted by the retention purger, newest first; since defaults to 30 days ago
+func (s *APIServer) purgesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	since := time.Now().AddDate(0, 0, -30)
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid since, want RFC 3339", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	limit := 500
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 5000 {
+			http.Error(w, "invalid limit (1..5000)", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	purges, err := s.store.ListPurges(r.Context(), since, limit)
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"purges": purges,
+		"count":  len(purges),
+	})
+}
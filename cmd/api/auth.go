@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// requiredScope returns the API key scope a request needs; empty for the
+// endpoints open to anyone
+func requiredScope(r *http.Request) string {
+	switch {
+	case r.URL.Path == "/health" || r.URL.Path == "/metrics":
+		return ""
+	case strings.HasPrefix(r.URL.Path, "/admin/") || r.URL.Path == "/data":
+		return store.ScopeAdmin
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return store.ScopeRead
+	}
+	return store.ScopeWrite
+}
+
+// requestKey returns the API key of a request: Authorization: Bearer <key>,
+// else X-API-Key
+func requestKey(r *http.Request) string {
+	if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(v)
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// authenticate requires an API key with the scope of the endpoint when API_AUTH
+// is "keys". The key of a tenant replaces the X-Tenant-ID header with its
+// tenant, so it only ever sees its own jobs; operator keys and ADMIN_API_KEY,
+// the key to create the first keys with, keep the header.
+func (s *APIServer) authenticate(next http.Handler) http.Handler {
+	if !s.auth {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope := requiredScope(r)
+		if scope == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := requestKey(r)
+		if key == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "API key required", http.StatusUnauthorized)
+			return
+		}
+		if s.adminKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(s.adminKey)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		k, err := s.store.AuthenticateAPIKey(r.Context(), key)
+		if errors.Is(err, store.ErrInvalidKey) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !k.HasScope(scope) {
+			http.Error(w, "API key lacks scope "+scope, http.StatusForbidden)
+			return
+		}
+		if k.TenantID != nil && strings.HasPrefix(r.URL.Path, "/admin/workers") {
+			// workers are shared by every tenant
+			http.Error(w, "operator key required", http.StatusForbidden)
+			return
+		}
+		if k.TenantID != nil {
+			r.Header.Set("X-Tenant-ID", *k.TenantID)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// keysHandler manages API keys:
+//
+//	GET    /admin/keys       lists them, without their secrets
+//	POST   /admin/keys       creates one: name, scopes (comma separated), tenant, expires_at (RFC 3339)
+//	DELETE /admin/keys/{id}  revokes one
+//
+// The key itself is only in the response to the POST. Under X-Tenant-ID (or
+// with the key of a tenant) only the keys of that tenant are seen and created.
+func (s *APIServer) keysHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/keys"), "/")
+	switch {
+	case rest == "" && r.Method == http.MethodGet:
+		keys, err := s.store.ListAPIKeys(r.Context())
+		if err != nil {
+			http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	case rest == "" && r.Method == http.MethodPost:
+		s.createKey(w, r)
+	case rest != "" && r.Method == http.MethodDelete:
+		id, err := uuid.Parse(rest)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		revoked, err := s.store.RevokeAPIKey(r.Context(), id)
+		if err != nil {
+			http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !revoked {
+			http.Error(w, "no such key, or revoked already", http.StatusNotFound)
+			return
+		}
+		log.Printf("revoked API key %s", id)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "revoked": true})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *APIServer) createKey(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	scopes, err := store.ParseScopes(r.FormValue("scopes"))
+	if err != nil {
+		http.Error(w, "scopes: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	tenant, err := queue.ParseLabel("tenant", r.FormValue("tenant"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var expiresAt *time.Time
+	if v := r.FormValue("expires_at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil || !t.After(time.Now()) {
+			http.Error(w, "invalid expires_at, want a future RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		expiresAt = &t
+	}
+	k, key, err := s.store.CreateAPIKey(r.Context(), tenant, name, scopes, expiresAt)
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("created API key %s (%s) for tenant %q with scopes %s", k.ID, k.Name, deref(k.TenantID), strings.Join(k.Scopes, ","))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"api_key": k, "key": key})
+}
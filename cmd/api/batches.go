@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// Limits of batches
+const (
+	maxBatchJobs = 1000 // listed by GET /batches/{id}
+	maxBatchName = 200  // bytes
+)
+
+// batchesHandler groups jobs into batches:
+//
+//	POST /batches       creates one: kind (submission or comparison), name
+//	GET  /batches/{id}  returns it with its aggregated status, job counts per status and jobs
+//
+// Jobs join a batch with batch_id on /submit; bundles are batches of their
+// children, with the id of the bundle job.
+func (s *APIServer) batchesHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/batches"), "/")
+	switch {
+	case rest == "" && r.Method == http.MethodPost:
+		s.createBatch(w, r)
+	case rest != "" && r.Method == http.MethodGet:
+		id, err := uuid.Parse(rest)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		s.getBatch(w, r, id)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *APIServer) createBatch(w http.ResponseWriter, r *http.Request) {
+	kind := r.FormValue("kind")
+	if kind == "" {
+		kind = store.BatchSubmission
+	}
+	if !slices.Contains(store.BatchKinds, kind) {
+		http.Error(w, "invalid kind, want "+strings.Join(store.BatchKinds, " or "), http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	if len(name) > maxBatchName {
+		http.Error(w, fmt.Sprintf("name longer than %d bytes", maxBatchName), http.StatusBadRequest)
+		return
+	}
+	var owner *uuid.UUID
+	if v := r.Header.Get("X-User-ID"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "invalid X-User-ID", http.StatusBadRequest)
+			return
+		}
+		owner = &id
+	}
+	b, err := s.store.CreateBatch(r.Context(), store.NewBatch{Kind: kind, Name: name, OwnerID: owner})
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("created %s batch %s for tenant %q", b.Kind, b.ID, s.store.Tenant())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(b)
+}
+
+func (s *APIServer) getBatch(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	ctx := r.Context()
+	b, err := s.store.GetBatch(ctx, id)
+	if err != nil {
+		http.Error(w, "not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	counts, err := s.store.BatchStatusCounts(ctx, id)
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jobs, err := s.store.ListJobs(ctx, store.JobFilter{BatchID: &id, Limit: maxBatchJobs})
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"batch":     b,
+		"status":    store.AggregateStatus(counts),
+		"total":     total,
+		"by_status": counts,
+		"jobs":      jobs, // newest first, up to maxBatchJobs
+	})
+}
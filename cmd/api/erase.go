@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// eraseHandler serves data subject erasure requests:
+//
+//	DELETE /data?caller_ref=...  erases every job of a call reference
+//	DELETE /data?job_id=...      erases one job
+//	GET    /data?erase_id=...    returns the audit of an earlier erase
+//
+// Erasing deletes the original, the outputs, previews and transcripts with all
+// their versions and scrubs the personal data of the jobs (and of the children
+// of bundles), see store.EraseJob. The X-Tenant-ID header restricts a request
+// to the jobs of that tenant.
+func (s *APIServer) eraseHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodDelete:
+		s.erase(w, r)
+	case http.MethodGet:
+		s.eraseAudit(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// erasedJob reports one job of an erase request
+type erasedJob struct {
+	ID      uuid.UUID `json:"job_id"`
+	Objects int       `json:"objects"`
+	Already bool      `json:"already_erased,omitempty"`
+}
+
+func (s *APIServer) erase(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := store.EraseQuery{CallerRef: r.URL.Query().Get("caller_ref")}
+	if v := r.URL.Query().Get("job_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "invalid job_id", http.StatusBadRequest)
+			return
+		}
+		q.JobID = id
+	}
+	if (q.CallerRef == "") == (q.JobID == uuid.Nil) {
+		http.Error(w, "need either caller_ref or job_id", http.StatusBadRequest)
+		return
+	}
+	tenant, err := queue.ParseLabel("tenant", r.Header.Get("X-Tenant-ID"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.Tenant = tenant
+
+	jobs, err := s.store.FindErasable(ctx, q)
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(jobs) == 0 {
+		http.Error(w, "no jobs found", http.StatusNotFound)
+		return
+	}
+	// a running job would upload its output after the erase
+	for _, j := range jobs {
+		if !j.Finished && !j.Erased {
+			http.Error(w, fmt.Sprintf("job %s is %s; cancel it or wait until it is finished", j.ID, j.Status), http.StatusConflict)
+			return
+		}
+	}
+
+	eraseID := uuid.New()
+	resp := map[string]interface{}{"erase_id": eraseID}
+	if q.CallerRef != "" {
+		resp["caller_ref"] = q.CallerRef
+	}
+	var done []erasedJob
+	for _, j := range jobs {
+		if j.Erased {
+			done = append(done, erasedJob{ID: j.ID, Already: true})
+			continue
+		}
+		n, err := s.eraseJob(ctx, eraseID, q.CallerRef, j)
+		if err != nil {
+			// the jobs erased so far are in the audit; the request can be repeated
+			log.Printf("erase %s: job %s: %v", eraseID, j.ID, err)
+			resp["jobs"] = done
+			resp["error"] = fmt.Sprintf("job %s: %v", j.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		done = append(done, erasedJob{ID: j.ID, Objects: n})
+	}
+	log.Printf("erase %s: %d jobs (caller_ref=%q job_id=%s tenant=%q)", eraseID, len(done), q.CallerRef, q.JobID, q.Tenant)
+	resp["jobs"] = done
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// eraseJob deletes the objects of a job and scrubs its record, returning the
+// number of objects deleted
+func (s *APIServer) eraseJob(ctx context.Context, eraseID uuid.UUID, callerRef string, j store.ErasableJob) (int, error) {
+	objs, err := s.store.JobObjects(ctx, j.ID)
+	if err != nil {
+		return 0, err
+	}
+	for _, o := range objs {
+		objects, err := s.objects.ForBucket(o.Bucket)
+		if err != nil {
+			return 0, err
+		}
+		if err := objects.Delete(ctx, o.Key); err != nil {
+			return 0, fmt.Errorf("delete %s: %w", o.Key, err)
+		}
+	}
+	if err := s.store.EraseJob(ctx, eraseID, callerRef, j, objs); err != nil {
+		return 0, err
+	}
+	return len(objs), nil
+}
+
+func (s *APIServer) eraseAudit(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.URL.Query().Get("erase_id"))
+	if err != nil {
+		http.Error(w, "invalid erase_id", http.StatusBadRequest)
+		return
+	}
+	records, err := s.store.ListErasures(r.Context(), id)
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(records) == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"erase_id": id,
+		"jobs":     records,
+	})
+}
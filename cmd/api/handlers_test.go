@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store/storetest"
+)
+
+// serve runs one request through the routes and authentication of s
+func serve(s *APIServer, method, target string, header map[string]string, form url.Values) *httptest.ResponseRecorder {
+	var body *strings.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	} else {
+		body = strings.NewReader("")
+	}
+	r := httptest.NewRequest(method, target, body)
+	if form != nil {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	s.authenticate(s.routes()).ServeHTTP(w, r)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+}
+
+func createJob(t *testing.T, st store.Store, nj store.NewJob) uuid.UUID {
+	t.Helper()
+	id, err := st.CreateJob(context.Background(), nj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestStatusScopedToTenant(t *testing.T) {
+	st := storetest.NewFake()
+	s := &APIServer{store: st}
+	id := createJob(t, st, store.NewJob{InputPath: "in.wav", Tenant: "acme"})
+
+	for _, tc := range []struct {
+		tenant string
+		want   int
+	}{
+		{"", http.StatusOK},
+		{"acme", http.StatusOK},
+		{"globex", http.StatusNotFound},
+		{"not a tenant!", http.StatusBadRequest},
+	} {
+		w := serve(s, http.MethodGet, "/status/"+id.String(), map[string]string{"X-Tenant-ID": tc.tenant}, nil)
+		if w.Code != tc.want {
+			t.Errorf("tenant %q: status %d, want %d: %s", tc.tenant, w.Code, tc.want, w.Body)
+		}
+	}
+	if w := serve(s, http.MethodGet, "/status/"+uuid.NewString(), nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown job: status %d, want 404", w.Code)
+	}
+}
+
+func TestTenantsWithoutKeys(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	refused := func(st store.Store, configured bool) bool {
+		buf.Reset()
+		refuse := tenantsWithoutKeys(context.Background(), st, configured)
+		if warned := strings.Contains(buf.String(), "WARNING"); warned != refuse {
+			t.Errorf("warned %v, refused %v", warned, refuse)
+		}
+		return refuse
+	}
+
+	st := storetest.NewFake()
+	if refused(st, false) {
+		t.Error("refused without tenants")
+	}
+	if !refused(st, true) {
+		t.Error("not refused with tenant_storage configured")
+	}
+	if _, err := st.CreateTenant(context.Background(), "acme", "Acme"); err != nil {
+		t.Fatal(err)
+	}
+	if !refused(st, false) {
+		t.Error("not refused with a tenant in the store")
+	}
+}
+
+func TestRefuseTenants(t *testing.T) {
+	st := storetest.NewFake()
+	s := &APIServer{store: st, refuseTenants: true}
+	id := createJob(t, st, store.NewJob{InputPath: "in.wav", Tenant: "acme"})
+	acme := map[string]string{"X-Tenant-ID": "acme"}
+
+	for _, path := range []string{"/status/" + id.String(), "/jobs", "/batches", "/data?job_id=" + id.String()} {
+		if w := serve(s, http.MethodGet, path, acme, nil); w.Code != http.StatusForbidden {
+			t.Errorf("GET %s with X-Tenant-ID: status %d, want 403", path, w.Code)
+		}
+	}
+	for _, path := range []string{"/submit", "/uploads"} {
+		if w := serve(s, http.MethodPost, path, acme, url.Values{}); w.Code != http.StatusForbidden {
+			t.Errorf("POST %s with X-Tenant-ID: status %d, want 403", path, w.Code)
+		}
+	}
+	if w := serve(s, http.MethodGet, "/status/"+id.String(), nil, nil); w.Code != http.StatusOK {
+		t.Errorf("without X-Tenant-ID: status %d, want 200: %s", w.Code, w.Body)
+	}
+}
+
+func TestListJobs(t *testing.T) {
+	st := storetest.NewFake()
+	s := &APIServer{store: st}
+	a := createJob(t, st, store.NewJob{Tenant: "acme", Labels: map[string]string{"campaign": "q3"}})
+	b := createJob(t, st, store.NewJob{Tenant: "acme", Labels: map[string]string{"campaign": "q4"}})
+	c := createJob(t, st, store.NewJob{Tenant: "globex", Labels: map[string]string{"campaign": "q3"}})
+	if _, err := st.CancelJob(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		query  string
+		tenant string
+		want   []uuid.UUID
+	}{
+		{"all, newest first", "", "", []uuid.UUID{c, b, a}},
+		{"tenant", "", "acme", []uuid.UUID{b, a}},
+		{"label", "?label=campaign=q3", "", []uuid.UUID{c, a}},
+		{"label and tenant", "?label=campaign=q3", "globex", []uuid.UUID{c}},
+		{"status", "?status=cancelled", "", []uuid.UUID{b}},
+		{"limit", "?limit=1", "", []uuid.UUID{c}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serve(s, http.MethodGet, "/jobs"+tc.query, map[string]string{"X-Tenant-ID": tc.tenant}, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var resp struct{ Jobs []store.JobSummary }
+			decode(t, w, &resp)
+			var got []uuid.UUID
+			for _, j := range resp.Jobs {
+				got = append(got, j.ID)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("jobs %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("jobs %v, want %v", got, tc.want)
+				}
+			}
+		})
+	}
+
+	if w := serve(s, http.MethodGet, "/jobs?limit=0.5", nil, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("limit 0.5: status %d, want 400", w.Code)
+	}
+	if w := serve(s, http.MethodPost, "/jobs", nil, nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status %d, want 405", w.Code)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	st := storetest.NewFake()
+	s := &APIServer{store: st} // no NATS: the jobs cancelled aren't processing
+	id := createJob(t, st, store.NewJob{Tenant: "acme"})
+	path := "/jobs/" + id.String() + "/cancel"
+
+	if w := serve(s, http.MethodGet, path, nil, nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want 405", w.Code)
+	}
+	if w := serve(s, http.MethodPost, path, map[string]string{"X-Tenant-ID": "globex"}, nil); w.Code != http.StatusNotFound {
+		t.Errorf("other tenant: status %d, want 404", w.Code)
+	}
+	w := serve(s, http.MethodPost, path, map[string]string{"X-Tenant-ID": "acme"}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("cancel: status %d: %s", w.Code, w.Body)
+	}
+	job, err := st.GetJob(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != "cancelled" {
+		t.Errorf("job %s, want cancelled", job.Status)
+	}
+	if w := serve(s, http.MethodPost, path, nil, nil); w.Code != http.StatusConflict {
+		t.Errorf("cancel again: status %d, want 409", w.Code)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	st := storetest.NewFake()
+	s := &APIServer{store: st}
+	ctx := context.Background()
+	id := createJob(t, st, store.NewJob{Tenant: "acme"})
+	if _, err := st.ClaimJob(ctx, id, "worker-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetFailed(ctx, id, "boom"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(s, http.MethodGet, "/jobs/"+id.String()+"/events", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp struct{ Events []store.JobEvent }
+	decode(t, w, &resp)
+	var got []string
+	for _, e := range resp.Events {
+		got = append(got, e.Event)
+	}
+	want := []string{"queued", store.EventClaimed, store.EventFailed}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events %v, want %v", got, want)
+	}
+
+	if w := serve(s, http.MethodGet, "/jobs/"+id.String()+"/events", map[string]string{"X-Tenant-ID": "globex"}, nil); w.Code != http.StatusNotFound {
+		t.Errorf("other tenant: status %d, want 404", w.Code)
+	}
+	if w := serve(s, http.MethodGet, "/jobs/"+id.String()+"/nope", nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown action: status %d, want 404", w.Code)
+	}
+}
+
+func TestBatches(t *testing.T) {
+	st := storetest.NewFake()
+	s := &APIServer{store: st}
+	acme := map[string]string{"X-Tenant-ID": "acme"}
+
+	w := serve(s, http.MethodPost, "/batches", acme, url.Values{"name": {"nightly"}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+	var b store.Batch
+	decode(t, w, &b)
+	if b.Tenant == nil || *b.Tenant != "acme" || b.Kind != store.BatchSubmission {
+		t.Fatalf("batch %+v, want a submission batch of acme", b)
+	}
+	if w := serve(s, http.MethodPost, "/batches", nil, url.Values{"kind": {"nope"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown kind: status %d, want 400", w.Code)
+	}
+
+	done := createJob(t, st, store.NewJob{Tenant: "acme", BatchID: &b.ID})
+	createJob(t, st, store.NewJob{Tenant: "acme", BatchID: &b.ID})
+	ctx := context.Background()
+	if _, err := st.ClaimJob(ctx, done, "worker-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetFinished(ctx, done); err != nil {
+		t.Fatal(err)
+	}
+
+	w = serve(s, http.MethodGet, "/batches/"+b.ID.String(), acme, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get: status %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Total    int
+		ByStatus map[string]int `json:"by_status"`
+		Jobs     []store.JobSummary
+	}
+	decode(t, w, &resp)
+	if resp.Total != 2 || resp.ByStatus["done"] != 1 || resp.ByStatus["queued"] != 1 || len(resp.Jobs) != 2 {
+		t.Errorf("batch total %d, by status %v, %d jobs; want 2 jobs, one done and one queued", resp.Total, resp.ByStatus, len(resp.Jobs))
+	}
+	if w := serve(s, http.MethodGet, "/batches/"+b.ID.String(), map[string]string{"X-Tenant-ID": "globex"}, nil); w.Code != http.StatusNotFound {
+		t.Errorf("other tenant: status %d, want 404", w.Code)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	st := storetest.NewFake()
+	s := &APIServer{store: st, auth: true, adminKey: "operator-secret"}
+	ctx := context.Background()
+	acmeJob := createJob(t, st, store.NewJob{Tenant: "acme"})
+	createJob(t, st, store.NewJob{Tenant: "globex"})
+	_, read, err := st.CreateAPIKey(ctx, "acme", "reader", []string{store.ScopeRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedKey, revoked, err := st.CreateAPIKey(ctx, "acme", "old", []string{store.ScopeRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.RevokeAPIKey(ctx, revokedKey.ID); err != nil {
+		t.Fatal(err)
+	}
+	hourAgo, inAnHour := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	_, expired, err := st.CreateAPIKey(ctx, "acme", "expired", []string{store.ScopeRead}, &hourAgo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, write, err := st.CreateAPIKey(ctx, "acme", "ci", []string{store.ScopeRead, store.ScopeWrite}, &inAnHour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, tenantAdmin, err := st.CreateAPIKey(ctx, "acme", "admin", []string{store.ScopeRead, store.ScopeAdmin}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, operator, err := st.CreateAPIKey(ctx, "", "operator", []string{store.ScopeAdmin}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bearer := func(key string) map[string]string { return map[string]string{"Authorization": "Bearer " + key} }
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		header map[string]string
+		want   int
+	}{
+		{"open endpoint", http.MethodGet, "/health", nil, http.StatusOK},
+		{"no key", http.MethodGet, "/jobs", nil, http.StatusUnauthorized},
+		{"unknown key", http.MethodGet, "/jobs", bearer("bk_0000_nope"), http.StatusUnauthorized},
+		{"revoked key", http.MethodGet, "/jobs", bearer(revoked), http.StatusUnauthorized},
+		{"expired key", http.MethodGet, "/jobs", bearer(expired), http.StatusUnauthorized},
+		{"read scope", http.MethodGet, "/jobs", bearer(read), http.StatusOK},
+		{"X-API-Key", http.MethodGet, "/jobs", map[string]string{"X-API-Key": read}, http.StatusOK},
+		{"write needs jobs:write", http.MethodPost, "/batches", bearer(read), http.StatusForbidden},
+		{"write scope", http.MethodPost, "/batches", bearer(write), http.StatusCreated},
+		{"admin needs admin", http.MethodGet, "/admin/keys", bearer(read), http.StatusForbidden},
+		{"admin key", http.MethodGet, "/admin/keys", bearer("operator-secret"), http.StatusOK},
+		{"admin key on workers", http.MethodGet, "/admin/workers", bearer("operator-secret"), http.StatusOK},
+		{"tenant admin on keys", http.MethodGet, "/admin/keys", bearer(tenantAdmin), http.StatusOK},
+		{"tenant admin on workers", http.MethodGet, "/admin/workers", bearer(tenantAdmin), http.StatusForbidden},
+		{"operator key on workers", http.MethodGet, "/admin/workers", bearer(operator), http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if w := serve(s, tc.method, tc.path, tc.header, nil); w.Code != tc.want {
+				t.Errorf("status %d, want %d: %s", w.Code, tc.want, w.Body)
+			}
+		})
+	}
+
+	// the key of a tenant overrides X-Tenant-ID: it only ever sees its own jobs
+	w := serve(s, http.MethodGet, "/jobs", map[string]string{"Authorization": "Bearer " + read, "X-Tenant-ID": "globex"}, nil)
+	var resp struct{ Jobs []store.JobSummary }
+	decode(t, w, &resp)
+	if len(resp.Jobs) != 1 || resp.Jobs[0].ID != acmeJob {
+		t.Errorf("jobs %+v, want only the job of acme", resp.Jobs)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	st := storetest.NewFake()
+	s := &APIServer{store: st}
+	acme := map[string]string{"X-Tenant-ID": "acme"}
+
+	w := serve(s, http.MethodPost, "/admin/keys", acme, url.Values{"name": {"ci"}, "scopes": {"jobs:read,jobs:write"}, "tenant": {"globex"}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		APIKey store.APIKey `json:"api_key"`
+		Key    string       `json:"key"`
+	}
+	decode(t, w, &created)
+	if tenant := created.APIKey.TenantID; tenant == nil || *tenant != "acme" {
+		t.Errorf("key of tenant %v, want acme: a scoped request creates keys of its own tenant", tenant)
+	}
+	k, err := st.AuthenticateAPIKey(context.Background(), created.Key)
+	if err != nil {
+		t.Fatalf("authenticate the created key: %v", err)
+	}
+	if !k.HasScope(store.ScopeWrite) || k.HasScope(store.ScopeAdmin) {
+		t.Errorf("scopes %v, want jobs:read and jobs:write", k.Scopes)
+	}
+	if w := serve(s, http.MethodPost, "/admin/keys", nil, url.Values{"name": {"ci"}, "scopes": {"root"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown scope: status %d, want 400", w.Code)
+	}
+
+	path := "/admin/keys/" + k.ID.String()
+	if w := serve(s, http.MethodDelete, path, map[string]string{"X-Tenant-ID": "globex"}, nil); w.Code != http.StatusNotFound {
+		t.Errorf("revoke from another tenant: status %d, want 404", w.Code)
+	}
+	if w := serve(s, http.MethodDelete, path, acme, nil); w.Code != http.StatusOK {
+		t.Errorf("revoke: status %d: %s", w.Code, w.Body)
+	}
+	if _, err := st.AuthenticateAPIKey(context.Background(), created.Key); err != store.ErrInvalidKey {
+		t.Errorf("authenticate a revoked key: %v, want ErrInvalidKey", err)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// jobsHandler routes /jobs/{id}/{action}[/...]
+func (s *APIServer) jobsHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/"), "/")
+	if len(parts) < 2 || (len(parts) > 2 && parts[1] != "versions") {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	id, err := uuid.Parse(parts[0])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	switch parts[1] {
+	case "cancel":
+		s.cancelHandler(w, r, id)
+	case "report":
+		s.reportHandler(w, r, id)
+	case "events":
+		s.eventsHandler(w, r, id)
+	case "transcript":
+		s.transcriptHandler(w, r, id)
+	case "versions":
+		s.versionsHandler(w, r, id, parts[2:])
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+// cancelHandler: POST /jobs/{id}/cancel marks the job cancelled and tells
+// workers to kill it if it is already running.
+func (s *APIServer) cancelHandler(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+	job, err := s.store.GetJob(ctx, id)
+	if err != nil {
+		http.Error(w, "not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	cancelled, err := s.store.CancelJob(ctx, id)
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !cancelled {
+		http.Error(w, "job already "+job.Status, http.StatusConflict)
+		return
+	}
+	if job.Status == "processing" {
+		b, _ := json.Marshal(queue.ControlMsg{Action: queue.ActionCancel, JobID: id.String()})
+		if err := s.nc.Publish(queue.ControlSubject, b); err != nil {
+			log.Printf("nats publish cancel for job %s: %v", id, err)
+		}
+	}
+	log.Printf("cancelled job %s (was %s)", id, job.Status)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"job_id": id.String(), "status": "cancelled"})
+}
+
+// eventsHandler: GET /jobs/{id}/events lists the transitions of the job, oldest
+// first, with the worker and the time of each, for debugging slow or stuck jobs
+func (s *APIServer) eventsHandler(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+	if _, err := s.store.GetJob(ctx, id); err != nil {
+		http.Error(w, "not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	events, err := s.store.ListJobEvents(ctx, id)
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"job_id": id, "events": events})
+}
+
+// listJobsHandler lists jobs, newest first:
+//
+//	GET /jobs[?status=done][&label=campaign=q3&label=queue=support][&since=RFC 3339][&limit=100]
+//
+// Every label given must be on a job. With min_dead_air_pct=30 it lists the
+// calls with at least that much dead air instead, worst first.
+func (s *APIServer) listJobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit, err := formFloat(r, "limit", 1, 1000)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = 100
+	}
+	r.ParseForm()
+	labels, err := queue.ParseLabels(strings.Join(r.Form["label"], ","))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.FormValue("min_dead_air_pct") == "" {
+		s.listJobs(w, r, labels, int(limit))
+		return
+	}
+	minDeadAir, err := formFloat(r, "min_dead_air_pct", 0, 100)
+	if err != nil {
+		http.Error(w, "min_dead_air_pct (0..100): "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	jobs, err := s.store.ListDeadAirJobs(r.Context(), minDeadAir, labels, int(limit))
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if jobs == nil {
+		jobs = []store.DeadAirJob{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"jobs": jobs})
+}
+
+func (s *APIServer) listJobs(w http.ResponseWriter, r *http.Request, labels map[string]string, limit int) {
+	f := store.JobFilter{Labels: labels, Limit: limit}
+	var err error
+	if f.Status, err = queue.ParseLabel("status", r.FormValue("status")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if v := r.FormValue("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "invalid since, want an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+	jobs, err := s.store.ListJobs(r.Context(), f)
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"jobs": jobs})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/audio"
+	"github.com/google/uuid"
+)
+
+// measurementDiff is one before/after row of a job report; nil values were not measured
+type measurementDiff struct {
+	Before *float64 `json:"before"`
+	After  *float64 `json:"after"`
+	Delta  *float64 `json:"delta,omitempty"` // after - before
+}
+
+func newDiff(before, after *float64) measurementDiff {
+	d := measurementDiff{Before: before, After: after}
+	if before != nil && after != nil {
+		delta := *after - *before
+		d.Delta = &delta
+	}
+	return d
+}
+
+// jobReport is the before/after comparison served by /jobs/{id}/report
+type jobReport struct {
+	JobID          uuid.UUID       `json:"job_id"`
+	Status         string          `json:"status"`
+	DenoiseMethod  string          `json:"denoise_method,omitempty"`
+	Preset         string          `json:"preset,omitempty"`
+	FilterChain    string          `json:"filter_chain,omitempty"`
+	DurationSec    *float64        `json:"duration_sec,omitempty"`
+	Loudness       measurementDiff `json:"loudness_lufs"`
+	TruePeak       measurementDiff `json:"true_peak_dbtp"`
+	LRA            measurementDiff `json:"lra_lu"`
+	SNR            measurementDiff `json:"snr_db"`
+	NoiseLevel     measurementDiff `json:"noise_level_db"`
+	SNRImprovement *float64        `json:"snr_improvement_db,omitempty"`
+}
+
+// reportAnalysis are the parts of analysis_json a report is built from
+type reportAnalysis struct {
+	Loudness struct {
+		Before *audio.Loudness `json:"before"`
+		After  *audio.Loudness `json:"after"`
+	} `json:"loudness"`
+	Noise struct {
+		Before *float64 `json:"before"`
+		After  *float64 `json:"after"`
+	} `json:"noise"`
+	Quality struct {
+		Before *struct {
+			SNR float64 `json:"snr"`
+		} `json:"before"`
+		After *struct {
+			SNR float64 `json:"snr"`
+		} `json:"after"`
+	} `json:"quality"`
+	Processing struct {
+		DenoiseMethod string `json:"denoise_method"`
+		Preset        string `json:"preset"`
+		FilterChain   string `json:"filter_chain"`
+	} `json:"processing"`
+}
+
+// reportHandler: GET /jobs/{id}/report compares the input and the output of a
+// finished job: loudness, true peak, LRA, SNR and noise level, plus the filter
+// chain that was run
+func (s *APIServer) reportHandler(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	job, err := s.store.GetJob(r.Context(), id)
+	if err != nil {
+		http.Error(w, "not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	if job.Status != "done" && job.Status != "completed_with_warnings" {
+		http.Error(w, "no report, job is "+job.Status, http.StatusConflict)
+		return
+	}
+	var a reportAnalysis
+	if len(job.Analysis) > 0 {
+		if err := json.Unmarshal(job.Analysis, &a); err != nil {
+			http.Error(w, "decode analysis: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	loudnessDiff := func(field func(*audio.Loudness) float64) measurementDiff {
+		var before, after *float64
+		if l := a.Loudness.Before; l != nil {
+			v := field(l)
+			before = &v
+		}
+		if l := a.Loudness.After; l != nil {
+			v := field(l)
+			after = &v
+		}
+		return newDiff(before, after)
+	}
+	rep := jobReport{
+		JobID:         job.ID,
+		Status:        job.Status,
+		DenoiseMethod: a.Processing.DenoiseMethod,
+		Preset:        a.Processing.Preset,
+		FilterChain:   a.Processing.FilterChain,
+		DurationSec:   job.Duration,
+		Loudness:      loudnessDiff(func(l *audio.Loudness) float64 { return l.Integrated }),
+		TruePeak:      loudnessDiff(func(l *audio.Loudness) float64 { return l.TruePeak }),
+		LRA:           loudnessDiff(func(l *audio.Loudness) float64 { return l.LRA }),
+		NoiseLevel:    newDiff(a.Noise.Before, a.Noise.After),
+	}
+	if rep.DenoiseMethod == "" && job.DenoiseMethod != nil {
+		rep.DenoiseMethod = *job.DenoiseMethod // jobs processed before the processing section existed
+	}
+	var snrBefore, snrAfter *float64
+	if a.Quality.Before != nil {
+		snrBefore = &a.Quality.Before.SNR
+	}
+	if a.Quality.After != nil {
+		snrAfter = &a.Quality.After.SNR
+	}
+	rep.SNR = newDiff(snrBefore, snrAfter)
+	rep.SNRImprovement = rep.SNR.Delta
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rep)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// maxStatsRange bounds the period of /stats, which scans the jobs of the period
+const maxStatsRange = 366 * 24 * time.Hour
+
+// statsHandler: GET /stats[?since=RFC3339][&until=RFC3339] returns the job counts,
+// success rate, p50/p95 processing time and mean SNR improvement of the jobs
+// created in the period, in total and per UTC day and denoiser, for dashboards.
+// The period defaults to the last 30 days.
+func (s *APIServer) statsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	until := time.Now()
+	if v := r.URL.Query().Get("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid until, want RFC 3339", http.StatusBadRequest)
+			return
+		}
+		until = t
+	}
+	since := until.AddDate(0, 0, -30)
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid since, want RFC 3339", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	if !since.Before(until) || until.Sub(since) > maxStatsRange {
+		http.Error(w, "since must be before until, at most 366 days apart", http.StatusBadRequest)
+		return
+	}
+	stats, err := s.store.JobStatsBetween(r.Context(), since, until)
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/audio"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/bundle"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/cleanup"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/models"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// submission is what a /submit request asks for, before its input is stored
+type submission struct {
+	msg     queue.JobMsg // the options; ids and locations are set once the job exists
+	dedupe  bool
+	owner   *uuid.UUID // X-User-ID
+	batchID *uuid.UUID
+}
+
+// submitHandler: multipart upload field "file", or "upload_key" of a file a
+// browser uploaded straight to the bucket, see uploadsHandler
+func (s *APIServer) submitHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// refuse files before spooling them; registering a direct upload (a plain
+	// form with upload_key) needs no local disk
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "multipart/form-data" {
+		if err := s.disk.Check(); err != nil {
+			diskPressure(w, err)
+			return
+		}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		http.Error(w, "invalid form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	uploadKey := r.FormValue("upload_key")
+	var f multipart.File
+	var fh *multipart.FileHeader
+	var originalName string
+	if uploadKey != "" {
+		originalName = path.Base(uploadKey)
+	} else {
+		var err error
+		f, fh, err = r.FormFile("file")
+		if err != nil {
+			http.Error(w, "file or upload_key required: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		if limit := int64(getIntEnv("MAX_INPUT_BYTES", 0)); limit > 0 && fh.Size > limit {
+			http.Error(w, fmt.Sprintf("file too large: %d bytes, limit is %d", fh.Size, limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if fh.Size == 0 {
+			http.Error(w, "file is empty", http.StatusBadRequest)
+			return
+		}
+		originalName = fh.Filename
+	}
+
+	sub, err := s.submitOptions(ctx, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	msg := sub.msg
+	if bundle.IsArchive(originalName) {
+		// archives are unpacked by a worker into one child job per recording
+		msg.Kind = queue.KindBundle
+	}
+
+	filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), sanitize(originalName))
+	msg.OutputPath = filepath.Join(storageOutputDir, filename+"_processed."+audio.OutputExt(msg.OutputFormat))
+	objects := s.objects.For(msg.Tenant)
+	var contentHash string
+	if uploadKey != "" {
+		// uploaded straight to the bucket: no local copy, so no content hash
+		// (and no dedupe); the worker's preflight checks the file
+		if _, status, err := s.uploadedInput(ctx, objects, uploadKey); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+	} else {
+		msg.InputPath, contentHash, err = s.spool(f, filename, msg.Kind == queue.KindBundle)
+		var pe *audio.PreflightError
+		switch {
+		case errors.Is(err, syscall.ENOSPC):
+			diskPressure(w, s.disk.Update())
+			return
+		case errors.As(err, &pe):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	optionsHash := msg.OptionsHash()
+
+	// same recording with the same options already processed: hand back that job
+	if sub.dedupe && contentHash != "" {
+		existing, err := s.store.FindDoneByHash(ctx, contentHash, optionsHash)
+		if err != nil {
+			cleanup.Remove(msg.InputPath)
+			http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if existing != nil {
+			cleanup.Remove(msg.InputPath)
+			log.Printf("duplicate submission of job %s (sha256=%s)", existing.ID, contentHash)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"job_id": existing.ID.String(), "status": existing.Status, "duplicate": true})
+			return
+		}
+	}
+
+	// create job in DB
+	jobInput := msg.InputPath
+	if uploadKey != "" {
+		jobInput = uploadKey
+	}
+	jobID, err := s.store.CreateJob(ctx, store.NewJob{
+		InputPath:      jobInput,
+		OutputPath:     msg.OutputPath,
+		Priority:       msg.Priority,
+		ProcessAfter:   msg.ProcessAfter,
+		ContentHash:    contentHash,
+		OptionsHash:    optionsHash,
+		Kind:           msg.Kind,
+		Tenant:         msg.Tenant,
+		RetentionClass: msg.RetentionClass,
+		CallerRef:      msg.CallerRef,
+		OwnerID:        sub.owner,
+		Labels:         msg.Labels,
+		BatchID:        sub.batchID,
+	})
+	if err != nil {
+		cleanup.Remove(msg.InputPath)
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	msg.ID = jobID.String()
+
+	// keep the original under the job id, for workers on other hosts to fetch it
+	// and for reprocessing and audit later; a direct upload stays where it is
+	msg.InputBucket = objects.Bucket()
+	msg.InputKey = uploadKey
+	var info storage.UploadInfo
+	if uploadKey == "" {
+		msg.InputKey = objects.Key(storage.OriginalKey(msg.ID, originalName))
+		info, err = s.uploadOriginal(ctx, objects, msg, fh.Header.Get("Content-Type"), contentHash)
+		if err != nil {
+			if ferr := s.store.SetFailed(ctx, jobID, "input upload failed: "+err.Error()); ferr != nil {
+				log.Printf("mark job %s failed: %v", jobID, ferr)
+			}
+			http.Error(w, "input upload error: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	if err := s.store.SetOriginal(ctx, jobID, msg.InputBucket, msg.InputKey, info.SHA256, info.Endpoint); err != nil {
+		log.Printf("store original key of job %s: %v", jobID, err)
+	}
+	msg.InputSHA256 = info.SHA256
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"job_id": msg.ID, "status": s.enqueue(ctx, jobID, msg)})
+}
+
+// submitOptions parses the processing options of a /submit request; every
+// error is the client's
+func (s *APIServer) submitOptions(ctx context.Context, r *http.Request) (*submission, error) {
+	presetName := r.FormValue("preset")
+	pipeline := s.pipeline
+	if presetName != "" {
+		preset, ok := s.presets[presetName]
+		if !ok {
+			return nil, fmt.Errorf("unknown preset %q (want one of %s)", presetName, strings.Join(audio.PresetNames(s.presets), ", "))
+		}
+		pipeline = preset.PipelineConfig
+	}
+	denoiseMethod := r.FormValue("denoise_method")
+	if denoiseMethod == "" {
+		denoiseMethod = pipeline.DenoiseDefault // default of the preset or the config file
+	}
+	denoiseParams, err := audio.ParseDenoiseParams(r.FormValue("denoise_params"))
+	if err != nil {
+		return nil, err
+	}
+	benchmark, err := audio.ParseBenchmark(r.FormValue("benchmark"))
+	if err != nil {
+		return nil, err
+	}
+	// the model is resolved by the worker; reject names it can never know
+	denoiseModel := r.FormValue("denoise_model")
+	if denoiseModel != "" {
+		if _, err := models.NewManager("", nil).Lookup(denoiseModel); err != nil {
+			return nil, err
+		}
+	}
+	priority, err := queue.ParsePriority(r.FormValue("priority"))
+	if err != nil {
+		return nil, err
+	}
+	// optional RFC3339 timestamp; future values hold the job until the scheduler releases it
+	var processAfter *time.Time
+	if v := r.FormValue("process_after"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid process_after (want RFC3339): %w", err)
+		}
+		if t.After(time.Now()) {
+			processAfter = &t
+		}
+	}
+	outputFormat, err := audio.ParseOutputFormat(r.FormValue("output_format"))
+	if err != nil {
+		return nil, err
+	}
+	bitrate := 0
+	if v := r.FormValue("bitrate"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &bitrate); err != nil || bitrate < 8 || bitrate > 320 {
+			return nil, errors.New("invalid bitrate (kbps, 8..320)")
+		}
+	}
+	trimThreshold, err := formFloat(r, "trim_threshold_db", -90, 0)
+	if err != nil {
+		return nil, err
+	}
+	trimPadding, err := formFloat(r, "trim_padding", 0, 10)
+	if err != nil {
+		return nil, err
+	}
+	maxGap, err := formFloat(r, "max_gap", 0, 60)
+	if err != nil {
+		return nil, err
+	}
+	gateThreshold, err := formFloat(r, "gate_threshold_db", -90, 0)
+	if err != nil {
+		return nil, err
+	}
+	highpass, err := formFloat(r, "highpass", 0, 1000)
+	if err != nil {
+		return nil, err
+	}
+	lowpass, err := formFloat(r, "lowpass", 0, 24000)
+	if err != nil {
+		return nil, err
+	}
+	if highpass > 0 && lowpass > 0 && lowpass <= highpass {
+		return nil, errors.New("lowpass must be above highpass")
+	}
+	deesserLevel, err := formFloat(r, "deesser_intensity", 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	customFilter, err := audio.ParseCustomFilter(r.FormValue("custom_filter"))
+	if err != nil {
+		return nil, err
+	}
+	customMode, err := audio.ParseCustomMode(r.FormValue("custom_filter_mode"))
+	if err != nil {
+		return nil, err
+	}
+	if customFilter == "" {
+		customMode = ""
+	}
+	channelMode, err := audio.ParseChannelMode(r.FormValue("channel_mode"))
+	if err != nil {
+		return nil, err
+	}
+	if channelMode == audio.ChannelMono {
+		channelMode = "" // default, keep it out of the payload and the options hash
+	}
+	tempo, err := formFloat(r, "tempo", 0.5, 4)
+	if err != nil {
+		return nil, err
+	}
+	tempo, tempoMode, err := audio.ParseTempo(tempo, r.FormValue("tempo_mode"))
+	if err != nil {
+		return nil, err
+	}
+	tenant, err := queue.ParseLabel("tenant", r.Header.Get("X-Tenant-ID"))
+	if err != nil {
+		return nil, err
+	}
+	var owner *uuid.UUID
+	if v := r.Header.Get("X-User-ID"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return nil, errors.New("invalid X-User-ID")
+		}
+		owner = &id
+	}
+	retentionClass, err := queue.ParseLabel("retention_class", r.FormValue("retention_class"))
+	if err != nil {
+		return nil, err
+	}
+	if retentionClass == "" {
+		retentionClass = queue.DefaultRetentionClass
+	}
+	callerRef := strings.TrimSpace(r.FormValue("caller_ref"))
+	if len(callerRef) > maxCallerRef {
+		return nil, fmt.Errorf("caller_ref longer than %d bytes", maxCallerRef)
+	}
+	labels, err := queue.ParseLabels(r.FormValue("labels"))
+	if err != nil {
+		return nil, err
+	}
+	var batchID *uuid.UUID
+	if v := r.FormValue("batch_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return nil, errors.New("invalid batch_id")
+		}
+		// the submitter's tenant must own the batch, whatever store s holds
+		batch, err := s.store.ForTenant(tenant).GetBatch(ctx, id)
+		if err != nil || batch.Kind == store.BatchBundle || deref(batch.Tenant) != tenant {
+			return nil, errors.New("no such batch")
+		}
+		batchID = &id
+	}
+	keywordList := r.FormValue("keyword_list")
+	if _, ok := s.keywords[keywordList]; keywordList != "" && !ok {
+		return nil, fmt.Errorf("unknown keyword_list %q", keywordList)
+	}
+	redact := r.FormValue("redact")
+	if _, err := audio.ParseRedactSources(redact); err != nil {
+		return nil, err
+	}
+	redactMode := r.FormValue("redact_mode")
+	if _, err := audio.ParseRedactMode(redactMode); err != nil {
+		return nil, err
+	}
+	archivalCopy := r.FormValue("archival_copy")
+	if archivalCopy == "" {
+		archivalCopy = os.Getenv("ARCHIVAL_COPY")
+	}
+	if archivalCopy, err = audio.ParseArchivalCopy(archivalCopy); err != nil {
+		return nil, err
+	}
+
+	return &submission{
+		msg: queue.JobMsg{
+			AnalyzeOnly:    r.FormValue("analyze_only") == "true",
+			Preset:         presetName,
+			DenoiseMethod:  denoiseMethod,
+			DenoiseParams:  denoiseParams,
+			DenoiseModel:   denoiseModel,
+			OutputFormat:   outputFormat,
+			BitrateKbps:    bitrate,
+			TrimSilence:    r.FormValue("trim_silence") == "true",
+			TrimThreshold:  trimThreshold,
+			TrimPadding:    trimPadding,
+			RemoveGaps:     r.FormValue("remove_gaps") == "true",
+			MaxGapSec:      maxGap,
+			NoiseGate:      r.FormValue("noise_gate") == "true",
+			GateThreshold:  gateThreshold,
+			HighpassHz:     int(highpass),
+			LowpassHz:      int(lowpass),
+			Deesser:        r.FormValue("deesser") == "true",
+			DeesserLevel:   deesserLevel,
+			Dereverb:       r.FormValue("dereverb") == "true",
+			Declip:         r.FormValue("declip") == "true",
+			DCRemove:       r.FormValue("dc_remove") == "true",
+			CustomFilter:   customFilter,
+			CustomMode:     customMode,
+			ChannelMode:    channelMode,
+			Tempo:          tempo,
+			TempoMode:      tempoMode,
+			Spectrogram:    r.FormValue("spectrogram") == "true",
+			QualityScores:  r.FormValue("quality_scores") == "true",
+			Diarize:        r.FormValue("diarize") == "true",
+			Transcribe:     r.FormValue("transcribe") == "true",
+			KeywordList:    keywordList,
+			Redact:         redact,
+			RedactMode:     redactMode,
+			RedactPII:      r.FormValue("redact_pii") == "true",
+			Benchmark:      benchmark,
+			ArchivalCopy:   archivalCopy,
+			Priority:       priority,
+			ProcessAfter:   processAfter,
+			Tenant:         tenant,
+			RetentionClass: retentionClass,
+			CallerRef:      callerRef,
+			Labels:         labels,
+		},
+		dedupe:  r.FormValue("dedupe") == "true",
+		owner:   owner,
+		batchID: batchID,
+	}, nil
+}
+
+// spool writes an uploaded file to the spool dir, hashing it on the way, and
+// returns its path and sha256. Files that are plainly not audio fail with an
+// audio.PreflightError, a full disk with syscall.ENOSPC; archives are left to
+// the worker.
+func (s *APIServer) spool(f io.Reader, filename string, archive bool) (string, string, error) {
+	inputPath := filepath.Join(s.spoolDir, filename)
+	out, err := os.Create(inputPath)
+	if err != nil {
+		return "", "", fmt.Errorf("create file error: %w", err)
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hasher), f); err != nil {
+		out.Close()
+		cleanup.Remove(inputPath)
+		return "", "", fmt.Errorf("write file error: %w", err)
+	}
+	out.Close()
+
+	// reject what is plainly not audio now rather than as a failed job; the worker's
+	// preflight runs the ffprobe checks
+	if !archive {
+		if _, err := audio.CheckInput(inputPath, audio.PreflightLimits{}); err != nil {
+			cleanup.Remove(inputPath)
+			return "", "", err
+		}
+	}
+	return inputPath, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// uploadOriginal uploads the spooled input of msg to its InputKey and removes
+// the local copy, workers download from object storage. contentHash is the
+// sha256 of the file as received, checked against the upload.
+func (s *APIServer) uploadOriginal(ctx context.Context, objects storage.Placement, msg queue.JobMsg, contentType, contentHash string) (storage.UploadInfo, error) {
+	defer cleanup.Remove(msg.InputPath)
+	// browsers send octet-stream for what they don't know, e.g. .opus or .amr;
+	// empty has the storage detect the type from the file
+	if contentType == "application/octet-stream" {
+		contentType = ""
+	}
+	info, err := objects.UploadFile(storage.WithTags(ctx, msg.ObjectTags(0)), msg.InputPath, msg.InputKey, contentType)
+	if err == nil && info.SHA256 != contentHash {
+		// the spooled file changed on disk between receiving and uploading it
+		err = fmt.Errorf("%w: uploaded sha256 %s, received %s", storage.ErrChecksumMismatch, info.SHA256, contentHash)
+	}
+	return info, err
+}
+
+// enqueue keeps the payload of a created job, so the janitor/scheduler can
+// (re)publish it later, and publishes it to the NATS subject of its method and
+// priority unless it is scheduled; it returns the status of the job
+func (s *APIServer) enqueue(ctx context.Context, jobID uuid.UUID, msg queue.JobMsg) string {
+	b, _ := json.Marshal(msg)
+	if err := s.store.SetPayload(ctx, jobID, b); err != nil {
+		log.Printf("store payload error: %v", err)
+	}
+
+	if msg.ProcessAfter != nil && s.scheduled(ctx, jobID) {
+		// scheduled: the worker scheduler publishes it once process_after has passed
+		log.Printf("scheduled job %s (method=%s priority=%s) after %s", jobID.String(), msg.DenoiseMethod, msg.Priority, msg.ProcessAfter.Format(time.RFC3339))
+		return "scheduled"
+	}
+	if err := s.nc.Publish(msg.Subject(), b); err != nil {
+		// log but continue worker may poll DB later
+		log.Printf("nats publish error: %v", err)
+	}
+	log.Printf("enqueued job %s (method=%s priority=%s)", jobID.String(), msg.DenoiseMethod, msg.Priority)
+	return "queued"
+}
+
+// scheduled tells whether a job with a process_after was created as scheduled:
+// CreateJob compares it with the time of the insert, after the upload, so a
+// process_after that passed meanwhile queues the job. When the status can't be
+// read the job is published; a worker only claims it once it is queued.
+func (s *APIServer) scheduled(ctx context.Context, jobID uuid.UUID) bool {
+	j, err := s.store.GetJob(ctx, jobID)
+	if err != nil {
+		log.Printf("status of job %s: %v", jobID, err)
+		return false
+	}
+	return j.Status == "scheduled"
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/audio"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store/storetest"
+)
+
+func TestSubmitOptions(t *testing.T) {
+	st := storetest.NewFake()
+	bundleBatch, err := st.CreateBatch(context.Background(), store.NewBatch{Kind: store.BatchBundle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	acmeBatch, err := st.CreateBatch(context.Background(), store.NewBatch{Kind: store.BatchSubmission, Tenant: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &APIServer{store: st, pipeline: audio.PipelineConfig{DenoiseDefault: "afftdn"}}
+	submitAs := func(tenant string, form url.Values) (*submission, error) {
+		r := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if tenant != "" {
+			r.Header.Set("X-Tenant-ID", tenant)
+		}
+		return s.submitOptions(r.Context(), r)
+	}
+	submit := func(form url.Values) (*submission, error) {
+		return submitAs("", form)
+	}
+
+	sub, err := submit(url.Values{"channel_mode": {"mono"}, "custom_filter_mode": {"replace"}, "dedupe": {"true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := sub.msg; m.DenoiseMethod != "afftdn" || m.RetentionClass != queue.DefaultRetentionClass || m.ChannelMode != "" || m.CustomMode != "" || !sub.dedupe {
+		t.Errorf("defaults not applied: %+v", sub)
+	}
+
+	sub, err = submit(url.Values{"highpass": {"100"}, "lowpass": {"3400"}, "process_after": {time.Now().Add(time.Hour).Format(time.RFC3339)}, "labels": {"queue=support"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := sub.msg; m.HighpassHz != 100 || m.LowpassHz != 3400 || m.ProcessAfter == nil || m.Labels["queue"] != "support" {
+		t.Errorf("options not parsed: %+v", m)
+	}
+	if sub, _ := submit(url.Values{"process_after": {"2000-01-01T00:00:00Z"}}); sub.msg.ProcessAfter != nil {
+		t.Error("a past process_after schedules the job")
+	}
+
+	for _, tc := range []struct {
+		form    url.Values
+		wantErr string
+	}{
+		{url.Values{"preset": {"podcast"}}, "unknown preset"},
+		{url.Values{"bitrate": {"4"}}, "invalid bitrate"},
+		{url.Values{"highpass": {"300"}, "lowpass": {"200"}}, "lowpass must be above highpass"},
+		{url.Values{"process_after": {"tomorrow"}}, "invalid process_after"},
+		{url.Values{"custom_filter": {"movie=/etc/passwd"}}, "not allowed"},
+		{url.Values{"caller_ref": {strings.Repeat("x", maxCallerRef+1)}}, "caller_ref longer"},
+		{url.Values{"batch_id": {uuid.NewString()}}, "no such batch"},
+		{url.Values{"batch_id": {bundleBatch.ID.String()}}, "no such batch"},
+		{url.Values{"keyword_list": {"nope"}}, "unknown keyword_list"},
+	} {
+		if _, err := submit(tc.form); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%v: err %v, want %q", tc.form, err, tc.wantErr)
+		}
+	}
+
+	// a batch only takes jobs of its own tenant, also through an unscoped store
+	form := url.Values{"batch_id": {acmeBatch.ID.String()}}
+	if sub, err := submitAs("acme", form); err != nil || *sub.batchID != acmeBatch.ID {
+		t.Errorf("acme into its batch: %+v, %v", sub, err)
+	}
+	for _, tenant := range []string{"", "globex"} {
+		if _, err := submitAs(tenant, form); err == nil || !strings.Contains(err.Error(), "no such batch") {
+			t.Errorf("tenant %q into the batch of acme: err %v, want no such batch", tenant, err)
+		}
+	}
+}
+
+// wav is a 16-bit mono WAV file of n silent samples
+func wav(n int) []byte {
+	b := []byte("RIFF\x00\x00\x00\x00WAVEfmt ")
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1)    // PCM
+	b = binary.LittleEndian.AppendUint16(b, 1)    // channels
+	b = binary.LittleEndian.AppendUint32(b, 8000) // rate
+	b = binary.LittleEndian.AppendUint32(b, 16000)
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(2*n))
+	b = append(b, make([]byte, 2*n)...)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)-8))
+	return b
+}
+
+// submitResponse is the answer of /submit
+type submitResponse struct {
+	JobID     string `json:"job_id"`
+	Status    string `json:"status"`
+	Duplicate bool   `json:"duplicate"`
+}
+
+// submitFile posts data as the file of a /submit request with the fields and
+// headers given, scheduled an hour ahead so no NATS is needed
+func submitFile(s *APIServer, name string, data []byte, header map[string]string, fields map[string]string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", name)
+	fw.Write(data)
+	mw.WriteField("process_after", time.Now().Add(time.Hour).Format(time.RFC3339))
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/submit", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	s.authenticate(s.routes()).ServeHTTP(w, r)
+	return w
+}
+
+// localObjects is object storage in a temporary dir
+func localObjects(t *testing.T) *storage.Router {
+	t.Helper()
+	objects, err := storage.NewRouter(storage.Config{Backend: storage.BackendLocal, Local: storage.LocalConfig{Root: t.TempDir()}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return objects
+}
+
+func TestSubmit(t *testing.T) {
+	st := storetest.NewFake()
+	objects := localObjects(t)
+	s := &APIServer{store: st, objects: objects, spoolDir: t.TempDir()}
+	upload := func(name string, data []byte) *httptest.ResponseRecorder {
+		return submitFile(s, name, data, nil, nil)
+	}
+
+	w := upload("call.wav", wav(800))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp submitResponse
+	decode(t, w, &resp)
+	if resp.Status != "scheduled" {
+		t.Errorf("job %s", resp.Status)
+	}
+	j, err := st.GetJob(context.Background(), uuid.MustParse(resp.JobID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.OriginalKey == nil || j.OriginalSHA256 == nil {
+		t.Fatalf("original not recorded: %+v", j)
+	}
+	if _, err := objects.For("").StatObject(context.Background(), *j.OriginalKey); err != nil {
+		t.Errorf("original %s: %v", *j.OriginalKey, err)
+	}
+
+	if w := upload("notes.wav", []byte("not a recording at all")); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("not audio: status %d, want 422: %s", w.Code, w.Body)
+	}
+	if left, _ := os.ReadDir(s.spoolDir); len(left) != 0 {
+		t.Errorf("spool dir holds %d files after the uploads", len(left))
+	}
+}
+
+func TestSubmitScopedToTenant(t *testing.T) {
+	st := storetest.NewFake()
+	s := &APIServer{store: st, objects: localObjects(t), spoolDir: t.TempDir()}
+	submitAs := func(tenant string) submitResponse {
+		t.Helper()
+		w := submitFile(s, "call.wav", wav(800), map[string]string{"X-Tenant-ID": tenant}, map[string]string{"dedupe": "true"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("tenant %q: status %d: %s", tenant, w.Code, w.Body)
+		}
+		var resp submitResponse
+		decode(t, w, &resp)
+		return resp
+	}
+
+	first := submitAs("acme")
+	id := uuid.MustParse(first.JobID)
+	j, err := st.GetJob(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Tenant == nil || *j.Tenant != "acme" {
+		t.Fatalf("job of tenant %v, want acme", j.Tenant)
+	}
+	finished := time.Now()
+	st.Edit(id, func(j *store.Job) { j.Status, j.FinishedAt = "done", &finished })
+
+	// dedupe hands back finished jobs of the submitter's tenant only
+	if again := submitAs("acme"); !again.Duplicate || again.JobID != first.JobID {
+		t.Errorf("acme again: %+v, want the duplicate %s", again, first.JobID)
+	}
+	if other := submitAs("globex"); other.Duplicate || other.JobID == first.JobID {
+		t.Errorf("globex: %+v, want a job of its own", other)
+	}
+}
+
+func TestScheduled(t *testing.T) {
+	st := storetest.NewFake()
+	s := &APIServer{store: st}
+	later, passed := time.Now().Add(time.Hour), time.Now().Add(-time.Second)
+
+	// process_after passed between parsing the form and creating the job
+	for _, tc := range []struct {
+		name  string
+		after time.Time
+		want  bool
+	}{
+		{"future", later, true},
+		{"passed", passed, false},
+	} {
+		id := createJob(t, st, store.NewJob{ProcessAfter: &tc.after})
+		if got := s.scheduled(context.Background(), id); got != tc.want {
+			t.Errorf("%s: scheduled %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	if s.scheduled(context.Background(), uuid.New()) {
+		t.Error("unknown job: scheduled, want it published")
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// transcriptHandler: GET /jobs/{id}/transcript[?format=json|text|srt|vtt]
+// returns the transcript of a job transcribed with transcribe=true: JSON with
+// segments and word timings (default), the plain text, or captions
+func (s *APIServer) transcriptHandler(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := r.Context()
+	if _, err := s.store.GetJob(ctx, id); err != nil {
+		http.Error(w, "not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	tr, err := s.store.GetTranscript(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "job has no transcript", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tr)
+	case "text", "txt":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, tr.Text+"\n")
+	case "srt":
+		w.Header().Set("Content-Type", "application/x-subrip")
+		io.WriteString(w, tr.SRT())
+	case "vtt":
+		w.Header().Set("Content-Type", "text/vtt")
+		io.WriteString(w, tr.VTT())
+	default:
+		http.Error(w, "invalid format, want json, text, srt or vtt", http.StatusBadRequest)
+	}
+}
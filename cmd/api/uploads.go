@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+)
+
+// uploadsPrefix is where browsers upload recordings with a POST policy
+const uploadsPrefix = "uploads/"
+
+// uploadContentTypes are the content types a POST policy is issued for, besides audio/*
+var uploadContentTypes = map[string]bool{
+	"application/octet-stream": true,
+	"application/zip":          true,
+	"application/x-tar":        true,
+	"application/gzip":         true,
+	"video/mp4":                true, // m4a files are often sent as video/mp4
+	"video/webm":               true,
+}
+
+// uploadsHandler: POST /uploads?filename=...[&content_type=...] issues an S3
+// POST policy for a browser to upload one recording straight to the bucket.
+// The upload is then registered as a job with /submit and upload_key.
+func (s *APIServer) uploadsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	filename := sanitize(r.FormValue("filename"))
+	if filename == "" || filename == "." || filename == "/" {
+		http.Error(w, "filename required", http.StatusBadRequest)
+		return
+	}
+	contentType := r.FormValue("content_type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if !strings.HasPrefix(contentType, "audio/") && !uploadContentTypes[contentType] {
+		http.Error(w, fmt.Sprintf("content_type %q is no audio or archive type", contentType), http.StatusBadRequest)
+		return
+	}
+	tenant, err := queue.ParseLabel("tenant", r.Header.Get("X-Tenant-ID"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	maxBytes := int64(getIntEnv("MAX_INPUT_BYTES", 0))
+	if maxBytes <= 0 {
+		maxBytes = maxDirectUpload
+	}
+	objects := s.objects.For(tenant)
+	key := objects.Key(uploadsPrefix + uuid.NewString() + "/" + filename)
+	policy, err := objects.PresignedPost(r.Context(), key, storage.PostConditions{
+		ContentType: contentType,
+		MaxBytes:    maxBytes,
+		Expires:     time.Duration(getIntEnv("UPLOAD_POLICY_SECS", 15*60)) * time.Second,
+	})
+	if errors.Is(err, storage.ErrUnsupported) {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, "presign error: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"upload_key":   key,
+		"url":          policy.URL,
+		"fields":       policy.Fields,
+		"content_type": contentType,
+		"max_bytes":    maxBytes,
+		"expires_at":   policy.Expires,
+	})
+}
+
+// uploadedInput checks an upload_key of /submit: it must be an upload of the
+// tenant made with a policy of /uploads, not yet registered, and within limits
+func (s *APIServer) uploadedInput(ctx context.Context, objects storage.Placement, key string) (storage.ObjectInfo, int, error) {
+	if !strings.HasPrefix(key, objects.Key(uploadsPrefix)) || strings.Contains(key, "..") {
+		return storage.ObjectInfo{}, http.StatusBadRequest, fmt.Errorf("upload_key %q is not an upload of /uploads", key)
+	}
+	info, err := objects.StatObject(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return info, http.StatusNotFound, fmt.Errorf("upload_key %q: nothing uploaded", key)
+	}
+	if err != nil {
+		return info, http.StatusBadGateway, fmt.Errorf("stat upload: %w", err)
+	}
+	if limit := int64(getIntEnv("MAX_INPUT_BYTES", 0)); limit > 0 && info.Size > limit {
+		return info, http.StatusRequestEntityTooLarge, fmt.Errorf("file too large: %d bytes, limit is %d", info.Size, limit)
+	}
+	if info.Size == 0 {
+		return info, http.StatusBadRequest, errors.New("file is empty")
+	}
+	// a second job would lose its input when the first one is erased or purged
+	used, err := s.store.OriginalInUse(ctx, objects.Bucket(), key)
+	if err != nil {
+		return info, http.StatusInternalServerError, fmt.Errorf("db error: %w", err)
+	}
+	if used {
+		return info, http.StatusConflict, fmt.Errorf("upload_key %q is registered already", key)
+	}
+	return info, 0, nil
+}
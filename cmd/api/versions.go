@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// outputRef is a stored output of a job: the main one, or an additional one by name
+type outputRef struct {
+	name      string // empty for the main output
+	bucket    string
+	key       string
+	versionID string // the version recorded on the job
+	output    *store.JobOutput
+}
+
+// jobOutputRef finds the output name (empty: the main output) of a job
+func (s *APIServer) jobOutputRef(ctx context.Context, job *store.Job, name string) (outputRef, error) {
+	if name == "" {
+		if job.S3Key == nil || *job.S3Key == "" {
+			return outputRef{}, errors.New("job has no output")
+		}
+		return outputRef{bucket: deref(job.S3Bucket), key: *job.S3Key, versionID: deref(job.S3Version)}, nil
+	}
+	outputs, err := s.store.ListJobOutputs(ctx, job.ID)
+	if err != nil {
+		return outputRef{}, err
+	}
+	for i, o := range outputs {
+		if o.Name == name {
+			return outputRef{name: name, bucket: o.S3Bucket, key: o.S3Key, versionID: deref(o.S3Version), output: &outputs[i]}, nil
+		}
+	}
+	return outputRef{}, fmt.Errorf("job has no output %q", name)
+}
+
+// outputVersion is one version of a job output, with a download link
+type outputVersion struct {
+	storage.ObjectVersion
+	Current bool   `json:"current"` // the version recorded on the job
+	URL     string `json:"url,omitempty"`
+}
+
+// versionsHandler serves the versions of a job output kept by a versioned bucket:
+//
+//	GET  /jobs/{id}/versions[?output=name]                      lists them with download links
+//	POST /jobs/{id}/versions/{version_id}/restore[?output=name] makes a version current again
+//
+// output selects an additional output (preview, redacted, ...) instead of the
+// main one. A restore copies the version over the output, e.g. after a retried
+// job overwrote a good result, and records the copy on the job.
+func (s *APIServer) versionsHandler(w http.ResponseWriter, r *http.Request, id uuid.UUID, rest []string) {
+	switch {
+	case len(rest) == 0 && r.Method == http.MethodGet:
+	case len(rest) == 2 && rest[1] == "restore" && r.Method == http.MethodPost:
+	case len(rest) == 0 || (len(rest) == 2 && rest[1] == "restore"):
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	ctx := r.Context()
+	job, err := s.store.GetJob(ctx, id)
+	if err != nil {
+		http.Error(w, "not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	if job.PurgedAt != nil {
+		http.Error(w, "the objects of the job were deleted", http.StatusGone)
+		return
+	}
+	ref, err := s.jobOutputRef(ctx, job, r.URL.Query().Get("output"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	objects, err := s.objects.ForBucket(ref.bucket)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if len(rest) == 0 {
+		s.listVersions(w, r, objects, ref)
+		return
+	}
+	s.restoreVersion(w, r, job, objects, ref, rest[0])
+}
+
+func (s *APIServer) listVersions(w http.ResponseWriter, r *http.Request, objects storage.Storage, ref outputRef) {
+	ctx := r.Context()
+	versions, err := objects.ListVersions(ctx, ref.key)
+	if errors.Is(err, storage.ErrUnsupported) {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, "list versions: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	out := make([]outputVersion, 0, len(versions))
+	for _, v := range versions {
+		ov := outputVersion{ObjectVersion: v, Current: v.VersionID == ref.versionID}
+		if !v.DeleteMarker {
+			if ov.URL, err = objects.PresignedGetVersionURL(ctx, ref.key, v.VersionID); err != nil {
+				http.Error(w, "presign: "+err.Error(), http.StatusBadGateway)
+				return
+			}
+		}
+		out = append(out, ov)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":      ref.key,
+		"versions": out,
+	})
+}
+
+func (s *APIServer) restoreVersion(w http.ResponseWriter, r *http.Request, job *store.Job, objects storage.Storage, ref outputRef, versionID string) {
+	ctx := r.Context()
+	switch job.Status {
+	case "scheduled", "queued", "processing":
+		// the worker would overwrite the restored output
+		http.Error(w, "job is "+job.Status, http.StatusConflict)
+		return
+	}
+	info, err := objects.RestoreVersion(ctx, ref.key, versionID)
+	switch {
+	case errors.Is(err, storage.ErrUnsupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "restore: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if ref.output == nil {
+		err = s.store.UpdateJobStorage(ctx, job.ID, ref.bucket, ref.key, info.VersionID, info.SHA256, info.Endpoint)
+	} else {
+		o := *ref.output
+		o.S3Version, o.SHA256, o.Endpoint = nil, info.SHA256, info.Endpoint
+		if info.VersionID != "" {
+			o.S3Version = &info.VersionID
+		}
+		err = s.store.AddJobOutput(ctx, o)
+	}
+	if err != nil {
+		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("job %s: restored version %s of %s as %s", job.ID, versionID, ref.key, info.VersionID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job_id":        job.ID,
+		"key":           ref.key,
+		"restored_from": versionID,
+		"s3_version_id": info.VersionID,
+		"sha256":        info.SHA256,
+		"previous":      ref.versionID,
+		"output":        ref.name,
+	})
+}
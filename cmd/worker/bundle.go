@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/audio"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/bundle"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// expandBundle unpacks an archive job and creates one child job per file, with the
+// bundle's options. Children are uploaded and published like regular submissions.
+// A bundle unpacked again, requeued after a crash or retried, keeps the children
+// it has: only files without a child get one, and a child left queued without
+// its upload is uploaded and published now.
+func (w *Worker) expandBundle(ctx context.Context, parentID uuid.UUID, jm queue.JobMsg, jobDir string) (int, error) {
+	extractDir := filepath.Join(jobDir, "bundle")
+	if err := os.MkdirAll(extractDir, 0o755); err != nil {
+		return 0, err
+	}
+	files, err := bundle.Extract(jm.InputPath, extractDir, bundle.DefaultLimits)
+	if err != nil {
+		return 0, fmt.Errorf("unpack bundle: %w", err)
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("bundle contains no files")
+	}
+	// the children make up a batch with the bundle's id, kept when unpacked again
+	if _, err := w.store.CreateBatch(ctx, store.NewBatch{ID: parentID, Kind: store.BatchBundle, Tenant: jm.Tenant}); err != nil {
+		return 0, fmt.Errorf("create batch: %w", err)
+	}
+	// the archive unpacks to the same unique names every time
+	children, err := w.store.ChildJobs(ctx, parentID)
+	if err != nil {
+		return 0, fmt.Errorf("list children: %w", err)
+	}
+	existing := make(map[string]store.ChildJob, len(children))
+	for _, c := range children {
+		existing[c.InputPath] = c
+	}
+
+	for i, f := range files {
+		name := filepath.Base(f)
+		child := jm
+		child.Kind = ""
+		child.ParentID = parentID.String()
+		child.InputPath = name
+		child.OutputPath = name + "_processed." + audio.OutputExt(child.OutputFormat)
+		child.ProcessAfter = nil
+
+		c, ok := existing[name]
+		if ok && (c.OriginalKey != nil || c.Status != "queued") {
+			continue
+		}
+		childID := c.ID
+		if !ok {
+			childID, err = w.store.CreateJob(ctx, store.NewJob{
+				InputPath:      child.InputPath,
+				OutputPath:     child.OutputPath,
+				Priority:       child.Priority,
+				OptionsHash:    child.OptionsHash(),
+				ParentID:       &parentID,
+				BatchID:        &parentID,
+				Tenant:         child.Tenant,
+				RetentionClass: child.RetentionClass,
+				CallerRef:      child.CallerRef,
+				Labels:         child.Labels,
+			})
+			if err != nil {
+				return i, fmt.Errorf("create child job for %s: %w", name, err)
+			}
+		}
+		child.ID = childID.String()
+		objects := w.objects.For(child.Tenant)
+		inputKey := objects.Key(storage.OriginalKey(child.ID, name))
+		info, err := objects.UploadFile(storage.WithTags(ctx, child.ObjectTags(0)), f, inputKey, "")
+		if err != nil {
+			w.markFailed(ctx, childID, "input upload failed: "+err.Error())
+			return i, fmt.Errorf("upload %s: %w", name, err)
+		}
+		if err := w.store.SetOriginal(ctx, childID, objects.Bucket(), inputKey, info.SHA256, info.Endpoint); err != nil {
+			log.Printf("[bundle %s] store original key of child %s: %v", parentID, childID, err)
+		}
+		child.InputBucket = objects.Bucket()
+		child.InputKey = inputKey
+		child.InputSHA256 = info.SHA256
+		b, _ := json.Marshal(child)
+		if err := w.store.SetPayload(ctx, childID, b); err != nil {
+			log.Printf("[bundle %s] store payload of child %s: %v", parentID, childID, err)
+		}
+		if err := w.nc.Publish(child.Subject(), b); err != nil {
+			// the payload is stored, the child can be republished later
+			log.Printf("[bundle %s] publish child %s: %v", parentID, childID, err)
+		}
+	}
+	return len(files), nil
+}
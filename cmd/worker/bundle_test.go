@@ -0,0 +1,81 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store/storetest"
+)
+
+// testZip writes a zip archive of files (name to content) and returns its path
+func testZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "calls.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	return path
+}
+
+func TestExpandBundleAgain(t *testing.T) {
+	ctx := context.Background()
+	st := storetest.NewFake()
+	objects, err := storage.NewRouter(storage.Config{Backend: storage.BackendLocal, Local: storage.LocalConfig{Root: t.TempDir()}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// no NATS: publishing fails and is logged, the payloads are stored
+	w := &Worker{store: st, objects: objects}
+	parentID, err := st.CreateJob(ctx, store.NewJob{Kind: queue.KindBundle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jm := queue.JobMsg{Kind: queue.KindBundle, InputPath: testZip(t, map[string]string{"a.wav": "RIFF a", "b.wav": "RIFF b"})}
+
+	children := func() []store.ChildJob {
+		t.Helper()
+		c, err := st.ChildJobs(ctx, parentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	for i := range 2 {
+		if n, err := w.expandBundle(ctx, parentID, jm, t.TempDir()); err != nil || n != 2 {
+			t.Fatalf("expand %d: %d files, %v", i, n, err)
+		}
+		if c := children(); len(c) != 2 {
+			t.Fatalf("expand %d: %d children, want 2", i, len(c))
+		}
+	}
+
+	// a child created before a crash, without its upload, is completed
+	crashed := children()[0]
+	st.Edit(crashed.ID, func(j *store.Job) { j.OriginalKey = nil })
+	if _, err := w.expandBundle(ctx, parentID, jm, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	c := children()
+	if len(c) != 2 || c[0].ID != crashed.ID || c[0].OriginalKey == nil {
+		t.Errorf("children after the crash %+v, want %s uploaded again", c, crashed.ID)
+	}
+}
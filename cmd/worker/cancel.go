@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+)
+
+// errJobCancelled is the cancel cause of jobs stopped by an operator
+var errJobCancelled = errors.New("job cancelled")
+
+// inflight maps running job IDs to the cancel func of their context
+type inflight struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelCauseFunc
+}
+
+func (f *inflight) track(id string, cancel context.CancelCauseFunc) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cancels == nil {
+		f.cancels = map[string]context.CancelCauseFunc{}
+	}
+	f.cancels[id] = cancel
+}
+
+func (f *inflight) untrack(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.cancels, id)
+}
+
+// cancel stops a running job; cancelling its context kills the ffmpeg/python
+// process groups it spawned. It reports whether the job was running here.
+func (f *inflight) cancel(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.cancels[id]
+	if ok {
+		c(errJobCancelled)
+	}
+	return ok
+}
+
+// markFailed records a job failure, or a cancellation when the job context was
+// cancelled by an operator. It uses its own context since ctx may already be done.
+func (w *Worker) markFailed(ctx context.Context, id uuid.UUID, msg string) {
+	dbCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if errors.Is(context.Cause(ctx), errJobCancelled) {
+		if _, err := w.store.CancelJob(dbCtx, id); err != nil {
+			log.Printf("[worker %s] mark job %s cancelled: %v", w.ID, id, err)
+		}
+		return
+	}
+	if err := w.store.SetFailed(dbCtx, id, msg); err != nil {
+		log.Printf("[worker %s] mark job %s failed: %v", w.ID, id, err)
+	}
+}
+
+// retryOrFail schedules another attempt of a job that failed for a transient
+// reason, a storage outage or timeout, while it has attempts left; otherwise it
+// marks the job failed like markFailed
+func (w *Worker) retryOrFail(ctx context.Context, id uuid.UUID, msg string, err error) {
+	if storage.Retryable(err) && context.Cause(ctx) == nil {
+		dbCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		retried, rerr := w.store.RetryJob(dbCtx, id, msg, w.retryBackoff)
+		if rerr != nil {
+			log.Printf("[worker %s] retry job %s: %v", w.ID, id, rerr)
+		}
+		if retried {
+			log.Printf("[worker %s] job %s will be retried: %s", w.ID, id, msg)
+			return
+		}
+	}
+	w.markFailed(ctx, id, msg)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/metrics"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+)
+
+// pauseGate blocks worker goroutines from taking new jobs while paused.
+// Jobs already running are not affected.
+type pauseGate struct {
+	mu      sync.Mutex
+	paused  bool
+	resumed chan struct{} // closed on resume
+}
+
+func (g *pauseGate) Pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		return false
+	}
+	g.paused = true
+	g.resumed = make(chan struct{})
+	return true
+}
+
+func (g *pauseGate) Resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return false
+	}
+	g.paused = false
+	close(g.resumed)
+	return true
+}
+
+func (g *pauseGate) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Wait blocks while paused; it returns false if ctx is done first
+func (g *pauseGate) Wait(ctx context.Context) bool {
+	g.mu.Lock()
+	if !g.paused {
+		g.mu.Unlock()
+		return true
+	}
+	ch := g.resumed
+	g.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-ch:
+		return true
+	}
+}
+
+// subscribeControl applies operator commands published on audio.control
+func (w *Worker) subscribeControl(ctx context.Context, nc *nats.Conn) error {
+	_, err := nc.Subscribe(queue.ControlSubject, func(msg *nats.Msg) {
+		var cm queue.ControlMsg
+		if err := json.Unmarshal(msg.Data, &cm); err != nil {
+			log.Printf("[control] invalid msg: %v", err)
+			return
+		}
+		if cm.WorkerID != "" && cm.WorkerID != w.ID {
+			return
+		}
+		w.handleControl(ctx, cm)
+	})
+	return err
+}
+
+func (w *Worker) handleControl(ctx context.Context, cm queue.ControlMsg) {
+	switch cm.Action {
+	case queue.ActionPause:
+		if w.gate.Pause() {
+			metrics.WorkerPaused.Set(1)
+			w.setRegistryStatus(ctx, "paused")
+			log.Printf("[control] paused: not taking new jobs (%d in flight)", w.active.Load())
+		}
+	case queue.ActionResume:
+		if w.gate.Resume() {
+			metrics.WorkerPaused.Set(0)
+			w.setRegistryStatus(ctx, "running")
+			log.Printf("[control] resumed")
+		}
+	case queue.ActionCancel:
+		if w.jobs.cancel(cm.JobID) {
+			log.Printf("[control] cancelled in-flight job %s", cm.JobID)
+		}
+	default:
+		log.Printf("[control] unknown action %q", cm.Action)
+	}
+}
+
+func (w *Worker) setRegistryStatus(ctx context.Context, status string) {
+	if err := w.store.SetWorkerStatus(ctx, w.ID, status); err != nil {
+		log.Printf("[control] set worker status %s: %v", status, err)
+	}
+}
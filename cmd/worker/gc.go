@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/metrics"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// runGC periodically reconciles the stored objects with the jobs: objects no
+// job records (orphans) and objects recorded on jobs that are gone (missing)
+// are reported, and orphans older than grace are deleted when del is set
+func runGC(ctx context.Context, st store.Store, objects *storage.Router, grace time.Duration, del bool, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			collectGarbage(ctx, st, objects, grace, del)
+		}
+	}
+}
+
+// gcCounts are the findings of a garbage collection in one bucket
+type gcCounts struct {
+	orphaned, missing, deleted int
+}
+
+func collectGarbage(ctx context.Context, st store.Store, objects *storage.Router, grace time.Duration, del bool) {
+	counts := map[string]*gcCounts{}
+	failed := map[string]bool{}
+	for i, p := range objects.Placements() {
+		bucket := p.Bucket()
+		buckets := []string{bucket}
+		if i == 0 {
+			buckets = append(buckets, "") // the default bucket of jobs recorded without one
+		}
+		if counts[bucket] == nil {
+			counts[bucket] = &gcCounts{}
+		}
+		for _, dir := range storage.KeyDirs {
+			if err := gcPrefix(ctx, st, p, buckets, p.Key(dir), grace, del, counts[bucket]); err != nil {
+				failed[bucket] = true
+				log.Printf("[gc] %s/%s: %v", bucket, p.Key(dir), err)
+			}
+		}
+	}
+	for bucket, c := range counts {
+		if failed[bucket] {
+			continue // partial counts would clear the gauges
+		}
+		metrics.OrphanedObjects.WithLabelValues(bucket).Set(float64(c.orphaned))
+		metrics.MissingObjects.WithLabelValues(bucket).Set(float64(c.missing))
+		log.Printf("[gc] bucket %s: %d orphaned objects (%d deleted), %d missing", bucket, c.orphaned, c.deleted, c.missing)
+	}
+}
+
+// gcPrefix merges the listing of prefix with the keys recorded on jobs, both
+// in byte order: a key only in the listing is an orphan, one only on a job is
+// missing
+func gcPrefix(ctx context.Context, st store.Store, objects storage.Storage, buckets []string, prefix string, grace time.Duration, del bool, c *gcCounts) error {
+	refs, err := st.ObjectRefs(ctx, buckets, prefix)
+	if err != nil {
+		return fmt.Errorf("list job objects: %w", err)
+	}
+	defer refs.Close()
+
+	bucket := objects.Bucket()
+	ref, ok := refs.Next()
+	err = objects.List(ctx, prefix, func(o storage.ObjectInfo) error {
+		for ok && ref.Key < o.Key {
+			c.missing++
+			log.Printf("[gc] missing %s/%s of job %s", bucket, ref.Key, ref.JobID)
+			ref, ok = refs.Next()
+		}
+		if ok && ref.Key == o.Key {
+			for ok && ref.Key == o.Key { // shared by several jobs, e.g. deduplicated originals
+				ref, ok = refs.Next()
+			}
+			return nil
+		}
+		// younger objects may belong to a job still being recorded
+		if time.Since(o.LastModified) < grace {
+			return nil
+		}
+		c.orphaned++
+		if !del {
+			log.Printf("[gc] orphaned %s/%s (%d bytes, %s)", bucket, o.Key, o.Size, o.LastModified.Format(time.RFC3339))
+			return nil
+		}
+		if err := objects.Delete(ctx, o.Key); err != nil {
+			return fmt.Errorf("delete %s: %w", o.Key, err)
+		}
+		c.deleted++
+		metrics.GCDeletedObjects.Inc()
+		log.Printf("[gc] deleted orphaned %s/%s (%d bytes, %s)", bucket, o.Key, o.Size, o.LastModified.Format(time.RFC3339))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for ; ok; ref, ok = refs.Next() {
+		c.missing++
+		log.Printf("[gc] missing %s/%s of job %s", bucket, ref.Key, ref.JobID)
+	}
+	return refs.Err()
+}
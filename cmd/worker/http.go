@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// version is set at build time: go build -ldflags "-X main.version=1.2.3" ./cmd/worker
+var version = "dev"
+
+// serveHTTP exposes /metrics, /healthz, /info and /models for scraping and monitoring the worker
+func (w *Worker) serveHTTP(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/info", w.infoHandler)
+	mux.HandleFunc("/models", w.modelsHandler)
+
+	log.Printf("worker http listening on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("worker http: %v", err)
+	}
+}
+
+func (w *Worker) infoHandler(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(map[string]interface{}{
+		"worker_id":   w.ID,
+		"version":     version,
+		"started_at":  w.startedAt,
+		"uptime_sec":  int64(time.Since(w.startedAt).Seconds()),
+		"active_jobs": w.active.Load(),
+		"paused":      w.gate.Paused(),
+		"goroutines":  runtime.NumGoroutine(),
+	})
+}
+
+// modelsHandler lists the RNNoise model catalog with install and checksum state
+func (w *Worker) modelsHandler(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(map[string]interface{}{
+		"dir":    w.models.Dir,
+		"models": w.models.List(),
+	})
+}
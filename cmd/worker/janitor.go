@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// runJanitor periodically requeues jobs whose worker stopped heartbeating
+// (crash, OOM kill, lost host) and republishes them to the job subject.
+func runJanitor(ctx context.Context, st store.Store, nc *nats.Conn, every, staleAfter time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			requeueOrphans(ctx, st, nc, staleAfter)
+		}
+	}
+}
+
+func requeueOrphans(ctx context.Context, st store.Store, nc *nats.Conn, staleAfter time.Duration) {
+	jobs, err := st.RequeueOrphaned(ctx, staleAfter)
+	if err != nil {
+		log.Printf("[janitor] requeue orphaned: %v", err)
+		return
+	}
+	for _, j := range jobs {
+		if err := publishPayload(nc, j.Payload); err != nil {
+			log.Printf("[janitor] republish job %s: %v", j.ID, err)
+			continue
+		}
+		log.Printf("[janitor] requeued orphaned job %s (last worker=%s)", j.ID, j.WorkerID)
+	}
+}
+
+// runScheduler periodically publishes scheduled jobs whose process_after has passed
+func runScheduler(ctx context.Context, st store.Store, nc *nats.Conn, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			jobs, err := st.ReleaseDueJobs(ctx, 500)
+			if err != nil {
+				log.Printf("[scheduler] release due jobs: %v", err)
+				continue
+			}
+			for _, j := range jobs {
+				if err := publishPayload(nc, j.Payload); err != nil {
+					log.Printf("[scheduler] publish job %s: %v", j.ID, err)
+					continue
+				}
+				log.Printf("[scheduler] released scheduled job %s", j.ID)
+			}
+		}
+	}
+}
+
+// publishPayload publishes a stored job message to the subject of its method and priority
+func publishPayload(nc *nats.Conn, payload []byte) error {
+	if len(payload) == 0 {
+		return errors.New("job has no stored payload")
+	}
+	var jm queue.JobMsg
+	if err := json.Unmarshal(payload, &jm); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+	return nc.Publish(jm.Subject(), payload)
+}
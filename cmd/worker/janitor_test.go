@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store/storetest"
+)
+
+func TestRequeueOrphans(t *testing.T) {
+	ctx := context.Background()
+	st := storetest.NewFake()
+	claim := func(maxAttempts int, heartbeat time.Duration) uuid.UUID {
+		id, err := st.CreateJob(ctx, store.NewJob{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := st.ClaimJob(ctx, id, "worker-1"); err != nil || !ok {
+			t.Fatalf("claim: %v %v", ok, err)
+		}
+		st.Edit(id, func(j *store.Job) {
+			beat := time.Now().Add(-heartbeat)
+			j.MaxAttempts, j.HeartbeatAt = maxAttempts, &beat
+		})
+		return id
+	}
+	jobs := map[uuid.UUID]string{
+		claim(3, time.Minute):    "queued",     // attempts left
+		claim(1, time.Minute):    "failed",     // used up its attempts
+		claim(3, 10*time.Second): "processing", // heartbeating
+	}
+
+	// no payloads stored, so nothing is published and no NATS is needed
+	requeueOrphans(ctx, st, nil, 30*time.Second)
+
+	for id, want := range jobs {
+		j, err := st.GetJob(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j.Status != want {
+			t.Errorf("job %s: %s, want %s", id, j.Status, want)
+		}
+	}
+}
+
+func TestRunScheduler(t *testing.T) {
+	ctx := context.Background()
+	st := storetest.NewFake()
+	schedule := func(after time.Duration) uuid.UUID {
+		at := time.Now().Add(time.Hour)
+		id, err := st.CreateJob(ctx, store.NewJob{ProcessAfter: &at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		due := time.Now().Add(after)
+		st.Edit(id, func(j *store.Job) { j.ProcessAfter = &due })
+		return id
+	}
+	jobs := map[uuid.UUID]string{
+		schedule(-time.Minute): "queued",
+		schedule(time.Hour):    "scheduled",
+	}
+
+	// no payloads stored, so nothing is published and no NATS is needed
+	runCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	runScheduler(runCtx, st, nil, 10*time.Millisecond)
+
+	for id, want := range jobs {
+		j, err := st.GetJob(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j.Status != want {
+			t.Errorf("job %s: %s, want %s", id, j.Status, want)
+		}
+	}
+}
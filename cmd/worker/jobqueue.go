@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+)
+
+// jobQueue buffers received jobs in one lane per priority so that
+// urgent jobs are picked before bulk ones regardless of arrival order.
+type jobQueue struct {
+	lanes []chan queue.JobMsg // indexed by queue.Rank
+}
+
+func newJobQueue(size int) *jobQueue {
+	q := &jobQueue{lanes: make([]chan queue.JobMsg, len(queue.Priorities))}
+	for i := range q.lanes {
+		q.lanes[i] = make(chan queue.JobMsg, size)
+	}
+	return q
+}
+
+func (q *jobQueue) push(jm queue.JobMsg) {
+	q.lanes[queue.Rank(jm.Priority)] <- jm
+}
+
+// pop returns the most urgent pending job, blocking until one arrives or ctx is done
+func (q *jobQueue) pop(ctx context.Context) (queue.JobMsg, bool) {
+	for _, lane := range q.lanes {
+		select {
+		case jm := <-lane:
+			return jm, true
+		default:
+		}
+	}
+	// nothing pending: wait on ctx and every lane, case i+1 being lane i
+	cases := make([]reflect.SelectCase, 0, len(q.lanes)+1)
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+	for _, lane := range q.lanes {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(lane)})
+	}
+	chosen, v, _ := reflect.Select(cases)
+	if chosen == 0 {
+		return queue.JobMsg{}, false
+	}
+	return v.Interface().(queue.JobMsg), true
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+)
+
+func TestJobQueuePriority(t *testing.T) {
+	q := newJobQueue(10)
+	for _, jm := range []queue.JobMsg{
+		{ID: "batch", Priority: queue.PriorityBatch},
+		{ID: "normal", Priority: queue.PriorityNormal},
+		{ID: "unknown", Priority: "later"},
+		{ID: "high", Priority: queue.PriorityHigh},
+		{ID: "realtime", Priority: queue.PriorityRealtime},
+		{ID: "high 2", Priority: queue.PriorityHigh},
+	} {
+		q.push(jm)
+	}
+	for _, want := range []string{"realtime", "high", "high 2", "normal", "unknown", "batch"} {
+		jm, ok := q.pop(context.Background())
+		if !ok || jm.ID != want {
+			t.Fatalf("popped %q (%v), want %q", jm.ID, ok, want)
+		}
+	}
+}
+
+func TestJobQueuePopWaits(t *testing.T) {
+	for _, p := range queue.Priorities {
+		q := newJobQueue(1)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			q.push(queue.JobMsg{ID: p, Priority: p})
+		}()
+		if jm, ok := q.pop(context.Background()); !ok || jm.ID != p {
+			t.Errorf("popped %q (%v), want %q", jm.ID, ok, p)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := newJobQueue(1).pop(ctx); ok {
+		t.Error("popped from an empty queue after ctx was done")
+	}
+}
+
+func TestNextJobHeldWhilePaused(t *testing.T) {
+	w := &Worker{}
+	q := newJobQueue(1)
+	got := make(chan queue.JobMsg, 1)
+	go func() {
+		jm, _ := w.nextJob(context.Background(), q)
+		got <- jm
+	}()
+	time.Sleep(10 * time.Millisecond) // nextJob waits in pop
+	w.gate.Pause()
+	q.push(queue.JobMsg{ID: "held"})
+	q.push(queue.JobMsg{ID: "queued"}) // fills the lane
+
+	select {
+	case jm := <-got:
+		t.Fatalf("got job %q while paused", jm.ID)
+	case <-time.After(20 * time.Millisecond):
+	}
+	w.gate.Resume()
+	select {
+	case jm := <-got:
+		if jm.ID != "held" {
+			t.Errorf("got job %q, want held", jm.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("held job not handed out after resume")
+	}
+	if jm, ok := q.pop(context.Background()); !ok || jm.ID != "queued" {
+		t.Errorf("popped %q (%v), want queued", jm.ID, ok)
+	}
+}
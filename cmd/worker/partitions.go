@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/metrics"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// runPartitioner keeps the monthly partitions of the jobs table: the partitions
+// of the current month and ahead months after it are created, and with keep set
+// the partitions of months ended more than keep months ago are dropped once the
+// purger handled all their jobs. The first pass runs right away, so a worker
+// started late in a month still creates the next one. Until migration 040
+// partitioned the table there is nothing to maintain and passes are skipped.
+func runPartitioner(ctx context.Context, st store.Store, ahead, keep int, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	var waiting bool
+	for {
+		partitioned := maintainPartitions(ctx, st, ahead, keep)
+		if !partitioned && !waiting {
+			log.Printf("[partitions] audio_jobs is not partitioned, waiting for migration 040")
+		}
+		waiting = !partitioned
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// maintainPartitions runs one pass; false when audio_jobs is not partitioned
+func maintainPartitions(ctx context.Context, st store.Store, ahead, keep int) bool {
+	partitioned, err := st.JobsPartitioned(ctx)
+	if err != nil {
+		log.Printf("[partitions] check: %v", err)
+		return true
+	}
+	if !partitioned {
+		return false
+	}
+
+	now := time.Now().UTC()
+	created, err := st.CreatePartitions(ctx, now, ahead)
+	if err != nil {
+		log.Printf("[partitions] create: %v", err)
+	}
+	for _, name := range created {
+		log.Printf("[partitions] created %s", name)
+	}
+
+	if keep > 0 {
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		dropped, kept, err := st.DropPartitions(ctx, month.AddDate(0, -keep, 0))
+		if err != nil {
+			log.Printf("[partitions] drop: %v", err)
+		}
+		for _, name := range dropped {
+			metrics.JobPartitionsDropped.Inc()
+			log.Printf("[partitions] dropped %s", name)
+		}
+		for _, name := range kept {
+			log.Printf("[partitions] kept expired %s: it has jobs whose objects were not purged", name)
+		}
+	}
+
+	parts, err := st.Partitions(ctx)
+	if err != nil {
+		log.Printf("[partitions] list: %v", err)
+		return true
+	}
+	var future int
+	for _, p := range parts {
+		if p.Month.After(now) {
+			future++
+		}
+	}
+	metrics.JobPartitions.WithLabelValues("existing").Set(float64(len(parts)))
+	metrics.JobPartitions.WithLabelValues("ahead").Set(float64(future))
+	return true
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store/storetest"
+)
+
+func TestMaintainPartitions(t *testing.T) {
+	ctx := context.Background()
+	st := storetest.NewFake()
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	// two expired months: one with every job purged, one with a job left
+	purged, kept := month.AddDate(0, -6, 0), month.AddDate(0, -5, 0)
+	for _, m := range []time.Time{purged, kept} {
+		if _, err := st.CreatePartitions(ctx, m, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		month  time.Time
+		purged bool
+	}{{purged, true}, {kept, true}, {kept, false}} {
+		id, err := st.CreateJob(ctx, store.NewJob{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		st.Edit(id, func(j *store.Job) {
+			j.CreatedAt = tc.month.Add(time.Hour)
+			if tc.purged {
+				j.PurgedAt = &now
+			}
+		})
+	}
+
+	maintainPartitions(ctx, st, 2, 3)
+
+	parts, err := st.Partitions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{kept, month, month.AddDate(0, 1, 0), month.AddDate(0, 2, 0)}
+	if len(parts) != len(want) {
+		t.Fatalf("partitions %+v, want the months %v", parts, want)
+	}
+	for i, p := range parts {
+		if !p.Month.Equal(want[i]) {
+			t.Errorf("partition %d is of %v, want %v", i, p.Month, want[i])
+		}
+	}
+	if parts[0].Rows != 2 {
+		t.Errorf("kept partition has %d jobs, want 2", parts[0].Rows)
+	}
+
+	// a second pass creates nothing more
+	maintainPartitions(ctx, st, 2, 3)
+	if again, _ := st.Partitions(ctx); len(again) != len(parts) {
+		t.Errorf("%d partitions after a second pass, want %d", len(again), len(parts))
+	}
+}
+
+func TestMaintainPartitionsUnpartitioned(t *testing.T) {
+	ctx := context.Background()
+	st := storetest.NewFake()
+	st.SetPartitioned(false)
+	if maintainPartitions(ctx, st, 2, 3) {
+		t.Error("pass reported a partitioned table")
+	}
+
+	// migration 040 applied while the worker runs
+	st.SetPartitioned(true)
+	if !maintainPartitions(ctx, st, 2, 3) {
+		t.Error("pass reported an unpartitioned table")
+	}
+	if parts, _ := st.Partitions(ctx); len(parts) != 3 {
+		t.Errorf("%d partitions, want 3", len(parts))
+	}
+}
+
+func TestMaintainPartitionsDrop(t *testing.T) {
+	ctx := context.Background()
+	st := storetest.NewFake()
+	now := time.Now().UTC()
+	old := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -12, 0)
+	if _, err := st.CreatePartitions(ctx, old, 0); err != nil {
+		t.Fatal(err)
+	}
+	id, err := st.CreateJob(ctx, store.NewJob{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AddJobEvent(ctx, id, store.EventFinished, "", "worker-1"); err != nil {
+		t.Fatal(err)
+	}
+	st.Edit(id, func(j *store.Job) { j.CreatedAt, j.PurgedAt = old.Add(time.Hour), &now })
+
+	// without -partition-keep-months every partition stays
+	maintainPartitions(ctx, st, 0, 0)
+	if _, err := st.GetJob(ctx, id); err != nil {
+		t.Fatalf("job of a kept partition: %v", err)
+	}
+
+	maintainPartitions(ctx, st, 0, 6)
+	if _, err := st.GetJob(ctx, id); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("job of a dropped partition: err %v, want pgx.ErrNoRows", err)
+	}
+	if events, _ := st.ListJobEvents(ctx, id); len(events) != 0 {
+		t.Errorf("%d events of a dropped job are left", len(events))
+	}
+	if parts, _ := st.Partitions(ctx); len(parts) != 1 || !parts[0].Month.Equal(old.AddDate(0, 12, 0)) {
+		t.Errorf("partitions %+v, want the current month only", parts)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+)
+
+// pool is a set of worker goroutines with its own subscription and local queue,
+// so slow denoisers (e.g. python noisereduce) cannot starve cheap ffmpeg-only jobs.
+type pool struct {
+	Name        string // denoise method token, or "default"
+	Concurrency int
+}
+
+// parsePools parses "method=n,method=n" into dedicated pools
+func parsePools(spec string) ([]pool, error) {
+	var pools []pool
+	seen := map[string]bool{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, n, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid pool %q (want method=concurrency)", part)
+		}
+		c, err := strconv.Atoi(strings.TrimSpace(n))
+		if err != nil || c < 1 {
+			return nil, fmt.Errorf("invalid concurrency for pool %q", name)
+		}
+		token := queue.MethodToken(name)
+		if token == "default" || seen[token] {
+			return nil, fmt.Errorf("duplicate or reserved pool name %q", name)
+		}
+		seen[token] = true
+		pools = append(pools, pool{Name: token, Concurrency: c})
+	}
+	return pools, nil
+}
+
+// subscription returns the subject the pool listens on and a filter for received jobs.
+// The default pool listens on everything and skips methods that have a dedicated pool.
+func (p pool) subscription(dedicated []pool) (string, func(queue.JobMsg) bool) {
+	if p.Name != "default" {
+		return queue.MethodSubject(p.Name), nil
+	}
+	skip := map[string]bool{}
+	for _, d := range dedicated {
+		skip[d.Name] = true
+	}
+	return queue.SubjectPrefix + ".>", func(jm queue.JobMsg) bool {
+		return !skip[queue.MethodToken(jm.DenoiseMethod)]
+	}
+}
+
+// methods returns the denoise methods the pool claims from the database with
+// -poll-db: its own, or for the default pool every one except the dedicated ones
+func (p pool) methods(dedicated []pool) (methods, exclude []string) {
+	if p.Name != "default" {
+		return []string{p.Name}, nil
+	}
+	for _, d := range dedicated {
+		exclude = append(exclude, d.Name)
+	}
+	return nil, exclude
+}
+
+// subscribeJobs pushes jobs received on subject into jobs, dropping the ones accept rejects
+func subscribeJobs(nc *nats.Conn, subject string, jobs *jobQueue, accept func(queue.JobMsg) bool) error {
+	_, err := nc.Subscribe(subject, func(msg *nats.Msg) {
+		var jm queue.JobMsg
+		if err := json.Unmarshal(msg.Data, &jm); err != nil {
+			log.Printf("invalid job msg: %v", err)
+			return
+		}
+		if accept != nil && !accept(jm) {
+			return
+		}
+		log.Printf("enqueued job %s (denoiser=%s priority=%s)", jm.ID, jm.DenoiseMethod, jm.Priority)
+		jobs.push(jm)
+	})
+	return err
+}
@@ -0,0 +1,590 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/audio"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/cleanup"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/metrics"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// jobRun is the state of a job carried from one step of processSingleJob to
+// the next. The steps that can end the job mark it failed, rejected or retried
+// themselves and return false.
+type jobRun struct {
+	workerID int
+	id       uuid.UUID
+	jm       queue.JobMsg
+	objects  storage.Placement
+	scratch  string
+
+	pf       *audio.PreflightInfo
+	base     audio.PipelineConfig
+	opts     audio.ProcessOptions
+	analysis map[string]interface{}
+
+	before, after measures
+	reverbBefore  *audio.ReverbStats
+
+	start       time.Time
+	processedIn time.Duration
+	stats       *audio.Stats
+	// output is where the processed audio is read back from for measurements and
+	// renditions: the local file, or the stored object when it was streamed there
+	output    string
+	objectKey string
+	info      storage.UploadInfo
+	streamed  bool
+
+	transcript    *audio.Transcript
+	redactSources []string
+}
+
+// measures are the SNR and loudness of the input or the output of a job
+type measures struct {
+	quality  *audio.QualityMetrics
+	loudness *audio.Loudness
+}
+
+func (m measures) snr() float64 {
+	if m.quality == nil {
+		return 0
+	}
+	return m.quality.SNR
+}
+
+func (m measures) integrated() float64 {
+	if m.loudness == nil {
+		return 0
+	}
+	return m.loudness.Integrated
+}
+
+func (w *Worker) processSingleJob(ctx context.Context, workerID int, jm queue.JobMsg, claimed bool) {
+	jobUUID, err := uuid.Parse(jm.ID)
+	if err != nil {
+		log.Printf("[w%d] invalid job id: %v", workerID, err)
+		return
+	}
+
+	if !claimed {
+		if claimed, err = w.store.ClaimJob(ctx, jobUUID, w.ID); err != nil {
+			log.Printf("[w%d] db claim error: %v", workerID, err)
+			return
+		}
+	}
+	if !claimed {
+		log.Printf("[w%d] job %s already claimed or not queued, skipping", workerID, jm.ID)
+		return
+	}
+	// everything below runs under the job context so a cancel message can stop it
+	ctx, cancelJob := context.WithCancelCause(audio.WithLimits(ctx, w.childLimits))
+	defer cancelJob(nil)
+	w.jobs.track(jm.ID, cancelJob)
+	defer w.jobs.untrack(jm.ID)
+
+	w.active.Add(1)
+	metrics.ActiveJobs.Inc()
+	defer func() {
+		w.active.Add(-1)
+		metrics.ActiveJobs.Dec()
+	}()
+
+	// keep the claim alive while we work on it
+	hbCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go w.heartbeat(hbCtx, jobUUID)
+
+	// per-job directory holding the downloaded input and the processed output;
+	// removed once the job is over, whatever the outcome
+	jobDir, err := os.MkdirTemp(w.workDir, "job-"+jm.ID+"-")
+	if err != nil {
+		log.Printf("[w%d] job %s work dir: %v", workerID, jm.ID, err)
+		w.markFailed(ctx, jobUUID, "work dir: "+err.Error())
+		return
+	}
+	defer cleanup.RemoveDir(jobDir)
+
+	r := &jobRun{workerID: workerID, id: jobUUID, jm: jm, objects: w.objects.For(jm.Tenant)}
+
+	// intermediate files of processing, apart from input and output when a
+	// scratch dir is set
+	if w.scratchDir != "" {
+		if r.scratch, err = os.MkdirTemp(w.scratchDir, "scratch-"+jm.ID+"-"); err != nil {
+			log.Printf("[w%d] job %s scratch dir: %v", workerID, jm.ID, err)
+			w.markFailed(ctx, jobUUID, "scratch dir: "+err.Error())
+			return
+		}
+		defer cleanup.RemoveDir(r.scratch)
+	}
+
+	if !w.fetchInput(ctx, r, jobDir) {
+		return
+	}
+
+	// archives are unpacked into child jobs instead of being processed
+	if r.jm.Kind == queue.KindBundle {
+		n, err := w.expandBundle(ctx, jobUUID, r.jm, jobDir)
+		if err != nil {
+			log.Printf("[w%d] bundle %s failed after %d children: %v", workerID, jm.ID, n, err)
+			w.markFailed(ctx, jobUUID, err.Error())
+			return
+		}
+		if err := w.store.SetExpanded(ctx, jobUUID); err != nil {
+			log.Printf("[w%d] db set expanded: %v", workerID, err)
+		}
+		log.Printf("[w%d] bundle %s expanded into %d jobs", workerID, jm.ID, n)
+		return
+	}
+
+	if !w.preflight(ctx, r) {
+		return
+	}
+	_ = w.store.UpdateProgress(ctx, jobUUID, 10)
+
+	// every object stored for the job from here on is tagged for lifecycle rules and cost reports
+	ctx = storage.WithTags(ctx, r.jm.ObjectTags(r.pf.DurationSec))
+
+	if !w.resolveOptions(ctx, r) {
+		return
+	}
+	_ = w.store.UpdateProgress(ctx, jobUUID, 20)
+
+	// long recordings get at least real-time length to finish
+	procTimeout := 5 * time.Minute
+	if d := time.Duration(r.pf.DurationSec * float64(time.Second)); d > procTimeout {
+		procTimeout = d
+	}
+	procCtx, cancel := context.WithTimeout(ctx, procTimeout)
+	defer cancel()
+
+	r.analysis = w.inputAnalysis(procCtx, workerID, r.jm, r.opts)
+	if r.jm.AnalyzeOnly {
+		w.finishAnalyzeOnly(ctx, procCtx, workerID, jobUUID, r.jm, r.opts, r.analysis)
+		return
+	}
+	if dc, ok := r.analysis["dc_offset"].(*audio.DCOffsetStats); ok && r.opts.DCRemove {
+		r.opts.DCOffset = dc.Offset
+	}
+
+	r.before = w.measure(ctx, procCtx, r, r.jm.InputPath, "before")
+	if r.opts.Dereverb {
+		if r.reverbBefore, err = audio.EstimateReverb(procCtx, r.jm.InputPath); err != nil {
+			log.Printf("[w%d] warning: reverb estimation failed for job %s: %v", workerID, jm.ID, err)
+		}
+	}
+
+	if !w.denoise(ctx, procCtx, r) || !w.postProcess(ctx, procCtx, r) {
+		return
+	}
+
+	r.after = w.measure(ctx, procCtx, r, r.output, "after")
+	if r.streamed {
+		audio.MeasureOutput(procCtx, r.stats, r.jm.InputPath, r.output, r.opts)
+	}
+	_ = w.store.UpdateProgress(procCtx, jobUUID, 70)
+
+	uploadCtx, cancelUpload := context.WithTimeout(ctx, uploadTimeout(r.jm.OutputPath))
+	defer cancelUpload()
+
+	if !w.uploadOutputs(ctx, procCtx, uploadCtx, r) {
+		return
+	}
+	if r.stats.TrimmedSec > 0 {
+		log.Printf("[w%d] job %s: trimmed %.2fs of leading/trailing silence", workerID, jm.ID, r.stats.TrimmedSec)
+	}
+	if !w.analyzeOutput(ctx, procCtx, uploadCtx, r) {
+		return
+	}
+	w.finishJob(ctx, uploadCtx, r)
+}
+
+// fetchInput downloads the input of a job stored in a bucket into jobDir and
+// points the input and output paths of the job there
+func (w *Worker) fetchInput(ctx context.Context, r *jobRun, jobDir string) bool {
+	if r.jm.InputKey != "" {
+		src, err := w.objects.ForBucket(r.jm.InputBucket)
+		if err != nil {
+			log.Printf("[w%d] job %s: %v", r.workerID, r.jm.ID, err)
+			w.markFailed(ctx, r.id, "input bucket not served by worker: "+r.jm.InputBucket)
+			return false
+		}
+		localInput := filepath.Join(jobDir, filepath.Base(r.jm.InputKey))
+		dlCtx, cancelDl := context.WithTimeout(ctx, w.downloadLimit)
+		err = storage.DownloadVerified(dlCtx, src, r.jm.InputKey, localInput, r.jm.InputSHA256)
+		cancelDl()
+		if errors.Is(err, storage.ErrChecksumMismatch) {
+			metrics.ChecksumMismatches.WithLabelValues("download").Inc()
+		}
+		if err != nil {
+			log.Printf("[w%d] download failed for job %s: %v", r.workerID, r.jm.ID, err)
+			w.retryOrFail(ctx, r.id, "download failed: "+err.Error(), err)
+			return false
+		}
+		r.jm.InputPath = localInput
+	}
+	r.jm.OutputPath = filepath.Join(jobDir, filepath.Base(r.jm.OutputPath))
+	return true
+}
+
+// preflight fails fast on empty, truncated, non-audio or oversized inputs,
+// which are marked rejected with the reason, and stores the media info
+func (w *Worker) preflight(ctx context.Context, r *jobRun) bool {
+	pfCtx, cancelPf := context.WithTimeout(ctx, 30*time.Second)
+	pf, err := audio.Preflight(pfCtx, r.jm.InputPath, w.limits)
+	cancelPf()
+	if err != nil {
+		log.Printf("[w%d] job %s rejected: %v", r.workerID, r.jm.ID, err)
+		var rejected *audio.PreflightError
+		if errors.As(err, &rejected) {
+			metrics.PreflightRejections.WithLabelValues(rejected.Code).Inc()
+			if err := w.store.SetRejected(ctx, r.id, rejected.Code, err.Error()); err != nil {
+				log.Printf("[w%d] mark job %s rejected: %v", r.workerID, r.jm.ID, err)
+			}
+			return false
+		}
+		w.markFailed(ctx, r.id, err.Error())
+		return false
+	}
+	log.Printf("[w%d] job %s preflight ok: %.1fs, %d bytes", r.workerID, r.jm.ID, pf.DurationSec, pf.SizeBytes)
+	if pf.Media != nil {
+		metrics.InputFormats.WithLabelValues(pf.Media.Container, pf.Media.Codec).Inc()
+		if err := w.store.SetMediaInfo(ctx, r.id, pf.Media); err != nil {
+			log.Printf("[w%d] db update media info failed: %v", r.workerID, err)
+		}
+	}
+	r.pf = pf
+	return true
+}
+
+// resolveOptions builds the processing options of a job from its preset, or
+// the worker pipeline, and the job's own settings
+func (w *Worker) resolveOptions(ctx context.Context, r *jobRun) bool {
+	r.base = w.pipeline
+	if r.jm.Preset != "" {
+		preset, ok := w.presets[r.jm.Preset]
+		if !ok {
+			log.Printf("[w%d] job %s: unknown preset %q", r.workerID, r.jm.ID, r.jm.Preset)
+			w.markFailed(ctx, r.id, fmt.Sprintf("unknown preset %q", r.jm.Preset))
+			return false
+		}
+		r.base = preset.PipelineConfig
+	}
+	r.opts = jobOptions(r.base, r.jm)
+	r.opts.Engine = w.engine
+	r.opts.ScratchDir = r.scratch
+	// a model named by the job must be usable, no silent afftdn fallback
+	if r.jm.DenoiseModel != "" {
+		var err error
+		if r.opts.RNNoiseModel, err = w.modelPath(ctx, r.jm.DenoiseModel); err != nil {
+			log.Printf("[w%d] job %s: %v", r.workerID, r.jm.ID, err)
+			w.markFailed(ctx, r.id, err.Error())
+			return false
+		}
+	}
+	return true
+}
+
+// measure estimates the SNR and the loudness of the input or the output of a
+// job; failures leave the measure out
+func (w *Worker) measure(ctx, procCtx context.Context, r *jobRun, path, what string) measures {
+	snrCtx, cancelSnr := context.WithTimeout(ctx, 90*time.Second)
+	defer cancelSnr()
+
+	var m measures
+	var err error
+	if m.quality, err = audio.EstimateQuality(snrCtx, path); err != nil {
+		log.Printf("[w%d] warning: SNR %s estimation failed for job %s: %v", r.workerID, what, r.jm.ID, err)
+	}
+	m.loudness, _ = audio.MeasureLoudness(procCtx, path, r.opts.TargetLUFS)
+	return m
+}
+
+// denoise runs the processing chain of a job: streamed into storage, per
+// channel, in segments or as one file
+func (w *Worker) denoise(ctx, procCtx context.Context, r *jobRun) bool {
+	jm, opts := r.jm, r.opts
+	r.start = time.Now()
+	log.Printf("Processing job %s with denoise method: %s", jm.ID, jm.DenoiseMethod)
+
+	r.output = jm.OutputPath
+	r.objectKey = r.objects.Key(fmt.Sprintf("processed/%s", filepath.Base(jm.OutputPath)))
+	r.streamed = w.streamable(jm, opts, r.pf.DurationSec)
+
+	var err error
+	if r.streamed {
+		r.stats, r.info, err = w.processToStorage(procCtx, jm, opts, r.objectKey)
+		if errors.Is(err, storage.ErrChecksumMismatch) {
+			metrics.ChecksumMismatches.WithLabelValues("upload").Inc()
+		}
+		if err == nil {
+			r.output, err = r.objects.PresignedGetURL(procCtx, r.objectKey)
+		}
+	} else if opts.ChannelMode == audio.ChannelDual || opts.ChannelMode == audio.ChannelSplit {
+		r.stats, err = audio.ProcessDualChannel(procCtx, jm.InputPath, jm.OutputPath, opts)
+	} else if w.segmentOver > 0 && r.pf.DurationSec > w.segmentOver.Seconds() {
+		log.Printf("[w%d] job %s is %.0fs long, processing in segments", r.workerID, jm.ID, r.pf.DurationSec)
+		r.stats, err = audio.ProcessSegmented(procCtx, jm.InputPath, jm.OutputPath, opts, w.segment)
+	} else {
+		r.stats, err = audio.ProcessFile(procCtx, jm.InputPath, jm.OutputPath, opts)
+	}
+	if err != nil {
+		log.Printf("[w%d] job %s failed: %v", r.workerID, jm.ID, err)
+		w.markFailed(ctx, r.id, err.Error())
+		return false
+	}
+	r.processedIn = time.Since(r.start)
+	w.event(ctx, r.id, store.EventDenoiseDone, fmt.Sprintf("%s in %s", jm.DenoiseMethod, r.processedIn.Round(time.Millisecond)))
+	return true
+}
+
+// postProcess transcribes the output, mutes PII in it and renders the tempo
+// rendition; transcription comes before any rendition or measurement so that PII
+// is muted in the output everything else is derived from
+func (w *Worker) postProcess(ctx, procCtx context.Context, r *jobRun) bool {
+	jm := r.jm
+	r.redactSources, _ = audio.ParseRedactSources(jm.Redact) // validated by the API
+	if jm.Transcribe || jm.KeywordList != "" || jm.RedactPII || slices.Contains(r.redactSources, audio.RedactProfanity) {
+		r.transcript = w.transcribe(procCtx, r.workerID, jm)
+	}
+	if jm.RedactPII {
+		if err := w.redactPII(procCtx, jm, r.opts, r.stats, r.transcript, r.analysis); err != nil {
+			log.Printf("[w%d] job %s pii redaction failed: %v", r.workerID, jm.ID, err)
+			w.markFailed(ctx, r.id, "pii redaction failed: "+err.Error())
+			return false
+		}
+	}
+
+	if jm.Tempo > 0 && jm.TempoMode == audio.TempoRendition {
+		path, err := audio.RenderTempo(procCtx, jm.OutputPath, jm.Tempo, r.opts)
+		if err != nil {
+			log.Printf("[w%d] job %s failed: %v", r.workerID, jm.ID, err)
+			w.markFailed(ctx, r.id, err.Error())
+			return false
+		}
+		if r.stats.Extras == nil {
+			r.stats.Extras = map[string]string{}
+		}
+		r.stats.Extras[fmt.Sprintf("tempo_x%g", jm.Tempo)] = path
+	}
+	return true
+}
+
+// uploadOutputs stores the main output, unless it was streamed there, and the
+// additional outputs; the preview, archival copies and spectrogram are optional
+// and their failures only logged
+func (w *Worker) uploadOutputs(ctx, procCtx, uploadCtx context.Context, r *jobRun) bool {
+	jm, objects := r.jm, r.objects
+	contentType := audio.ContentType(r.opts.OutputFormat)
+	if !r.streamed {
+		var err error
+		progressCtx := storage.WithProgress(uploadCtx, w.uploadProgress(uploadCtx, r.id))
+		r.info, err = objects.UploadFile(progressCtx, jm.OutputPath, r.objectKey, contentType)
+		if errors.Is(err, storage.ErrChecksumMismatch) {
+			metrics.ChecksumMismatches.WithLabelValues("upload").Inc()
+		}
+		if err != nil {
+			log.Printf("[w%d] upload failed for job %s: %v", r.workerID, jm.ID, err)
+			w.retryOrFail(ctx, r.id, "upload failed: "+err.Error(), err)
+			return false
+		}
+	}
+
+	if err := w.store.UpdateJobStorage(uploadCtx, r.id, objects.Bucket(), r.objectKey, r.info.VersionID, r.info.SHA256, r.info.Endpoint); err != nil {
+		log.Printf("[w%d] db update storage failed: %v", r.workerID, err)
+	}
+	w.event(uploadCtx, r.id, store.EventUploaded, objects.Bucket()+"/"+r.objectKey)
+
+	// additional outputs (per-party audio, ...) go next to the main output
+	for name, path := range r.stats.Extras {
+		key := fmt.Sprintf("processed/%s", filepath.Base(path))
+		if err := w.uploadOutput(uploadCtx, jm, r.id, name, path, key, contentType); err != nil {
+			log.Printf("[w%d] upload of %s output failed for job %s: %v", r.workerID, name, jm.ID, err)
+			w.retryOrFail(ctx, r.id, fmt.Sprintf("upload of %s output failed: %v", name, err), err)
+			return false
+		}
+	}
+
+	// short preview for instant listening in the dashboard; optional like the spectrogram
+	if w.preview.LengthSec > 0 {
+		clip := audio.PreviewPath(jm.OutputPath, w.preview)
+		if err := audio.RenderPreview(procCtx, r.output, clip, w.preview); err != nil {
+			log.Printf("[w%d] warning: preview failed for job %s: %v", r.workerID, jm.ID, err)
+		} else if err := w.uploadOutput(uploadCtx, jm, r.id, "preview", clip, "previews/"+filepath.Base(clip), ""); err != nil {
+			log.Printf("[w%d] warning: preview upload failed for job %s: %v", r.workerID, jm.ID, err)
+		}
+	}
+
+	// lossless copies for long-term keeping, e.g. when the delivery format is
+	// WAV or lossy; optional like the preview
+	for _, src := range archivalSources(jm, r.output, r.opts.OutputFormat) {
+		flac := audio.ArchivalPath(jm.OutputPath, src.name)
+		if err := audio.RenderArchival(procCtx, src.path, flac); err != nil {
+			log.Printf("[w%d] warning: %s failed for job %s: %v", r.workerID, src.name, jm.ID, err)
+		} else if err := w.uploadOutput(uploadCtx, jm, r.id, src.name, flac, "archive/"+jm.ID+"_"+src.name+".flac", ""); err != nil {
+			log.Printf("[w%d] warning: %s upload failed for job %s: %v", r.workerID, src.name, jm.ID, err)
+		}
+	}
+
+	// spectrogram for visual QA; a failed render doesn't fail the job
+	if jm.Spectrogram {
+		png := audio.SpectrogramPath(jm.OutputPath)
+		if err := audio.RenderSpectrogram(procCtx, r.output, png); err != nil {
+			log.Printf("[w%d] warning: spectrogram failed for job %s: %v", r.workerID, jm.ID, err)
+		} else if err := w.uploadOutput(uploadCtx, jm, r.id, "spectrogram", png, "spectrograms/"+filepath.Base(png), "image/png"); err != nil {
+			log.Printf("[w%d] warning: spectrogram upload failed for job %s: %v", r.workerID, jm.ID, err)
+		}
+	}
+	return true
+}
+
+// analyzeOutput fills the analysis of a job with the measurements of its
+// output, see /jobs/{id}/report, and publishes the transcript and redactions
+func (w *Worker) analyzeOutput(ctx, procCtx, uploadCtx context.Context, r *jobRun) bool {
+	jm, opts, analysis := r.jm, r.opts, r.analysis
+	if opts.Dereverb {
+		reverbAfter, err := audio.EstimateReverb(procCtx, r.output)
+		if err != nil {
+			log.Printf("[w%d] warning: reverb estimation failed for job %s output: %v", r.workerID, jm.ID, err)
+		}
+		analysis["reverb"] = map[string]*audio.ReverbStats{"before": r.reverbBefore, "after": reverbAfter}
+	}
+	if jm.QualityScores {
+		if !audio.SameTimeline(opts) {
+			log.Printf("[w%d] job %s: quality scores skipped, the output timeline differs from the input", r.workerID, jm.ID)
+		} else if scores, err := audio.ScoreObjective(procCtx, jm.InputPath, jm.OutputPath, r.scratch); err != nil {
+			log.Printf("[w%d] warning: quality scoring failed for job %s: %v", r.workerID, jm.ID, err)
+		} else {
+			analysis["objective"] = scores
+			if scores.PESQ != nil {
+				metrics.PESQScore.WithLabelValues(jm.DenoiseMethod).Observe(*scores.PESQ)
+			}
+			if scores.STOI != nil {
+				metrics.STOIScore.WithLabelValues(jm.DenoiseMethod).Observe(*scores.STOI)
+			}
+		}
+	}
+	if w.mos != nil {
+		if score, err := w.mos.Score(procCtx, jm.OutputPath); err != nil {
+			log.Printf("[w%d] warning: MOS estimation failed for job %s: %v", r.workerID, jm.ID, err)
+		} else {
+			analysis["mos"] = score
+			metrics.MOSScore.WithLabelValues(jm.DenoiseMethod).Observe(score.MOS)
+			if err := w.store.SetJobMOS(uploadCtx, r.id, score.MOS); err != nil {
+				log.Printf("[w%d] db update mos failed: %v", r.workerID, err)
+			}
+		}
+	}
+	if timeline, ok := analysis["timeline"].(*audio.Timeline); ok {
+		var err error
+		if timeline.Output, err = audio.QualityTimeline(procCtx, r.output, timeline.WindowSec); err != nil {
+			log.Printf("[w%d] warning: output quality timeline failed for job %s: %v", r.workerID, jm.ID, err)
+		}
+	}
+	if r.transcript != nil {
+		w.publishTranscript(uploadCtx, r.workerID, r.id, jm, r.transcript, analysis)
+	}
+	if len(r.redactSources) > 0 {
+		// a rendition meant for sharing must not leave anything out, so failures fail the job
+		if err := w.redact(procCtx, uploadCtx, r.id, jm, opts, r.redactSources, r.transcript, analysis); err != nil {
+			log.Printf("[w%d] job %s redaction failed: %v", r.workerID, jm.ID, err)
+			w.markFailed(ctx, r.id, "redaction failed: "+err.Error())
+			return false
+		}
+	}
+	// before/after measurements and the chain behind the output
+	noise := map[string]*float64{"before": &r.stats.NoiseLevel}
+	if after, err := audio.GetNoiseLevel(procCtx, r.output); err != nil {
+		log.Printf("[w%d] warning: output noise level failed for job %s: %v", r.workerID, jm.ID, err)
+	} else {
+		noise["after"] = &after
+	}
+	analysis["noise"] = noise
+	analysis["loudness"] = map[string]*audio.Loudness{"before": r.before.loudness, "after": r.after.loudness}
+	analysis["processing"] = map[string]string{"denoise_method": jm.DenoiseMethod, "preset": jm.Preset, "filter_chain": r.stats.FilterChain}
+	if len(jm.Benchmark) > 0 {
+		w.benchmark(procCtx, uploadCtx, r.workerID, r.id, jm, r.base, opts, r.processedIn, analysis)
+	}
+	if r.before.quality != nil || r.after.quality != nil {
+		// both the VAD based snr and the deprecated snr_peak_rms, for the transition
+		analysis["quality"] = map[string]*audio.QualityMetrics{"before": r.before.quality, "after": r.after.quality}
+	}
+	return true
+}
+
+// finishJob applies the quality gate, stores the results and the sidecar of a
+// processed job and marks it done, done with warnings or failed
+func (w *Worker) finishJob(ctx, uploadCtx context.Context, r *jobRun) {
+	st, jm, stats := w.store, r.jm, r.stats
+	gateAction, gateReasons := w.checkQualityGate(r.workerID, jm, r.opts, r.after.quality, r.after.loudness, r.analysis)
+	if len(r.analysis) > 0 {
+		if err := st.MergeJobAnalysis(uploadCtx, r.id, r.analysis); err != nil {
+			log.Printf("[w%d] db update analysis failed: %v", r.workerID, err)
+		}
+	}
+	if err := st.SetQuality(uploadCtx, r.id, store.Quality{Before: r.before.quality, After: r.after.quality}, r.before.loudness); err != nil {
+		log.Printf("[w%d] db update quality failed: %v", r.workerID, err)
+	}
+
+	if stats.DurationSec > 0 {
+		_ = st.UpdateJobMetadata(uploadCtx, r.id, stats.DurationSec, stats.Loudness, stats.NoiseLevel, jm.DenoiseMethod)
+	} else {
+		_ = st.UpdateJobMetadata(uploadCtx, r.id, 0.0, stats.Loudness, stats.NoiseLevel, jm.DenoiseMethod)
+	}
+
+	status := "done"
+	switch gateAction {
+	case audio.GateFail:
+		status = "failed"
+	case audio.GateWarn:
+		status = "completed_with_warnings"
+	}
+	if err := w.writeSidecar(uploadCtx, jm, r.id, status, r.objectKey, r.info, audio.ContentType(r.opts.OutputFormat), stats, r.analysis); err != nil {
+		log.Printf("[w%d] warning: metadata sidecar failed for job %s: %v", r.workerID, jm.ID, err)
+	}
+
+	presignedURL, err := r.objects.PresignedGetURL(uploadCtx, r.objectKey)
+	if err != nil {
+		log.Printf("[w%d] presign failed: %v", r.workerID, err)
+	}
+
+	var finishErr error
+	switch gateAction {
+	case audio.GateFail:
+		w.markFailed(ctx, r.id, "quality gate: "+gateReasons)
+	case audio.GateWarn:
+		finishErr = st.SetFinishedWithWarnings(uploadCtx, r.id, "quality gate: "+gateReasons)
+	default:
+		_ = st.UpdateProgress(uploadCtx, r.id, 100)
+		finishErr = st.SetFinished(uploadCtx, r.id)
+	}
+	if finishErr != nil {
+		// e.g. cancelled while its output was uploaded: the job keeps that status
+		log.Printf("[w%d] mark job %s done: %v", r.workerID, jm.ID, finishErr)
+	}
+
+	duration := time.Since(r.start)
+	success := gateAction != audio.GateFail && finishErr == nil
+	metrics.ObserveJob(jm.DenoiseMethod, duration, success, r.before.integrated(), r.after.integrated(), r.before.snr(), r.after.snr())
+	if r.before.quality != nil && r.after.quality != nil {
+		metrics.ObservePeakRMS(jm.DenoiseMethod, r.before.quality.SNRPeakRMS, r.after.quality.SNRPeakRMS)
+	}
+	if gateAction == audio.GateFail {
+		return
+	}
+
+	log.Printf("[w%d] job %s done in %s; s3=%s/%s ver=%s presign=%s snr_before=%.2f snr_after=%.2f",
+		r.workerID, jm.ID, duration, r.objects.Bucket(), r.objectKey, r.info.VersionID, presignedURL, r.before.snr(), r.after.snr())
+}
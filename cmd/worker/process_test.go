@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/audio"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/metrics"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store/storetest"
+)
+
+func TestParseFlags(t *testing.T) {
+	c, err := parseFlags(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.heartbeatEvery != 10*time.Second || c.partitionsAhead != 3 || c.diskWatermark != 90 || c.migrate {
+		t.Errorf("defaults = %+v", c)
+	}
+
+	c, err = parseFlags([]string{"-concurrency", "3", "-pools", "arnndn=2", "-poll-db", "5s", "-migrate"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.concurrency != 3 || c.pools != "arnndn=2" || c.pollDB != 5*time.Second || !c.migrate {
+		t.Errorf("overrides = %+v", c)
+	}
+
+	if _, err := parseFlags([]string{"-concurrency", "many"}); err == nil {
+		t.Error("invalid value accepted")
+	}
+}
+
+func TestNewWorker(t *testing.T) {
+	ctx := context.Background()
+	st := storetest.NewFake()
+	c, err := parseFlags([]string{"-scratch-dir", filepath.Join(t.TempDir(), "scratch"), "-work-dir", t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := newWorker(ctx, c, st, nil, nil, &audio.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.engine != audio.EngineFFmpeg || w.disk == nil || w.mos != nil {
+		t.Errorf("worker = %+v", w)
+	}
+	if _, err := os.Stat(c.scratchDir); err != nil {
+		t.Errorf("scratch dir not created: %v", err)
+	}
+
+	c.engine = "gpu"
+	if _, err := newWorker(ctx, c, st, nil, nil, &audio.Config{}); err == nil || !strings.HasPrefix(err.Error(), "engine:") {
+		t.Errorf("unknown engine: err = %v", err)
+	}
+}
+
+// testRun creates a processing job in st and the run of it in w
+func testRun(t *testing.T, w *Worker, jm queue.JobMsg) *jobRun {
+	t.Helper()
+	ctx := context.Background()
+	id, err := w.store.CreateJob(ctx, store.NewJob{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := w.store.ClaimJob(ctx, id, "worker-1"); err != nil || !ok {
+		t.Fatalf("claim: %v %v", ok, err)
+	}
+	jm.ID = id.String()
+	return &jobRun{workerID: 1, id: id, jm: jm, objects: w.objects.For("")}
+}
+
+func getJob(t *testing.T, st store.Store, id uuid.UUID) *store.Job {
+	t.Helper()
+	j, err := st.GetJob(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func TestFetchInput(t *testing.T) {
+	ctx := context.Background()
+	objects, err := storage.NewRouter(storage.Config{Backend: storage.BackendLocal, Local: storage.LocalConfig{Root: t.TempDir()}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Worker{store: storetest.NewFake(), objects: objects, downloadLimit: time.Minute}
+	p := objects.For("")
+
+	data := []byte("RIFF....WAVE")
+	src := filepath.Join(t.TempDir(), "call.wav")
+	if err := os.WriteFile(src, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	key := p.Key("uploads/call.wav")
+	if _, err := p.UploadFile(ctx, src, key, "audio/wav"); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+
+	jobDir := t.TempDir()
+	r := testRun(t, w, queue.JobMsg{InputBucket: p.Bucket(), InputKey: key, InputSHA256: hex.EncodeToString(sum[:]), OutputPath: "/out/call_clean.wav"})
+	if !w.fetchInput(ctx, r, jobDir) {
+		t.Fatal("download failed")
+	}
+	if r.jm.InputPath != filepath.Join(jobDir, "call.wav") || r.jm.OutputPath != filepath.Join(jobDir, "call_clean.wav") {
+		t.Errorf("paths = %s, %s", r.jm.InputPath, r.jm.OutputPath)
+	}
+	if got, err := os.ReadFile(r.jm.InputPath); err != nil || string(got) != string(data) {
+		t.Errorf("input = %q, %v", got, err)
+	}
+
+	// a missing object is not worth another attempt
+	r = testRun(t, w, queue.JobMsg{InputBucket: p.Bucket(), InputKey: p.Key("uploads/gone.wav")})
+	if w.fetchInput(ctx, r, t.TempDir()) {
+		t.Fatal("missing input downloaded")
+	}
+	if j := getJob(t, w.store, r.id); j.Status != "failed" || j.ErrorMsg == nil || !strings.HasPrefix(*j.ErrorMsg, "download failed") {
+		t.Errorf("missing input: status %s, error %v", j.Status, j.ErrorMsg)
+	}
+
+	r = testRun(t, w, queue.JobMsg{InputBucket: "elsewhere", InputKey: key})
+	if w.fetchInput(ctx, r, t.TempDir()) {
+		t.Fatal("input of an unknown bucket downloaded")
+	}
+	if j := getJob(t, w.store, r.id); j.Status != "failed" {
+		t.Errorf("unknown bucket: status %s", j.Status)
+	}
+}
+
+func TestPreflightRejects(t *testing.T) {
+	ctx := context.Background()
+	objects, err := storage.NewRouter(storage.Config{Backend: storage.BackendLocal, Local: storage.LocalConfig{Root: t.TempDir()}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Worker{store: storetest.NewFake(), objects: objects}
+
+	empty := filepath.Join(t.TempDir(), "empty.wav")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := testRun(t, w, queue.JobMsg{InputPath: empty})
+	if w.preflight(ctx, r) {
+		t.Fatal("empty input passed preflight")
+	}
+	j := getJob(t, w.store, r.id)
+	if j.Status != "failed" || j.ErrorCode == nil || *j.ErrorCode != audio.RejectEmpty {
+		t.Errorf("status %s, code %v", j.Status, j.ErrorCode)
+	}
+}
+
+func TestResolveOptions(t *testing.T) {
+	ctx := context.Background()
+	objects, err := storage.NewRouter(storage.Config{Backend: storage.BackendLocal, Local: storage.LocalConfig{Root: t.TempDir()}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Worker{
+		store:    storetest.NewFake(),
+		objects:  objects,
+		engine:   audio.EngineNative,
+		pipeline: audio.PipelineConfig{TargetLUFS: -16},
+		presets:  map[string]audio.Preset{"broadcast": {PipelineConfig: audio.PipelineConfig{TargetLUFS: -23}}},
+	}
+
+	r := testRun(t, w, queue.JobMsg{Preset: "broadcast"})
+	r.scratch = "/scratch"
+	if !w.resolveOptions(ctx, r) {
+		t.Fatal("preset not resolved")
+	}
+	if r.base.TargetLUFS != -23 || r.opts.Engine != audio.EngineNative || r.opts.ScratchDir != "/scratch" {
+		t.Errorf("base %+v, opts %+v", r.base, r.opts)
+	}
+
+	r = testRun(t, w, queue.JobMsg{Preset: "podcast"})
+	if w.resolveOptions(ctx, r) {
+		t.Fatal("unknown preset resolved")
+	}
+	if j := getJob(t, w.store, r.id); j.Status != "failed" || j.ErrorMsg == nil || *j.ErrorMsg != `unknown preset "podcast"` {
+		t.Errorf("status %s, error %v", j.Status, j.ErrorMsg)
+	}
+}
+
+func TestFinishJob(t *testing.T) {
+	ctx := context.Background()
+	objects, err := storage.NewRouter(storage.Config{Backend: storage.BackendLocal, Local: storage.LocalConfig{Root: t.TempDir()}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	processed := func(method, result string) float64 {
+		var m dto.Metric
+		metrics.JobProcessed.WithLabelValues(result, method).Write(&m)
+		return m.GetCounter().GetValue()
+	}
+
+	for _, tc := range []struct {
+		method     string // one per case, to count its metrics apart
+		gate       audio.QualityGate
+		cancelled  bool
+		wantStatus string
+		wantResult string
+	}{
+		{"finish-pass", audio.QualityGate{MinSNR: 10}, false, "done", "success"},
+		{"finish-warn", audio.QualityGate{MinSNR: 30}, false, "completed_with_warnings", "success"},
+		{"finish-gate", audio.QualityGate{MinSNR: 30, Action: audio.GateFail}, false, "failed", "failed"},
+		{"finish-cancelled", audio.QualityGate{MinSNR: 10}, true, "cancelled", "failed"},
+	} {
+		w := &Worker{store: storetest.NewFake(), objects: objects, qualityGate: tc.gate}
+		r := testRun(t, w, queue.JobMsg{DenoiseMethod: tc.method, OutputPath: filepath.Join(t.TempDir(), "out.wav")})
+		r.start, r.stats, r.analysis = time.Now(), &audio.Stats{}, map[string]interface{}{}
+		r.after.quality = &audio.QualityMetrics{SNR: 20}
+		r.objectKey = "processed/" + tc.method + ".wav"
+		if tc.cancelled {
+			w.store.CancelJob(ctx, r.id)
+		}
+
+		w.finishJob(ctx, ctx, r)
+		if j := getJob(t, w.store, r.id); j.Status != tc.wantStatus {
+			t.Errorf("%s: status %s, want %s", tc.method, j.Status, tc.wantStatus)
+		}
+		other := map[string]string{"success": "failed", "failed": "success"}[tc.wantResult]
+		if processed(tc.method, tc.wantResult) != 1 || processed(tc.method, other) != 0 {
+			t.Errorf("%s: observed success %v, failed %v; want one %s", tc.method, processed(tc.method, "success"), processed(tc.method, "failed"), tc.wantResult)
+		}
+	}
+}
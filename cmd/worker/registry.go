@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+// register announces the worker in the workers table
+func (w *Worker) register(ctx context.Context, concurrency int) {
+	host, _ := os.Hostname()
+	if err := w.store.RegisterWorker(ctx, w.ID, host, version, concurrency); err != nil {
+		log.Printf("[registry] register worker %s: %v", w.ID, err)
+	}
+}
+
+// keepRegistered heartbeats the registry entry until ctx is cancelled
+func (w *Worker) keepRegistered(ctx context.Context) {
+	t := time.NewTicker(w.heartbeatEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := w.store.WorkerHeartbeat(ctx, w.ID); err != nil && ctx.Err() == nil {
+				log.Printf("[registry] heartbeat: %v", err)
+			}
+		}
+	}
+}
+
+// deregister marks the worker stopped so it disappears from the alive list right away
+func (w *Worker) deregister() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.store.SetWorkerStatus(ctx, w.ID, "stopped"); err != nil {
+		log.Printf("[registry] deregister: %v", err)
+	}
+}
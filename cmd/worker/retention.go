@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/metrics"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/retention"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// purgeBatch is how many expired jobs a purge pass handles at most
+const purgeBatch = 200
+
+// runPurger periodically deletes the objects of jobs past their retention and
+// marks the jobs purged. Several workers may run it; a job is audited once.
+func runPurger(ctx context.Context, st store.Store, objects *storage.Router, policy retention.Policy, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			purgeExpired(ctx, st, objects, policy)
+		}
+	}
+}
+
+func purgeExpired(ctx context.Context, st store.Store, objects *storage.Router, policy retention.Policy) {
+	jobs, err := st.ExpiredJobs(ctx, policy, purgeBatch)
+	if err != nil {
+		log.Printf("[purger] list expired jobs: %v", err)
+		return
+	}
+	for _, j := range jobs {
+		if err := deleteObjects(ctx, objects, j.Objects); err != nil {
+			// the job stays unpurged and is retried on the next pass
+			metrics.RetentionPurges.WithLabelValues("failed").Inc()
+			log.Printf("[purger] job %s: %v", j.ID, err)
+			continue
+		}
+		reason := j.Reason(policy)
+		purged, err := st.MarkPurged(ctx, j, reason)
+		if err != nil {
+			metrics.RetentionPurges.WithLabelValues("failed").Inc()
+			log.Printf("[purger] mark job %s purged: %v", j.ID, err)
+			continue
+		}
+		if purged {
+			metrics.RetentionPurges.WithLabelValues("purged").Inc()
+			metrics.RetentionPurgedObjects.Add(float64(len(j.Objects)))
+			log.Printf("[purger] purged job %s, %d objects (%s)", j.ID, len(j.Objects), reason)
+		}
+	}
+}
+
+// deleteObjects deletes the stored objects of a job; objects in a bucket this
+// worker doesn't serve are an error, so the job is not marked purged
+func deleteObjects(ctx context.Context, objects *storage.Router, objs []store.StoredObject) error {
+	for _, o := range objs {
+		s, err := objects.ForBucket(o.Bucket)
+		if err != nil {
+			return err
+		}
+		if err := s.Delete(ctx, o.Key); err != nil {
+			return fmt.Errorf("delete %s: %w", o.Key, err)
+		}
+	}
+	return nil
+}
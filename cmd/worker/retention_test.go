@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/retention"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store/storetest"
+)
+
+func TestPurgeExpired(t *testing.T) {
+	ctx := context.Background()
+	objects, err := storage.NewRouter(storage.Config{Backend: storage.BackendLocal, Local: storage.LocalConfig{Root: t.TempDir()}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := objects.For("")
+	st := storetest.NewFake()
+
+	src := filepath.Join(t.TempDir(), "out.wav")
+	if err := os.WriteFile(src, []byte("RIFF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// finished days ago, under retention classes of 30 and 7 days
+	jobs := map[string]struct {
+		class  string
+		days   int
+		expire bool
+	}{
+		"legal": {"legal", 10, false},
+		"short": {"short", 10, true},
+		"new":   {"short", 1, false},
+	}
+	ids := map[string]store.ExpiredJob{}
+	for name, tc := range jobs {
+		id, err := st.CreateJob(ctx, store.NewJob{Tenant: "acme", RetentionClass: tc.class})
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := p.Key("processed/" + name + ".wav")
+		if _, err := p.UploadFile(ctx, src, key, "audio/wav"); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.UpdateJobStorage(ctx, id, p.Bucket(), key, "", "", ""); err != nil {
+			t.Fatal(err)
+		}
+		finished := time.Now().AddDate(0, 0, -tc.days)
+		st.Edit(id, func(j *store.Job) { j.Status, j.FinishedAt = "done", &finished })
+		ids[name] = store.ExpiredJob{ID: id, Objects: []store.StoredObject{{Bucket: p.Bucket(), Key: key}}}
+	}
+
+	policy := retention.Policy{DefaultDays: 365, Classes: map[string]int{"legal": 30, "short": 7}}
+	purgeExpired(ctx, st, objects, policy)
+
+	for name, tc := range jobs {
+		j, err := st.GetJob(ctx, ids[name].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (j.PurgedAt != nil) != tc.expire {
+			t.Errorf("job %s purged at %v, want purged %v", name, j.PurgedAt, tc.expire)
+		}
+		_, err = p.StatObject(ctx, ids[name].Objects[0].Key)
+		if exists := err == nil; exists == tc.expire {
+			t.Errorf("object of job %s exists %v, want %v", name, exists, !tc.expire)
+		}
+	}
+
+	purges, err := st.ForTenant("acme").ListPurges(ctx, time.Now().Add(-time.Minute), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purges) != 1 || purges[0].JobID != ids["short"].ID || !strings.HasPrefix(purges[0].Reason, "retention_class short") {
+		t.Errorf("purge audit %+v, want the object of the short job", purges)
+	}
+
+	// a second pass finds nothing left to purge
+	purgeExpired(ctx, st, objects, policy)
+	if again, _ := st.ListPurges(ctx, time.Time{}, 10); len(again) != 1 {
+		t.Errorf("%d purge records after a second pass, want 1", len(again))
+	}
+}
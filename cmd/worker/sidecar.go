@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/audio"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+)
+
+// sidecarObject locates an object described by a sidecar
+type sidecarObject struct {
+	Bucket      string `json:"bucket,omitempty"`
+	Key         string `json:"key"`
+	VersionID   string `json:"version_id,omitempty"`
+	SHA256      string `json:"sha256,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Endpoint    string `json:"endpoint,omitempty"`
+}
+
+// sidecar is the metadata.json stored next to the output of a job. It repeats
+// what the database knows about the output, so the audio stays self-describing
+// when the database is lost or restored from an older backup.
+type sidecar struct {
+	JobID       string                 `json:"job_id"`
+	Tenant      string                 `json:"tenant,omitempty"`
+	CallerRef   string                 `json:"caller_ref,omitempty"`
+	Labels      map[string]string      `json:"labels,omitempty"`
+	Status      string                 `json:"status"`
+	WrittenAt   time.Time              `json:"written_at"`
+	Software    map[string]string      `json:"software"`
+	Options     queue.JobMsg           `json:"options"`
+	Input       sidecarObject          `json:"input"`
+	Output      sidecarObject          `json:"output"`
+	Outputs     []sidecarObject        `json:"outputs,omitempty"`
+	DurationSec float64                `json:"duration_sec,omitempty"`
+	FilterChain string                 `json:"filter_chain,omitempty"`
+	Loudness    *audio.Loudness        `json:"loudness,omitempty"`
+	NoiseLevel  float64                `json:"noise_level,omitempty"`
+	Analysis    map[string]interface{} `json:"analysis,omitempty"`
+}
+
+// sidecarKey is the key of the sidecar of an output file:
+// processed/<output name without extension>.metadata.json
+func sidecarKey(outputPath string) string {
+	base := filepath.Base(outputPath)
+	return "processed/" + strings.TrimSuffix(base, filepath.Ext(base)) + ".metadata.json"
+}
+
+// writeSidecar uploads the sidecar of a finished job and records it as the
+// output "metadata". It goes last, after the additional outputs it lists.
+func (w *Worker) writeSidecar(ctx context.Context, jm queue.JobMsg, jobUUID uuid.UUID, status, objectKey string, info storage.UploadInfo, contentType string, stats *audio.Stats, analysis map[string]interface{}) error {
+	sc := sidecar{
+		JobID:     jm.ID,
+		Tenant:    jm.Tenant,
+		CallerRef: jm.CallerRef,
+		Labels:    jm.Labels,
+		Status:    status,
+		WrittenAt: time.Now().UTC(),
+		Software: map[string]string{
+			"worker":    version,
+			"worker_id": w.ID,
+			"engine":    w.engine,
+			"ffmpeg":    audio.FFmpegVersion(),
+		},
+		Options:  jm.Options(),
+		Input:    sidecarObject{Bucket: jm.InputBucket, Key: jm.InputKey, SHA256: jm.InputSHA256},
+		Output:   sidecarObject{Bucket: w.objects.For(jm.Tenant).Bucket(), Key: objectKey, VersionID: info.VersionID, SHA256: info.SHA256, ContentType: contentType, Endpoint: info.Endpoint},
+		Analysis: analysis,
+	}
+	if stats != nil {
+		sc.DurationSec, sc.FilterChain, sc.Loudness, sc.NoiseLevel = stats.DurationSec, stats.FilterChain, stats.Loudness, stats.NoiseLevel
+	}
+	outputs, err := w.store.ListJobOutputs(ctx, jobUUID)
+	if err != nil {
+		return err
+	}
+	for _, o := range outputs {
+		if o.Name == "metadata" {
+			continue
+		}
+		so := sidecarObject{Bucket: o.S3Bucket, Key: o.S3Key, SHA256: o.SHA256, ContentType: o.ContentType, Endpoint: o.Endpoint}
+		if o.S3Version != nil {
+			so.VersionID = *o.S3Version
+		}
+		sc.Outputs = append(sc.Outputs, so)
+	}
+
+	b, err := json.MarshalIndent(sc, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(filepath.Dir(jm.OutputPath), jm.ID+"_metadata.json")
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return err
+	}
+	return w.uploadOutput(ctx, jm, jobUUID, "metadata", path, sidecarKey(jm.OutputPath), "application/json")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/metrics"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/retention"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// archiveBatch is how many jobs an archive pass handles at most
+const archiveBatch = 200
+
+// runArchiver periodically moves the objects of jobs finished longer than the
+// tiering age ago to its storage class and marks the jobs archived
+func runArchiver(ctx context.Context, st store.Store, objects *storage.Router, t retention.Tiering, every time.Duration) {
+	tk := time.NewTicker(every)
+	defer tk.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tk.C:
+			archiveOld(ctx, st, objects, t)
+		}
+	}
+}
+
+func archiveOld(ctx context.Context, st store.Store, objects *storage.Router, t retention.Tiering) {
+	jobs, err := st.ArchivableJobs(ctx, t.AfterDays, archiveBatch)
+	if err != nil {
+		log.Printf("[archiver] list jobs: %v", err)
+		return
+	}
+	for _, j := range jobs {
+		if err := setStorageClass(ctx, objects, j.Objects, t.StorageClass); err != nil {
+			metrics.ArchivedJobs.WithLabelValues("failed").Inc()
+			log.Printf("[archiver] job %s: %v", j.ID, err)
+			if errors.Is(err, storage.ErrUnsupported) {
+				return // no use trying the next ones
+			}
+			continue // retried on the next pass
+		}
+		archived, err := st.MarkArchived(ctx, j.ID, t.StorageClass)
+		if err != nil {
+			metrics.ArchivedJobs.WithLabelValues("failed").Inc()
+			log.Printf("[archiver] mark job %s archived: %v", j.ID, err)
+			continue
+		}
+		if archived {
+			metrics.ArchivedJobs.WithLabelValues("archived").Inc()
+			log.Printf("[archiver] archived job %s, %d objects (%s)", j.ID, len(j.Objects), t.StorageClass)
+		}
+	}
+}
+
+// setStorageClass moves the stored objects of a job to class
+func setStorageClass(ctx context.Context, objects *storage.Router, objs []store.StoredObject, class string) error {
+	for _, o := range objs {
+		s, err := objects.ForBucket(o.Bucket)
+		if err != nil {
+			return err
+		}
+		if err := s.SetStorageClass(ctx, o.Key, class); err != nil {
+			return fmt.Errorf("%s: %w", o.Key, err)
+		}
+	}
+	return nil
+}
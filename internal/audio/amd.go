@@ -0,0 +1,82 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Answer classes of DetectAnswer
+const (
+	AnswerHuman   = "human"
+	AnswerMachine = "machine" // voicemail or answering machine
+	AnswerUnknown = "unknown"
+)
+
+const (
+	machineGreetingSec = 3.0 // uninterrupted first utterance of a recorded greeting
+	humanGreetingSec   = 1.5 // "hello?" and the like
+	humanPauseSec      = 0.5 // a person waits for the caller after greeting
+	noAnswerSec        = 5.0 // nobody speaks for this long at the start
+)
+
+// AnswerClass tells a live conversation from a voicemail/answering machine
+type AnswerClass struct {
+	Class       string   `json:"class"`
+	Confidence  float64  `json:"confidence"` // 0.5..1
+	GreetingSec float64  `json:"greeting_sec"`
+	BeepSec     *float64 `json:"beep_sec,omitempty"` // start of the record-after-the-tone beep
+	Reasons     []string `json:"reasons,omitempty"`
+}
+
+// DetectAnswer classifies the recording as human or machine answered from the
+// length of the first utterance, the pause after it and a beep following the
+// greeting. tones are the DetectTones results of the same file.
+func DetectAnswer(ctx context.Context, path string, conf VADConf, tones []LabeledSegment) (*AnswerClass, error) {
+	total, silences, err := detectSilences(ctx, path, "", conf)
+	if err != nil {
+		return nil, err
+	}
+	return classifyAnswer(speechIntervals(total, silences), tones), nil
+}
+
+func classifyAnswer(speech [][2]float64, tones []LabeledSegment) *AnswerClass {
+	ac := &AnswerClass{Class: AnswerUnknown, Confidence: 0.5}
+	if len(speech) == 0 {
+		ac.Reasons = append(ac.Reasons, "no speech")
+		return ac
+	}
+	first := speech[0]
+	ac.GreetingSec = first[1] - first[0]
+	if first[0] > noAnswerSec {
+		ac.Reasons = append(ac.Reasons, fmt.Sprintf("no speech in the first %.0fs", noAnswerSec))
+	}
+
+	machine, human := 0, 0
+	for _, t := range tones {
+		if t.Label == LabelBeep && t.StartSec >= first[1] {
+			start := t.StartSec
+			ac.BeepSec = &start
+			machine += 2
+			ac.Reasons = append(ac.Reasons, fmt.Sprintf("beep at %.1fs after the greeting", start))
+			break
+		}
+	}
+	switch {
+	case ac.GreetingSec >= machineGreetingSec:
+		machine++
+		ac.Reasons = append(ac.Reasons, fmt.Sprintf("uninterrupted %.1fs greeting", ac.GreetingSec))
+	case ac.GreetingSec <= humanGreetingSec && (len(speech) == 1 || speech[1][0]-first[1] >= humanPauseSec):
+		human++
+		ac.Reasons = append(ac.Reasons, fmt.Sprintf("short %.1fs greeting followed by a pause", ac.GreetingSec))
+	}
+
+	switch {
+	case machine > human:
+		ac.Class = AnswerMachine
+	case human > machine:
+		ac.Class = AnswerHuman
+	}
+	ac.Confidence = math.Min(1, 0.5+0.2*math.Abs(float64(machine-human)))
+	return ac
+}
@@ -0,0 +1,47 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+)
+
+// AnalysisReport is the measurement of an input without processing it
+type AnalysisReport struct {
+	DurationSec   float64         `json:"duration_sec"`
+	Channels      int             `json:"channels,omitempty"`
+	ChannelLayout string          `json:"channel_layout,omitempty"`
+	SampleRate    int             `json:"sample_rate,omitempty"`
+	Loudness      *Loudness       `json:"loudness,omitempty"`
+	NoiseLevel    float64         `json:"noise_level"`
+	Quality       *QualityMetrics `json:"quality,omitempty"` // SNR, RMS and peak levels
+}
+
+// Analyze runs only the measurement stages on path: duration, channel layout,
+// loudness, noise level and SNR. Only the duration is required; the other
+// measurements are left empty when they fail.
+func Analyze(ctx context.Context, path string, targetLUFS float64) (*AnalysisReport, error) {
+	d, err := GetDuration(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("analyze: %w", err)
+	}
+	r := &AnalysisReport{DurationSec: d}
+	if n, err := GetChannels(ctx, path); err == nil {
+		r.Channels = n
+	}
+	if layout, err := GetChannelLayout(ctx, path); err == nil && layout != "unknown" {
+		r.ChannelLayout = layout
+	}
+	if sr, err := GetSampleRate(ctx, path); err == nil {
+		r.SampleRate = sr
+	}
+	if l, err := MeasureLoudness(ctx, path, targetLUFS); err == nil {
+		r.Loudness = l
+	}
+	if nl, err := GetNoiseLevel(ctx, path); err == nil {
+		r.NoiseLevel = nl
+	}
+	if q, err := EstimateQuality(ctx, path); err == nil {
+		r.Quality = q
+	}
+	return r, nil
+}
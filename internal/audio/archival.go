@@ -0,0 +1,44 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Archival copies kept besides the delivery format
+const (
+	ArchivalProcessed = "processed" // of the output
+	ArchivalOriginal  = "original"  // of the input
+	ArchivalBoth      = "both"
+)
+
+// ParseArchivalCopy validates which archival copies a job keeps; empty and
+// "none" are none
+func ParseArchivalCopy(s string) (string, error) {
+	switch s {
+	case "none":
+		return "", nil
+	case "", ArchivalProcessed, ArchivalOriginal, ArchivalBoth:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown archival_copy %q (want %s, %s or %s)", s, ArchivalProcessed, ArchivalOriginal, ArchivalBoth)
+}
+
+// ArchivalPath is where the archival copy of path goes: <name>_<suffix>.flac
+// next to it
+func ArchivalPath(path, suffix string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "_" + suffix + ".flac"
+}
+
+// RenderArchival encodes the audio at path (a file or a URL ffmpeg can read) to
+// FLAC, keeping rate and channels; lossless, it takes about half the space of
+// PCM WAV
+func RenderArchival(ctx context.Context, path, out string) error {
+	args := append([]string{"-y", "-i", path, "-vn"}, encoderArgs(ProcessOptions{OutputFormat: "flac"})...)
+	if err := runFFmpeg(ctx, append(args, out)...); err != nil {
+		return fmt.Errorf("archival copy: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package audio
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// BenchmarkMethods are the denoisers compared by benchmark=true
+var BenchmarkMethods = []string{"afftdn", "arnndn", "noisereduce"}
+
+// BenchmarkVariant holds the metrics of one denoiser of an A/B benchmark job
+type BenchmarkVariant struct {
+	Method        string   `json:"method"`
+	Output        string   `json:"output"` // job output name, "" for the main output
+	ProcessingSec float64  `json:"processing_sec"`
+	SNR           *float64 `json:"snr,omitempty"`
+	NoiseLevel    *float64 `json:"noise_level,omitempty"`
+	LoudnessI     *float64 `json:"loudness_lufs,omitempty"`
+	MOS           *float64 `json:"mos,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// ParseBenchmark parses the benchmark submit field: "true" selects
+// BenchmarkMethods, anything else is a comma separated list of denoise methods.
+// Empty and "false" disable benchmarking.
+func ParseBenchmark(s string) ([]string, error) {
+	switch s {
+	case "", "false":
+		return nil, nil
+	case "true":
+		return append([]string(nil), BenchmarkMethods...), nil
+	}
+	var methods []string
+	seen := map[string]bool{}
+	for _, m := range strings.Split(s, ",") {
+		m = normalizeMethod(m)
+		if m == "" || seen[m] {
+			continue
+		}
+		if !knownMethod(m) {
+			return nil, fmt.Errorf("benchmark: unknown denoise method %q", m)
+		}
+		seen[m] = true
+		methods = append(methods, m)
+	}
+	return methods, nil
+}
+
+// BenchmarkPath is the output path of the variant of outputPath denoised with method
+func BenchmarkPath(outputPath, method string) string {
+	ext := filepath.Ext(outputPath)
+	return strings.TrimSuffix(outputPath, ext) + "_" + method + ext
+}
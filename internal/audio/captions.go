@@ -0,0 +1,63 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Limits of the cues built from words when a transcript has no segments
+const (
+	maxCueWords = 12
+	maxCueSec   = 6.0
+)
+
+// Cues returns the timed lines of a transcript for captions: its segments, or
+// runs of words when the ASR returned words only
+func (t *Transcript) Cues() []TranscriptSegment {
+	if len(t.Segments) > 0 {
+		return t.Segments
+	}
+	var cues []TranscriptSegment
+	var words []string
+	var cur TranscriptSegment
+	for i, w := range t.Words {
+		if len(words) == 0 {
+			cur.StartSec = w.StartSec
+		}
+		words = append(words, strings.TrimSpace(w.Word))
+		cur.EndSec = w.EndSec
+		last := i == len(t.Words)-1
+		if last || len(words) >= maxCueWords || t.Words[i+1].EndSec-cur.StartSec > maxCueSec {
+			cur.Text = strings.Join(words, " ")
+			cues = append(cues, cur)
+			words = words[:0]
+		}
+	}
+	return cues
+}
+
+// SRT renders the transcript as SubRip captions
+func (t *Transcript) SRT() string {
+	var b strings.Builder
+	for i, c := range t.Cues() {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, captionTime(c.StartSec, ","), captionTime(c.EndSec, ","), strings.TrimSpace(c.Text))
+	}
+	return b.String()
+}
+
+// VTT renders the transcript as WebVTT captions
+func (t *Transcript) VTT() string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, c := range t.Cues() {
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", captionTime(c.StartSec, "."), captionTime(c.EndSec, "."), strings.TrimSpace(c.Text))
+	}
+	return b.String()
+}
+
+// captionTime formats seconds as hh:mm:ss followed by sep and milliseconds
+func captionTime(sec float64, sep string) string {
+	ms := int64(math.Round(math.Max(sec, 0) * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
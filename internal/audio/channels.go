@@ -0,0 +1,143 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Channel modes
+const (
+	ChannelMono  = "mono"  // downmix to the configured channel count (default)
+	ChannelDual  = "dual"  // process left and right independently, output stereo
+	ChannelSplit = "split" // like dual, plus one mono output per party
+)
+
+// parties of a two-party call recording, in channel order (agent left, customer right)
+var callChannels = []string{"agent", "customer"}
+
+// ParseChannelMode validates a channel mode; empty means mono
+func ParseChannelMode(mode string) (string, error) {
+	switch mode {
+	case "", ChannelMono:
+		return ChannelMono, nil
+	case ChannelDual, ChannelSplit:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown channel_mode %q (want %s, %s or %s)", mode, ChannelMono, ChannelDual, ChannelSplit)
+}
+
+// ProcessDualChannel processes the two channels of a stereo call (agent left,
+// customer right) independently, each with its own denoise and loudnorm pass, and
+// merges them back into a stereo output so speaker separation is preserved.
+// In ChannelSplit mode each party is also encoded to its own mono file next to
+// outputPath (<name>_agent.<ext>, <name>_customer.<ext>), listed in Stats.Extras.
+// Mono inputs are handed to ProcessFile.
+func ProcessDualChannel(ctx context.Context, inputPath, outputPath string, opts ProcessOptions) (*Stats, error) {
+	inputPathAbs, _ := filepath.Abs(inputPath)
+	outputPathAbs, _ := filepath.Abs(outputPath)
+
+	n, err := GetChannels(ctx, inputPathAbs)
+	if err != nil {
+		return nil, err
+	}
+	if n < 2 {
+		log.Printf("dual channel mode requested for a %d channel input, processing as mono", n)
+		return ProcessFile(ctx, inputPath, outputPath, opts)
+	}
+
+	tmpDir, err := os.MkdirTemp(opts.scratchDir(outputPathAbs), "channels-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	processed, chStats, err := processChannels(ctx, inputPathAbs, tmpDir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// merge the processed channels back into one stereo file
+	args := []string{"-y", "-i", processed[0], "-i", processed[1],
+		"-filter_complex", "[0:a][1:a]amerge=inputs=2[a]", "-map", "[a]",
+		"-ar", strconv.Itoa(opts.SampleRate), "-ac", "2", "-vn",
+	}
+	args = append(args, encoderArgs(opts)...)
+	args = append(args, outputPathAbs)
+	if err := runFFmpeg(ctx, args...); err != nil {
+		return nil, fmt.Errorf("merge channels: %w", err)
+	}
+
+	stats := collectStats(ctx, inputPathAbs, outputPathAbs, opts, (chStats[0].NoiseLevel+chStats[1].NoiseLevel)/2, nil)
+	stats.FilterChain = chStats[0].FilterChain // both channels run the same chain
+	stats.Channels = map[string]*Stats{}
+	for i, name := range callChannels {
+		stats.Channels[name] = chStats[i]
+	}
+
+	if opts.ChannelMode == ChannelSplit {
+		stats.Extras = map[string]string{}
+		ext := filepath.Ext(outputPathAbs)
+		for i, name := range callChannels {
+			out := strings.TrimSuffix(outputPathAbs, ext) + "_" + name + ext
+			args := []string{"-y", "-i", processed[i], "-ar", strconv.Itoa(opts.SampleRate), "-ac", "1", "-vn"}
+			args = append(args, encoderArgs(opts)...)
+			args = append(args, out)
+			if err := runFFmpeg(ctx, args...); err != nil {
+				return nil, fmt.Errorf("encode %s channel: %w", name, err)
+			}
+			stats.Extras[name] = out
+		}
+	}
+	return stats, nil
+}
+
+// processChannels extracts the first two channels of inputPath to mono files and
+// processes them concurrently into mono wav files in dir. Stages that change the
+// timeline (silence trimming, gap removal) are disabled so the channels stay aligned.
+func processChannels(ctx context.Context, inputPath, dir string, opts ProcessOptions) ([]string, []*Stats, error) {
+	opts.Channels = 1
+	opts.OutputFormat = "wav"
+	opts.BitrateKbps = 0
+	opts.DCOffset = 0 // measured on the downmix, each channel has its own
+	if opts.TrimSilence || opts.VAD.RemoveGaps {
+		log.Printf("silence trimming and gap removal are disabled per channel to keep the channels aligned")
+		opts.TrimSilence, opts.VAD.RemoveGaps = false, false
+	}
+
+	outs := make([]string, len(callChannels))
+	stats := make([]*Stats, len(callChannels))
+	g, gctx := errgroup.WithContext(ctx)
+	for i, name := range callChannels {
+		i, name := i, name
+		g.Go(func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("%s channel panicked: %v", name, r)
+				}
+			}()
+			src := filepath.Join(dir, name+"_in.wav")
+			if err := runFFmpeg(gctx, "-y", "-i", inputPath, "-af", fmt.Sprintf("pan=mono|c0=c%d", i),
+				"-c:a", "pcm_s16le", "-vn", src); err != nil {
+				return fmt.Errorf("extract %s channel: %w", name, err)
+			}
+			outs[i] = filepath.Join(dir, name+".wav")
+			st, err := ProcessFile(gctx, src, outs[i], opts)
+			if err != nil {
+				return fmt.Errorf("%s channel: %w", name, err)
+			}
+			stats[i] = st
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+	return outs, stats, nil
+}
@@ -0,0 +1,48 @@
+package audio
+
+import (
+	"context"
+	"math"
+)
+
+// ClippingStats reports how much of a recording is clipped
+type ClippingStats struct {
+	ClippedSamples int64   `json:"clipped_samples"`
+	TotalSamples   int64   `json:"total_samples"`
+	ClippedPct     float64 `json:"clipped_pct"`
+}
+
+// clipLevel is the absolute sample value (full scale = 1) counted as clipped
+const clipLevel = 0.999
+
+// DetectClipping counts samples at full scale. A sample only counts when its
+// neighbour is clipped too, so single legitimate peaks are not reported.
+// The file is decoded at its native rate, resampling would smear the flat tops.
+func DetectClipping(ctx context.Context, path string) (*ClippingStats, error) {
+	st := &ClippingStats{}
+	prevClipped, prevCounted := false, false
+	err := streamPCM(ctx, path, 0, 4096, func(frame []float64) error {
+		for _, x := range frame {
+			st.TotalSamples++
+			clipped := math.Abs(x) >= clipLevel
+			if clipped && prevClipped {
+				if !prevCounted {
+					st.ClippedSamples++ // the run start
+				}
+				st.ClippedSamples++
+				prevCounted = true
+			} else {
+				prevCounted = false
+			}
+			prevClipped = clipped
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if st.TotalSamples > 0 {
+		st.ClippedPct = 100 * float64(st.ClippedSamples) / float64(st.TotalSamples)
+	}
+	return st, nil
+}
@@ -0,0 +1,135 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"go.yaml.in/yaml/v2"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/retention"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/storage"
+)
+
+// Config is the YAML config file shared by the API and the worker
+type Config struct {
+	Pipeline     PipelineConfig         `yaml:"pipeline"` // defaults of jobs without a preset
+	Presets      map[string]Preset      `yaml:"presets"`
+	KeywordLists map[string]KeywordList `yaml:"keyword_lists"` // selectable with the keyword_list submit field
+	Redaction    RedactionConf          `yaml:"redaction"`
+	QualityGate  QualityGate            `yaml:"quality_gate"` // thresholds processed outputs must meet
+	Retention    retention.Policy       `yaml:"retention"`    // when recordings are deleted, see the worker's purger
+	Tiering      retention.Tiering      `yaml:"tiering"`      // when recordings move to cold storage, see the worker's archiver
+
+	TenantStorage map[string]storage.TenantConfig `yaml:"tenant_storage"` // buckets and key prefixes of tenants
+}
+
+// DenoiseMethods are the accepted denoise_method values ("rnnoise" is an alias of arnndn)
+var DenoiseMethods = []string{"afftdn", "afftdn_tracked", "anlmdn", "arnndn", "rnnoise", "noisereduce", "spectral_gate"}
+
+// LoadConfig reads the config file at path. Keys missing from the pipeline section
+// keep their DefaultPipeline value; presets are merged over the built-in ones.
+// An empty path or a missing file yields the defaults.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{Pipeline: DefaultPipeline, Redaction: RedactionConf{PadSec: 0.2}}
+	if path != "" {
+		b, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, err
+		default:
+			if err := yaml.UnmarshalStrict(b, cfg); err != nil {
+				return nil, fmt.Errorf("parse %s: %w", path, err)
+			}
+		}
+	}
+	if err := cfg.Pipeline.Validate(); err != nil {
+		return nil, fmt.Errorf("pipeline: %w", err)
+	}
+
+	presets := make(map[string]Preset, len(builtinPresets)+len(cfg.Presets))
+	for name, p := range builtinPresets {
+		presets[name] = p
+	}
+	for name, p := range cfg.Presets {
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("preset %q: %w", name, err)
+		}
+		presets[name] = p
+	}
+	cfg.Presets = presets
+
+	for name, list := range cfg.KeywordLists {
+		for category, terms := range list {
+			for _, term := range terms {
+				if normalizeWord(term) == "" {
+					return nil, fmt.Errorf("keyword list %q: empty term in category %q", name, category)
+				}
+			}
+		}
+	}
+
+	if cfg.Redaction.PadSec < 0 || cfg.Redaction.PadSec > 2 {
+		return nil, fmt.Errorf("redaction: pad_sec %.2f out of range (0..2)", cfg.Redaction.PadSec)
+	}
+	for _, term := range cfg.Redaction.Terms {
+		if normalizeWord(term) == "" {
+			return nil, errors.New("redaction: empty term")
+		}
+	}
+	if err := cfg.QualityGate.Validate(); err != nil {
+		return nil, fmt.Errorf("quality_gate: %w", err)
+	}
+	if err := cfg.Retention.Validate(); err != nil {
+		return nil, fmt.Errorf("retention: %w", err)
+	}
+	if err := cfg.Tiering.Validate(); err != nil {
+		return nil, fmt.Errorf("tiering: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate rejects configs the pipeline can't run
+func (c PipelineConfig) Validate() error {
+	if !knownMethod(c.DenoiseDefault) {
+		return fmt.Errorf("unknown denoise_default %q", c.DenoiseDefault)
+	}
+	for method := range c.Denoisers {
+		if _, ok := denoiseProfiles[method]; !ok {
+			return fmt.Errorf("denoisers: %q has no filter options", method)
+		}
+	}
+	if c.TargetLUFS < -70 || c.TargetLUFS > -5 {
+		return fmt.Errorf("target_lufs %.1f out of range (-70..-5)", c.TargetLUFS)
+	}
+	if c.SampleRate < 8000 || c.SampleRate > 192000 {
+		return fmt.Errorf("sample_rate %d out of range (8000..192000)", c.SampleRate)
+	}
+	if c.Channels != 1 && c.Channels != 2 {
+		return fmt.Errorf("channels must be 1 or 2, got %d", c.Channels)
+	}
+	if c.UseCompressor && c.Compressor.Ratio < 1 {
+		return fmt.Errorf("compressor ratio must be at least 1, got %.1f", c.Compressor.Ratio)
+	}
+	if c.UseLimiter && c.Limiter.ThresholdDB > 0 {
+		return fmt.Errorf("limiter threshold_db must not be positive, got %.1f", c.Limiter.ThresholdDB)
+	}
+	if c.HighpassHz < 0 || c.LowpassHz < 0 || (c.HighpassHz > 0 && c.LowpassHz > 0 && c.LowpassHz <= c.HighpassHz) {
+		return fmt.Errorf("invalid highpass_hz/lowpass_hz %d/%d", c.HighpassHz, c.LowpassHz)
+	}
+	if _, err := ParseEngine(c.Engine); err != nil {
+		return err
+	}
+	return nil
+}
+
+func knownMethod(method string) bool {
+	method = normalizeMethod(method)
+	for _, m := range DenoiseMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package audio
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Custom filter modes
+const (
+	CustomAppend  = "append"  // custom chain runs after the generated chain (default)
+	CustomReplace = "replace" // custom chain replaces denoise and mastering
+)
+
+// customFilterWhitelist lists the ffmpeg audio filters accepted in custom_filter.
+// Filters that read files (arnndn, amovie, ...) or take filtergraphs are left out.
+// Some options of volume and afade are expressions: customValueRe only lets a
+// single number or name through to them, never an operator or function call.
+var customFilterWhitelist = map[string]bool{
+	"acompressor": true, "adeclick": true, "adeclip": true, "afade": true, "afftdn": true,
+	"agate": true, "alimiter": true, "anlmdn": true, "aresample": true, "atempo": true,
+	"bandpass": true, "bandreject": true, "bass": true, "deesser": true, "dynaudnorm": true,
+	"equalizer": true, "highpass": true, "highshelf": true, "loudnorm": true, "lowpass": true,
+	"lowshelf": true, "silenceremove": true, "speechnorm": true, "treble": true, "volume": true,
+}
+
+var (
+	customKeyRe = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	// a number with an optional unit (-16, 1.5, 3dB, 200ms, 1e3) or a word (q, hann)
+	customValueRe = regexp.MustCompile(`^(?:[+-]?(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][+-]?[0-9]+)?[A-Za-z]*|[A-Za-z][A-Za-z0-9_]*)$`)
+)
+
+const (
+	maxCustomFilters = 16
+	maxCustomValue   = 32 // bytes of one option value
+)
+
+// ParseCustomFilter validates a user supplied -af chain and rebuilds it from its
+// parsed parts: filter names must be whitelisted and options are restricted to
+// key=value pairs of plain numbers/words, so quoting, escaping, file references and
+// filtergraph links cannot be smuggled in.
+func ParseCustomFilter(chain string) (string, error) {
+	chain = strings.TrimSpace(chain)
+	if chain == "" {
+		return "", nil
+	}
+	parts := strings.Split(chain, ",")
+	if len(parts) > maxCustomFilters {
+		return "", fmt.Errorf("custom_filter: at most %d filters", maxCustomFilters)
+	}
+	out := make([]string, 0, len(parts))
+	for _, part := range parts {
+		name, args, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if !customFilterWhitelist[name] {
+			return "", fmt.Errorf("custom_filter: filter %q not allowed (allowed: %s)", name, strings.Join(allowedCustomFilters(), ", "))
+		}
+		if args == "" {
+			out = append(out, name)
+			continue
+		}
+		opts := strings.Split(args, ":")
+		for _, o := range opts {
+			k, v, ok := strings.Cut(o, "=")
+			if !ok || !customKeyRe.MatchString(k) || len(v) > maxCustomValue || !customValueRe.MatchString(v) {
+				return "", fmt.Errorf("custom_filter: invalid option %q for %s (want key=value)", o, name)
+			}
+		}
+		out = append(out, name+"="+strings.Join(opts, ":"))
+	}
+	return strings.Join(out, ","), nil
+}
+
+// ParseCustomMode validates a custom filter mode; empty means append
+func ParseCustomMode(mode string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", CustomAppend:
+		return CustomAppend, nil
+	case CustomReplace:
+		return CustomReplace, nil
+	}
+	return "", fmt.Errorf("custom_filter_mode: want %s or %s", CustomAppend, CustomReplace)
+}
+
+func allowedCustomFilters() []string {
+	names := make([]string, 0, len(customFilterWhitelist))
+	for n := range customFilterWhitelist {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,59 @@
+package audio
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCustomFilter(t *testing.T) {
+	for _, tc := range []struct {
+		chain, want string
+	}{
+		{"", ""},
+		{"  ", ""},
+		{"equalizer=f=3000:t=q:w=1:g=3,volume=volume=1.5", "equalizer=f=3000:t=q:w=1:g=3,volume=volume=1.5"},
+		{" highpass=f=200 , lowpass=f=3400 ", "highpass=f=200,lowpass=f=3400"},
+		{"loudnorm=i=-16:tp=-1.5:lra=11", "loudnorm=i=-16:tp=-1.5:lra=11"},
+		{"volume=volume=3dB,afade=t=in:d=.5", "volume=volume=3dB,afade=t=in:d=.5"},
+		{"adeclick", "adeclick"},
+		{"afftdn=nr=1e1", "afftdn=nr=1e1"},
+	} {
+		got, err := ParseCustomFilter(tc.chain)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseCustomFilter(%q) = %q, %v; want %q", tc.chain, got, err, tc.want)
+		}
+	}
+}
+
+func TestParseCustomFilterRejects(t *testing.T) {
+	for _, tc := range []struct {
+		name, chain string
+	}{
+		{"second command", "volume=volume=2;movie=/etc/passwd"},
+		{"semicolon in a value", "volume=volume=2;1"},
+		{"link label", "[0:a]volume=volume=2"},
+		{"link label after a filter", "volume=volume=2[out]"},
+		{"comma in a value", `volume=volume=2\,3`},
+		{"movie source", "movie=/etc/passwd"},
+		{"amovie source", "amovie=filename=/etc/passwd"},
+		{"file reading filter", "arnndn=m=/models/x.rnnn"},
+		{"unknown filter", "sine=f=440"},
+		{"empty filter", "volume=volume=2,,lowpass=f=3000"},
+		{"positional option", "aresample=16000"},
+		{"empty value", "volume=volume="},
+		{"quoted value", "volume=volume='2'"},
+		{"path value", "equalizer=f=/tmp/x"},
+		{"overlong value", "volume=volume=" + strings.Repeat("1", maxCustomValue+1)},
+		{"overlong word", "afade=curve=" + strings.Repeat("a", maxCustomValue+1)},
+		{"expression sum", "volume=volume=1+t:eval=frame"},
+		{"expression of a name", "volume=volume=t-1:eval=frame"},
+		{"expression call", "volume=volume=sin(t)"},
+		{"expression product", "afade=t=in:st=2*3"},
+		{"upper case key", "volume=Volume=2"},
+		{"too many filters", strings.Repeat("volume=volume=1,", maxCustomFilters) + "volume=volume=1"},
+	} {
+		if got, err := ParseCustomFilter(tc.chain); err == nil {
+			t.Errorf("%s: %q accepted as %q", tc.name, tc.chain, got)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// DCOffsetThreshold is the offset (full scale = 1, about -46 dBFS) above which a
+// recording is reported as offset; it is enough to bias the compressor's detector
+const DCOffsetThreshold = 0.005
+
+// dcHighpassHz is the corner of the high-pass that removes a DC offset that was
+// not measured or differs between channels
+const dcHighpassHz = 10
+
+// DCOffsetStats reports the DC offset of a recording
+type DCOffsetStats struct {
+	Offset      float64 `json:"offset"`      // mean sample value, full scale = 1
+	OffsetDB    float64 `json:"offset_dbfs"` // level of the offset, -120 when there is none
+	Significant bool    `json:"significant"` // above DCOffsetThreshold
+}
+
+// DetectDCOffset measures the mean of the (downmixed) signal, the DC offset some
+// hardware recorders add. The file is decoded at its native rate.
+func DetectDCOffset(ctx context.Context, path string) (*DCOffsetStats, error) {
+	var sum float64
+	var n int64
+	err := streamPCM(ctx, path, 0, 4096, func(frame []float64) error {
+		for _, x := range frame {
+			sum += x
+		}
+		n += int64(len(frame))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	st := &DCOffsetStats{OffsetDB: -120}
+	if n == 0 {
+		return st, nil
+	}
+	st.Offset = sum / float64(n)
+	if a := math.Abs(st.Offset); a > 0 {
+		st.OffsetDB = math.Max(20*math.Log10(a), -120)
+	}
+	st.Significant = math.Abs(st.Offset) > DCOffsetThreshold
+	return st, nil
+}
+
+// dcFilter removes a DC offset: a measured offset is shifted out exactly with
+// dcshift, otherwise a high-pass far below speech takes out DC and slow drift
+func dcFilter(offset float64) string {
+	if offset != 0 && math.Abs(offset) < 1 {
+		return fmt.Sprintf("dcshift=shift=%s", stripTrailingZeros(-offset))
+	}
+	return fmt.Sprintf("highpass=f=%d", dcHighpassHz)
+}
@@ -0,0 +1,67 @@
+package audio
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// denoiseProfile is an ffmpeg denoise filter with its default options
+type denoiseProfile struct {
+	Filter   string
+	Defaults map[string]string
+}
+
+// denoiseProfiles maps the ffmpeg-side denoise methods to their filters.
+// arnndn and noisereduce are handled separately (model file, external helper).
+var denoiseProfiles = map[string]denoiseProfile{
+	// broadband FFT denoiser with ffmpeg defaults
+	"afftdn": {Filter: "afftdn"},
+	// afftdn tuned for calls: stronger reduction and noise floor tracking,
+	// so changing background noise (moving callers, HVAC) keeps being removed
+	"afftdn_tracked": {Filter: "afftdn", Defaults: map[string]string{"nr": "15", "nf": "-35", "tn": "1"}},
+	// non-local means, slower but gentler on speech
+	"anlmdn": {Filter: "anlmdn", Defaults: map[string]string{"s": "0.0001", "p": "0.002", "r": "0.006"}},
+}
+
+// filter renders the profile with params overriding its defaults; option order is stable
+func (p denoiseProfile) filter(params map[string]string) string {
+	merged := map[string]string{}
+	for k, v := range p.Defaults {
+		merged[k] = v
+	}
+	for k, v := range params {
+		merged[k] = v
+	}
+	if len(merged) == 0 {
+		return p.Filter
+	}
+	keys := make([]string, 0, len(merged))
+	for k := range merged {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	opts := make([]string, len(keys))
+	for i, k := range keys {
+		opts[i] = k + "=" + merged[k]
+	}
+	return p.Filter + "=" + strings.Join(opts, ":")
+}
+
+// ParseDenoiseParams parses "key=value:key=value" filter options for the
+// ffmpeg-side denoisers, with the same restrictions as custom_filter options
+func ParseDenoiseParams(s string) (map[string]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	params := map[string]string{}
+	for _, o := range strings.Split(s, ":") {
+		k, v, ok := strings.Cut(o, "=")
+		if !ok || !customKeyRe.MatchString(k) || !customValueRe.MatchString(v) {
+			return nil, fmt.Errorf("denoise_params: invalid option %q (want key=value)", o)
+		}
+		params[k] = v
+	}
+	return params, nil
+}
@@ -0,0 +1,82 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// SpeakerSegment is a stretch of speech attributed to one speaker
+type SpeakerSegment struct {
+	Speaker  string  `json:"speaker"` // agent, customer or speaker_<n>
+	Raw      string  `json:"raw_speaker,omitempty"`
+	StartSec float64 `json:"start_sec"`
+	EndSec   float64 `json:"end_sec"`
+}
+
+// Diarizer splits a recording into speaker-labeled segments
+type Diarizer interface {
+	Diarize(ctx context.Context, path string) ([]SpeakerSegment, error)
+}
+
+// NewDiarizer builds a diarizer from a spec (see jsonTool): a service URL the audio
+// is POSTed to, or cmd:<command> such as "cmd:python3 tools/diarize_pyannote.py".
+// The backend answers {"segments": [{"speaker": "SPEAKER_00", "start": 0.5, "end": 3.2}, ...]}.
+// An empty spec returns nil (diarization disabled).
+func NewDiarizer(spec string) (Diarizer, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	t, err := parseJSONTool("diarizer", spec)
+	if err != nil {
+		return nil, err
+	}
+	return toolDiarizer{t}, nil
+}
+
+type toolDiarizer struct {
+	tool *jsonTool
+}
+
+func (d toolDiarizer) Diarize(ctx context.Context, path string) ([]SpeakerSegment, error) {
+	var resp struct {
+		Segments []struct {
+			Speaker string  `json:"speaker"`
+			Start   float64 `json:"start"`
+			End     float64 `json:"end"`
+		} `json:"segments"`
+	}
+	if err := d.tool.call(ctx, path, &resp); err != nil {
+		return nil, fmt.Errorf("diarize: %w", err)
+	}
+	segs := make([]SpeakerSegment, 0, len(resp.Segments))
+	for _, s := range resp.Segments {
+		if s.End <= s.Start {
+			continue
+		}
+		segs = append(segs, SpeakerSegment{Raw: s.Speaker, StartSec: s.Start, EndSec: s.End})
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i].StartSec < segs[j].StartSec })
+	labelParties(segs)
+	return segs, nil
+}
+
+// labelParties names the backend's speakers by order of first appearance: on an
+// outbound or answered call the agent speaks first (greeting), so the first speaker
+// is labeled agent, the second customer and any further one speaker_<n>.
+func labelParties(segs []SpeakerSegment) {
+	names := map[string]string{}
+	for i := range segs {
+		name, ok := names[segs[i].Raw]
+		if !ok {
+			switch n := len(names); n {
+			case 0, 1:
+				name = callChannels[n]
+			default:
+				name = fmt.Sprintf("speaker_%d", n+1)
+			}
+			names[segs[i].Raw] = name
+		}
+		segs[i].Speaker = name
+	}
+}
@@ -0,0 +1,140 @@
+package audio
+
+import (
+	"context"
+	"math"
+)
+
+// DTMFEvent is one detected keypress
+type DTMFEvent struct {
+	Digit    string  `json:"digit"`
+	StartSec float64 `json:"start_sec"`
+	EndSec   float64 `json:"end_sec"`
+}
+
+const (
+	dtmfRate      = 8000
+	dtmfBlock     = 205 // ~25.6 ms, the classic Goertzel block size for 8 kHz DTMF
+	dtmfMinBlocks = 2   // a digit must be stable this many blocks (~50 ms) to count
+	dtmfMinPower  = 1e-4
+	dtmfTwistDB   = 8.0 // max level difference between the row and column tone
+	dtmfPurity    = 0.6 // share of the block energy carried by the two tones
+	dtmfPeakRatio = 4.0 // strongest tone must dominate the others of its group
+)
+
+var (
+	dtmfRows = []float64{697, 770, 852, 941}
+	dtmfCols = []float64{1209, 1336, 1477, 1633}
+	dtmfKeys = [4][4]string{
+		{"1", "2", "3", "A"},
+		{"4", "5", "6", "B"},
+		{"7", "8", "9", "C"},
+		{"*", "0", "#", "D"},
+	}
+)
+
+// goertzel returns the power of freq in frame, normalized by the frame length
+func goertzel(frame []float64, freq float64, rate int) float64 {
+	coeff := 2 * math.Cos(2*math.Pi*freq/float64(rate))
+	var s1, s2 float64
+	for _, x := range frame {
+		s0 := x + coeff*s1 - s2
+		s2, s1 = s1, s0
+	}
+	p := s1*s1 + s2*s2 - coeff*s1*s2
+	n := float64(len(frame))
+	return p / (n * n)
+}
+
+// DetectDTMF finds DTMF keypresses (IVR navigation, PIN entry) with Goertzel
+// filters on the eight DTMF frequencies
+func DetectDTMF(ctx context.Context, path string) ([]DTMFEvent, error) {
+	var tr dtmfTracker
+	err := streamPCM(ctx, path, dtmfRate, dtmfBlock, func(frame []float64) error {
+		tr.add(frame)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tr.close(), nil
+}
+
+// dtmfTracker turns the digits of consecutive blocks into keypresses
+type dtmfTracker struct {
+	events []DTMFEvent
+	cur    string // digit of the current run of blocks
+	run    int
+	block  int
+}
+
+// add classifies the next block of dtmfBlock samples at dtmfRate
+func (t *dtmfTracker) add(frame []float64) {
+	d := dtmfDigit(frame)
+	if d != t.cur {
+		t.closeRun()
+		t.cur, t.run = d, 0
+	}
+	if d != "" {
+		t.run++
+	}
+	t.block++
+}
+
+// close ends the last run and returns the keypresses
+func (t *dtmfTracker) close() []DTMFEvent {
+	t.closeRun()
+	t.cur, t.run = "", 0
+	return t.events
+}
+
+func (t *dtmfTracker) closeRun() {
+	if t.cur != "" && t.run >= dtmfMinBlocks {
+		blockSec := float64(dtmfBlock) / dtmfRate
+		end := float64(t.block) * blockSec
+		t.events = append(t.events, DTMFEvent{Digit: t.cur, StartSec: end - float64(t.run)*blockSec, EndSec: end})
+	}
+}
+
+// dtmfDigit classifies one block, "" when no valid digit is present
+func dtmfDigit(frame []float64) string {
+	if len(frame) < dtmfBlock {
+		return ""
+	}
+	energy := 0.0
+	for _, x := range frame {
+		energy += x * x
+	}
+	energy /= float64(len(frame))
+	if energy < dtmfMinPower {
+		return ""
+	}
+	row, rowP, rowNext := strongest(frame, dtmfRows)
+	col, colP, colNext := strongest(frame, dtmfCols)
+	if rowP < dtmfPeakRatio*rowNext || colP < dtmfPeakRatio*colNext {
+		return ""
+	}
+	if math.Abs(10*math.Log10(rowP/colP)) > dtmfTwistDB {
+		return ""
+	}
+	// a pure sine of amplitude a has power a²/4 in goertzel units and a²/2 in energy
+	if 2*(rowP+colP) < dtmfPurity*energy {
+		return ""
+	}
+	return dtmfKeys[row][col]
+}
+
+// strongest returns the index and power of the strongest of freqs and the power of the runner-up
+func strongest(frame []float64, freqs []float64) (int, float64, float64) {
+	best, bestP, nextP := 0, 0.0, 0.0
+	for i, f := range freqs {
+		p := goertzel(frame, f, dtmfRate)
+		if p > bestP {
+			nextP = bestP
+			best, bestP = i, p
+		} else if p > nextP {
+			nextP = p
+		}
+	}
+	return best, bestP, nextP
+}
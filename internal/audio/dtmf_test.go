@@ -0,0 +1,101 @@
+package audio
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// dtmfTone returns seconds of the key at row, col of dtmfKeys, each tone of
+// peak amplitude amp, with gaussian noise of deviation noise
+func dtmfTone(rng *rand.Rand, row, col int, amp, noise, seconds float64) []float64 {
+	x := make([]float64, int(seconds*dtmfRate))
+	for i := range x {
+		t := float64(i) / dtmfRate
+		if row >= 0 {
+			x[i] += amp * math.Sin(2*math.Pi*dtmfRows[row]*t)
+		}
+		if col >= 0 {
+			x[i] += amp * math.Sin(2*math.Pi*dtmfCols[col]*t)
+		}
+		x[i] += noise * rng.NormFloat64()
+	}
+	return x
+}
+
+func TestDTMFDigit(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for row := range dtmfRows {
+		for col := range dtmfCols {
+			want := dtmfKeys[row][col]
+			if d := dtmfDigit(dtmfTone(rng, row, col, 0.3, 0.02, 0.05)[:dtmfBlock]); d != want {
+				t.Errorf("key %s detected as %q", want, d)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		name  string
+		frame []float64
+	}{
+		{"silence", make([]float64, dtmfBlock)},
+		{"quiet key", dtmfTone(rng, 0, 0, 0.001, 0, 0.05)[:dtmfBlock]},
+		{"noise", dtmfTone(rng, -1, -1, 0, 0.3, 0.05)[:dtmfBlock]},
+		{"row tone only", dtmfTone(rng, 1, -1, 0.3, 0.02, 0.05)[:dtmfBlock]},
+		{"column tone only", dtmfTone(rng, -1, 2, 0.3, 0.02, 0.05)[:dtmfBlock]},
+		{"key drowned in noise", dtmfTone(rng, 2, 1, 0.3, 0.3, 0.05)[:dtmfBlock]},
+		{"short frame", dtmfTone(rng, 0, 0, 0.3, 0, 0.01)},
+	} {
+		if d := dtmfDigit(tc.frame); d != "" {
+			t.Errorf("%s detected as %q", tc.name, d)
+		}
+	}
+
+	// the row tone 14 dB above the column tone is beyond the twist allowed
+	twisted := dtmfTone(rng, 3, 1, 0, 0, 0.05)[:dtmfBlock]
+	for i := range twisted {
+		tt := float64(i) / dtmfRate
+		twisted[i] = 0.5*math.Sin(2*math.Pi*dtmfRows[3]*tt) + 0.1*math.Sin(2*math.Pi*dtmfCols[1]*tt)
+	}
+	if d := dtmfDigit(twisted); d != "" {
+		t.Errorf("twisted key detected as %q", d)
+	}
+}
+
+func TestDTMFTracker(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	silence := func(sec float64) []float64 { return dtmfTone(rng, -1, -1, 0, 0.01, sec) }
+	var x []float64
+	for _, part := range [][]float64{
+		silence(0.1),
+		dtmfTone(rng, 0, 0, 0.3, 0.01, 0.1), // 1 from 0.1 to 0.2
+		silence(0.06),
+		dtmfTone(rng, 3, 2, 0.3, 0.01, 0.1), // # from 0.26 to 0.36
+		silence(0.1),
+		dtmfTone(rng, 1, 1, 0.3, 0.01, 0.02), // 5, too short to count
+		silence(0.1),
+		dtmfTone(rng, 0, 0, 0.3, 0.01, 0.1),  // 1 again from 0.58 to 0.68
+		dtmfTone(rng, 2, 2, 0.3, 0.01, 0.12), // 9 right after, to the end at 0.8
+	} {
+		x = append(x, part...)
+	}
+
+	var tr dtmfTracker
+	for len(x) >= dtmfBlock {
+		tr.add(x[:dtmfBlock])
+		x = x[dtmfBlock:]
+	}
+	got := tr.close()
+
+	want := []DTMFEvent{{"1", 0.1, 0.2}, {"#", 0.26, 0.36}, {"1", 0.58, 0.68}, {"9", 0.68, 0.8}}
+	if len(got) != len(want) {
+		t.Fatalf("events %+v, want %+v", got, want)
+	}
+	// a keypress is found to the block
+	tol := 1.5 * float64(dtmfBlock) / dtmfRate
+	for i, e := range got {
+		if e.Digit != want[i].Digit || math.Abs(e.StartSec-want[i].StartSec) > tol || math.Abs(e.EndSec-want[i].EndSec) > tol {
+			t.Errorf("event %d: %+v, want %+v", i, e, want[i])
+		}
+	}
+}
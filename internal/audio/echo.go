@@ -0,0 +1,108 @@
+package audio
+
+import (
+	"context"
+	"math"
+	"math/cmplx"
+	"sort"
+)
+
+// EchoStats is a blind estimate of line/acoustic echo in a recording
+type EchoStats struct {
+	Score   float64 `json:"score"`              // 0 (none found) .. 1 (strong echo)
+	DelayMs float64 `json:"delay_ms,omitempty"` // delay of the strongest echo
+	Frames  int     `json:"frames"`             // speech frames the estimate is based on
+}
+
+const (
+	echoRate        = 8000
+	echoFrame       = 16384 // ~2 s, long enough for echoes of up to echoMaxDelaySec
+	echoMinDelaySec = 0.03  // below this the cepstrum is dominated by the voice pitch
+	echoMaxDelaySec = 0.6
+	echoMinLevelDB  = -45.0 // frames quieter than this hold no speech to echo
+	echoMinZ        = 6.0   // cepstral peak prominence scored as 0
+	echoFullZ       = 40.0  // and as 1
+)
+
+// EstimateEcho looks for a delayed copy of the signal with cepstral analysis: an
+// echo at delay d puts a peak at quefrency d in the power cepstrum. The cepstra
+// of the speech frames are averaged so a steady echo path stands out from the
+// random peaks of single frames; the score is the prominence of the strongest
+// peak (in standard deviations) mapped onto 0..1. Stereo calls are mixed down,
+// which keeps an echo of one party in the other party's channel detectable.
+func EstimateEcho(ctx context.Context, path string) (*EchoStats, error) {
+	window := hann(echoFrame)
+	buf := make([]complex128, echoFrame)
+	lo := int(echoMinDelaySec * echoRate)
+	hi := int(echoMaxDelaySec * echoRate)
+	sum := make([]float64, hi+1)
+	frames := 0
+	err := streamPCM(ctx, path, echoRate, echoFrame, func(frame []float64) error {
+		if len(frame) < echoFrame {
+			return nil
+		}
+		power := 0.0
+		for _, v := range frame {
+			power += v * v
+		}
+		if powerDB(power/float64(len(frame))) < echoMinLevelDB {
+			return nil
+		}
+		addCepstrum(sum, frame, window, buf, lo)
+		frames++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	st := &EchoStats{Frames: frames}
+	if frames == 0 {
+		return st, nil
+	}
+	z, peak := cepstralPeak(sum[lo:])
+	st.Score = math.Max(0, math.Min(1, (z-echoMinZ)/(echoFullZ-echoMinZ)))
+	if st.Score > 0 {
+		st.DelayMs = 1000 * float64(lo+peak) / echoRate
+	}
+	return st, nil
+}
+
+// addCepstrum adds the power cepstrum of the windowed frame to sum from quefrency lo on
+func addCepstrum(sum, frame, window []float64, buf []complex128, lo int) {
+	for i, v := range frame {
+		buf[i] = complex(v*window[i], 0)
+	}
+	fft(buf)
+	for i, v := range buf {
+		buf[i] = complex(math.Log(real(v)*real(v)+imag(v)*imag(v)+1e-12), 0)
+	}
+	ifft(buf)
+	for q := lo; q < len(sum); q++ {
+		sum[q] += cmplx.Abs(buf[q])
+	}
+}
+
+// cepstralPeak returns the prominence of the largest value of c over its median,
+// in standard deviations, and its index
+func cepstralPeak(c []float64) (float64, int) {
+	sorted := append([]float64(nil), c...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	mean, peak := 0.0, 0
+	for i, v := range c {
+		mean += v
+		if v > c[peak] {
+			peak = i
+		}
+	}
+	mean /= float64(len(c))
+	variance := 0.0
+	for _, v := range c {
+		variance += (v - mean) * (v - mean)
+	}
+	std := math.Sqrt(variance / float64(len(c)))
+	if std == 0 {
+		return 0, peak
+	}
+	return (c[peak] - median) / std, peak
+}
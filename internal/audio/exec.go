@@ -0,0 +1,148 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/metrics"
+)
+
+// ResourceLimits bounds the ffmpeg/python processes spawned for a job.
+// Zero values leave the corresponding resource unlimited.
+type ResourceLimits struct {
+	Nice        int   // niceness added to children (1..19)
+	Threads     int   // ffmpeg -threads/-filter_threads and BLAS/OpenMP threads for python
+	MaxMemBytes int64 // address-space ceiling (RLIMIT_AS) per child, linux only
+}
+
+type limitsKey struct{}
+
+// WithLimits returns a context whose child processes run under limits
+func WithLimits(ctx context.Context, limits ResourceLimits) context.Context {
+	return context.WithValue(ctx, limitsKey{}, limits)
+}
+
+func limitsFrom(ctx context.Context) ResourceLimits {
+	l, _ := ctx.Value(limitsKey{}).(ResourceLimits)
+	return l
+}
+
+// newCmd builds a command for an external tool, applying the limits carried by ctx.
+// The child gets its own process group, killed as a whole when ctx is cancelled.
+func newCmd(ctx context.Context, path string, args ...string) *exec.Cmd {
+	limits := limitsFrom(ctx)
+	tool := toolName(path)
+	var env []string
+	if limits.Threads > 0 {
+		n := strconv.Itoa(limits.Threads)
+		switch {
+		case tool == "ffmpeg":
+			args = append([]string{"-threads", n, "-filter_threads", n}, args...)
+		case strings.HasPrefix(tool, "python"):
+			env = append(os.Environ(), "OMP_NUM_THREADS="+n, "OPENBLAS_NUM_THREADS="+n, "MKL_NUM_THREADS="+n)
+		}
+	}
+	path, args = limitCommand(path, args, limits)
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Env = env
+	setProcessGroup(cmd)
+	return cmd
+}
+
+// runCmd starts cmd, waits for it and records the child's resource usage.
+func runCmd(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return waitCmd(cmd)
+}
+
+// waitCmd waits for a started child and records its resource usage; callers
+// streaming the child's output start it themselves
+func waitCmd(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+	if ps := cmd.ProcessState; ps != nil {
+		tool := cmdTool(cmd)
+		metrics.ChildCPUSeconds.WithLabelValues(tool).Observe((ps.UserTime() + ps.SystemTime()).Seconds())
+		if rss := maxRSSBytes(ps); rss > 0 {
+			metrics.ChildMaxRSSBytes.WithLabelValues(tool).Observe(float64(rss))
+		}
+	}
+	return err
+}
+
+func toolName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".exe")
+}
+
+// cmdTool names the tool cmd runs, looking through the nice/prlimit wrappers
+// added by limitCommand
+func cmdTool(cmd *exec.Cmd) string {
+	tool := toolName(cmd.Path)
+	if tool != "nice" && tool != "prlimit" {
+		return tool
+	}
+	if i := slices.Index(cmd.Args, "--"); i >= 0 && i+1 < len(cmd.Args) {
+		return toolName(cmd.Args[i+1])
+	}
+	return tool
+}
+
+// runFFmpeg runs ffmpeg with args; on failure the error carries ffmpeg's stderr
+func runFFmpeg(ctx context.Context, args ...string) error {
+	_, err := ffmpegStderr(ctx, args...)
+	return err
+}
+
+// ffmpegStderr runs ffmpeg and returns its stderr, where analysis filters
+// (silencedetect, loudnorm, ...) print their results
+func ffmpegStderr(ctx context.Context, args ...string) (string, error) {
+	return ffmpegOutput(ctx, nil, args...)
+}
+
+// ffmpegOutput is ffmpegStderr for commands writing their output to stdout
+// (pipe:1), which is copied to w
+func ffmpegOutput(ctx context.Context, w io.Writer, args ...string) (string, error) {
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg not found in PATH: %w", err)
+	}
+	cmd := newCmd(ctx, ffmpegPath, args...)
+	cmd.Stdout = w
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := runCmd(cmd); err != nil {
+		return stderr.String(), fmt.Errorf("ffmpeg failed: %w - stderr: %s", err, stderr.String())
+	}
+	return stderr.String(), nil
+}
+
+var ffmpegVersion struct {
+	once sync.Once
+	v    string
+}
+
+// FFmpegVersion returns the version ffmpeg reports, e.g. "6.1.1"; empty when
+// ffmpeg can't be run. It is asked once per process.
+func FFmpegVersion() string {
+	ffmpegVersion.once.Do(func() {
+		out, err := exec.Command("ffmpeg", "-version").Output()
+		if err != nil {
+			return
+		}
+		// ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 ...
+		if f := strings.Fields(string(out)); len(f) > 2 && f[1] == "version" {
+			ffmpegVersion.v = f[2]
+		}
+	})
+	return ffmpegVersion.v
+}
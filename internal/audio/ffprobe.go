@@ -4,27 +4,33 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
 )
 
-// GetDuration returns duration in seconds (float) via ffprobe
+// GetDuration returns duration in seconds (float) via ffprobe.
+// Without ffprobe, PCM WAV files are still measured from their header.
 func GetDuration(ctx context.Context, path string) (float64, error) {
 	ffprobePath, err := exec.LookPath("ffprobe")
 	if err != nil {
+		if d, werr := wavDuration(path); werr == nil {
+			return d, nil
+		}
 		return 0, fmt.Errorf("ffprobe not found in PATH: %w", err)
 	}
 	args := []string{"-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", path}
-	cmd := exec.CommandContext(ctx, ffprobePath, args...)
+	cmd := newCmd(ctx, ffprobePath, args...)
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
+	if err := runCmd(cmd); err != nil {
 		return 0, fmt.Errorf("ffprobe failed: %w - stderr: %s", err, stderr.String())
 	}
 	s := strings.TrimSpace(out.String())
@@ -38,23 +44,103 @@ func GetDuration(ctx context.Context, path string) (float64, error) {
 	return f, nil
 }
 
-// LoudnessStats holds measured loudnorm stats
-type LoudnessStats struct {
-	InputI         float64 // measured integrated loudness
-	InputTP        float64
-	InputLRA       float64
-	InputThresh    float64
-	MeasuredI      float64
-	MeasuredTP     float64
-	MeasuredLRA    float64
-	MeasuredThresh float64
-	// raw lines
-	Raw map[string]string
+// GetChannels returns the channel count of the first audio stream via ffprobe
+func GetChannels(ctx context.Context, path string) (int, error) {
+	return probeStreamInt(ctx, path, "channels")
+}
+
+// GetSampleRate returns the sample rate of the first audio stream via ffprobe
+func GetSampleRate(ctx context.Context, path string) (int, error) {
+	return probeStreamInt(ctx, path, "sample_rate")
+}
+
+// GetChannelLayout returns the channel layout name (e.g. "mono", "stereo") of the
+// first audio stream via ffprobe; empty when the container doesn't record one
+func GetChannelLayout(ctx context.Context, path string) (string, error) {
+	return probeStream(ctx, path, "channel_layout")
+}
+
+// probeStreamInt reads an integer stream entry of the first audio stream
+func probeStreamInt(ctx context.Context, path, entry string) (int, error) {
+	s, err := probeStream(ctx, path, entry)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", entry, err)
+	}
+	return n, nil
+}
+
+// probeStream reads a stream entry of the first audio stream
+func probeStream(ctx context.Context, path, entry string) (string, error) {
+	ffprobePath, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return "", fmt.Errorf("ffprobe not found in PATH: %w", err)
+	}
+	args := []string{"-v", "error", "-select_streams", "a:0", "-show_entries", "stream=" + entry, "-of", "default=noprint_wrappers=1:nokey=1", path}
+	cmd := newCmd(ctx, ffprobePath, args...)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := runCmd(cmd); err != nil {
+		return "", fmt.Errorf("ffprobe failed: %w - stderr: %s", err, stderr.String())
+	}
+	return strings.TrimSpace(out.String()), nil
 }
 
-// MeasureLoudness runs ffmpeg single-pass loudnorm with print_format=summary and parses key metrics
-// It returns a map with measured values (I, TP, LRA, threshold) from FFmpeg output.
-func MeasureLoudness(ctx context.Context, path string, targetLufs float64) (map[string]float64, error) {
+// Loudness is an EBU R128 measurement of a file by ffmpeg loudnorm. The
+// Output fields are what loudnorm would normalize the file to, see
+// MeasureLoudness.
+type Loudness struct {
+	Integrated       float64 `json:"integrated_lufs"`
+	TruePeak         float64 `json:"true_peak_dbtp"`
+	LRA              float64 `json:"lra_lu"`
+	Threshold        float64 `json:"threshold_lufs"`
+	OutputIntegrated float64 `json:"output_integrated_lufs"`
+	OutputTruePeak   float64 `json:"output_true_peak_dbtp"`
+	OutputLRA        float64 `json:"output_lra_lu"`
+	OutputThreshold  float64 `json:"output_threshold_lufs"`
+	TargetOffset     float64 `json:"target_offset_lu"`
+}
+
+// UnmarshalJSON also reads the input_i, input_tp, input_lra and output_i keys
+// loudness was stored with before it had a type, so the analysis of older jobs
+// still decodes
+func (l *Loudness) UnmarshalJSON(b []byte) error {
+	type loudness Loudness
+	var legacy struct {
+		loudness
+		InputI   *float64 `json:"input_i"`
+		InputTP  *float64 `json:"input_tp"`
+		InputLRA *float64 `json:"input_lra"`
+		OutputI  *float64 `json:"output_i"`
+	}
+	if err := json.Unmarshal(b, &legacy); err != nil {
+		return err
+	}
+	*l = Loudness(legacy.loudness)
+	for _, f := range []struct {
+		src *float64
+		dst *float64
+	}{
+		{legacy.InputI, &l.Integrated},
+		{legacy.InputTP, &l.TruePeak},
+		{legacy.InputLRA, &l.LRA},
+		{legacy.OutputI, &l.OutputIntegrated},
+	} {
+		if f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+	return nil
+}
+
+// MeasureLoudness runs ffmpeg single-pass loudnorm with print_format=summary and
+// parses the measurement. The integrated loudness, true peak, LRA and threshold
+// are those of the file; silent files, which have no integrated loudness, fail.
+func MeasureLoudness(ctx context.Context, path string, targetLufs float64) (*Loudness, error) {
 	ffmpegPath, err := exec.LookPath("ffmpeg")
 	if err != nil {
 		return nil, fmt.Errorf("ffmpeg not found: %w", err)
@@ -62,13 +148,13 @@ func MeasureLoudness(ctx context.Context, path string, targetLufs float64) (map[
 
 	// Using loudnorm with print_format=summary; single-pass measure only
 	// Example: ffmpeg -i input.wav -af loudnorm=I=-16:TP=-1.5:LRA=7:print_format=summary -f null -
-	args := []string{"-i", path, "-af", fmt.Sprintf("loudnorm=I=%v:TP=-1.5:LRA=7:print_format=summary", targetLufs), "-f", "null", "-"}
-	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	args := []string{"-i", path, "-af", fmt.Sprintf("loudnorm=I=%v:TP=%v:LRA=%v:print_format=summary", targetLufs, loudnormTP, loudnormLRA), "-f", "null", "-"}
+	cmd := newCmd(ctx, ffmpegPath, args...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
 	// ffmpeg prints summary to stderr
-	if err := cmd.Run(); err != nil {
+	if err := runCmd(cmd); err != nil {
 		// ffmpeg returns non-zero when output is null; still parse stderr
 		// i'll attempt to parse stderr even on error
 	}
@@ -77,8 +163,77 @@ func MeasureLoudness(ctx context.Context, path string, targetLufs float64) (map[
 	return parseLoudnormSummary(out)
 }
 
-// parseLoudnormSummary reads the ffmpeg loudnorm summary and returns a map of measured values
-func parseLoudnormSummary(s string) (map[string]float64, error) {
+// LoudnormMeasurement holds the first-pass loudnorm values that the second
+// (apply) pass needs for accurate, linear normalization
+type LoudnormMeasurement struct {
+	InputI       float64
+	InputTP      float64
+	InputLRA     float64
+	InputThresh  float64
+	TargetOffset float64
+}
+
+// MeasureLoudnorm runs the loudnorm analysis pass with print_format=json.
+// preFilter (e.g. the denoiser) is applied before measuring so the measurement
+// matches the signal the apply pass will normalize; pass "" to measure the file as is.
+func MeasureLoudnorm(ctx context.Context, path, preFilter string, targetLufs float64) (*LoudnormMeasurement, error) {
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg not found: %w", err)
+	}
+	filter := fmt.Sprintf("loudnorm=I=%v:TP=%v:LRA=%v:print_format=json", targetLufs, loudnormTP, loudnormLRA)
+	if preFilter != "" {
+		filter = preFilter + "," + filter
+	}
+	cmd := newCmd(ctx, ffmpegPath, "-hide_banner", "-nostats", "-i", path, "-af", filter, "-f", "null", "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := runCmd(cmd); err != nil {
+		return nil, fmt.Errorf("loudnorm measure failed: %w - stderr: %s", err, stderr.String())
+	}
+	return parseLoudnormJSON(stderr.String())
+}
+
+// parseLoudnormJSON extracts the JSON block loudnorm prints at the end of stderr
+func parseLoudnormJSON(s string) (*LoudnormMeasurement, error) {
+	start := strings.LastIndex(s, "{")
+	end := strings.LastIndex(s, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("loudnorm json not found in ffmpeg output")
+	}
+	var raw struct {
+		InputI       string `json:"input_i"`
+		InputTP      string `json:"input_tp"`
+		InputLRA     string `json:"input_lra"`
+		InputThresh  string `json:"input_thresh"`
+		TargetOffset string `json:"target_offset"`
+	}
+	if err := json.Unmarshal([]byte(s[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("parse loudnorm json: %w", err)
+	}
+	m := &LoudnormMeasurement{}
+	for _, f := range []struct {
+		src string
+		dst *float64
+	}{
+		{raw.InputI, &m.InputI},
+		{raw.InputTP, &m.InputTP},
+		{raw.InputLRA, &m.InputLRA},
+		{raw.InputThresh, &m.InputThresh},
+		{raw.TargetOffset, &m.TargetOffset},
+	} {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f.src), 64)
+		if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+			// silent input yields -inf; linear normalization is impossible then
+			return nil, fmt.Errorf("loudnorm returned unusable value %q", f.src)
+		}
+		*f.dst = v
+	}
+	return m, nil
+}
+
+// parseLoudnormSummary reads the ffmpeg loudnorm summary into a Loudness
+func parseLoudnormSummary(s string) (*Loudness, error) {
 	// Example snippet contains lines like:
 	// Input Integrated:    -24.8 LUFS
 	// Input True Peak:     -0.3 dBTP
@@ -87,41 +242,137 @@ func parseLoudnormSummary(s string) (map[string]float64, error) {
 	// Target offset: 7.0 LU
 	// Output Integrated:   -16.0 LUFS
 
-	result := map[string]float64{}
-	reFloat := regexp.MustCompile(`(-?\d+(\.\d+)?)`)
+	l := &Loudness{}
+	fields := map[string]*float64{
+		"Input Integrated:":  &l.Integrated,
+		"Input True Peak:":   &l.TruePeak,
+		"Input LRA:":         &l.LRA,
+		"Input Threshold:":   &l.Threshold,
+		"Output Integrated:": &l.OutputIntegrated,
+		"Output True Peak:":  &l.OutputTruePeak,
+		"Output LRA:":        &l.OutputLRA,
+		"Output Threshold:":  &l.OutputThreshold,
+		"Target Offset:":     &l.TargetOffset,
+	}
+	// -inf (silence) has no digits and is left out
+	reFloat := regexp.MustCompile(`[-+]?\d+(\.\d+)?`)
+	haveIntegrated := false
 
 	scanner := bufio.NewScanner(strings.NewReader(s))
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		switch {
-		case strings.HasPrefix(line, "Input Integrated:"):
-			if m := reFloat.FindString(line); m != "" {
-				if v, err := strconv.ParseFloat(m, 64); err == nil {
-					result["input_i"] = v
-				}
+		for prefix, dst := range fields {
+			if !strings.HasPrefix(line, prefix) {
+				continue
 			}
-		case strings.HasPrefix(line, "Input True Peak:"):
-			if m := reFloat.FindString(line); m != "" {
+			if m := reFloat.FindString(line[len(prefix):]); m != "" {
 				if v, err := strconv.ParseFloat(m, 64); err == nil {
-					result["input_tp"] = v
-				}
-			}
-		case strings.HasPrefix(line, "Input LRA:"):
-			if m := reFloat.FindString(line); m != "" {
-				if v, err := strconv.ParseFloat(m, 64); err == nil {
-					result["input_lra"] = v
-				}
-			}
-		case strings.HasPrefix(line, "Output Integrated:"):
-			if m := reFloat.FindString(line); m != "" {
-				if v, err := strconv.ParseFloat(m, 64); err == nil {
-					result["output_i"] = v
+					*dst = v
+					haveIntegrated = haveIntegrated || dst == &l.Integrated
 				}
 			}
 		}
 	}
-	if len(result) == 0 {
-		return nil, fmt.Errorf("failed to parse loudnorm summary (no metrics found)")
+	if !haveIntegrated {
+		return nil, fmt.Errorf("failed to parse loudnorm summary (no integrated loudness found)")
+	}
+	return l, nil
+}
+
+// MediaInfo is the container and first audio stream of a file as reported by
+// ffprobe; fields ffprobe leaves out stay zero
+type MediaInfo struct {
+	Container     string  `json:"container"` // format name, e.g. "wav", "mov,mp4,m4a,3gp,3g2,mj2"
+	Codec         string  `json:"codec"`     // e.g. "pcm_s16le", "pcm_mulaw", "opus"
+	CodecProfile  string  `json:"codec_profile,omitempty"`
+	SampleFormat  string  `json:"sample_format,omitempty"` // e.g. "s16", "fltp"
+	SampleRate    int     `json:"sample_rate"`
+	Channels      int     `json:"channels"`
+	ChannelLayout string  `json:"channel_layout,omitempty"`
+	BitRate       int64   `json:"bit_rate,omitempty"` // of the stream, else of the container, bits/s
+	DurationSec   float64 `json:"duration_sec,omitempty"`
+	SizeBytes     int64   `json:"size_bytes,omitempty"`
+	AudioStreams  int     `json:"audio_streams"`
+	OtherStreams  int     `json:"other_streams,omitempty"` // video, subtitle, data streams
+	Encoder       string  `json:"encoder,omitempty"`       // encoder tag of the container
+}
+
+// ProbeMedia reads the container and audio stream metadata of path with a single
+// ffprobe call
+func ProbeMedia(ctx context.Context, path string) (*MediaInfo, error) {
+	ffprobePath, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe not found in PATH: %w", err)
+	}
+	args := []string{"-v", "error", "-show_format", "-show_streams", "-of", "json", path}
+	cmd := newCmd(ctx, ffprobePath, args...)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := runCmd(cmd); err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w - stderr: %s", err, stderr.String())
+	}
+	return parseProbeJSON(out.Bytes())
+}
+
+// parseProbeJSON builds a MediaInfo from ffprobe -show_format -show_streams -of json
+func parseProbeJSON(b []byte) (*MediaInfo, error) {
+	var raw struct {
+		Streams []struct {
+			CodecType     string `json:"codec_type"`
+			CodecName     string `json:"codec_name"`
+			Profile       string `json:"profile"`
+			SampleFmt     string `json:"sample_fmt"`
+			SampleRate    string `json:"sample_rate"`
+			Channels      int    `json:"channels"`
+			ChannelLayout string `json:"channel_layout"`
+			BitRate       string `json:"bit_rate"`
+		} `json:"streams"`
+		Format struct {
+			FormatName string            `json:"format_name"`
+			Duration   string            `json:"duration"`
+			Size       string            `json:"size"`
+			BitRate    string            `json:"bit_rate"`
+			Tags       map[string]string `json:"tags"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("parse ffprobe json: %w", err)
+	}
+	// ffprobe leaves out what it doesn't know, or reports "N/A"
+	atoi := func(s string) int64 {
+		n, _ := strconv.ParseInt(s, 10, 64)
+		return n
+	}
+	m := &MediaInfo{
+		Container: raw.Format.FormatName,
+		SizeBytes: atoi(raw.Format.Size),
+		BitRate:   atoi(raw.Format.BitRate),
+		Encoder:   raw.Format.Tags["encoder"],
+	}
+	if d, err := strconv.ParseFloat(raw.Format.Duration, 64); err == nil {
+		m.DurationSec = d
+	}
+	for _, s := range raw.Streams {
+		if s.CodecType != "audio" {
+			m.OtherStreams++
+			continue
+		}
+		m.AudioStreams++
+		if m.AudioStreams > 1 {
+			continue
+		}
+		m.Codec = s.CodecName
+		if s.Profile != "" && s.Profile != "unknown" {
+			m.CodecProfile = s.Profile
+		}
+		m.SampleFormat = s.SampleFmt
+		m.SampleRate = int(atoi(s.SampleRate))
+		m.Channels = s.Channels
+		m.ChannelLayout = s.ChannelLayout
+		if br := atoi(s.BitRate); br > 0 {
+			m.BitRate = br
+		}
 	}
-	return result, nil
+	return m, nil
 }
@@ -0,0 +1,72 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// fft computes the discrete Fourier transform of x in place (iterative radix-2).
+// len(x) must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*wk
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				wk *= w
+			}
+		}
+	}
+}
+
+// ifft computes the inverse transform of x in place
+func ifft(x []complex128) {
+	for i := range x {
+		x[i] = cmplx.Conj(x[i])
+	}
+	fft(x)
+	n := complex(float64(len(x)), 0)
+	for i := range x {
+		x[i] = cmplx.Conj(x[i]) / n
+	}
+}
+
+// hann returns a periodic Hann window of length n
+func hann(n int) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+	}
+	return w
+}
+
+// powerSpectrum returns |X[k]|² for k = 0..n/2 of the windowed frame
+func powerSpectrum(frame, window []float64, buf []complex128) []float64 {
+	for i := range buf {
+		v := 0.0
+		if i < len(frame) {
+			v = frame[i] * window[i]
+		}
+		buf[i] = complex(v, 0)
+	}
+	fft(buf)
+	ps := make([]float64, len(buf)/2+1)
+	for k := range ps {
+		re, im := real(buf[k]), imag(buf[k])
+		ps[k] = re*re + im*im
+	}
+	return ps
+}
@@ -0,0 +1,113 @@
+package audio
+
+import (
+	"context"
+	"math"
+	"math/bits"
+)
+
+const (
+	fpRate   = 5000
+	fpFrame  = 2048 // ~0.41 s
+	fpHop    = 256  // ~51 ms between sub-fingerprints
+	fpBands  = 33   // log-spaced bands, 32 bits of band energy differences
+	fpLowHz  = 300.0
+	fpHighHz = 2000.0
+
+	// FingerprintHopSec is the time between two sub-fingerprints
+	FingerprintHopSec = float64(fpHop) / fpRate
+	// FingerprintMaxBER is the bit error rate below which two fingerprints are the
+	// same recording; unrelated audio stays around 0.5
+	FingerprintMaxBER = 0.35
+
+	fpMinOverlap  = 100  // sub-fingerprints (~5 s) two prints must share to be compared
+	fpProbeFrames = 1200 // ~1 minute of a print is compared, see FingerprintBER
+	fpMaxShift    = 98   // sub-fingerprints (~5 s) two copies of a recording may be offset by
+)
+
+// Fingerprint computes an acoustic fingerprint of the file (Haitsma-Kalker): one
+// 32 bit sub-fingerprint per hop, each bit the sign of the change of the energy
+// difference of two neighbouring bands between frames. The bits survive
+// re-encoding, resampling and level changes, so copies of a recording ingested
+// from different systems produce nearly the same print.
+func Fingerprint(ctx context.Context, path string) ([]uint32, error) {
+	window := hann(fpFrame)
+	buf := make([]complex128, fpFrame)
+	edges := make([]int, fpBands+1)
+	for i := range edges {
+		hz := fpLowHz * math.Pow(fpHighHz/fpLowHz, float64(i)/fpBands)
+		edges[i] = int(hz * fpFrame / fpRate)
+	}
+
+	frame := make([]float64, 0, fpFrame+fpHop)
+	var prev []float64
+	var fp []uint32
+	err := streamPCM(ctx, path, fpRate, fpHop, func(chunk []float64) error {
+		frame = append(frame, chunk...)
+		if len(frame) < fpFrame {
+			return nil
+		}
+		if n := len(frame) - fpFrame; n > 0 {
+			copy(frame, frame[n:])
+			frame = frame[:fpFrame]
+		}
+		spec := powerSpectrum(frame, window, buf)
+		energy := make([]float64, fpBands)
+		for b := 0; b < fpBands; b++ {
+			for k := edges[b]; k < edges[b+1]; k++ {
+				energy[b] += spec[k]
+			}
+		}
+		if prev != nil {
+			var sub uint32
+			for b := 0; b < fpBands-1; b++ {
+				if (energy[b]-energy[b+1])-(prev[b]-prev[b+1]) > 0 {
+					sub |= 1 << b
+				}
+			}
+			fp = append(fp, sub)
+		}
+		prev = energy
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fp, nil
+}
+
+// FingerprintBER returns the lowest bit error rate between a and b over the
+// offsets of up to fpMaxShift; 1 when the prints are too short to compare.
+// Only a stretch from the middle of a is compared: besides being faster, it
+// keeps calls that merely start with the same IVR greeting apart.
+func FingerprintBER(a, b []uint32) float64 {
+	start := 0
+	if len(a) > fpProbeFrames {
+		start = (len(a) - fpProbeFrames) / 2
+		a = a[start : start+fpProbeFrames]
+	}
+	minOverlap := min(fpMinOverlap, len(a), len(b))
+	if minOverlap < fpMinOverlap/5 {
+		return 1
+	}
+	maxShift := fpMaxShift
+	best := 1.0
+	for shift := -maxShift; shift <= maxShift; shift++ {
+		errs, n := 0, 0
+		for i := range a {
+			j := start + i + shift
+			if j < 0 || j >= len(b) {
+				continue
+			}
+			errs += bits.OnesCount32(a[i] ^ b[j])
+			n++
+		}
+		if n < minOverlap {
+			continue
+		}
+		if ber := float64(errs) / float64(32*n); ber < best {
+			best = ber
+		}
+	}
+	return best
+}
@@ -0,0 +1,69 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+// What a quality gate does with a job whose output misses a threshold
+const (
+	GateWarn = "warn" // finish the job as completed_with_warnings
+	GateFail = "fail" // fail the job with the quality reasons
+)
+
+// QualityGate holds the minimum acceptable quality of processed outputs; a zero
+// threshold is not checked
+type QualityGate struct {
+	MinSNR              float64 `yaml:"min_snr_db"`
+	MaxNoiseDB          float64 `yaml:"max_noise_db"`          // estimated background noise of the output (QualityMetrics.NoiseLevel), dB
+	LoudnessToleranceLU float64 `yaml:"loudness_tolerance_lu"` // allowed distance from the target loudness
+	Action              string  `yaml:"action"`                // GateWarn (default) or GateFail
+}
+
+// GateViolation is a threshold an output missed
+type GateViolation struct {
+	Check  string  `json:"check"` // snr, noise or loudness
+	Value  float64 `json:"value"`
+	Limit  float64 `json:"limit"`
+	Reason string  `json:"reason"`
+}
+
+// Enabled reports whether any threshold is set
+func (g QualityGate) Enabled() bool {
+	return g.MinSNR != 0 || g.MaxNoiseDB != 0 || g.LoudnessToleranceLU != 0
+}
+
+// Validate rejects gates the worker can't apply
+func (g QualityGate) Validate() error {
+	switch g.Action {
+	case "", GateWarn, GateFail:
+	default:
+		return fmt.Errorf("unknown action %q (want %s or %s)", g.Action, GateWarn, GateFail)
+	}
+	if g.MaxNoiseDB > 0 {
+		return fmt.Errorf("max_noise_db %.1f must be negative", g.MaxNoiseDB)
+	}
+	if g.LoudnessToleranceLU < 0 {
+		return fmt.Errorf("loudness_tolerance_lu %.1f must not be negative", g.LoudnessToleranceLU)
+	}
+	return nil
+}
+
+// Check returns the thresholds the output measurements miss. Measurements that
+// are nil (not available) pass.
+func (g QualityGate) Check(snr, noiseDB, loudness *float64, targetLUFS float64) []GateViolation {
+	var out []GateViolation
+	if g.MinSNR != 0 && snr != nil && *snr < g.MinSNR {
+		out = append(out, GateViolation{Check: "snr", Value: *snr, Limit: g.MinSNR,
+			Reason: fmt.Sprintf("snr %.1f dB below %.1f dB", *snr, g.MinSNR)})
+	}
+	if g.MaxNoiseDB != 0 && noiseDB != nil && *noiseDB > g.MaxNoiseDB {
+		out = append(out, GateViolation{Check: "noise", Value: *noiseDB, Limit: g.MaxNoiseDB,
+			Reason: fmt.Sprintf("noise level %.1f dB above %.1f dB", *noiseDB, g.MaxNoiseDB)})
+	}
+	if g.LoudnessToleranceLU != 0 && loudness != nil && math.Abs(*loudness-targetLUFS) > g.LoudnessToleranceLU {
+		out = append(out, GateViolation{Check: "loudness", Value: *loudness, Limit: g.LoudnessToleranceLU,
+			Reason: fmt.Sprintf("loudness %.1f LUFS more than %.1f LU off the %.1f LUFS target", *loudness, g.LoudnessToleranceLU, targetLUFS)})
+	}
+	return out
+}
@@ -0,0 +1,70 @@
+package audio
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// KeywordList maps a category (e.g. "churn") to its terms; a term may be a phrase
+type KeywordList map[string][]string
+
+// KeywordHit is an occurrence of a listed term in a transcript
+type KeywordHit struct {
+	Term     string  `json:"term"`
+	Category string  `json:"category"`
+	StartSec float64 `json:"start_sec"`
+	EndSec   float64 `json:"end_sec"`
+}
+
+// SpotKeywords finds the terms of list in the transcript words. Matching ignores
+// case and punctuation; phrases match consecutive words.
+func SpotKeywords(words []Word, list KeywordList) []KeywordHit {
+	norm := make([]string, len(words))
+	for i, w := range words {
+		norm[i] = normalizeWord(w.Word)
+	}
+	var hits []KeywordHit
+	for category, terms := range list {
+		for _, term := range terms {
+			phrase := strings.Fields(term)
+			for i := range phrase {
+				phrase[i] = normalizeWord(phrase[i])
+			}
+			if len(phrase) == 0 {
+				continue
+			}
+			for i := 0; i+len(phrase) <= len(norm); i++ {
+				if matchAt(norm, i, phrase) {
+					hits = append(hits, KeywordHit{
+						Term:     term,
+						Category: category,
+						StartSec: words[i].StartSec,
+						EndSec:   words[i+len(phrase)-1].EndSec,
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].StartSec < hits[j].StartSec })
+	return hits
+}
+
+func matchAt(words []string, at int, phrase []string) bool {
+	for j, p := range phrase {
+		if words[at+j] != p {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeWord lowercases w and drops everything but letters and digits
+func normalizeWord(w string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, w)
+}
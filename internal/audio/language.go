@@ -0,0 +1,48 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// LanguageGuess is the detected spoken language of a recording
+type LanguageGuess struct {
+	Language   string  `json:"language"`   // ISO 639-1 code, e.g. "en"
+	Confidence float64 `json:"confidence"` // 0..1
+}
+
+// LanguageDetector identifies the spoken language of a recording
+type LanguageDetector interface {
+	DetectLanguage(ctx context.Context, path string) (*LanguageGuess, error)
+}
+
+// NewLanguageDetector builds a detector from a spec (see jsonTool), e.g.
+// "cmd:python3 tools/detect_language.py" or an http(s) classifier service
+// answering {"language": "de", "confidence": 0.93}. An empty spec returns nil.
+func NewLanguageDetector(spec string) (LanguageDetector, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	t, err := parseJSONTool("language detector", spec)
+	if err != nil {
+		return nil, err
+	}
+	return toolLanguage{t}, nil
+}
+
+type toolLanguage struct {
+	tool *jsonTool
+}
+
+func (d toolLanguage) DetectLanguage(ctx context.Context, path string) (*LanguageGuess, error) {
+	var g LanguageGuess
+	if err := d.tool.call(ctx, path, &g); err != nil {
+		return nil, fmt.Errorf("language detection: %w", err)
+	}
+	g.Language = strings.ToLower(strings.TrimSpace(g.Language))
+	if g.Language == "" {
+		return nil, fmt.Errorf("language detection: no language in answer")
+	}
+	return &g, nil
+}
@@ -0,0 +1,56 @@
+//go:build linux
+
+package audio
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/metrics"
+)
+
+// setProcessGroup starts the child in its own process group and makes context
+// cancellation kill the whole group, including anything ffmpeg/python spawned.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// limitCommand wraps the command in nice and prlimit, so the child is reniced
+// and its address space capped before it execs rather than after it started.
+// The real tool follows "--" in the arguments. A wrapper missing from PATH is
+// counted and skipped: limits are best effort, the job still runs.
+func limitCommand(path string, args []string, limits ResourceLimits) (string, []string) {
+	var wrap []string
+	if limits.MaxMemBytes > 0 {
+		if _, err := exec.LookPath("prlimit"); err == nil {
+			wrap = append(wrap, "prlimit", "--as="+strconv.FormatInt(limits.MaxMemBytes, 10))
+		} else {
+			metrics.ChildLimitErrors.Inc()
+		}
+	}
+	if limits.Nice > 0 {
+		if _, err := exec.LookPath("nice"); err == nil {
+			wrap = append([]string{"nice", "-n", strconv.Itoa(limits.Nice)}, wrap...)
+		} else {
+			metrics.ChildLimitErrors.Inc()
+		}
+	}
+	if len(wrap) == 0 {
+		return path, args
+	}
+	wrapper, _ := exec.LookPath(wrap[0])
+	return wrapper, append(append(wrap[1:], "--", path), args...)
+}
+
+// maxRSSBytes returns the peak resident set size of a finished child
+func maxRSSBytes(ps *os.ProcessState) int64 {
+	if ru, ok := ps.SysUsage().(*syscall.Rusage); ok {
+		return ru.Maxrss * 1024 // kilobytes on linux
+	}
+	return 0
+}
@@ -0,0 +1,33 @@
+//go:build linux
+
+package audio
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestLimitCommand(t *testing.T) {
+	for _, tool := range []string{"nice", "prlimit", "sh"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not in PATH", tool)
+		}
+	}
+	ctx := WithLimits(context.Background(), ResourceLimits{Nice: 5, MaxMemBytes: 1 << 30})
+	// the child reports the limits it was started with, so they were in place before exec
+	cmd := newCmd(ctx, "/bin/sh", "-c", "nice; ulimit -v")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := runCmd(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Fields(out.String()), []string{"5", "1048576"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("niceness and address space %v, want %v", got, want)
+	}
+	if tool := cmdTool(cmd); tool != "sh" {
+		t.Errorf("tool %q, want sh", tool)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package audio
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op outside linux; cancellation kills the direct child only
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// limitCommand is a no-op outside linux; -threads still applies through newCmd
+func limitCommand(path string, args []string, limits ResourceLimits) (string, []string) {
+	return path, args
+}
+
+func maxRSSBytes(ps *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,51 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+)
+
+// MOSScore is a non-intrusive (reference free) quality estimate, DNSMOS style
+type MOSScore struct {
+	MOS float64  `json:"mos"`           // overall quality, 1..5
+	SIG *float64 `json:"sig,omitempty"` // speech signal quality, when the model reports it
+	BAK *float64 `json:"bak,omitempty"` // background noise quality, when the model reports it
+}
+
+// MOSEstimator scores a processed file without a reference
+type MOSEstimator interface {
+	Score(ctx context.Context, path string) (*MOSScore, error)
+}
+
+// NewMOSEstimator builds an estimator from a spec:
+//
+//	http(s)://host/path   POST the file to a model server
+//	cmd:<command> [args]  run a subprocess with the file path appended
+//
+// Both must answer with the MOSScore JSON, e.g. {"mos": 3.6, "sig": 3.9, "bak": 4.1}.
+// An empty spec returns nil (scoring disabled).
+func NewMOSEstimator(spec string) (MOSEstimator, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	t, err := parseJSONTool("mos", spec)
+	if err != nil {
+		return nil, err
+	}
+	return toolMOS{t}, nil
+}
+
+type toolMOS struct {
+	tool *jsonTool
+}
+
+func (m toolMOS) Score(ctx context.Context, path string) (*MOSScore, error) {
+	var s MOSScore
+	if err := m.tool.call(ctx, path, &s); err != nil {
+		return nil, fmt.Errorf("mos: %w", err)
+	}
+	if s.MOS < 1 || s.MOS > 5 {
+		return nil, fmt.Errorf("mos %.2f out of range 1..5", s.MOS)
+	}
+	return &s, nil
+}
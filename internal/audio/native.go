@@ -0,0 +1,283 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+)
+
+// Processing engines
+const (
+	EngineFFmpeg = "ffmpeg" // default, full feature set
+	EngineNative = "native" // pure Go for WAV inputs: resample, loudness, gain, limiter
+)
+
+// ParseEngine validates an engine name; empty means ffmpeg
+func ParseEngine(name string) (string, error) {
+	switch name {
+	case "", EngineFFmpeg:
+		return EngineFFmpeg, nil
+	case EngineNative:
+		return EngineNative, nil
+	}
+	return "", fmt.Errorf("unknown engine %q (want %s or %s)", name, EngineFFmpeg, EngineNative)
+}
+
+// ProcessNative is the pure-Go pipeline for PCM WAV inputs, for deployments
+// without ffmpeg/ffprobe: decode, downmix to mono, resample to opts.SampleRate,
+// normalize to opts.TargetLUFS (EBU R128 integrated loudness, one linear gain)
+// and apply the limiter. Denoising, the compressor and the optional stages
+// need ffmpeg and are skipped. The output is always a mono 16-bit WAV.
+func ProcessNative(ctx context.Context, inputPath, outputPath string, opts ProcessOptions) (*Stats, error) {
+	if opts.OutputFormat != "" && opts.OutputFormat != "wav" {
+		return nil, fmt.Errorf("native engine only writes wav, not %s", opts.OutputFormat)
+	}
+	if opts.Channels > 1 {
+		log.Printf("native engine writes mono output, ignoring channels=%d", opts.Channels)
+	}
+	x, rate, err := readWAVMono(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("native engine: %s: %w", filepath.Base(inputPath), err)
+	}
+	if len(x) == 0 {
+		return nil, fmt.Errorf("native engine: %s has no samples", filepath.Base(inputPath))
+	}
+	noiseLevel := meanVolumeDB(x)
+
+	if opts.SampleRate > 0 && opts.SampleRate != rate {
+		x = resample(x, rate, opts.SampleRate)
+		rate = opts.SampleRate
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	before := integratedLoudness(x, rate)
+	if !math.IsInf(before, -1) {
+		gain := math.Pow(10, (opts.TargetLUFS-before)/20)
+		for i := range x {
+			x[i] *= gain
+		}
+	}
+	if opts.UseLimiter {
+		limit(x, rate, math.Pow(10, opts.Limiter.ThresholdDB/20))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	out, err := createWAV(outputPath, rate)
+	if err != nil {
+		return nil, err
+	}
+	if err := out.Write(x); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		DurationSec: float64(len(x)) / float64(rate),
+		NoiseLevel:  noiseLevel,
+		FilterChain: nativeChain(opts),
+	}
+	if after := integratedLoudness(x, rate); !math.IsInf(before, -1) && !math.IsInf(after, -1) {
+		stats.Loudness = &Loudness{Integrated: before, OutputIntegrated: after}
+	}
+	return stats, nil
+}
+
+// nativeChain describes the stages ProcessNative ran in ffmpeg filter terms
+func nativeChain(opts ProcessOptions) string {
+	chain := fmt.Sprintf("native:loudnorm=I=%v", opts.TargetLUFS)
+	if opts.SampleRate > 0 {
+		chain = fmt.Sprintf("native:aresample=%d,loudnorm=I=%v", opts.SampleRate, opts.TargetLUFS)
+	}
+	if opts.UseLimiter {
+		chain += fmt.Sprintf(",limiter=%vdB", opts.Limiter.ThresholdDB)
+	}
+	return chain
+}
+
+// meanVolumeDB is the RMS level in dBFS, like ffmpeg volumedetect's mean_volume
+func meanVolumeDB(x []float64) float64 {
+	sum := 0.0
+	for _, v := range x {
+		sum += v * v
+	}
+	return 10 * math.Log10(sum/float64(len(x))+1e-12)
+}
+
+// biquad is a direct form I second order filter
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting returns the BS.1770 pre-filter (high shelf + high pass) for rate
+func kWeighting(rate int) (*biquad, *biquad) {
+	fs := float64(rate)
+	f0, g, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, g/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := &biquad{
+		b0: (vh + vb*k/q + k*k) / a0, b1: 2 * (k*k - vh) / a0, b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0, a2: (1 - k/q + k*k) / a0,
+	}
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	hp := &biquad{b0: 1, b1: -2, b2: 1, a1: 2 * (k*k - 1) / a0, a2: (1 - k/q + k*k) / a0}
+	return shelf, hp
+}
+
+// integratedLoudness measures EBU R128 / BS.1770 integrated loudness (LUFS) of a
+// mono signal: K-weighting, 400 ms blocks with 75% overlap, absolute gate at
+// -70 LUFS and relative gate 10 LU below the ungated level. Returns -Inf for silence.
+func integratedLoudness(x []float64, rate int) float64 {
+	shelf, hp := kWeighting(rate)
+	sq := make([]float64, len(x))
+	for i, v := range x {
+		y := hp.process(shelf.process(v))
+		sq[i] = y * y
+	}
+	block, step := rate*4/10, rate/10
+	var blocks []float64
+	for start := 0; start+block <= len(sq); start += step {
+		sum := 0.0
+		for _, v := range sq[start : start+block] {
+			sum += v
+		}
+		blocks = append(blocks, sum/float64(block))
+	}
+	lufs := func(z float64) float64 { return -0.691 + 10*math.Log10(z) }
+	gated := func(threshold float64) (float64, int) {
+		sum, n := 0.0, 0
+		for _, z := range blocks {
+			if z > 0 && lufs(z) > threshold {
+				sum += z
+				n++
+			}
+		}
+		return sum, n
+	}
+	sum, n := gated(-70)
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	sum, n = gated(lufs(sum/float64(n)) - 10)
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	return lufs(sum / float64(n))
+}
+
+// resample converts x from rate `from` to `to` with a Hann windowed sinc
+// interpolator, low-passing at the lower of the two Nyquist frequencies
+func resample(x []float64, from, to int) []float64 {
+	ratio := float64(to) / float64(from)
+	n := int(float64(len(x)) * ratio)
+	cutoff := math.Min(1, ratio) * 0.95
+	const taps = 16 // zero crossings on each side
+	width := int(math.Ceil(taps / cutoff))
+	out := make([]float64, n)
+	for i := range out {
+		t := float64(i) / ratio
+		center := int(t)
+		sum := 0.0
+		for j := center - width + 1; j <= center+width; j++ {
+			if j < 0 || j >= len(x) {
+				continue
+			}
+			d := t - float64(j)
+			w := 0.5 + 0.5*math.Cos(math.Pi*d/float64(width))
+			sum += x[j] * cutoff * sinc(cutoff*d) * w
+		}
+		out[i] = sum
+	}
+	return out
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// limit keeps |x| at or below threshold with a 5 ms look-ahead peak limiter and
+// 50 ms release. The gain is the minimum required gain around each sample
+// smoothed over the look-ahead, so it ramps into peaks instead of stepping.
+func limit(x []float64, rate int, threshold float64) {
+	la := rate * 5 / 1000
+	if la < 1 {
+		la = 1
+	}
+	req := make([]float64, len(x))
+	for i, v := range x {
+		req[i] = 1
+		if a := math.Abs(v); a > threshold {
+			req[i] = threshold / a
+		}
+	}
+	m := slidingMin(req, la)
+	half := la / 2
+	release := 1 - math.Exp(-1/(float64(rate)*0.05))
+	prev := 1.0
+	// moving average of m over [i-half, i+half]; every m in that window already
+	// covers sample i, so the average never exceeds req[i]
+	sum, cnt := 0.0, 0
+	for j := 0; j <= half && j < len(m); j++ {
+		sum += m[j]
+		cnt++
+	}
+	for i := range x {
+		g := sum / float64(cnt)
+		if up := prev + (1-prev)*release; g > up {
+			g = up
+		}
+		x[i] *= g
+		prev = g
+		if j := i + half + 1; j < len(m) {
+			sum += m[j]
+			cnt++
+		}
+		if j := i - half; j >= 0 {
+			sum -= m[j]
+			cnt--
+		}
+	}
+}
+
+// slidingMin returns min(v[i-w .. i+w]) for every i
+func slidingMin(v []float64, w int) []float64 {
+	out := make([]float64, len(v))
+	var dq []int // indexes with increasing values
+	next := 0
+	for i := range v {
+		for ; next < len(v) && next <= i+w; next++ {
+			for len(dq) > 0 && v[dq[len(dq)-1]] >= v[next] {
+				dq = dq[:len(dq)-1]
+			}
+			dq = append(dq, next)
+		}
+		for dq[0] < i-w {
+			dq = dq[1:]
+		}
+		out[i] = v[dq[0]]
+	}
+	return out
+}
@@ -0,0 +1,311 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sine returns seconds of a sine of freq Hz with peak amplitude amp
+func sine(freq, amp float64, rate int, seconds float64) []float64 {
+	x := make([]float64, int(seconds*float64(rate)))
+	for i := range x {
+		x[i] = amp * math.Sin(2*math.Pi*freq*float64(i)/float64(rate))
+	}
+	return x
+}
+
+// chunk is a RIFF chunk, padded to an even size
+func chunk(id string, body []byte) []byte {
+	b := append([]byte(id), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(body)))
+	b = append(b, body...)
+	if len(body)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// fmtChunk is the body of a fmt chunk
+func fmtChunk(format, channels, rate, bits int) []byte {
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint16(b[0:], uint16(format))
+	binary.LittleEndian.PutUint16(b[2:], uint16(channels))
+	binary.LittleEndian.PutUint32(b[4:], uint32(rate))
+	binary.LittleEndian.PutUint32(b[8:], uint32(rate*channels*bits/8))
+	binary.LittleEndian.PutUint16(b[12:], uint16(channels*bits/8))
+	binary.LittleEndian.PutUint16(b[14:], uint16(bits))
+	return b
+}
+
+// extensible is the body of a WAVE_FORMAT_EXTENSIBLE fmt chunk of sub format format
+func extensible(format, channels, rate, bits int) []byte {
+	b := append(fmtChunk(0xFFFE, channels, rate, bits), make([]byte, 24)...)
+	binary.LittleEndian.PutUint16(b[16:], 22)
+	binary.LittleEndian.PutUint16(b[24:], uint16(format))
+	return b
+}
+
+// riff is a WAVE file of chunks
+func riff(chunks ...[]byte) []byte {
+	body := []byte("WAVE")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	return chunk("RIFF", body)
+}
+
+// pcm encodes interleaved samples
+func pcm(bits int, float bool, samples ...float64) []byte {
+	var b []byte
+	for _, v := range samples {
+		switch {
+		case float:
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(v)))
+		case bits == 16:
+			b = binary.LittleEndian.AppendUint16(b, uint16(int16(v*32767)))
+		case bits == 24:
+			s := int32(v * 8388607)
+			b = append(b, byte(s), byte(s>>8), byte(s>>16))
+		default:
+			b = binary.LittleEndian.AppendUint32(b, uint32(int32(v*2147483647)))
+		}
+	}
+	return b
+}
+
+func writeFile(t *testing.T, b []byte) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "in.wav")
+	if err := os.WriteFile(p, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestReadWAVMono(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		file []byte
+		rate int
+		want []float64
+	}{
+		{"16 bit mono", riff(chunk("fmt ", fmtChunk(1, 1, 8000, 16)), chunk("data", pcm(16, false, 0.5, -0.25, 0))), 8000, []float64{0.5, -0.25, 0}},
+		{"16 bit stereo averaged", riff(chunk("fmt ", fmtChunk(1, 2, 16000, 16)), chunk("data", pcm(16, false, 0.5, -0.5, 1, 0))), 16000, []float64{0, 0.5}},
+		{"24 bit", riff(chunk("fmt ", fmtChunk(1, 1, 48000, 24)), chunk("data", pcm(24, false, 0.75, -0.75))), 48000, []float64{0.75, -0.75}},
+		{"32 bit", riff(chunk("fmt ", fmtChunk(1, 1, 44100, 32)), chunk("data", pcm(32, false, -0.125))), 44100, []float64{-0.125}},
+		{"32 bit float", riff(chunk("fmt ", fmtChunk(3, 1, 8000, 32)), chunk("data", pcm(32, true, 0.3, -1))), 8000, []float64{0.3, -1}},
+		{"extensible", riff(chunk("fmt ", extensible(1, 1, 8000, 16)), chunk("data", pcm(16, false, 0.5))), 8000, []float64{0.5}},
+		{"odd sized chunk skipped", riff(chunk("fmt ", fmtChunk(1, 1, 8000, 16)), chunk("LIST", []byte("abc")), chunk("data", pcm(16, false, 0.5))), 8000, []float64{0.5}},
+		{"truncated last frame dropped", riff(chunk("fmt ", fmtChunk(1, 2, 8000, 16)), chunk("data", pcm(16, false, 0.5, 0.5, 0.5))), 8000, []float64{0.5}},
+		{"no samples", riff(chunk("fmt ", fmtChunk(1, 1, 8000, 16)), chunk("data", nil)), 8000, []float64{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			x, rate, err := readWAVMono(writeFile(t, tc.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rate != tc.rate {
+				t.Errorf("rate %d, want %d", rate, tc.rate)
+			}
+			if len(x) != len(tc.want) {
+				t.Fatalf("samples %v, want %v", x, tc.want)
+			}
+			for i := range x {
+				if math.Abs(x[i]-tc.want[i]) > 1e-4 {
+					t.Fatalf("samples %v, want %v", x, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadWAVMonoMalformed(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		file []byte
+	}{
+		{"empty", nil},
+		{"not RIFF", append([]byte("RIFX\x00\x00\x00\x00WAVE"), chunk("fmt ", fmtChunk(1, 1, 8000, 16))...)},
+		{"not WAVE", chunk("RIFF", []byte("AVI "))},
+		{"no fmt", riff(chunk("data", pcm(16, false, 0.5)))},
+		{"short fmt", riff(chunk("fmt ", fmtChunk(1, 1, 8000, 16)[:14]), chunk("data", pcm(16, false, 0.5)))},
+		{"no data", riff(chunk("fmt ", fmtChunk(1, 1, 8000, 16)))},
+		{"truncated fmt", riff(chunk("fmt ", fmtChunk(1, 1, 8000, 16)))[:30]},
+		{"8 bit", riff(chunk("fmt ", fmtChunk(1, 1, 8000, 8)), chunk("data", []byte{128}))},
+		{"64 bit float", riff(chunk("fmt ", fmtChunk(3, 1, 8000, 64)), chunk("data", make([]byte, 8)))},
+		{"A-law", riff(chunk("fmt ", fmtChunk(6, 1, 8000, 8)), chunk("data", []byte{0xd5}))},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, _, err := readWAVMono(writeFile(t, tc.file)); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
+
+// rms is the RMS of x
+func TestResample(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		from, to int
+		freq     float64
+		gain     float64 // of the tone, 1 when it passes
+	}{
+		{"down, in band", 48000, 16000, 1000, 1},
+		{"up", 8000, 16000, 1000, 1},
+		{"44.1 to 48 kHz", 44100, 48000, 3000, 1},
+		{"down, above the new Nyquist", 48000, 16000, 12000, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			x := sine(tc.freq, 0.5, tc.from, 1)
+			y := resample(x, tc.from, tc.to)
+			if len(y) != tc.to {
+				t.Fatalf("%d samples, want %d", len(y), tc.to)
+			}
+			// leave out the edges, where the filter runs out of input
+			mid := y[tc.to/10 : tc.to*9/10]
+			got := rms(mid) / (0.5 / math.Sqrt2)
+			if math.Abs(got-tc.gain) > 0.01 {
+				t.Errorf("tone gain %.4f, want %v", got, tc.gain)
+			}
+			if tc.gain == 0 {
+				return
+			}
+			// the tone keeps its frequency: compare with a sine generated at the new rate
+			want := sine(tc.freq, 0.5, tc.to, 1)[tc.to/10 : tc.to*9/10]
+			diff := make([]float64, len(mid))
+			for i := range mid {
+				diff[i] = mid[i] - want[i]
+			}
+			if e := rms(diff) / rms(want); e > 0.01 {
+				t.Errorf("relative error %.4f to the tone at the new rate", e)
+			}
+		})
+	}
+}
+
+func TestIntegratedLoudness(t *testing.T) {
+	// BS.1770: a 997 Hz sine at 0 dBFS peak in one channel reads -3.01 LUFS
+	for _, tc := range []struct {
+		name string
+		rate int
+		x    []float64
+		want float64
+	}{
+		{"0 dBFS 48 kHz", 48000, sine(997, 1, 48000, 5), -3.01},
+		{"-20 dBFS 48 kHz", 48000, sine(997, 0.1, 48000, 5), -23.01},
+		{"-20 dBFS 16 kHz", 16000, sine(997, 0.1, 16000, 5), -23.01},
+		{"-30 dBFS 8 kHz", 8000, sine(997, math.Pow(10, -1.5), 8000, 5), -33.01},
+		// silence is below the absolute gate and doesn't pull the level down
+		{"half silence", 48000, append(sine(997, 0.1, 48000, 10), make([]float64, 10*48000)...), -23.01},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := integratedLoudness(tc.x, tc.rate); math.Abs(got-tc.want) > 0.1 {
+				t.Errorf("%.2f LUFS, want %.2f", got, tc.want)
+			}
+		})
+	}
+	if got := integratedLoudness(make([]float64, 48000), 48000); !math.IsInf(got, -1) {
+		t.Errorf("silence: %v LUFS, want -Inf", got)
+	}
+	if got := integratedLoudness(sine(997, 1e-5, 48000, 2), 48000); !math.IsInf(got, -1) {
+		t.Errorf("-100 dBFS: %v LUFS, want -Inf below the absolute gate", got)
+	}
+	if got := integratedLoudness(sine(997, 1, 48000, 0.3), 48000); !math.IsInf(got, -1) {
+		t.Errorf("shorter than a block: %v LUFS, want -Inf", got)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	const rate = 16000
+	for _, tc := range []struct {
+		name      string
+		x         []float64
+		threshold float64
+	}{
+		{"sine above", sine(440, 1, rate, 1), 0.5},
+		{"sine far above", sine(3000, 4, rate, 1), 0.25},
+		{"impulses", func() []float64 {
+			x := sine(200, 0.1, rate, 1)
+			for _, i := range []int{0, 5, 4000, 4001, rate - 1} {
+				x[i] = 1
+			}
+			return x
+		}(), 0.3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			x := append([]float64(nil), tc.x...)
+			limit(x, rate, tc.threshold)
+			for i, v := range x {
+				if math.Abs(v) > tc.threshold+1e-9 {
+					t.Fatalf("sample %d is %v, above the ceiling %v", i, v, tc.threshold)
+				}
+				if math.Abs(v) > math.Abs(tc.x[i])+1e-12 {
+					t.Fatalf("sample %d amplified from %v to %v", i, tc.x[i], v)
+				}
+			}
+		})
+	}
+
+	t.Run("below the ceiling", func(t *testing.T) {
+		x := sine(440, 0.4, rate, 1)
+		want := append([]float64(nil), x...)
+		limit(x, rate, 0.5)
+		for i := range x {
+			if x[i] != want[i] {
+				t.Fatalf("sample %d changed from %v to %v", i, want[i], x[i])
+			}
+		}
+	})
+
+	t.Run("recovers after a peak", func(t *testing.T) {
+		x := sine(440, 0.2, rate, 1)
+		x[100] = 1
+		limit(x, rate, 0.5)
+		// 50 ms release: half a second later the gain is back to 1
+		tail := x[rate/2:]
+		if got, want := rms(tail), 0.2/math.Sqrt2; math.Abs(got-want)/want > 0.001 {
+			t.Errorf("RMS %.5f after the peak, want %.5f", got, want)
+		}
+	})
+}
+
+func TestProcessNative(t *testing.T) {
+	// 48 kHz stereo at -30 dBFS to 16 kHz mono at -16 LUFS
+	x := sine(997, math.Pow(10, -1.5), 48000, 4)
+	var samples []float64
+	for _, v := range x {
+		samples = append(samples, v, v)
+	}
+	in := writeFile(t, riff(chunk("fmt ", fmtChunk(1, 2, 48000, 16)), chunk("data", pcm(16, false, samples...))))
+	out := filepath.Join(t.TempDir(), "out.wav")
+	opts := ProcessOptions{SampleRate: 16000, TargetLUFS: -16, UseLimiter: true}
+	opts.Limiter.ThresholdDB = -1
+	stats, err := ProcessNative(context.Background(), in, out, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	y, rate, err := readWAVMono(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate != 16000 || len(y) != 4*16000 {
+		t.Errorf("%d samples at %d Hz, want 4 s at 16000 Hz", len(y), rate)
+	}
+	if got := integratedLoudness(y, rate); math.Abs(got+16) > 0.2 {
+		t.Errorf("output at %.2f LUFS, want -16", got)
+	}
+	if stats.Loudness == nil || math.Abs(stats.Loudness.Integrated+33.01) > 0.2 {
+		t.Errorf("input loudness %+v, want -33.01 LUFS", stats.Loudness)
+	}
+
+	opts.OutputFormat = "mp3"
+	if _, err := ProcessNative(context.Background(), in, out, opts); err == nil {
+		t.Error("mp3 output: no error")
+	}
+}
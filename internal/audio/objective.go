@@ -0,0 +1,81 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const qualityScoreScript = "tools/quality_score.py"
+
+// ObjectiveScores are intrusive quality scores of a processed file against the
+// signal before denoising. Scores the helper could not compute are nil.
+type ObjectiveScores struct {
+	PESQ     *float64 `json:"pesq,omitempty"`      // -0.5..4.5
+	PESQMode string   `json:"pesq_mode,omitempty"` // "wb" (16 kHz) or "nb"
+	STOI     *float64 `json:"stoi,omitempty"`      // 0..1 intelligibility
+	ESTOI    *float64 `json:"estoi,omitempty"`     // extended STOI, 0..1
+}
+
+// objectiveRate is the rate both signals are compared at (wideband PESQ)
+const objectiveRate = 16000
+
+// ScoreObjective computes PESQ/STOI of processed against reference with the python
+// helper (pesq and pystoi packages). Both are converted to 16 kHz mono WAV first.
+// The two files must share a timeline: outputs with trimmed silence, removed gaps
+// or changed tempo can't be compared sample by sample. The converted files go
+// to dir, empty is the directory of processed.
+func ScoreObjective(ctx context.Context, reference, processed, dir string) (*ObjectiveScores, error) {
+	if _, err := os.Stat(qualityScoreScript); err != nil {
+		return nil, fmt.Errorf("quality helper not found: %w", err)
+	}
+	py, err := exec.LookPath("python")
+	if err != nil {
+		py, err = exec.LookPath("python3")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("python not found in PATH (required for the quality helper)")
+	}
+
+	if dir == "" {
+		dir = filepath.Dir(processed)
+	}
+	stamp := time.Now().UnixNano()
+	ref := filepath.Join(dir, fmt.Sprintf("qs_ref_%d.wav", stamp))
+	deg := filepath.Join(dir, fmt.Sprintf("qs_deg_%d.wav", stamp))
+	defer os.Remove(ref)
+	defer os.Remove(deg)
+	for src, dst := range map[string]string{reference: ref, processed: deg} {
+		if err := runFFmpeg(ctx, "-y", "-i", src, "-ac", "1", "-ar", fmt.Sprint(objectiveRate), "-c:a", "pcm_s16le", "-vn", dst); err != nil {
+			return nil, fmt.Errorf("quality score input: %w", err)
+		}
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	cmd := newCmd(runCtx, py, qualityScoreScript, "--ref", ref, "--deg", deg)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := runCmd(cmd); err != nil {
+		return nil, fmt.Errorf("quality helper failed: %w - output: %s %s", err, strings.TrimSpace(stdout.String()), stderr.String())
+	}
+	var scores ObjectiveScores
+	if err := json.Unmarshal(stdout.Bytes(), &scores); err != nil {
+		return nil, fmt.Errorf("parse quality helper output: %w", err)
+	}
+	return &scores, nil
+}
+
+// SameTimeline reports whether the output of opts keeps the input timeline,
+// which intrusive scores like ScoreObjective need
+func SameTimeline(opts ProcessOptions) bool {
+	return !opts.TrimSilence && !opts.VAD.RemoveGaps && opts.Tempo == 0 &&
+		opts.CustomMode != CustomReplace && !strings.Contains(opts.CustomFilter, "atempo")
+}
@@ -0,0 +1,77 @@
+package audio
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// outputFormat describes how a delivery format is encoded
+type outputFormat struct {
+	Ext            string
+	Muxer          string // ffmpeg -f of streamed outputs, which have no file extension
+	Codec          string
+	ContentType    string
+	DefaultBitrate int      // kbps, 0 for lossless formats
+	Extra          []string // codec specific args
+}
+
+var outputFormats = map[string]outputFormat{
+	"wav":  {Ext: "wav", Muxer: "wav", Codec: "pcm_s16le", ContentType: "audio/wav"},
+	"flac": {Ext: "flac", Muxer: "flac", Codec: "flac", ContentType: "audio/flac", Extra: []string{"-compression_level", "8"}},
+	"mp3":  {Ext: "mp3", Muxer: "mp3", Codec: "libmp3lame", ContentType: "audio/mpeg", DefaultBitrate: 64},
+	// speech-tuned opus in an ogg container
+	"opus": {Ext: "ogg", Muxer: "ogg", Codec: "libopus", ContentType: "audio/ogg", DefaultBitrate: 24, Extra: []string{"-application", "voip"}},
+}
+
+// ParseOutputFormat validates an output format name; empty means wav and "ogg" is an alias of opus
+func ParseOutputFormat(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "":
+		return "wav", nil
+	case "ogg":
+		return "opus", nil
+	}
+	if _, ok := outputFormats[name]; !ok {
+		names := make([]string, 0, len(outputFormats))
+		for n := range outputFormats {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown output format %q (want one of %s)", name, strings.Join(names, ", "))
+	}
+	return name, nil
+}
+
+func formatOf(name string) outputFormat {
+	if f, ok := outputFormats[name]; ok {
+		return f
+	}
+	return outputFormats["wav"]
+}
+
+// OutputExt returns the file extension (without dot) of an output format
+func OutputExt(format string) string {
+	return formatOf(format).Ext
+}
+
+// ContentType returns the MIME type of an output format
+func ContentType(format string) string {
+	return formatOf(format).ContentType
+}
+
+// encoderArgs returns the ffmpeg codec arguments for the output of opts
+func encoderArgs(opts ProcessOptions) []string {
+	f := formatOf(opts.OutputFormat)
+	args := []string{"-c:a", f.Codec}
+	bitrate := opts.BitrateKbps
+	if bitrate <= 0 {
+		bitrate = f.DefaultBitrate
+	}
+	if f.DefaultBitrate > 0 && bitrate > 0 {
+		args = append(args, "-b:a", strconv.Itoa(bitrate)+"k")
+	}
+	return append(args, f.Extra...)
+}
@@ -0,0 +1,71 @@
+package audio
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+)
+
+// streamPCM decodes path to mono signed 16-bit PCM at rate (0 keeps the native rate) and calls fn with
+// consecutive frames of frameLen samples scaled to [-1, 1]. The last frame may be
+// shorter. The frame slice is reused between calls. Returning an error from fn
+// stops decoding. Analyses use this instead of loading whole calls into memory.
+func streamPCM(ctx context.Context, path string, rate, frameLen int, fn func(frame []float64) error) error {
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return fmt.Errorf("ffmpeg not found in PATH: %w", err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	args := []string{"-hide_banner", "-nostats", "-loglevel", "error", "-i", path, "-vn", "-ac", "1"}
+	if rate > 0 {
+		args = append(args, "-ar", strconv.Itoa(rate))
+	}
+	cmd := newCmd(ctx, ffmpegPath, append(args, "-f", "s16le", "-")...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	r := bufio.NewReaderSize(stdout, 64<<10)
+	buf := make([]byte, frameLen*2)
+	frame := make([]float64, frameLen)
+	var streamErr error
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n >= 2 {
+			samples := n / 2
+			for i := 0; i < samples; i++ {
+				frame[i] = float64(int16(binary.LittleEndian.Uint16(buf[2*i:]))) / 32768.0
+			}
+			if ferr := fn(frame[:samples]); ferr != nil {
+				streamErr = ferr
+				break
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			streamErr = err
+			break
+		}
+	}
+	if streamErr != nil {
+		cancel()
+		_ = waitCmd(cmd)
+		return streamErr
+	}
+	if err := waitCmd(cmd); err != nil {
+		return fmt.Errorf("ffmpeg decode failed: %w", err)
+	}
+	return nil
+}
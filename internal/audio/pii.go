@@ -0,0 +1,115 @@
+package audio
+
+import (
+	"regexp"
+	"strings"
+)
+
+// piiPatterns classify a run of spoken digits; anchored, a run is one number
+var piiPatterns = []struct {
+	reason string
+	re     *regexp.Regexp
+}{
+	{"pii_card", regexp.MustCompile(`^\d{13,19}$`)}, // payment card numbers (PAN)
+	{"pii_ssn", regexp.MustCompile(`^\d{9}$`)},      // social security numbers
+}
+
+// spokenDigits are the number words ASR backends write out instead of digits
+var spokenDigits = map[string]string{
+	"zero": "0", "oh": "0", "o": "0",
+	"one": "1", "two": "2", "three": "3", "four": "4", "five": "5",
+	"six": "6", "seven": "7", "eight": "8", "nine": "9",
+}
+
+// digitConnectors may separate the groups of a number without ending it
+var digitConnectors = map[string]bool{"dash": true, "hyphen": true, "space": true}
+
+// PIISpans finds card numbers and SSNs read out in the transcript. Consecutive
+// digit words ("4111", "four", "1111-2222") are joined into one number and
+// matched against piiPatterns; the span covers all words of a matching number.
+func PIISpans(words []Word) []RedactSpan {
+	var spans []RedactSpan
+	start := -1
+	var number strings.Builder
+	flush := func(end int) {
+		if start >= 0 {
+			for _, p := range piiPatterns {
+				if p.re.MatchString(number.String()) {
+					spans = append(spans, RedactSpan{StartSec: words[start].StartSec, EndSec: words[end].EndSec, Reason: p.reason})
+					break
+				}
+			}
+		}
+		start = -1
+		number.Reset()
+	}
+	last := -1 // last digit word of the current number
+	for i, w := range words {
+		token := normalizeWord(w.Word)
+		digits := wordDigits(token)
+		switch {
+		case digits != "":
+			if start < 0 {
+				start = i
+			}
+			number.WriteString(digits)
+			last = i
+		case start >= 0 && digitConnectors[token]:
+		default:
+			flush(last)
+		}
+	}
+	flush(last)
+	return spans
+}
+
+// wordDigits returns the digits a normalized word stands for, "" for other words
+func wordDigits(token string) string {
+	if d, ok := spokenDigits[token]; ok {
+		return d
+	}
+	if token == "" {
+		return ""
+	}
+	for _, r := range token {
+		if r < '0' || r > '9' {
+			return ""
+		}
+	}
+	return token
+}
+
+// Mask replaces the words overlapping spans with "[redacted]" and rebuilds the
+// segment and full texts from the words, so the transcript doesn't keep what
+// the audio no longer says. Transcripts without word timestamps are cleared.
+func (t *Transcript) Mask(spans []RedactSpan) {
+	if len(spans) == 0 {
+		return
+	}
+	if len(t.Words) == 0 {
+		t.Text, t.Segments = "", nil
+		return
+	}
+	for i, w := range t.Words {
+		for _, s := range spans {
+			if w.StartSec < s.EndSec && w.EndSec > s.StartSec {
+				t.Words[i].Word = "[redacted]"
+				break
+			}
+		}
+	}
+	for i, seg := range t.Segments {
+		var text []string
+		for _, w := range t.Words {
+			if w.StartSec >= seg.StartSec && w.StartSec < seg.EndSec {
+				text = append(text, strings.TrimSpace(w.Word))
+			}
+		}
+		t.Segments[i].Text = strings.Join(text, " ")
+	}
+	all := make([]string, len(t.Words))
+	for i, w := range t.Words {
+		all[i] = strings.TrimSpace(w.Word)
+	}
+	t.Text = strings.Join(all, " ")
+}
@@ -0,0 +1,191 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// PreflightLimits bounds the inputs accepted for processing; zero values disable a check
+type PreflightLimits struct {
+	MaxDurationSec float64
+	MaxBytes       int64
+}
+
+// PreflightInfo is what the preflight probe learned about the input
+type PreflightInfo struct {
+	DurationSec float64    `json:"duration_sec"`
+	SizeBytes   int64      `json:"size_bytes"`
+	Format      string     `json:"format,omitempty"` // container recognized from the magic bytes
+	Media       *MediaInfo `json:"media,omitempty"`  // nil when ffprobe is not available
+}
+
+// Codes of PreflightError, stored as the error_code of rejected jobs
+const (
+	RejectUnreadable   = "unreadable"
+	RejectEmpty        = "empty_file"
+	RejectTooLarge     = "too_large"
+	RejectNotAudio     = "not_audio"       // magic bytes of no known audio container
+	RejectNoAudio      = "no_audio_stream" // a container without audio, e.g. a video without sound
+	RejectTruncated    = "truncated"
+	RejectCorrupt      = "corrupt" // ffprobe can't make sense of the file
+	RejectZeroDuration = "zero_duration"
+	RejectTooLong      = "too_long"
+)
+
+// PreflightError explains why an input was rejected before processing
+type PreflightError struct {
+	Code   string // one of the Reject constants
+	Reason string
+}
+
+func (e *PreflightError) Error() string {
+	return "preflight " + e.Code + ": " + e.Reason
+}
+
+// audioMagic are the leading bytes of the containers accepted as audio; offset is
+// where the signature starts
+var audioMagic = []struct {
+	format string
+	offset int
+	magic  string
+}{
+	{"wav", 8, "WAVE"}, // after "RIFF" or "RF64" and the size
+	{"ogg", 0, "OggS"},
+	{"flac", 0, "fLaC"},
+	{"mp3", 0, "ID3"},
+	{"mp4", 4, "ftyp"},
+	{"amr", 0, "#!AMR"},
+	{"aiff", 8, "AIFF"},
+	{"aiff", 8, "AIFC"},
+	{"au", 0, ".snd"},
+	{"caf", 0, "caff"},
+	{"webm", 0, "\x1a\x45\xdf\xa3"}, // Matroska/WebM
+	{"asf", 0, "\x30\x26\xb2\x75\x8e\x66\xcf\x11"},
+	{"w64", 0, "riff\x2e\x91\xcf\x11"},
+}
+
+// SniffFormat returns the audio container recognized from the first bytes of a
+// file, or "" when it is none of the known ones
+func SniffFormat(header []byte) string {
+	for _, m := range audioMagic {
+		if len(header) >= m.offset+len(m.magic) && string(header[m.offset:m.offset+len(m.magic)]) == m.magic {
+			if m.format == "wav" && !bytes.HasPrefix(header, []byte("RIFF")) && !bytes.HasPrefix(header, []byte("RF64")) {
+				continue
+			}
+			return m.format
+		}
+	}
+	// MPEG audio without a tag, ADTS AAC: an 11 bit frame sync
+	if len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0 {
+		return "mpeg"
+	}
+	return ""
+}
+
+// CheckInput is the part of the preflight that needs no ffprobe: the file must be
+// readable, non-empty and within limits.MaxBytes, start like a known audio
+// container and, for WAV, hold the data its header declares. The API runs it on
+// upload, the worker as the first step of Preflight.
+func CheckInput(path string, limits PreflightLimits) (*PreflightInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &PreflightError{Code: RejectUnreadable, Reason: fmt.Sprintf("input not readable: %v", err)}
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, &PreflightError{Code: RejectUnreadable, Reason: fmt.Sprintf("input not readable: %v", err)}
+	}
+	info := &PreflightInfo{SizeBytes: fi.Size()}
+	if info.SizeBytes == 0 {
+		return info, &PreflightError{Code: RejectEmpty, Reason: "input file is empty"}
+	}
+	if limits.MaxBytes > 0 && info.SizeBytes > limits.MaxBytes {
+		return info, &PreflightError{Code: RejectTooLarge, Reason: fmt.Sprintf("input is %d bytes, limit is %d", info.SizeBytes, limits.MaxBytes)}
+	}
+
+	header := make([]byte, 16)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return info, &PreflightError{Code: RejectUnreadable, Reason: fmt.Sprintf("input not readable: %v", err)}
+	}
+	if info.Format = SniffFormat(header[:n]); info.Format == "" {
+		return info, &PreflightError{Code: RejectNotAudio, Reason: fmt.Sprintf("not a known audio format (starts with % x)", header[:min(n, 8)])}
+	}
+
+	if info.Format == "wav" {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return info, &PreflightError{Code: RejectUnreadable, Reason: fmt.Sprintf("input not readable: %v", err)}
+		}
+		wav, err := readWAVHeader(f)
+		switch {
+		case errors.Is(err, errNotWAV):
+			return info, &PreflightError{Code: RejectTruncated, Reason: "WAV header is incomplete"}
+		case err != nil:
+			// other encodings (mu-law, ADPCM, ...) are left to ffprobe
+		case wav.DataBytes == 0 || wav.DataBytes == 0xFFFFFFFF:
+			// size not filled in by a recorder that was streaming; ffprobe reads to the end
+		case wav.DataOffset+wav.DataBytes > info.SizeBytes:
+			return info, &PreflightError{Code: RejectTruncated, Reason: fmt.Sprintf("WAV data chunk declares %d bytes, file holds %d", wav.DataBytes, info.SizeBytes-wav.DataOffset)}
+		}
+	}
+	return info, nil
+}
+
+// Preflight rejects inputs that can't be processed up front, so they fail with a
+// specific code and reason instead of a generic ffmpeg error: CheckInput, then an
+// ffprobe stream check (an audio stream, a duration within limits).
+func Preflight(ctx context.Context, path string, limits PreflightLimits) (*PreflightInfo, error) {
+	info, err := CheckInput(path, limits)
+	if err != nil {
+		return info, err
+	}
+
+	if _, err := exec.LookPath("ffprobe"); err == nil {
+		media, err := ProbeMedia(ctx, path)
+		if err != nil {
+			if ctx.Err() != nil {
+				return info, err
+			}
+			return info, probeRejection(err)
+		}
+		info.Media = media
+		if media.AudioStreams == 0 {
+			return info, &PreflightError{Code: RejectNoAudio, Reason: fmt.Sprintf("%s file has no audio stream", media.Container)}
+		}
+		info.DurationSec = media.DurationSec
+	}
+	if info.DurationSec == 0 {
+		// ffprobe not installed or a container without a duration
+		d, err := GetDuration(ctx, path)
+		if err != nil {
+			return info, &PreflightError{Code: RejectCorrupt, Reason: fmt.Sprintf("cannot probe duration: %v", err)}
+		}
+		info.DurationSec = d
+	}
+	if info.DurationSec <= 0 {
+		return info, &PreflightError{Code: RejectZeroDuration, Reason: "input has zero duration"}
+	}
+	if limits.MaxDurationSec > 0 && info.DurationSec > limits.MaxDurationSec {
+		return info, &PreflightError{Code: RejectTooLong, Reason: fmt.Sprintf("input is %.1fs long, limit is %.1fs", info.DurationSec, limits.MaxDurationSec)}
+	}
+	return info, nil
+}
+
+// probeRejection turns an ffprobe failure into a PreflightError, telling cut-off
+// files from otherwise unreadable ones by ffprobe's complaint
+func probeRejection(err error) *PreflightError {
+	msg := err.Error()
+	for _, s := range []string{"moov atom not found", "partial file", "Truncat", "End of file", "unexpected end"} {
+		if strings.Contains(msg, s) {
+			return &PreflightError{Code: RejectTruncated, Reason: msg}
+		}
+	}
+	return &PreflightError{Code: RejectCorrupt, Reason: msg}
+}
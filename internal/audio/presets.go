@@ -0,0 +1,112 @@
+package audio
+
+import "sort"
+
+// DefaultPipeline is the processing used by jobs without a preset
+var DefaultPipeline = PipelineConfig{
+	DenoiseDefault: "afftdn",
+	TargetLUFS:     -16.0,
+	SampleRate:     48000,
+	Channels:       1,
+	UseCompressor:  true,
+	Compressor:     CompressorConf{ThresholdDB: -20, Ratio: 3.1, Attack: 5, Release: 120},
+	UseLimiter:     true,
+	Limiter:        LimiterConf{ThresholdDB: -1.0},
+}
+
+// Preset is a named pipeline configuration clients select instead of tuning every stage
+type Preset struct {
+	Description    string `yaml:"description"`
+	PipelineConfig `yaml:",inline"`
+}
+
+// builtinPresets are available without any config file; the presets section of the
+// config file adds to them and replaces entries with the same name (see LoadConfig)
+var builtinPresets = map[string]Preset{
+	"call-center": {
+		Description: "narrowband agent/customer calls: band-limited, gated, compressed for QA listening",
+		PipelineConfig: PipelineConfig{
+			DenoiseDefault: "afftdn",
+			TargetLUFS:     -16.0,
+			SampleRate:     16000,
+			Channels:       1,
+			UseCompressor:  true,
+			Compressor:     CompressorConf{ThresholdDB: -20, Ratio: 3, Attack: 5, Release: 120},
+			UseLimiter:     true,
+			Limiter:        LimiterConf{ThresholdDB: -1.0},
+			TrimSilence:    true,
+			UseGate:        true,
+			HighpassHz:     100,
+			LowpassHz:      7000,
+		},
+	},
+	"voicemail": {
+		Description: "single-speaker messages recorded on phones: strong denoise, declip, trimmed",
+		PipelineConfig: PipelineConfig{
+			DenoiseDefault: "afftdn_tracked",
+			TargetLUFS:     -18.0,
+			SampleRate:     16000,
+			Channels:       1,
+			UseCompressor:  true,
+			Compressor:     CompressorConf{ThresholdDB: -18, Ratio: 2.5, Attack: 10, Release: 200},
+			UseLimiter:     true,
+			Limiter:        LimiterConf{ThresholdDB: -1.5},
+			TrimSilence:    true,
+			HighpassHz:     80,
+			Declip:         true,
+		},
+	},
+	"podcast": {
+		Description: "wideband speech for publishing: gentle denoise, de-essed, broadcast loudness",
+		PipelineConfig: PipelineConfig{
+			DenoiseDefault: "anlmdn",
+			TargetLUFS:     -16.0,
+			SampleRate:     48000,
+			Channels:       1,
+			UseCompressor:  true,
+			Compressor:     CompressorConf{ThresholdDB: -18, Ratio: 2, Attack: 20, Release: 250},
+			UseLimiter:     true,
+			Limiter:        LimiterConf{ThresholdDB: -1.5},
+			HighpassHz:     60,
+			UseDeesser:     true,
+		},
+	},
+}
+
+// PresetNames returns the preset names, sorted
+func PresetNames(presets map[string]Preset) []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Options converts the config into the ProcessOptions of a job
+func (c PipelineConfig) Options() ProcessOptions {
+	return ProcessOptions{
+		DenoiseMethod: c.DenoiseDefault,
+		DenoiseParams: c.Denoisers[c.DenoiseDefault],
+		TargetLUFS:    c.TargetLUFS,
+		SampleRate:    c.SampleRate,
+		Channels:      c.Channels,
+		UseCompressor: c.UseCompressor,
+		Compressor:    c.Compressor,
+		UseLimiter:    c.UseLimiter,
+		Limiter:       c.Limiter,
+		TrimSilence:   c.TrimSilence,
+		Trim:          c.Trim,
+		VAD:           c.VAD,
+		UseGate:       c.UseGate,
+		Gate:          c.Gate,
+		HighpassHz:    c.HighpassHz,
+		LowpassHz:     c.LowpassHz,
+		UseDeesser:    c.UseDeesser,
+		Deesser:       c.Deesser,
+		Dereverb:      c.Dereverb,
+		Declip:        c.Declip,
+		DCRemove:      c.DCRemove,
+		Engine:        c.Engine,
+	}
+}
@@ -0,0 +1,119 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Preview start selection
+const (
+	PreviewStart   = "start"   // the first seconds of the recording
+	PreviewLoudest = "loudest" // the window with the most speech energy
+)
+
+// PreviewConf controls the short listen-before-download rendition
+type PreviewConf struct {
+	LengthSec   float64 // 0 disables the preview
+	From        string  // PreviewStart or PreviewLoudest (default)
+	Format      string  // lossy output format, default mp3
+	BitrateKbps int     // default 32
+}
+
+// ParsePreviewFrom validates a preview start selection; empty means loudest
+func ParsePreviewFrom(from string) (string, error) {
+	switch from {
+	case "", PreviewLoudest:
+		return PreviewLoudest, nil
+	case PreviewStart:
+		return PreviewStart, nil
+	}
+	return "", fmt.Errorf("unknown preview start %q (want %s or %s)", from, PreviewStart, PreviewLoudest)
+}
+
+// previewRate is the analysis rate of the loudest window search; speech energy
+// sits well below 4 kHz
+const previewRate = 8000
+
+// PreviewPath is where the preview of the output at outputPath goes:
+// <name>_preview.<ext> next to it
+func PreviewPath(outputPath string, conf PreviewConf) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + "_preview." + OutputExt(conf.withDefaults().Format)
+}
+
+func (conf PreviewConf) withDefaults() PreviewConf {
+	if conf.Format == "" {
+		conf.Format = "mp3"
+	}
+	if conf.BitrateKbps <= 0 {
+		conf.BitrateKbps = 32
+	}
+	return conf
+}
+
+// RenderPreview encodes a short low-bitrate clip of the processed audio at path
+// (a file or a URL ffmpeg can read) to out, see PreviewPath. Recordings shorter
+// than the preview are encoded whole.
+func RenderPreview(ctx context.Context, path, out string, conf PreviewConf) error {
+	conf = conf.withDefaults()
+	start := 0.0
+	if conf.From != PreviewStart {
+		s, err := loudestWindow(ctx, path, conf.LengthSec)
+		if err != nil {
+			return fmt.Errorf("preview: %w", err)
+		}
+		start = s
+	}
+
+	args := []string{
+		"-y",
+		"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+		"-t", strconv.FormatFloat(conf.LengthSec, 'f', 3, 64),
+		"-i", path,
+		"-ac", "1",
+		"-vn",
+	}
+	args = append(args, encoderArgs(ProcessOptions{OutputFormat: conf.Format, BitrateKbps: conf.BitrateKbps})...)
+	args = append(args, out)
+	if err := runFFmpeg(ctx, args...); err != nil {
+		return fmt.Errorf("preview: %w", err)
+	}
+	return nil
+}
+
+// loudestWindow returns the start (in seconds, on a 0.5s grid) of the length-second
+// window of path with the highest energy, i.e. the densest stretch of speech
+func loudestWindow(ctx context.Context, path string, length float64) (float64, error) {
+	const step = 0.5
+	var energy []float64
+	err := streamPCM(ctx, path, previewRate, int(step*previewRate), func(frame []float64) error {
+		var e float64
+		for _, v := range frame {
+			e += v * v
+		}
+		energy = append(energy, e)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	win := int(math.Round(length / step))
+	if win <= 0 || len(energy) <= win {
+		return 0, nil
+	}
+	var sum float64
+	for _, e := range energy[:win] {
+		sum += e
+	}
+	best, bestAt := sum, 0
+	for i := win; i < len(energy); i++ {
+		sum += energy[i] - energy[i-win]
+		if sum > best {
+			best, bestAt = sum, i-win+1
+		}
+	}
+	return float64(bestAt) * step, nil
+}
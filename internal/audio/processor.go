@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"os"
@@ -25,6 +26,21 @@ type PipelineConfig struct {
 	Compressor     CompressorConf `yaml:"compressor"`
 	UseLimiter     bool           `yaml:"use_limiter"`
 	Limiter        LimiterConf    `yaml:"limiter"`
+	TrimSilence    bool           `yaml:"trim_silence"`
+	Trim           TrimConf       `yaml:"trim"`
+	VAD            VADConf        `yaml:"vad"`
+	UseGate        bool           `yaml:"use_gate"`
+	Gate           GateConf       `yaml:"gate"`
+	HighpassHz     int            `yaml:"highpass_hz"`
+	LowpassHz      int            `yaml:"lowpass_hz"`
+	UseDeesser     bool           `yaml:"use_deesser"`
+	Deesser        DeesserConf    `yaml:"deesser"`
+	Dereverb       bool           `yaml:"dereverb"`
+	Declip         bool           `yaml:"declip"`
+	DCRemove       bool           `yaml:"dc_remove"`
+	// per-method filter options, e.g. denoisers: {anlmdn: {s: "0.0002"}}
+	Denoisers map[string]map[string]string `yaml:"denoisers"`
+	Engine    string                       `yaml:"engine"` // ffmpeg (default) or native
 }
 
 type CompressorConf struct {
@@ -48,28 +64,76 @@ type ProcessOptions struct {
 	Compressor    CompressorConf
 	UseLimiter    bool
 	Limiter       LimiterConf
+	OutputFormat  string // wav (default), flac, mp3 or opus; see ParseOutputFormat
+	BitrateKbps   int    // lossy formats only, 0 uses the format default
+	TrimSilence   bool   // cut leading/trailing silence, see TrimConf
+	Trim          TrimConf
+	VAD           VADConf // speech detection settings; VAD.RemoveGaps shortens long pauses
+	UseGate       bool    // noise gate after denoising, see GateConf
+	Gate          GateConf
+	HighpassHz    int // 0 disables; applied before denoising
+	LowpassHz     int // 0 disables; applied before denoising
+	UseDeesser    bool
+	Deesser       DeesserConf
+	Dereverb      bool              // arnndn with a dereverb model before denoising, skipped when no model is installed
+	CustomFilter  string            // user -af chain, validated by ParseCustomFilter
+	CustomMode    string            // CustomAppend (default) or CustomReplace
+	ChannelMode   string            // ChannelMono (default), ChannelDual or ChannelSplit, see ProcessDualChannel
+	Tempo         float64           // playback speed of the main output, 0 keeps it (see RenderTempo for extra files)
+	Declip        bool              // adeclip before any other stage, see DetectClipping
+	DCRemove      bool              // remove the DC offset first, see DetectDCOffset
+	DCOffset      float64           // measured offset of the input for DCRemove; 0 uses a high-pass instead
+	DenoiseParams map[string]string // filter options overriding the method defaults, see ParseDenoiseParams
+	RNNoiseModel  string            // arnndn model file; empty uses RNNOISE_MODEL_PATH or the bundled default
+	Engine        string            // EngineFFmpeg (default) or EngineNative, see ProcessNative
+	ScratchDir    string            // intermediate files; empty puts them next to the output
 }
 
 // Stats returned after processing
 type Stats struct {
-	DurationSec float64            `json:"duration_sec"`
-	Loudness    map[string]float64 `json:"loudness"` // measured loudness map (keys from MeasureLoudness)
-	NoiseLevel  float64            `json:"noise_level"`
+	DurationSec float64           `json:"duration_sec"`
+	Loudness    *Loudness         `json:"loudness"` // measured loudness of the output
+	NoiseLevel  float64           `json:"noise_level"`
+	TrimmedSec  float64           `json:"trimmed_sec,omitempty"`  // silence cut by TrimSilence
+	Channels    map[string]*Stats `json:"channels,omitempty"`     // per-party stats in dual/split channel mode
+	Extras      map[string]string `json:"-"`                      // additional output files by name, next to the main output
+	FilterChain string            `json:"filter_chain,omitempty"` // ffmpeg chain of the apply pass(es), " | " between passes
 }
 
 // ProcessFile performs:
-// 1) choose denoiser (arnndn if requested and available, else afftdn) or noisereduce
-// 2) measure loudness via ffmpeg loudnorm (first pass)
-// 3) apply loudnorm using measured params (second pass) + compressor + limiter
+// 1) optional high/low-pass, then the denoiser (arnndn if requested and available, else afftdn) or spectral gating
+// 2) measure loudness via ffmpeg loudnorm (first pass, after denoising)
+// 3) apply loudnorm using measured params (second pass, linear) + compressor + limiter
 // 4) returns Stats with duration and loudness metrics
 func ProcessFile(ctx context.Context, inputPath, outputPath string, opts ProcessOptions) (*Stats, error) {
+	if opts.Engine == EngineNative {
+		return ProcessNative(ctx, inputPath, outputPath, opts)
+	}
+	outputPathAbs, _ := filepath.Abs(outputPath)
+	return process(ctx, inputPath, outputPathAbs, nil, opts)
+}
+
+// ProcessStream runs the ProcessFile pipeline but streams the encoded output
+// to w instead of writing a file, e.g. into an object storage upload. The output
+// can't be measured here: DurationSec, Loudness and TrimmedSec of the returned
+// Stats are left to MeasureOutput once the output is readable again. WAV and
+// FLAC outputs carry no length in their headers, as ffmpeg can't seek back to
+// fill it in.
+func ProcessStream(ctx context.Context, inputPath string, w io.Writer, opts ProcessOptions) (*Stats, error) {
+	if opts.Engine == EngineNative {
+		return nil, fmt.Errorf("the native engine can't stream its output")
+	}
+	return process(ctx, inputPath, "", w, opts)
+}
+
+// process is ProcessFile with the ffmpeg engine; the output goes to w when it is
+// not nil, else to outputPathAbs
+func process(ctx context.Context, inputPath, outputPathAbs string, w io.Writer, opts ProcessOptions) (*Stats, error) {
 	// ensure input absolute path
 	inputPathAbs, _ := filepath.Abs(inputPath)
-	outputPathAbs, _ := filepath.Abs(outputPath)
 
 	// check ffmpeg present
-	ffmpegPath, err := exec.LookPath("ffmpeg")
-	if err != nil {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
 		return nil, fmt.Errorf("ffmpeg not found in PATH: %w", err)
 	}
 
@@ -84,58 +148,201 @@ func ProcessFile(ctx context.Context, inputPath, outputPath string, opts Process
 		return nil, fmt.Errorf("GetNoiseLevel  not work with the PATH: %w", err)
 	}
 
+	custom, err := ParseCustomFilter(opts.CustomFilter)
+	if err != nil {
+		return nil, err
+	}
+	if custom != "" && opts.CustomMode == CustomReplace {
+		// the user chain is all that runs, besides resampling to the output rate
+		chain := custom + fmt.Sprintf(",aresample=%d", opts.SampleRate)
+		if err := applyChain(ctx, inputPathAbs, outputPathAbs, w, chain, opts); err != nil {
+			return nil, err
+		}
+		stats := &Stats{NoiseLevel: noiseLevel}
+		if w == nil {
+			stats = collectStats(ctx, inputPathAbs, outputPathAbs, opts, noiseLevel, nil)
+		}
+		stats.FilterChain = chain
+		return stats, nil
+	}
+
 	// 1) choose denoise filter (FFmpeg side only)
-	denoiseFilter := "" // empty means "no ffmpeg-side denoising filter"
-	dnMethod := strings.ToLower(strings.TrimSpace(opts.DenoiseMethod))
+	dnMethod := normalizeMethod(opts.DenoiseMethod)
 
-	// using the external python noisereduce helper, use its output as the new input.
-	if dnMethod == "noisereduce" {
-		denoisedPath, err := runNoisereduce(ctx, inputPathAbs, 1.0, "")
+	// spectral gating (python noisereduce helper or the native implementation)
+	// runs before ffmpeg, use its output as the new input.
+	if isSpectralMethod(dnMethod) {
+		denoisedPath, err := spectralDenoise(ctx, inputPathAbs, opts.scratchDir(outputPathAbs), dnMethod)
 		if err != nil {
-			log.Printf("noisereduce failed: %v — continuing with original input", err)
+			log.Printf("%s failed: %v — continuing with original input", dnMethod, err)
 		} else {
 			inputPathAbs = denoisedPath
-			// caller should cleanup tmp files later (THE code already handles temp cleanup pattern)
+			// intermediate file, only needed until the apply pass is done
+			defer os.Remove(denoisedPath)
 		}
+	}
+	denoiseFilter := denoiseFilterFor(dnMethod, opts.DenoiseParams, opts.RNNoiseModel)
+
+	// noisereduce already ran on the unfiltered input, the band filters still apply before loudnorm
+	cleanupParts := preDenoiseFilters(opts)
+	if denoiseFilter != "" {
+		cleanupParts = append(cleanupParts, denoiseFilter)
+	}
+	cleanupParts = append(cleanupParts, postDenoiseFilters(opts)...)
+
+	// 2) measure loudness (first pass) on the denoised signal
+	inputLoudness, _ := MeasureLoudness(ctx, inputPathAbs, opts.TargetLUFS)
+	measured, err := MeasureLoudnorm(ctx, inputPathAbs, strings.Join(cleanupParts, ","), opts.TargetLUFS)
+	if err != nil {
+		log.Printf("loudnorm first pass failed: %v — falling back to single-pass normalization", err)
+	}
+
+	// 3) Build filter chain for second pass
+	filterParts := append(cleanupParts, masteringFilters(opts, measured)...)
+	filterParts = withCustom(filterParts, custom)
+
+	chain := strings.Join(filterParts, ",")
+	if err := applyChain(ctx, inputPathAbs, outputPathAbs, w, chain, opts); err != nil {
+		return nil, err
+	}
+
+	// 4) collect stats (duration & loudness after processing)
+	stats := &Stats{NoiseLevel: noiseLevel}
+	if w == nil {
+		stats = collectStats(ctx, inputPathAbs, outputPathAbs, opts, noiseLevel, inputLoudness)
+	}
+	stats.FilterChain = chain
+	if isSpectralMethod(dnMethod) {
+		stats.FilterChain = dnMethod + " | " + chain
+	}
+	return stats, nil
+}
+
+// withCustom inserts an appended custom chain before the final resample stage of parts
+func withCustom(parts []string, custom string) []string {
+	if custom == "" || len(parts) == 0 {
+		return parts
+	}
+	last := parts[len(parts)-1]
+	return append(append(parts[:len(parts)-1], custom), last)
+}
+
+// applyChain runs the ffmpeg apply pass with filterChain and the output encoder
+// settings, writing outputPath or, when w is not nil, streaming to w
+func applyChain(ctx context.Context, inputPath, outputPath string, w io.Writer, filterChain string, opts ProcessOptions) error {
+	args := []string{
+		"-y",
+		"-i", inputPath,
+		"-af", filterChain,
+		"-ar", strconv.Itoa(opts.SampleRate),
+		"-ac", strconv.Itoa(opts.Channels), // let ffmpeg handle channel conversion
+		"-vn",
+	}
+	args = append(args, encoderArgs(opts)...)
+	if w != nil {
+		args = append(args, "-f", formatOf(opts.OutputFormat).Muxer, "pipe:1")
 	} else {
-		// For FFmpeg built-in filters: prefer arnndn (RNNoise) when requested and available.
-		if dnMethod == "arnndn" || dnMethod == "rnnoise" {
-			// Check that ffmpeg supports arnndn
-			if ffmpegHasFilter("arnndn") {
-				// RNNoise model path (make configurable)
-				rnModel := os.Getenv("RNNOISE_MODEL_PATH")
-				if rnModel == "" {
-					rnModel = filepath.Join("tools", "models", "rnnoise-model.rnnn")
-				}
-				// If model file exists, set arnndn filter with model param; otherwise fall back.
-				if _, err := os.Stat(rnModel); err == nil {
-					// note: arnndn syntax: arnndn=m=path/to/model.rnnn
-					denoiseFilter = fmt.Sprintf("arnndn=m=%s", rnModel)
-				} else {
-					log.Printf("arnndn requested but model not found at %s, falling back to afftdn", rnModel)
-					denoiseFilter = "afftdn"
-				}
-			} else {
-				log.Printf("arnndn filter not available in ffmpeg build, falling back to afftdn")
-				denoiseFilter = "afftdn"
+		args = append(args, outputPath)
+	}
+
+	start := time.Now()
+	if _, err := ffmpegOutput(ctx, w, args...); err != nil {
+		return fmt.Errorf("ffmpeg apply failed after %s: %w", time.Since(start), err)
+	}
+	return nil
+}
+
+// collectStats measures the processed output. fallback is the pre-measured
+// loudness used when the final measurement fails.
+func collectStats(ctx context.Context, inputPath, outputPath string, opts ProcessOptions, noiseLevel float64, fallback *Loudness) *Stats {
+	stats := &Stats{NoiseLevel: noiseLevel}
+	MeasureOutput(ctx, stats, inputPath, outputPath, opts)
+	if stats.Loudness == nil {
+		stats.Loudness = fallback
+	}
+	return stats
+}
+
+// MeasureOutput fills in the duration, loudness and trimmed silence of stats from
+// the processed output, a path or a URL ffmpeg can read (see ProcessStream).
+// Failed measurements leave the fields unset.
+func MeasureOutput(ctx context.Context, stats *Stats, inputPath, output string, opts ProcessOptions) {
+	if d, err := GetDuration(ctx, output); err == nil {
+		stats.DurationSec = d
+	}
+	if l, err := MeasureLoudness(ctx, output, opts.TargetLUFS); err == nil {
+		stats.Loudness = l
+	}
+	if opts.TrimSilence && stats.DurationSec > 0 {
+		// a sped-up output is shorter without anything being trimmed
+		played := stats.DurationSec
+		if opts.Tempo > 0 {
+			played *= opts.Tempo
+		}
+		if in, err := GetDuration(ctx, inputPath); err == nil && in > played {
+			stats.TrimmedSec = in - played
+		}
+	}
+}
+
+func normalizeMethod(method string) string {
+	return strings.ToLower(strings.TrimSpace(method))
+}
+
+// denoiseFilterFor returns the ffmpeg-side denoise filter for a normalized method.
+// spectral gating runs before ffmpeg, so it has no filter ("").
+// params override the method's default filter options (see denoiseProfiles).
+func denoiseFilterFor(dnMethod string, params map[string]string, rnModel string) string {
+	if isSpectralMethod(dnMethod) {
+		return ""
+	}
+	// For FFmpeg built-in filters: prefer arnndn (RNNoise) when requested and available.
+	if dnMethod == "arnndn" || dnMethod == "rnnoise" {
+		// Check that ffmpeg supports arnndn
+		if !ffmpegHasFilter("arnndn") {
+			log.Printf("arnndn filter not available in ffmpeg build, falling back to afftdn")
+			return "afftdn"
+		}
+		// a model resolved by the worker's model manager must exist; the legacy
+		// env/default path keeps falling back to afftdn when it is missing
+		if rnModel == "" {
+			rnModel = os.Getenv("RNNOISE_MODEL_PATH")
+			if rnModel == "" {
+				rnModel = filepath.Join("tools", "models", "rnnoise-model.rnnn")
+			}
+			if _, err := os.Stat(rnModel); err != nil {
+				log.Printf("arnndn requested but model not found at %s, falling back to afftdn", rnModel)
+				return "afftdn"
 			}
-		} else {
-			// default: afftdn (broadband frequency-domain denoising)
-			denoiseFilter = "afftdn"
 		}
+		// note: arnndn syntax: arnndn=m=path/to/model.rnnn
+		return fmt.Sprintf("arnndn=m=%s", rnModel)
 	}
+	profile, ok := denoiseProfiles[dnMethod]
+	if !ok {
+		// default: afftdn (broadband frequency-domain denoising)
+		profile = denoiseProfiles["afftdn"]
+	}
+	return profile.filter(params)
+}
 
-	// 2) measure loudness (first pass)
-	loudnessMap, _ := MeasureLoudness(ctx, inputPathAbs, opts.TargetLUFS)
+// loudnorm true peak and loudness range targets
+const (
+	loudnormTP  = -1.5
+	loudnormLRA = 7.0
+)
 
-	// 3) Build filter chain for second pass
+// masteringFilters returns the stages applied after denoising:
+// loudnorm (two-pass when m is set), optional compressor, deesser and limiter, then resampling.
+func masteringFilters(opts ProcessOptions, m *LoudnormMeasurement) []string {
 	filterParts := []string{}
-	if denoiseFilter != "" {
-		filterParts = append(filterParts, denoiseFilter)
+	// preparing loudnorm application (using opts.TargetLUFS); with a first-pass
+	// measurement loudnorm can apply a single linear gain instead of dynamic normalization
+	loudnormApply := fmt.Sprintf("loudnorm=I=%v:TP=%v:LRA=%v", opts.TargetLUFS, loudnormTP, loudnormLRA)
+	if m != nil {
+		loudnormApply += fmt.Sprintf(":measured_I=%v:measured_TP=%v:measured_LRA=%v:measured_thresh=%v:offset=%v:linear=true",
+			m.InputI, m.InputTP, m.InputLRA, m.InputThresh, m.TargetOffset)
 	}
-
-	// preparing loudnorm application (using opts.TargetLUFS)
-	loudnormApply := fmt.Sprintf("loudnorm=I=%v:TP=-1.5:LRA=7", opts.TargetLUFS)
 	filterParts = append(filterParts, loudnormApply)
 	// ---------------------------------------------------------------------
 	// compressor (needs dB -> linear conversion for threshold)
@@ -156,6 +363,11 @@ func ProcessFile(ctx context.Context, inputPath, outputPath string, opts Process
 		filterParts = append(filterParts, comp)
 	}
 
+	// deesser after the compressor, which is what brings sibilance forward
+	if opts.UseDeesser {
+		filterParts = append(filterParts, deesserFilter(opts.Deesser))
+	}
+
 	// limiter (dB -> linear)
 	if opts.UseLimiter {
 		lim := opts.Limiter
@@ -170,49 +382,18 @@ func ProcessFile(ctx context.Context, inputPath, outputPath string, opts Process
 		filterParts = append(filterParts, limStr)
 	}
 
+	// sped-up main output, pitch preserved
+	if opts.Tempo > 0 && opts.Tempo != 1 {
+		filterParts = append(filterParts, tempoFilter(opts.Tempo))
+	}
+
 	// After filters, resample to configured sample rate (final step)
 	// Note.me: we avoid using pan because pan syntax can be picky across ffmpeg builds.
 	// We rely on -ac <channels> (passed in args) to set channels.
 	resample := fmt.Sprintf("aresample=%d", opts.SampleRate)
 	filterParts = append(filterParts, resample)
 
-	filterChain := strings.Join(filterParts, ",")
-
-	// build ffmpeg args for apply pass
-	args := []string{
-		"-y",
-		"-i", inputPathAbs,
-		"-af", filterChain,
-		"-ar", strconv.Itoa(opts.SampleRate),
-		"-ac", strconv.Itoa(opts.Channels), // let ffmpeg handle channel conversion
-		"-vn",
-		outputPathAbs,
-	}
-
-	// run ffmpeg second pass (apply)
-	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-	start := time.Now()
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("ffmpeg apply failed after %s: %w - stderr: %s", time.Since(start), err, stderr.String())
-	}
-
-	// 4) collect stats (duration & loudness after processing)
-	stats := &Stats{}
-	if d, err := GetDuration(ctx, outputPathAbs); err == nil {
-		stats.DurationSec = d
-	}
-	if lm, err := MeasureLoudness(ctx, outputPathAbs, opts.TargetLUFS); err == nil {
-		stats.Loudness = lm
-	} else {
-		// fallback to pre-measured map if final measure failed
-		stats.Loudness = loudnessMap
-	}
-
-	stats.NoiseLevel = noiseLevel
-
-	return stats, nil
+	return filterParts
 }
 
 // ffmpegHasFilter checks if ffmpeg supports a given filter name by calling "ffmpeg -filters"
@@ -244,13 +425,24 @@ func stripTrailingZeros(f float64) string {
 	return s
 }
 
-func runNoisereduce(ctx context.Context, inputPath string, propDecrease float64, noiseSamplePath string) (string, error) {
+// scratchDir returns the directory of the intermediate files of outputPathAbs
+func (o ProcessOptions) scratchDir(outputPathAbs string) string {
+	if o.ScratchDir != "" {
+		return o.ScratchDir
+	}
+	return filepath.Dir(outputPathAbs)
+}
+
+// noisereduceScript is the python spectral gating helper, relative to the working directory
+const noisereduceScript = "tools/noisereduce_denoise.py"
+
+func runNoisereduce(ctx context.Context, inputPath, dir string, propDecrease float64, noiseSamplePath string) (string, error) {
 	ffmpegPath, _ := exec.LookPath("ffmpeg") // used only if we need to resample (optional)
 	_ = ffmpegPath
 
-	tmpDir := os.TempDir()
+	// write to the scratch dir of the job so intermediate files live (and die) with it
 	base := filepath.Base(inputPath)
-	out := filepath.Join(tmpDir, fmt.Sprintf("nr_out_%d_%s.wav", time.Now().UnixNano(), base))
+	out := filepath.Join(dir, fmt.Sprintf("nr_out_%d_%s.wav", time.Now().UnixNano(), base))
 
 	// Build command: python tools/noisereduce_denoise.py --in <in> --out <out> [--noise <noise>] --prop-decrease <n>
 	py, perr := exec.LookPath("python")
@@ -261,7 +453,7 @@ func runNoisereduce(ctx context.Context, inputPath string, propDecrease float64,
 		return "", fmt.Errorf("python not found in PATH (required for noisereduce helper)")
 	}
 
-	args := []string{"tools/noisereduce_denoise.py", "--in", inputPath, "--out", out, "--prop-decrease", fmt.Sprintf("%g", propDecrease)}
+	args := []string{noisereduceScript, "--in", inputPath, "--out", out, "--prop-decrease", fmt.Sprintf("%g", propDecrease)}
 	if noiseSamplePath != "" {
 		args = append(args[:len(args)-0], append([]string{"--noise", noiseSamplePath}, args[len(args):]...)...)
 	}
@@ -270,11 +462,11 @@ func runNoisereduce(ctx context.Context, inputPath string, propDecrease float64,
 	runCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
 	defer cancel()
 
-	cmd := exec.CommandContext(runCtx, py, args...)
+	cmd := newCmd(runCtx, py, args...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 	cmd.Stdout = &stderr
-	if err := cmd.Run(); err != nil {
+	if err := runCmd(cmd); err != nil {
 		return "", fmt.Errorf("noisereduce script failed: %w - stderr: %s", err, stderr.String())
 	}
 
@@ -287,10 +479,10 @@ func runNoisereduce(ctx context.Context, inputPath string, propDecrease float64,
 
 func GetNoiseLevel(ctx context.Context, path string) (float64, error) {
 	ffmpegPath, _ := exec.LookPath("ffmpeg")
-	cmd := exec.CommandContext(ctx, ffmpegPath, "-i", path, "-af", "volumedetect", "-f", "null", "-")
+	cmd := newCmd(ctx, ffmpegPath, "-i", path, "-af", "volumedetect", "-f", "null", "-")
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
+	if err := runCmd(cmd); err != nil {
 		// ffmpeg returns non-zero for null output; we will parse stderr anyway
 	}
 	out := stderr.String()
@@ -1,11 +1,11 @@
 package audio
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
 	"math"
-	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
@@ -14,26 +14,31 @@ import (
 
 // QualityMetrics holds signal quality data extracted from FFmpeg or analysis.
 type QualityMetrics struct {
-	SNR        float64   `json:"snr"`         // Signal-to-noise ratio (dB)
-	RMSLevel   float64   `json:"rms_level"`   // Root mean square loudness (dB)
-	PeakLevel  float64   `json:"peak_level"`  // Peak loudness (dB)
-	NoiseLevel float64   `json:"noise_level"` // Estimated background noise (dB)
-	Duration   float64   `json:"duration"`    // Duration of the audio (seconds)
-	AnalyzedAt time.Time `json:"analyzed_at"` // Timestamp of when it was analyzed
+	SNR        float64   `json:"snr"`          // speech-to-noise ratio (dB) from VADSNR, 0 without enough speech
+	SNRPeakRMS float64   `json:"snr_peak_rms"` // deprecated: peak - RMS (crest factor), the former SNR value
+	RMSLevel   float64   `json:"rms_level"`    // Root mean square loudness (dB)
+	PeakLevel  float64   `json:"peak_level"`   // Peak loudness (dB)
+	NoiseLevel float64   `json:"noise_level"`  // Estimated background noise (dB)
+	Duration   float64   `json:"duration"`     // Duration of the audio (seconds)
+	AnalyzedAt time.Time `json:"analyzed_at"`  // Timestamp of when it was analyzed
 }
 
 // EstimateQuality runs an FFmpeg filter to estimate SNR and other stats.
 func EstimateQuality(ctx context.Context, path string) (*QualityMetrics, error) {
 	start := time.Now()
 
-	cmd := exec.CommandContext(ctx, "ffmpeg",
+	cmd := newCmd(ctx, "ffmpeg",
 		"-hide_banner",
 		"-nostats",
 		"-i", path,
 		"-filter_complex", "astats=metadata=1:reset=1",
 		"-f", "null", "-")
 
-	output, err := cmd.CombinedOutput()
+	var combined bytes.Buffer
+	cmd.Stdout = &combined
+	cmd.Stderr = &combined
+	err := runCmd(cmd)
+	output := combined.Bytes()
 	if err != nil {
 		//FFmpeg may exit nonzero even with useful output
 		log.Printf("[quality] warning: ffmpeg astats error: %v", err)
@@ -74,14 +79,21 @@ func EstimateQuality(ctx context.Context, path string) (*QualityMetrics, error)
 	peakAvg := avg(peakVals)
 	noiseAvg := avg(noiseVals)
 
-	// approximate SNR = peak - RMS, fallback to 0 if missing
-	snr := 0.0
+	// former approximation, kept while dashboards move over to the VAD based SNR
+	peakRMS := 0.0
 	if !math.IsNaN(peakAvg) && !math.IsNaN(rmsAvg) {
-		snr = peakAvg - rmsAvg
+		peakRMS = peakAvg - rmsAvg
+	}
+	snr, ok, err := VADSNR(ctx, path)
+	if err != nil {
+		log.Printf("[quality] warning: VAD SNR failed: %v", err)
+	} else if !ok {
+		log.Printf("[quality] too little speech in %s for an SNR estimate", path)
 	}
 
 	return &QualityMetrics{
 		SNR:        snr,
+		SNRPeakRMS: peakRMS,
 		RMSLevel:   rmsAvg,
 		PeakLevel:  peakAvg,
 		NoiseLevel: noiseAvg,
@@ -0,0 +1,142 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// How flagged ranges are overwritten in a redacted rendition
+const (
+	RedactBeep    = "beep"
+	RedactSilence = "silence"
+)
+
+// Sources of redaction spans a job can ask for
+const (
+	RedactDTMF      = "dtmf"      // keypresses, e.g. PINs typed on the phone
+	RedactProfanity = "profanity" // transcript words of the configured redaction terms
+)
+
+// RedactionConf is the redaction section of the config file
+type RedactionConf struct {
+	Terms  []string `yaml:"terms"`   // words and phrases beeped out by the profanity source
+	PadSec float64  `yaml:"pad_sec"` // added around every span, ASR timestamps are approximate
+}
+
+// RedactSpan is a time range of the output to overwrite and why it was flagged
+type RedactSpan struct {
+	StartSec float64 `json:"start_sec"`
+	EndSec   float64 `json:"end_sec"`
+	Reason   string  `json:"reason"`
+}
+
+// ParseRedactMode validates a redaction mode; empty selects beep
+func ParseRedactMode(s string) (string, error) {
+	switch s {
+	case "", RedactBeep:
+		return RedactBeep, nil
+	case RedactSilence:
+		return RedactSilence, nil
+	}
+	return "", fmt.Errorf("unknown redaction mode %q (want %s or %s)", s, RedactBeep, RedactSilence)
+}
+
+// ParseRedactSources splits and validates a comma separated list of redaction sources
+func ParseRedactSources(s string) ([]string, error) {
+	var sources []string
+	for _, src := range strings.Split(s, ",") {
+		src = strings.TrimSpace(src)
+		switch src {
+		case "":
+			continue
+		case RedactDTMF, RedactProfanity:
+			sources = append(sources, src)
+		default:
+			return nil, fmt.Errorf("unknown redaction source %q (want %s or %s)", src, RedactDTMF, RedactProfanity)
+		}
+	}
+	return sources, nil
+}
+
+// DTMFSpans turns keypresses into redaction spans
+func DTMFSpans(events []DTMFEvent) []RedactSpan {
+	spans := make([]RedactSpan, 0, len(events))
+	for _, e := range events {
+		spans = append(spans, RedactSpan{StartSec: e.StartSec, EndSec: e.EndSec, Reason: RedactDTMF})
+	}
+	return spans
+}
+
+// TermSpans returns the spans of the transcript words matching terms
+func TermSpans(words []Word, terms []string, reason string) []RedactSpan {
+	var spans []RedactSpan
+	for _, h := range SpotKeywords(words, KeywordList{reason: terms}) {
+		spans = append(spans, RedactSpan{StartSec: h.StartSec, EndSec: h.EndSec, Reason: reason})
+	}
+	return spans
+}
+
+// MergeSpans widens the spans by pad on both sides and joins the ones that
+// overlap; the result is sorted and keeps the reason of the first span of a run
+func MergeSpans(spans []RedactSpan, pad float64) []RedactSpan {
+	if len(spans) == 0 {
+		return nil
+	}
+	sorted := make([]RedactSpan, len(spans))
+	copy(sorted, spans)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].StartSec < sorted[j].StartSec })
+
+	var merged []RedactSpan
+	for _, s := range sorted {
+		s.StartSec -= pad
+		if s.StartSec < 0 {
+			s.StartSec = 0
+		}
+		s.EndSec += pad
+		if n := len(merged); n > 0 && s.StartSec <= merged[n-1].EndSec {
+			if s.EndSec > merged[n-1].EndSec {
+				merged[n-1].EndSec = s.EndSec
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// RenderRedacted writes a rendition of outputPath (<name>_redacted<ext>) with the
+// spans overwritten by a 1 kHz beep or by silence, encoded like the output
+func RenderRedacted(ctx context.Context, outputPath string, spans []RedactSpan, mode string, opts ProcessOptions) (string, error) {
+	ext := filepath.Ext(outputPath)
+	out := strings.TrimSuffix(outputPath, ext) + "_redacted" + ext
+	args := []string{"-y", "-i", outputPath}
+	if len(spans) > 0 {
+		args = append(args, "-filter_complex", redactFilter(spans, mode), "-map", "[out]")
+	}
+	args = append(args, "-vn")
+	args = append(args, encoderArgs(opts)...)
+	args = append(args, out)
+	if err := runFFmpeg(ctx, args...); err != nil {
+		return "", fmt.Errorf("redacted rendition: %w", err)
+	}
+	return out, nil
+}
+
+// redactFilter mutes the spans and, in beep mode, mixes a tone into them only
+func redactFilter(spans []RedactSpan, mode string) string {
+	ranges := make([]string, len(spans))
+	for i, s := range spans {
+		ranges[i] = fmt.Sprintf("between(t,%.3f,%.3f)", s.StartSec, s.EndSec)
+	}
+	inSpan := strings.Join(ranges, "+")
+	muted := fmt.Sprintf("[0:a]volume=volume=0:enable='%s'", inSpan)
+	if mode == RedactSilence {
+		return muted + "[out]"
+	}
+	return muted + "[m];" +
+		fmt.Sprintf("sine=frequency=1000:sample_rate=48000,volume=volume=0.25,volume=volume=0:enable='not(%s)'[b];", inSpan) +
+		"[m][b]amix=inputs=2:duration=first:normalize=0[out]"
+}
@@ -0,0 +1,99 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ReverbStats is a blind reverberation estimate of a recording
+type ReverbStats struct {
+	RT60Sec float64 `json:"rt60_sec"` // estimated time for the room tail to decay by 60 dB
+	Decays  int     `json:"decays"`   // free decays (speech offsets) the estimate is based on
+}
+
+const (
+	reverbRate       = 16000
+	reverbFrameSec   = 0.02
+	reverbMinFrames  = 5     // shortest decay run considered (100 ms)
+	reverbMinDropDB  = 10.0  // a run must fall at least this much to count as a decay
+	reverbStartDB    = -50.0 // decays must start from speech level, not from the noise floor
+	reverbPercentile = 0.2
+)
+
+// EstimateReverb estimates RT60 from the energy decay after speech offsets.
+// The observed decay cannot be faster than the room allows, so the fast end of
+// the measured decay distribution approximates the room tail.
+func EstimateReverb(ctx context.Context, path string) (*ReverbStats, error) {
+	frameLen := int(reverbRate * reverbFrameSec)
+	var run []float64
+	var rt60s []float64
+	flush := func() {
+		if len(run) >= reverbMinFrames && run[0] >= reverbStartDB && run[0]-run[len(run)-1] >= reverbMinDropDB {
+			if slope := decaySlope(run) / reverbFrameSec; slope < 0 {
+				rt60s = append(rt60s, -60/slope)
+			}
+		}
+		run = run[:0]
+	}
+	err := streamPCM(ctx, path, reverbRate, frameLen, func(frame []float64) error {
+		sum := 0.0
+		for _, v := range frame {
+			sum += v * v
+		}
+		db := 10 * math.Log10(sum/float64(len(frame))+1e-12)
+		if len(run) > 0 && db >= run[len(run)-1] {
+			flush()
+		}
+		run = append(run, db)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	flush()
+	if len(rt60s) == 0 {
+		return nil, fmt.Errorf("no speech decays found")
+	}
+	sort.Float64s(rt60s)
+	return &ReverbStats{
+		RT60Sec: rt60s[int(float64(len(rt60s)-1)*reverbPercentile)],
+		Decays:  len(rt60s),
+	}, nil
+}
+
+// decaySlope is the least-squares slope of ys (dB per frame)
+func decaySlope(ys []float64) float64 {
+	n := float64(len(ys))
+	var sx, sy, sxx, sxy float64
+	for i, y := range ys {
+		x := float64(i)
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	return (n*sxy - sx*sy) / (n*sxx - sx*sx)
+}
+
+// dereverbFilter returns the arnndn stage loaded with a dereverberation model,
+// or "" when no model is installed (DEREVERB_MODEL_PATH, default tools/models/dereverb.rnnn).
+func dereverbFilter() string {
+	model := os.Getenv("DEREVERB_MODEL_PATH")
+	if model == "" {
+		model = filepath.Join("tools", "models", "dereverb.rnnn")
+	}
+	if !ffmpegHasFilter("arnndn") {
+		log.Printf("dereverb requested but arnndn filter not available in ffmpeg build, skipping")
+		return ""
+	}
+	if _, err := os.Stat(model); err != nil {
+		log.Printf("dereverb requested but model not found at %s, skipping", model)
+		return ""
+	}
+	return fmt.Sprintf("arnndn=m=%s", model)
+}
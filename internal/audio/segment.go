@@ -0,0 +1,216 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// SegmentOptions controls chunked processing of long recordings
+type SegmentOptions struct {
+	ChunkSec     float64 // length of each chunk, without the crossfade overlap
+	CrossfadeSec float64 // overlap crossfaded between consecutive chunks
+	Parallel     int     // chunks denoised concurrently
+}
+
+// ProcessSegmented splits a long input into overlapping chunks, denoises the chunks
+// in parallel, joins them back with crossfades and runs the post-denoise stages and
+// the mastering chain (loudnorm, compressor, limiter, resample) once over the joined
+// audio, so loudness stays consistent across chunk boundaries.
+// Inputs shorter than two chunks are handed to ProcessFile.
+func ProcessSegmented(ctx context.Context, inputPath, outputPath string, opts ProcessOptions, seg SegmentOptions) (*Stats, error) {
+	if opts.Engine == EngineNative {
+		return ProcessNative(ctx, inputPath, outputPath, opts)
+	}
+	inputPathAbs, _ := filepath.Abs(inputPath)
+	outputPathAbs, _ := filepath.Abs(outputPath)
+
+	total, err := GetDuration(ctx, inputPathAbs)
+	if err != nil {
+		return nil, err
+	}
+	custom, err := ParseCustomFilter(opts.CustomFilter)
+	if err != nil {
+		return nil, err
+	}
+	// a replacing custom chain has no denoise stage worth splitting
+	if seg.ChunkSec <= 0 || total < 2*seg.ChunkSec || (custom != "" && opts.CustomMode == CustomReplace) {
+		return ProcessFile(ctx, inputPath, outputPath, opts)
+	}
+	if seg.Parallel < 1 {
+		seg.Parallel = 1
+	}
+	if seg.CrossfadeSec < 0 || seg.CrossfadeSec >= seg.ChunkSec {
+		seg.CrossfadeSec = 0
+	}
+
+	noiseLevel, err := GetNoiseLevel(ctx, inputPathAbs)
+	if err != nil {
+		return nil, fmt.Errorf("noise level: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp(opts.scratchDir(outputPathAbs), "segments-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dnMethod := normalizeMethod(opts.DenoiseMethod)
+	chunkParts := preDenoiseFilters(opts)
+	if f := denoiseFilterFor(dnMethod, opts.DenoiseParams, opts.RNNoiseModel); f != "" {
+		chunkParts = append(chunkParts, f)
+	}
+	filter := strings.Join(chunkParts, ",")
+
+	spans := chunkSpans(total, seg.ChunkSec, seg.CrossfadeSec)
+	n := len(spans)
+	chunks := make([]string, n)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(seg.Parallel)
+	for i := 0; i < n; i++ {
+		i := i
+		start, length := spans[i].start, spans[i].length
+		chunks[i] = filepath.Join(tmpDir, fmt.Sprintf("chunk_%04d.wav", i))
+		g.Go(func() (err error) {
+			// chunk goroutines are outside the worker's recover, keep a panic local to the job
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("chunk %d panicked: %v", i, r)
+				}
+			}()
+			return denoiseChunk(gctx, inputPathAbs, chunks[i], start, length, dnMethod, filter)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, fmt.Errorf("segment denoise: %w", err)
+	}
+	log.Printf("segmented processing: %d chunks of %.0fs denoised (parallel=%d)", n, seg.ChunkSec, seg.Parallel)
+
+	joined := filepath.Join(tmpDir, "joined.wav")
+	if err := joinCrossfade(ctx, chunks, seg.CrossfadeSec, joined); err != nil {
+		return nil, fmt.Errorf("segment join: %w", err)
+	}
+
+	joinedLoudness, _ := MeasureLoudness(ctx, joined, opts.TargetLUFS)
+	post := postDenoiseFilters(opts)
+	measured, err := MeasureLoudnorm(ctx, joined, strings.Join(post, ","), opts.TargetLUFS)
+	if err != nil {
+		log.Printf("loudnorm first pass failed: %v — falling back to single-pass normalization", err)
+	}
+
+	// final mastering pass over the joined audio
+	final := strings.Join(withCustom(append(post, masteringFilters(opts, measured)...), custom), ",")
+	args := []string{
+		"-y",
+		"-i", joined,
+		"-af", final,
+		"-ar", strconv.Itoa(opts.SampleRate),
+		"-ac", strconv.Itoa(opts.Channels),
+		"-vn",
+	}
+	args = append(args, encoderArgs(opts)...)
+	args = append(args, outputPathAbs)
+	if err := runFFmpeg(ctx, args...); err != nil {
+		return nil, fmt.Errorf("final pass: %w", err)
+	}
+
+	stats := &Stats{NoiseLevel: noiseLevel, FilterChain: filter + " | " + final}
+	if d, err := GetDuration(ctx, outputPathAbs); err == nil {
+		stats.DurationSec = d
+	}
+	if l, err := MeasureLoudness(ctx, outputPathAbs, opts.TargetLUFS); err == nil {
+		stats.Loudness = l
+	} else {
+		stats.Loudness = joinedLoudness
+	}
+	played := stats.DurationSec
+	if opts.Tempo > 0 {
+		played *= opts.Tempo
+	}
+	if opts.TrimSilence && played > 0 && total > played {
+		stats.TrimmedSec = total - played
+	}
+	return stats, nil
+}
+
+// chunkSpan is the part of the input a chunk reads; length 0 reads to the end
+type chunkSpan struct {
+	start, length float64
+}
+
+// chunkSpans splits total seconds into chunks of chunkSec, each reading xfade
+// more to overlap the head of the next; the last reads to the end of the input.
+// acrossfade needs both of its inputs at least xfade long, so a remainder not
+// longer than xfade is folded into the chunk before it.
+func chunkSpans(total, chunkSec, xfade float64) []chunkSpan {
+	n := int(math.Ceil(total / chunkSec))
+	if n > 1 && total-float64(n-1)*chunkSec <= xfade {
+		n--
+	}
+	spans := make([]chunkSpan, n)
+	for i := range spans {
+		spans[i] = chunkSpan{start: float64(i) * chunkSec, length: chunkSec + xfade}
+	}
+	spans[n-1].length = 0
+	return spans
+}
+
+// denoiseChunk extracts [start, start+length) of the input, or from start to
+// the end when length is 0, and denoises it into out
+func denoiseChunk(ctx context.Context, inputPath, out string, start, length float64, dnMethod, filter string) error {
+	args := []string{"-y", "-ss", strconv.FormatFloat(start, 'f', 3, 64)}
+	if length > 0 {
+		args = append(args, "-t", strconv.FormatFloat(length, 'f', 3, 64))
+	}
+	args = append(args, "-i", inputPath)
+	if filter != "" {
+		args = append(args, "-af", filter)
+	}
+	args = append(args, "-c:a", "pcm_s16le", "-vn", out)
+	if err := runFFmpeg(ctx, args...); err != nil {
+		return err
+	}
+
+	if isSpectralMethod(dnMethod) {
+		denoised, err := spectralDenoise(ctx, out, filepath.Dir(out), dnMethod)
+		if err != nil {
+			log.Printf("%s failed on %s: %v — keeping chunk as is", dnMethod, filepath.Base(out), err)
+			return nil
+		}
+		return os.Rename(denoised, out)
+	}
+	return nil
+}
+
+// joinCrossfade concatenates chunks, crossfading xfade seconds between neighbours
+func joinCrossfade(ctx context.Context, chunks []string, xfade float64, out string) error {
+	args := []string{"-y"}
+	for _, c := range chunks {
+		args = append(args, "-i", c)
+	}
+	var graph strings.Builder
+	prev := "[0:a]"
+	for i := 1; i < len(chunks); i++ {
+		label := fmt.Sprintf("[x%d]", i)
+		if xfade > 0 {
+			fmt.Fprintf(&graph, "%s[%d:a]acrossfade=d=%s%s;", prev, i, stripTrailingZeros(xfade), label)
+		} else {
+			fmt.Fprintf(&graph, "%s[%d:a]concat=n=2:v=0:a=1%s;", prev, i, label)
+		}
+		prev = label
+	}
+	args = append(args,
+		"-filter_complex", strings.TrimSuffix(graph.String(), ";"),
+		"-map", prev,
+		"-c:a", "pcm_s16le",
+		out,
+	)
+	return runFFmpeg(ctx, args...)
+}
@@ -0,0 +1,27 @@
+package audio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChunkSpans(t *testing.T) {
+	for _, tc := range []struct {
+		name                   string
+		total, chunkSec, xfade float64
+		want                   []chunkSpan
+	}{
+		{"shorter than a chunk", 20, 60, 2, []chunkSpan{{0, 0}}},
+		{"exact multiple", 120, 60, 2, []chunkSpan{{0, 62}, {60, 0}}},
+		{"remainder longer than the crossfade kept", 125, 60, 2, []chunkSpan{{0, 62}, {60, 62}, {120, 0}}},
+		{"remainder shorter than the crossfade folded", 121, 60, 2, []chunkSpan{{0, 62}, {60, 0}}},
+		{"remainder equal to the crossfade folded", 122, 60, 2, []chunkSpan{{0, 62}, {60, 0}}},
+		{"no crossfade", 121, 60, 0, []chunkSpan{{0, 60}, {60, 60}, {120, 0}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := chunkSpans(tc.total, tc.chunkSec, tc.xfade); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("spans %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
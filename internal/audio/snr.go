@@ -0,0 +1,106 @@
+package audio
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// frame-energy VAD used for the SNR estimate
+const (
+	snrRate       = 16000
+	snrFrameSec   = 0.02
+	snrSpeechDB   = 10.0  // frames this far above the noise floor count as speech
+	snrNoiseDB    = 3.0   // frames within this of the floor count as noise
+	snrSilenceDB  = -90.0 // digital silence, ignored for the noise floor
+	snrFloorPctl  = 0.10  // percentile of frame levels taken as the noise floor
+	snrMaxDB      = 90.0  // reported when no noise is left at all
+	snrMinSpeechF = 10    // fewer speech frames than this give no estimate
+)
+
+// VADSNR estimates the SNR of a recording in dB as the power of its speech
+// frames over the power of its non-speech frames. Frames are classified by an
+// energy VAD relative to the noise floor (the 10th percentile of 20ms frame
+// levels), so it works for noisy calls where a fixed silence threshold doesn't.
+// ok is false when the recording has too little speech for an estimate.
+func VADSNR(ctx context.Context, path string) (snr float64, ok bool, err error) {
+	var levels []float64
+	err = streamPCM(ctx, path, snrRate, int(snrFrameSec*snrRate), func(frame []float64) error {
+		var e float64
+		for _, v := range frame {
+			e += v * v
+		}
+		levels = append(levels, powerDB(e/float64(len(frame))))
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	snr, ok = snrFromLevels(levels)
+	return snr, ok, nil
+}
+
+// snrFromLevels classifies frame levels (dBFS) into speech and noise and returns
+// the speech-to-noise power ratio in dB
+func snrFromLevels(levels []float64) (float64, bool) {
+	var audible []float64
+	for _, l := range levels {
+		if l > snrSilenceDB {
+			audible = append(audible, l)
+		}
+	}
+	floor, ok := noiseFloor(audible)
+	if !ok {
+		return 0, false
+	}
+
+	var speechP, noiseP float64
+	var speechN, noiseN int
+	for _, l := range audible {
+		switch {
+		case l >= floor+snrSpeechDB:
+			speechP += math.Pow(10, l/10)
+			speechN++
+		case l <= floor+snrNoiseDB:
+			noiseP += math.Pow(10, l/10)
+			noiseN++
+		}
+	}
+	if speechN < snrMinSpeechF {
+		return 0, false
+	}
+	speechP /= float64(speechN)
+	if noiseN == 0 {
+		return snrMaxDB, true
+	}
+	noiseP /= float64(noiseN)
+	// speech frames carry the noise too
+	if speechP <= noiseP {
+		return 0, true
+	}
+	return math.Min(10*math.Log10((speechP-noiseP)/noiseP), snrMaxDB), true
+}
+
+// noiseFloor returns the snrFloorPctl percentile of the frame levels above
+// digital silence, the noise floor of the SNR estimate
+func noiseFloor(levels []float64) (float64, bool) {
+	var audible []float64
+	for _, l := range levels {
+		if l > snrSilenceDB {
+			audible = append(audible, l)
+		}
+	}
+	if len(audible) == 0 {
+		return 0, false
+	}
+	sort.Float64s(audible)
+	return audible[int(snrFloorPctl*float64(len(audible)-1))], true
+}
+
+// powerDB converts a mean-square power to dBFS, -inf clamped to the silence level
+func powerDB(p float64) float64 {
+	if p <= 0 {
+		return snrSilenceDB - 1
+	}
+	return 10 * math.Log10(p)
+}
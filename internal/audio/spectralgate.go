@@ -0,0 +1,209 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// SpectralGateConf configures the native spectral gating denoiser
+type SpectralGateConf struct {
+	PropDecrease float64 // 0..1, how much of the gated noise is removed (1 = all)
+	NStd         float64 // bins louder than noise mean + NStd*std count as signal
+	NoisePct     float64 // quietest share of frames used as the noise profile
+	ReleaseSec   float64 // how long a bin stays open after signal, avoids musical noise
+}
+
+// DefaultSpectralGate mirrors the stationary settings of the noisereduce helper
+var DefaultSpectralGate = SpectralGateConf{PropDecrease: 1.0, NStd: 1.5, NoisePct: 0.2, ReleaseSec: 0.05}
+
+const (
+	gateSilenceDB   = -90.0 // digital silence is not noise
+	gateBucketCount = 120   // 1 dB frame-energy buckets from -120 to 0 dB
+	gateFreqSmooth  = 2     // bins averaged on each side of the mask
+)
+
+// gateFFTSize picks a ~32 ms power-of-two frame for the sample rate
+func gateFFTSize(rate int) int {
+	n := 256
+	for n < rate*32/1000 {
+		n <<= 1
+	}
+	return n
+}
+
+// SpectralGate denoises inputPath into a mono 16-bit WAV at outPath without
+// external tools besides the ffmpeg decoder: an STFT pass estimates the per-bin
+// noise profile from the quietest frames, a second pass gates every bin that does
+// not rise above the profile and resynthesizes the signal.
+func SpectralGate(ctx context.Context, inputPath, outPath string, conf SpectralGateConf) error {
+	rate, err := GetSampleRate(ctx, inputPath)
+	if err != nil {
+		return err
+	}
+	out, err := createWAV(outPath, rate)
+	if err != nil {
+		return err
+	}
+	stream := func(hop int, fn func([]float64) error) error {
+		return streamPCM(ctx, inputPath, rate, hop, fn)
+	}
+	if err := spectralGate(rate, stream, out.Write, conf); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// spectralGate gates the samples at rate read twice from stream, in chunks of
+// hop, and passes the result to emit
+func spectralGate(rate int, stream func(hop int, fn func([]float64) error) error, emit func([]float64) error, conf SpectralGateConf) error {
+	nfft := gateFFTSize(rate)
+	bins := nfft/2 + 1
+
+	// pass 1: per-bin dB statistics grouped by frame energy, so the noise profile
+	// can be taken from the quietest frames without keeping every spectrum
+	sums := make([][]float64, gateBucketCount)
+	sqs := make([][]float64, gateBucketCount)
+	counts := make([]int, gateBucketCount)
+	analyze := newSTFT(nfft, func(spec []complex128) {
+		db := make([]float64, bins)
+		energy := 0.0
+		for k := 0; k < bins; k++ {
+			p := real(spec[k])*real(spec[k]) + imag(spec[k])*imag(spec[k])
+			energy += p
+			db[k] = 10 * math.Log10(p+1e-12)
+		}
+		frameDB := 10 * math.Log10(energy/float64(nfft*nfft)+1e-12)
+		if frameDB < gateSilenceDB {
+			return
+		}
+		b := int(frameDB) + gateBucketCount
+		if b < 0 {
+			b = 0
+		} else if b >= gateBucketCount {
+			b = gateBucketCount - 1
+		}
+		if sums[b] == nil {
+			sums[b], sqs[b] = make([]float64, bins), make([]float64, bins)
+		}
+		for k, v := range db {
+			sums[b][k] += v
+			sqs[b][k] += v * v
+		}
+		counts[b]++
+	}, func([]float64) error { return nil })
+	if err := stream(analyze.hop, analyze.push); err != nil {
+		return fmt.Errorf("spectral gate analysis: %w", err)
+	}
+	thresh, err := noiseThreshold(sums, sqs, counts, bins, conf)
+	if err != nil {
+		return err
+	}
+
+	// pass 2: gate and resynthesize
+	release := math.Exp(-float64(nfft/4) / (float64(rate) * math.Max(conf.ReleaseSec, 1e-3)))
+	mask := make([]float64, bins)
+	smooth := make([]float64, bins)
+	gate := newSTFT(nfft, func(spec []complex128) {
+		for k := 0; k < bins; k++ {
+			p := real(spec[k])*real(spec[k]) + imag(spec[k])*imag(spec[k])
+			open := 0.0
+			if 10*math.Log10(p+1e-12) > thresh[k] {
+				open = 1
+			}
+			mask[k] = math.Max(open, mask[k]*release)
+		}
+		for k := 0; k < bins; k++ {
+			sum, n := 0.0, 0
+			for j := k - gateFreqSmooth; j <= k+gateFreqSmooth; j++ {
+				if j >= 0 && j < bins {
+					sum += mask[j]
+					n++
+				}
+			}
+			smooth[k] = sum / float64(n)
+		}
+		for k := 0; k < bins; k++ {
+			g := complex(1-conf.PropDecrease*(1-smooth[k]), 0)
+			spec[k] *= g
+			if k > 0 && k < nfft-k {
+				spec[nfft-k] *= g
+			}
+		}
+	}, emit)
+	if err := stream(gate.hop, gate.push); err != nil {
+		return fmt.Errorf("spectral gate: %w", err)
+	}
+	return gate.flush()
+}
+
+// noiseThreshold combines the quietest buckets up to conf.NoisePct of the frames
+// into the per-bin gate threshold mean + NStd*std (dB)
+func noiseThreshold(sums, sqs [][]float64, counts []int, bins int, conf SpectralGateConf) ([]float64, error) {
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("spectral gate: input has no audible frames")
+	}
+	want := int(math.Ceil(float64(total) * conf.NoisePct))
+	sum, sq := make([]float64, bins), make([]float64, bins)
+	n := 0
+	for b := 0; b < gateBucketCount && n < want; b++ {
+		if counts[b] == 0 {
+			continue
+		}
+		for k := 0; k < bins; k++ {
+			sum[k] += sums[b][k]
+			sq[k] += sqs[b][k]
+		}
+		n += counts[b]
+	}
+	thresh := make([]float64, bins)
+	for k := range thresh {
+		mean := sum[k] / float64(n)
+		std := math.Sqrt(math.Max(sq[k]/float64(n)-mean*mean, 0))
+		thresh[k] = mean + conf.NStd*std
+	}
+	return thresh, nil
+}
+
+// isSpectralMethod reports whether the denoise method is spectral gating done
+// before ffmpeg: "spectral_gate" (native) or "noisereduce" (python helper,
+// native fallback)
+func isSpectralMethod(dnMethod string) bool {
+	return dnMethod == "noisereduce" || dnMethod == "spectral_gate"
+}
+
+// spectralDenoise gates inputPath into a new WAV in dir and returns its path.
+// noisereduce uses the python helper when python and the script are installed
+// and the native SpectralGate otherwise.
+func spectralDenoise(ctx context.Context, inputPath, dir, dnMethod string) (string, error) {
+	if dnMethod == "noisereduce" && noisereduceAvailable() {
+		return runNoisereduce(ctx, inputPath, dir, DefaultSpectralGate.PropDecrease, "")
+	}
+	out := filepath.Join(dir, fmt.Sprintf("sg_out_%d_%s.wav", time.Now().UnixNano(), filepath.Base(inputPath)))
+	if err := SpectralGate(ctx, inputPath, out, DefaultSpectralGate); err != nil {
+		os.Remove(out)
+		return "", err
+	}
+	return out, nil
+}
+
+// noisereduceAvailable reports whether the python noisereduce helper can run here
+func noisereduceAvailable() bool {
+	if _, err := os.Stat(noisereduceScript); err != nil {
+		return false
+	}
+	if _, err := exec.LookPath("python"); err == nil {
+		return true
+	}
+	_, err := exec.LookPath("python3")
+	return err == nil
+}
@@ -0,0 +1,107 @@
+package audio
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// chunked is a stream for spectralGate over x
+func chunked(x []float64) func(hop int, fn func([]float64) error) error {
+	return func(hop int, fn func([]float64) error) error {
+		for i := 0; i < len(x); i += hop {
+			if err := fn(x[i:min(i+hop, len(x))]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// toneDB is the level in dB of freq in x, 0 for a full scale sine
+func toneDB(x []float64, freq float64, rate int) float64 {
+	return 10 * math.Log10(4*goertzel(x, freq, rate)+1e-20)
+}
+
+func TestSTFTIdentity(t *testing.T) {
+	rng := rand.New(rand.NewPCG(5, 6))
+	x := make([]float64, 1000)
+	for i := range x {
+		x[i] = rng.Float64()*2 - 1
+	}
+	var y []float64
+	s := newSTFT(256, func([]complex128) {}, func(out []float64) error {
+		y = append(y, out...)
+		return nil
+	})
+	if err := chunked(x)(s.hop, s.push); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(y) != len(x) {
+		t.Fatalf("%d samples out of %d", len(y), len(x))
+	}
+	// the first frames lack the overlap of earlier ones
+	for i := s.nfft; i < len(x); i++ {
+		if math.Abs(y[i]-x[i]) > 1e-9 {
+			t.Fatalf("sample %d: %v, want %v", i, y[i], x[i])
+		}
+	}
+}
+
+func TestSpectralGate(t *testing.T) {
+	const rate = 16000
+	rng := rand.New(rand.NewPCG(7, 8))
+	// 3 s of white noise with a quiet 3 kHz hum all along, below the gate
+	// threshold, and a loud 1 kHz tone in the middle second, above it
+	x := make([]float64, 3*rate)
+	for i := range x {
+		tt := float64(i) / rate
+		x[i] = 0.01*rng.NormFloat64() + 0.003*math.Sin(2*math.Pi*3000*tt)
+		if i >= rate && i < 2*rate {
+			x[i] += 0.3 * math.Sin(2*math.Pi*1000*tt)
+		}
+	}
+	var y []float64
+	emit := func(out []float64) error {
+		y = append(y, out...)
+		return nil
+	}
+	if err := spectralGate(rate, chunked(x), emit, DefaultSpectralGate); err != nil {
+		t.Fatal(err)
+	}
+	if len(y) != len(x) {
+		t.Fatalf("%d samples out of %d", len(y), len(x))
+	}
+
+	// away from the edges of the tone, where the mask opens and releases
+	quiet, tone := [2]int{rate / 10, rate * 9 / 10}, [2]int{rate * 11 / 10, rate * 19 / 10}
+	if in, out := rms(x[quiet[0]:quiet[1]]), rms(y[quiet[0]:quiet[1]]); 20*math.Log10(out/in) > -10 {
+		t.Errorf("noise attenuated %.1f dB, want at least 10", 20*math.Log10(in/out))
+	}
+	if in, out := toneDB(x[quiet[0]:quiet[1]], 3000, rate), toneDB(y[quiet[0]:quiet[1]], 3000, rate); in-out < 15 {
+		t.Errorf("hum below the threshold attenuated %.1f dB, want at least 15", in-out)
+	}
+	// a pure tone opens 3 of the 5 bins the mask is smoothed over, which takes
+	// about 3 dB off it
+	if in, out := toneDB(x[tone[0]:tone[1]], 1000, rate), toneDB(y[tone[0]:tone[1]], 1000, rate); in-out > 4 || in-out < 0 {
+		t.Errorf("tone above the threshold attenuated %.1f dB, want at most 4", in-out)
+	}
+}
+
+func TestSpectralGateSilence(t *testing.T) {
+	err := spectralGate(8000, chunked(make([]float64, 8000)), func([]float64) error { return nil }, DefaultSpectralGate)
+	if err == nil {
+		t.Error("digital silence gated")
+	}
+}
+
+func rms(x []float64) float64 {
+	sum := 0.0
+	for _, v := range x {
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(x)))
+}
@@ -0,0 +1,33 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// spectrogram image size, wide enough to tell speech from tones and hiss at a glance
+const spectrogramSize = "1024x512"
+
+// SpectrogramPath is where the spectrogram of the audio at path goes:
+// <name>_spectrogram.png next to it
+func SpectrogramPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "_spectrogram.png"
+}
+
+// RenderSpectrogram draws a spectrogram PNG (ffmpeg showspectrumpic, with legend)
+// of the audio at path (a file or a URL ffmpeg can read) to out, see SpectrogramPath
+func RenderSpectrogram(ctx context.Context, path, out string) error {
+	args := []string{
+		"-y",
+		"-i", path,
+		"-lavfi", "showspectrumpic=s=" + spectrogramSize + ":legend=1",
+		"-frames:v", "1",
+		out,
+	}
+	if err := runFFmpeg(ctx, args...); err != nil {
+		return fmt.Errorf("spectrogram: %w", err)
+	}
+	return nil
+}
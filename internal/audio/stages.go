@@ -0,0 +1,190 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// TrimConf configures leading/trailing silence trimming
+type TrimConf struct {
+	ThresholdDB float64 `yaml:"threshold_db"` // level below which audio counts as silence
+	PaddingSec  float64 `yaml:"padding_sec"`  // silence kept before the first and after the last sound
+}
+
+// defaults used when a TrimConf field is left at zero
+const (
+	defaultTrimThresholdDB = -50.0
+	defaultTrimPaddingSec  = 0.25
+)
+
+// trimFilter cuts leading and trailing silence (dial tone gaps, post-hangup air).
+// silenceremove only trims the start reliably, so the stream is reversed to
+// trim the end with the same settings and reversed back.
+func trimFilter(c TrimConf) string {
+	th := c.ThresholdDB
+	if th == 0 {
+		th = defaultTrimThresholdDB
+	}
+	pad := c.PaddingSec
+	if pad <= 0 {
+		pad = defaultTrimPaddingSec
+	}
+	head := fmt.Sprintf("silenceremove=start_periods=1:start_threshold=%vdB:start_silence=%s",
+		th, stripTrailingZeros(pad))
+	return head + ",areverse," + head + ",areverse"
+}
+
+// preDenoiseFilters returns the optional stages that run before denoising:
+// DC offset removal, declipping, high-pass against rumble, low-pass against out-of-band noise of narrowband calls
+// and dereverberation of speakerphone calls
+func preDenoiseFilters(opts ProcessOptions) []string {
+	filterParts := []string{}
+	if opts.DCRemove {
+		filterParts = append(filterParts, dcFilter(opts.DCOffset))
+	}
+	// repair clipped peaks first, the other stages would only smear the distortion
+	if opts.Declip {
+		filterParts = append(filterParts, "adeclip")
+	}
+	if opts.HighpassHz > 0 {
+		filterParts = append(filterParts, fmt.Sprintf("highpass=f=%d", opts.HighpassHz))
+	}
+	if opts.LowpassHz > 0 {
+		filterParts = append(filterParts, fmt.Sprintf("lowpass=f=%d", opts.LowpassHz))
+	}
+	if opts.Dereverb {
+		if f := dereverbFilter(); f != "" {
+			filterParts = append(filterParts, f)
+		}
+	}
+	return filterParts
+}
+
+// GateConf configures the agate noise gate
+type GateConf struct {
+	ThresholdDB float64 `yaml:"threshold_db"` // gate opens above this level
+	Ratio       float64 `yaml:"ratio"`        // attenuation ratio below the threshold
+	Attack      int     `yaml:"attack"`       // ms
+	Release     int     `yaml:"release"`      // ms
+}
+
+// defaults used when a GateConf field is left at zero
+var defaultGate = GateConf{ThresholdDB: -45, Ratio: 4, Attack: 10, Release: 250}
+
+// gateFilter attenuates low-level hiss between words so the compressor
+// does not pump it up. agate expects the threshold as linear amplitude.
+func gateFilter(c GateConf) string {
+	if c.ThresholdDB == 0 {
+		c.ThresholdDB = defaultGate.ThresholdDB
+	}
+	if c.Ratio <= 0 {
+		c.Ratio = defaultGate.Ratio
+	}
+	if c.Attack <= 0 {
+		c.Attack = defaultGate.Attack
+	}
+	if c.Release <= 0 {
+		c.Release = defaultGate.Release
+	}
+	return fmt.Sprintf("agate=threshold=%s:ratio=%v:attack=%d:release=%d",
+		stripTrailingZeros(dbToLinear(c.ThresholdDB)), c.Ratio, c.Attack, c.Release)
+}
+
+// DeesserConf configures the deesser stage
+type DeesserConf struct {
+	Intensity float64 `yaml:"intensity"` // 0..1, how strongly sibilance is reduced
+	Frequency float64 `yaml:"frequency"` // 0..1, normalized split frequency; higher only targets sharper "s" sounds
+}
+
+// deesserFilter tames sibilance that compression brings forward
+func deesserFilter(c DeesserConf) string {
+	if c.Intensity <= 0 || c.Intensity > 1 {
+		c.Intensity = 0.5
+	}
+	if c.Frequency <= 0 || c.Frequency > 1 {
+		c.Frequency = 0.5
+	}
+	return fmt.Sprintf("deesser=i=%s:f=%s", stripTrailingZeros(c.Intensity), stripTrailingZeros(c.Frequency))
+}
+
+// dbToLinear converts a dBFS level to linear amplitude clipped to the range
+// ffmpeg dynamics filters accept
+func dbToLinear(db float64) float64 {
+	lin := math.Pow(10.0, db/20.0)
+	if lin < 0.000976563 {
+		lin = 0.000976563
+	} else if lin > 1.0 {
+		lin = 1.0
+	}
+	return lin
+}
+
+// postDenoiseFilters returns the optional stages that run on the denoised
+// signal, before loudness normalization. They are part of the loudnorm
+// measurement pass so the measured values match what gets normalized.
+func postDenoiseFilters(opts ProcessOptions) []string {
+	filterParts := []string{}
+	if opts.UseGate {
+		filterParts = append(filterParts, gateFilter(opts.Gate))
+	}
+	if opts.TrimSilence {
+		filterParts = append(filterParts, trimFilter(opts.Trim))
+	}
+	if opts.VAD.RemoveGaps {
+		filterParts = append(filterParts, gapFilter(opts.VAD))
+	}
+	return filterParts
+}
+
+// Tempo modes
+const (
+	TempoRendition = "rendition" // extra sped-up file next to the normal output (default)
+	TempoMain      = "main"      // the main output itself is sped up
+)
+
+// ParseTempo validates a playback speed; 0 (unset) disables the tempo stage
+func ParseTempo(tempo float64, mode string) (float64, string, error) {
+	if tempo == 0 || tempo == 1 {
+		return 0, "", nil
+	}
+	if tempo < 0.5 || tempo > 4 {
+		return 0, "", fmt.Errorf("tempo must be between 0.5 and 4")
+	}
+	switch mode {
+	case "":
+		mode = TempoRendition
+	case TempoRendition, TempoMain:
+	default:
+		return 0, "", fmt.Errorf("unknown tempo_mode %q (want %s or %s)", mode, TempoRendition, TempoMain)
+	}
+	return tempo, mode, nil
+}
+
+// tempoFilter changes playback speed without shifting pitch. A single atempo
+// instance handles 0.5..2.0, faster speeds are chained.
+func tempoFilter(tempo float64) string {
+	parts := []string{}
+	for tempo > 2.0 {
+		parts = append(parts, "atempo=2")
+		tempo /= 2.0
+	}
+	parts = append(parts, "atempo="+stripTrailingZeros(tempo))
+	return strings.Join(parts, ",")
+}
+
+// RenderTempo encodes a sped-up copy of the processed file at outputPath
+// next to it (<name>_x<tempo>.<ext>) and returns its path.
+func RenderTempo(ctx context.Context, outputPath string, tempo float64, opts ProcessOptions) (string, error) {
+	ext := filepath.Ext(outputPath)
+	out := strings.TrimSuffix(outputPath, ext) + "_x" + stripTrailingZeros(tempo) + ext
+	args := []string{"-y", "-i", outputPath, "-af", tempoFilter(tempo), "-vn"}
+	args = append(args, encoderArgs(opts)...)
+	args = append(args, out)
+	if err := runFFmpeg(ctx, args...); err != nil {
+		return "", fmt.Errorf("tempo rendition: %w", err)
+	}
+	return out, nil
+}
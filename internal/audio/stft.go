@@ -0,0 +1,91 @@
+package audio
+
+// stft runs a streaming short-time Fourier transform with overlap-add
+// resynthesis: push hop-sized chunks of input, every full frame is handed to
+// fn as a spectrum (which fn may modify) and the resynthesized output is passed
+// to emit with the algorithmic delay removed. Hann window, hop = nfft/4.
+type stft struct {
+	nfft, hop int
+	window    []float64
+	in        []float64 // sliding analysis window
+	ola       []float64 // overlap-add accumulator
+	buf       []complex128
+	delay     int // output samples still to drop
+	pushed    int // input samples seen
+	emitted   int // output samples emitted
+	fn        func(spec []complex128)
+	emit      func(out []float64) error
+}
+
+func newSTFT(nfft int, fn func(spec []complex128), emit func(out []float64) error) *stft {
+	return &stft{
+		nfft:   nfft,
+		hop:    nfft / 4,
+		window: hann(nfft),
+		in:     make([]float64, nfft),
+		ola:    make([]float64, nfft),
+		buf:    make([]complex128, nfft),
+		delay:  nfft - nfft/4,
+		fn:     fn,
+		emit:   emit,
+	}
+}
+
+// push feeds up to hop samples; shorter chunks are zero padded
+func (s *stft) push(chunk []float64) error {
+	s.pushed += len(chunk)
+	copy(s.in, s.in[s.hop:])
+	tail := s.in[s.nfft-s.hop:]
+	n := copy(tail, chunk)
+	for i := n; i < len(tail); i++ {
+		tail[i] = 0
+	}
+	return s.frame()
+}
+
+func (s *stft) frame() error {
+	for i, v := range s.in {
+		s.buf[i] = complex(v*s.window[i], 0)
+	}
+	fft(s.buf)
+	s.fn(s.buf)
+	ifft(s.buf)
+	for i := range s.ola {
+		s.ola[i] += real(s.buf[i])
+	}
+	// a periodic Hann window at 75% overlap sums to 2
+	out := make([]float64, s.hop)
+	for i := range out {
+		out[i] = s.ola[i] / 2
+	}
+	copy(s.ola, s.ola[s.hop:])
+	for i := s.nfft - s.hop; i < s.nfft; i++ {
+		s.ola[i] = 0
+	}
+	if s.delay >= len(out) {
+		s.delay -= len(out)
+		return nil
+	}
+	out = out[s.delay:]
+	s.delay = 0
+	if rest := s.pushed - s.emitted; len(out) > rest {
+		out = out[:rest]
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	s.emitted += len(out)
+	return s.emit(out)
+}
+
+// flush pushes silence until every input sample has been emitted
+func (s *stft) flush() error {
+	pushed := s.pushed
+	for s.emitted < pushed {
+		if err := s.push(nil); err != nil {
+			return err
+		}
+		s.pushed = pushed
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// DeadAirMinSec is the shortest silence counted as dead air; shorter pauses are
+// part of normal conversation
+const DeadAirMinSec = 2.0
+
+// InterruptionMinSec is the shortest overtalk counted as an interruption; shorter
+// overlaps are mostly backchannel ("mm-hm") and turn-taking jitter
+const InterruptionMinSec = 0.5
+
+// TalkStats are the talk-time analytics of a call
+type TalkStats struct {
+	TalkSec           float64            `json:"talk_sec"`
+	DeadAirSec        float64            `json:"dead_air_sec"`
+	DeadAirPct        float64            `json:"dead_air_pct"` // 0..100 of the call duration
+	LongestSilenceSec float64            `json:"longest_silence_sec"`
+	Channels          map[string]float64 `json:"channels,omitempty"` // talk seconds per party of a stereo call
+
+	// stereo calls only: both parties speaking at once
+	OvertalkSec   *float64 `json:"overtalk_sec,omitempty"`
+	OvertalkPct   *float64 `json:"overtalk_pct,omitempty"`  // 0..100 of the call duration
+	Interruptions *int     `json:"interruptions,omitempty"` // overtalk stretches of at least InterruptionMinSec
+}
+
+// TalkTime measures talk time, dead air (silences of at least DeadAirMinSec) and
+// the longest silence of the file. Stereo files also get the talk time of each
+// party (agent left, customer right, see callChannels) and their overtalk.
+func TalkTime(ctx context.Context, path string, conf VADConf) (*TalkStats, error) {
+	total, silences, err := detectSilences(ctx, path, "", conf)
+	if err != nil {
+		return nil, err
+	}
+	st := &TalkStats{TalkSec: total}
+	for _, s := range silences {
+		d := s[1] - s[0]
+		st.TalkSec -= d
+		if d >= DeadAirMinSec {
+			st.DeadAirSec += d
+		}
+		if d > st.LongestSilenceSec {
+			st.LongestSilenceSec = d
+		}
+	}
+	if st.TalkSec < 0 {
+		st.TalkSec = 0
+	}
+	if total > 0 {
+		st.DeadAirPct = 100 * st.DeadAirSec / total
+	}
+
+	if n, err := GetChannels(ctx, path); err == nil && n == 2 {
+		st.Channels = map[string]float64{}
+		speech := make([][][2]float64, len(callChannels))
+		for i, party := range callChannels {
+			_, chSilences, err := detectSilences(ctx, path, fmt.Sprintf("pan=mono|c0=c%d", i), conf)
+			if err != nil {
+				return nil, fmt.Errorf("%s channel: %w", party, err)
+			}
+			talk := total
+			for _, s := range chSilences {
+				talk -= s[1] - s[0]
+			}
+			if talk < 0 {
+				talk = 0
+			}
+			st.Channels[party] = talk
+			speech[i] = speechIntervals(total, chSilences)
+		}
+
+		var overtalk float64
+		interruptions := 0
+		for _, o := range intersectIntervals(speech[0], speech[1]) {
+			overtalk += o[1] - o[0]
+			if o[1]-o[0] >= InterruptionMinSec {
+				interruptions++
+			}
+		}
+		pct := 0.0
+		if total > 0 {
+			pct = 100 * overtalk / total
+		}
+		st.OvertalkSec, st.OvertalkPct, st.Interruptions = &overtalk, &pct, &interruptions
+	}
+	return st, nil
+}
+
+// speechIntervals returns the complement of the sorted silences within [0, total]
+func speechIntervals(total float64, silences [][2]float64) [][2]float64 {
+	var speech [][2]float64
+	at := 0.0
+	for _, s := range silences {
+		if s[0] > at {
+			speech = append(speech, [2]float64{at, s[0]})
+		}
+		if s[1] > at {
+			at = s[1]
+		}
+	}
+	if at < total {
+		speech = append(speech, [2]float64{at, total})
+	}
+	return speech
+}
+
+// intersectIntervals returns the overlaps of two sorted interval lists
+func intersectIntervals(a, b [][2]float64) [][2]float64 {
+	var out [][2]float64
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		start := math.Max(a[i][0], b[j][0])
+		end := math.Min(a[i][1], b[j][1])
+		if start < end {
+			out = append(out, [2]float64{start, end})
+		}
+		if a[i][1] < b[j][1] {
+			i++
+		} else {
+			j++
+		}
+	}
+	return out
+}
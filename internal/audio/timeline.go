@@ -0,0 +1,66 @@
+package audio
+
+import (
+	"context"
+	"math"
+)
+
+// Timeline is the windowed quality of a job's input and output
+type Timeline struct {
+	WindowSec float64          `json:"window_sec"`
+	Input     []TimelineWindow `json:"input"`
+	Output    []TimelineWindow `json:"output,omitempty"`
+}
+
+// TimelineWindow holds the quality measurements of one window of a recording;
+// a nil value could not be measured there (silence, no speech)
+type TimelineWindow struct {
+	StartSec float64  `json:"start_sec"`
+	EndSec   float64  `json:"end_sec"`
+	Loudness *float64 `json:"loudness_lufs,omitempty"` // gated BS.1770 loudness of the window
+	SNR      *float64 `json:"snr,omitempty"`           // VAD based, like QualityMetrics.SNR
+	NoiseDB  *float64 `json:"noise_db,omitempty"`      // noise floor, dBFS
+}
+
+// QualityTimeline measures loudness, SNR and noise floor in consecutive windows
+// of windowSec, so a drop in quality can be located in the call. A trailing
+// window shorter than a second is dropped.
+func QualityTimeline(ctx context.Context, path string, windowSec float64) ([]TimelineWindow, error) {
+	windowLen := int(windowSec * snrRate)
+	frameLen := int(snrFrameSec * snrRate)
+	var timeline []TimelineWindow
+	start := 0.0
+	err := streamPCM(ctx, path, snrRate, windowLen, func(window []float64) error {
+		end := start + float64(len(window))/snrRate
+		defer func() { start = end }()
+		if len(window) < snrRate {
+			return nil
+		}
+		tw := TimelineWindow{StartSec: start, EndSec: end}
+		if l := integratedLoudness(window, snrRate); !math.IsInf(l, 0) {
+			tw.Loudness = &l
+		}
+
+		levels := make([]float64, 0, len(window)/frameLen+1)
+		for i := 0; i < len(window); i += frameLen {
+			frame := window[i:min(i+frameLen, len(window))]
+			var e float64
+			for _, v := range frame {
+				e += v * v
+			}
+			levels = append(levels, powerDB(e/float64(len(frame))))
+		}
+		if snr, ok := snrFromLevels(levels); ok {
+			tw.SNR = &snr
+		}
+		if floor, ok := noiseFloor(levels); ok {
+			tw.NoiseDB = &floor
+		}
+		timeline = append(timeline, tw)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return timeline, nil
+}
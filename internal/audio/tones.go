@@ -0,0 +1,195 @@
+package audio
+
+import (
+	"context"
+	"math"
+)
+
+// Segment labels for non-conversation audio
+const (
+	LabelBeep      = "beep"
+	LabelBusyTone  = "busy_tone"
+	LabelTone      = "tone" // long steady tone (dial tone, fax, ...)
+	LabelHoldMusic = "hold_music"
+)
+
+// LabeledSegment is a span of non-conversation audio
+type LabeledSegment struct {
+	Label    string  `json:"label"`
+	StartSec float64 `json:"start_sec"`
+	EndSec   float64 `json:"end_sec"`
+	FreqHz   float64 `json:"freq_hz,omitempty"` // dominant frequency of tones
+}
+
+const (
+	toneRate          = 8000
+	toneFrame         = 512 // 64 ms
+	toneMinPower      = 1e-5
+	toneTonality      = 0.7 // share of the frame energy around the spectral peak
+	toneMinHz         = 300.0
+	toneMaxHz         = 3400.0
+	beepMaxSec        = 1.5
+	burstMinSec       = 0.12
+	burstMaxSec       = 0.7
+	busyMinBursts     = 3
+	busyMaxGapSec     = 0.8
+	holdWindowSec     = 3.0
+	holdMinSec        = 10.0
+	holdActiveShare   = 0.95 // music rarely pauses, speech does
+	holdMaxStdDB      = 6.0  // and its level is steadier than speech
+	holdMinLevelDB    = -45.0
+	holdMaxTonalShare = 0.5 // steady tones are not music
+)
+
+// toneFrameInfo is the per-frame summary the detectors work on
+type toneFrameInfo struct {
+	levelDB float64
+	tonal   bool
+	freq    float64
+}
+
+// DetectTones labels recording beeps, busy tones, long steady tones and hold
+// music so downstream analytics can skip non-conversation audio. Tones are found
+// by spectral peak concentration, hold music by continuous, steady energy.
+func DetectTones(ctx context.Context, path string) ([]LabeledSegment, error) {
+	window := hann(toneFrame)
+	buf := make([]complex128, toneFrame)
+	var frames []toneFrameInfo
+	err := streamPCM(ctx, path, toneRate, toneFrame, func(frame []float64) error {
+		frames = append(frames, analyzeToneFrame(frame, window, buf))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	frameSec := float64(toneFrame) / toneRate
+	segs := toneSegments(frames, frameSec)
+	segs = append(segs, holdMusicSegments(frames, frameSec)...)
+	return segs, nil
+}
+
+func analyzeToneFrame(frame, window []float64, buf []complex128) toneFrameInfo {
+	ps := powerSpectrum(frame, window, buf)
+	total, peak, peakK := 0.0, 0.0, 0
+	for k, p := range ps {
+		total += p
+		if p > peak {
+			peak, peakK = p, k
+		}
+	}
+	n := float64(len(frame))
+	level := 0.0
+	for _, x := range frame {
+		level += x * x
+	}
+	info := toneFrameInfo{levelDB: 10 * math.Log10(level/n+1e-12)}
+	if level/n < toneMinPower || total == 0 {
+		return info
+	}
+	around := 0.0
+	for k := peakK - 2; k <= peakK+2; k++ {
+		if k >= 0 && k < len(ps) {
+			around += ps[k]
+		}
+	}
+	freq := float64(peakK) * toneRate / float64(len(buf))
+	info.tonal = around/total >= toneTonality && freq >= toneMinHz && freq <= toneMaxHz
+	info.freq = freq
+	return info
+}
+
+// toneSegments groups tonal frames into bursts and classifies them: regular
+// bursts at one frequency form a busy tone, short isolated bursts are beeps and
+// long ones are steady tones
+func toneSegments(frames []toneFrameInfo, frameSec float64) []LabeledSegment {
+	type burst struct{ start, end, freq float64 }
+	var bursts []burst
+	for i := 0; i < len(frames); {
+		if !frames[i].tonal {
+			i++
+			continue
+		}
+		j := i
+		for j < len(frames) && frames[j].tonal && math.Abs(frames[j].freq-frames[i].freq) <= 2*toneRate/float64(toneFrame) {
+			j++
+		}
+		bursts = append(bursts, burst{float64(i) * frameSec, float64(j) * frameSec, frames[i].freq})
+		i = j
+	}
+
+	var out []LabeledSegment
+	for i := 0; i < len(bursts); {
+		// try to extend a busy-tone cadence from here
+		j := i + 1
+		for j < len(bursts) {
+			prev, b := bursts[j-1], bursts[j]
+			if math.Abs(b.freq-prev.freq) > 2*toneRate/float64(toneFrame) || b.start-prev.end > busyMaxGapSec ||
+				b.end-b.start < burstMinSec || b.end-b.start > burstMaxSec {
+				break
+			}
+			j++
+		}
+		first := bursts[i]
+		if j-i >= busyMinBursts && first.end-first.start >= burstMinSec && first.end-first.start <= burstMaxSec {
+			out = append(out, LabeledSegment{Label: LabelBusyTone, StartSec: first.start, EndSec: bursts[j-1].end, FreqHz: first.freq})
+			i = j
+			continue
+		}
+		d := first.end - first.start
+		switch {
+		case d >= burstMinSec && d <= beepMaxSec:
+			out = append(out, LabeledSegment{Label: LabelBeep, StartSec: first.start, EndSec: first.end, FreqHz: first.freq})
+		case d > beepMaxSec:
+			out = append(out, LabeledSegment{Label: LabelTone, StartSec: first.start, EndSec: first.end, FreqHz: first.freq})
+		}
+		i++
+	}
+	return out
+}
+
+// holdMusicSegments flags windows whose energy never drops into speech pauses
+// and stays steady, merged into spans of at least holdMinSec
+func holdMusicSegments(frames []toneFrameInfo, frameSec float64) []LabeledSegment {
+	per := int(holdWindowSec / frameSec)
+	if per == 0 {
+		return nil
+	}
+	var out []LabeledSegment
+	var cur *LabeledSegment
+	flush := func() {
+		if cur != nil && cur.EndSec-cur.StartSec >= holdMinSec {
+			out = append(out, *cur)
+		}
+		cur = nil
+	}
+	for w := 0; w+per <= len(frames); w += per {
+		win := frames[w : w+per]
+		active, tonal := 0, 0
+		var sum, sumSq float64
+		for _, f := range win {
+			if f.levelDB > holdMinLevelDB {
+				active++
+			}
+			if f.tonal {
+				tonal++
+			}
+			sum += f.levelDB
+			sumSq += f.levelDB * f.levelDB
+		}
+		n := float64(len(win))
+		mean := sum / n
+		std := math.Sqrt(math.Max(sumSq/n-mean*mean, 0))
+		music := float64(active)/n >= holdActiveShare && std <= holdMaxStdDB && float64(tonal)/n <= holdMaxTonalShare
+		start, end := float64(w)*frameSec, float64(w+per)*frameSec
+		switch {
+		case music && cur != nil:
+			cur.EndSec = end
+		case music:
+			cur = &LabeledSegment{Label: LabelHoldMusic, StartSec: start, EndSec: end}
+		default:
+			flush()
+		}
+	}
+	flush()
+	return out
+}
@@ -0,0 +1,88 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// jsonTool is an external analysis backend answering with JSON about an audio file:
+//
+//	http(s)://host/path   the file is POSTed as the request body
+//	cmd:<command> [args]  a subprocess, the file path is appended to its args
+type jsonTool struct {
+	url    string
+	args   []string
+	client *http.Client
+}
+
+// parseJSONTool parses a tool spec; what names the tool in errors
+func parseJSONTool(what, spec string) (*jsonTool, error) {
+	switch {
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return &jsonTool{url: spec, client: &http.Client{Timeout: 10 * time.Minute}}, nil
+	case strings.HasPrefix(spec, "cmd:"):
+		args := strings.Fields(strings.TrimPrefix(spec, "cmd:"))
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s: empty command", what)
+		}
+		return &jsonTool{args: args}, nil
+	}
+	return nil, fmt.Errorf("%s: unsupported backend %q (want http(s)://... or cmd:...)", what, spec)
+}
+
+// call runs the tool on path and decodes its JSON answer into v
+func (t *jsonTool) call(ctx context.Context, path string, v interface{}) error {
+	if t.url != "" {
+		return t.post(ctx, path, v)
+	}
+	runCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+	cmd := newCmd(runCtx, t.args[0], append(t.args[1:], path)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := runCmd(cmd); err != nil {
+		return fmt.Errorf("%s failed: %w - stderr: %s", t.args[0], err, stderr.String())
+	}
+	if err := json.Unmarshal(stdout.Bytes(), v); err != nil {
+		return fmt.Errorf("parse %s output: %w", t.args[0], err)
+	}
+	return nil
+}
+
+func (t *jsonTool) post(ctx context.Context, path string, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, f)
+	if err != nil {
+		return err
+	}
+	ct := mime.TypeByExtension(filepath.Ext(path))
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", ct)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", t.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", t.url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s response: %w", t.url, err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+)
+
+// Word is a transcribed word with its timing on the transcribed file
+type Word struct {
+	Word       string  `json:"word"`
+	StartSec   float64 `json:"start"`
+	EndSec     float64 `json:"end"`
+	Confidence float64 `json:"probability,omitempty"`
+}
+
+// TranscriptSegment is a sentence-like stretch of the transcript
+type TranscriptSegment struct {
+	StartSec float64 `json:"start"`
+	EndSec   float64 `json:"end"`
+	Text     string  `json:"text"`
+}
+
+// Transcript is the ASR result of a recording
+type Transcript struct {
+	Language string              `json:"language,omitempty"`
+	Model    string              `json:"model,omitempty"`
+	Text     string              `json:"text"`
+	Segments []TranscriptSegment `json:"segments,omitempty"`
+	Words    []Word              `json:"words,omitempty"` // needed for keyword and redaction timestamps
+}
+
+// Transcriber turns speech into a Transcript with word timestamps
+type Transcriber interface {
+	Transcribe(ctx context.Context, path string) (*Transcript, error)
+}
+
+// NewTranscriber builds a transcriber from a spec (see jsonTool), e.g.
+// "cmd:python3 tools/transcribe_whisper.py" or an ASR service URL answering the
+// Transcript JSON. An empty spec returns nil (transcription disabled).
+func NewTranscriber(spec string) (Transcriber, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	t, err := parseJSONTool("transcriber", spec)
+	if err != nil {
+		return nil, err
+	}
+	return toolTranscriber{t}, nil
+}
+
+type toolTranscriber struct {
+	tool *jsonTool
+}
+
+func (t toolTranscriber) Transcribe(ctx context.Context, path string) (*Transcript, error) {
+	var tr Transcript
+	if err := t.tool.call(ctx, path, &tr); err != nil {
+		return nil, fmt.Errorf("transcribe: %w", err)
+	}
+	return &tr, nil
+}
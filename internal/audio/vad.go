@@ -0,0 +1,126 @@
+package audio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VADConf configures the silencedetect based voice activity detection
+type VADConf struct {
+	ThresholdDB   float64 `yaml:"threshold_db"`    // level below which audio counts as non-speech
+	MinSilenceSec float64 `yaml:"min_silence_sec"` // shorter pauses count as speech
+	RemoveGaps    bool    `yaml:"remove_gaps"`     // shorten long non-speech gaps in the output
+	MaxGapSec     float64 `yaml:"max_gap_sec"`     // silence kept of each removed gap
+}
+
+// defaults used when a VADConf field is left at zero
+const (
+	defaultVADThresholdDB   = -35.0
+	defaultVADMinSilenceSec = 0.5
+	defaultVADMaxGapSec     = 1.0
+)
+
+func (c VADConf) withDefaults() VADConf {
+	if c.ThresholdDB == 0 {
+		c.ThresholdDB = defaultVADThresholdDB
+	}
+	if c.MinSilenceSec <= 0 {
+		c.MinSilenceSec = defaultVADMinSilenceSec
+	}
+	if c.MaxGapSec <= 0 {
+		c.MaxGapSec = defaultVADMaxGapSec
+	}
+	return c
+}
+
+// SpeechStats summarizes voice activity over a recording
+type SpeechStats struct {
+	SpeechSec   float64 `json:"speech_sec"`
+	SilenceSec  float64 `json:"silence_sec"`
+	SpeechRatio float64 `json:"speech_ratio"` // speech / total duration, 0..1
+	Pauses      int     `json:"pauses"`       // silences of at least MinSilenceSec
+}
+
+var (
+	silenceStartRe = regexp.MustCompile(`silence_start:\s*([-+]?[0-9]*\.?[0-9]+)`)
+	silenceEndRe   = regexp.MustCompile(`silence_end:\s*([-+]?[0-9]*\.?[0-9]+)`)
+)
+
+// DetectSpeech runs ffmpeg silencedetect over the file and derives speech and
+// silence time from the detected silences; everything that is not silence counts as speech.
+func DetectSpeech(ctx context.Context, path string, conf VADConf) (*SpeechStats, error) {
+	total, silences, err := detectSilences(ctx, path, "", conf)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &SpeechStats{Pauses: len(silences)}
+	for _, s := range silences {
+		st.SilenceSec += s[1] - s[0]
+	}
+	if st.SilenceSec > total {
+		st.SilenceSec = total
+	}
+	st.SpeechSec = total - st.SilenceSec
+	if total > 0 {
+		st.SpeechRatio = st.SpeechSec / total
+	}
+	return st, nil
+}
+
+// detectSilences runs silencedetect over the file, after the optional pre filter
+// (e.g. a pan selecting one channel), and returns the duration and the silences
+func detectSilences(ctx context.Context, path, pre string, conf VADConf) (float64, [][2]float64, error) {
+	conf = conf.withDefaults()
+	total, err := GetDuration(ctx, path)
+	if err != nil {
+		return 0, nil, err
+	}
+	filter := fmt.Sprintf("silencedetect=noise=%vdB:d=%s", conf.ThresholdDB, stripTrailingZeros(conf.MinSilenceSec))
+	if pre != "" {
+		filter = pre + "," + filter
+	}
+	out, err := ffmpegStderr(ctx, "-hide_banner", "-nostats", "-i", path, "-af", filter, "-f", "null", "-")
+	if err != nil {
+		return 0, nil, fmt.Errorf("silencedetect: %w", err)
+	}
+	return total, parseSilences(out, total), nil
+}
+
+// parseSilences pairs silence_start/silence_end lines into [start, end] intervals.
+// A silence still open at the end of the stream is closed at total.
+func parseSilences(out string, total float64) [][2]float64 {
+	var res [][2]float64
+	start := -1.0
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		if m := silenceStartRe.FindStringSubmatch(line); m != nil {
+			start, _ = strconv.ParseFloat(m[1], 64)
+			if start < 0 {
+				start = 0
+			}
+			continue
+		}
+		if m := silenceEndRe.FindStringSubmatch(line); m != nil && start >= 0 {
+			end, _ := strconv.ParseFloat(m[1], 64)
+			res = append(res, [2]float64{start, end})
+			start = -1
+		}
+	}
+	if start >= 0 && total > start {
+		res = append(res, [2]float64{start, total})
+	}
+	return res
+}
+
+// gapFilter shortens every non-speech gap longer than MaxGapSec down to MaxGapSec
+func gapFilter(c VADConf) string {
+	c = c.withDefaults()
+	return fmt.Sprintf("silenceremove=stop_periods=-1:stop_threshold=%vdB:stop_duration=%s:stop_silence=%s",
+		c.ThresholdDB, stripTrailingZeros(c.MaxGapSec), stripTrailingZeros(c.MaxGapSec))
+}
@@ -0,0 +1,82 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"math"
+	"os"
+)
+
+// wavWriter writes mono 16-bit PCM WAV files; the header sizes are patched on Close
+type wavWriter struct {
+	f       *os.File
+	w       *bufio.Writer
+	samples uint32
+}
+
+func createWAV(path string, rate int) (*wavWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	ww := &wavWriter{f: f, w: bufio.NewWriterSize(f, 64<<10)}
+	if err := ww.header(rate); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return ww, nil
+}
+
+func (ww *wavWriter) header(rate int) error {
+	h := make([]byte, 44)
+	copy(h[0:], "RIFF")
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(h[22:], 1) // mono
+	binary.LittleEndian.PutUint32(h[24:], uint32(rate))
+	binary.LittleEndian.PutUint32(h[28:], uint32(rate*2))
+	binary.LittleEndian.PutUint16(h[32:], 2)
+	binary.LittleEndian.PutUint16(h[34:], 16)
+	copy(h[36:], "data")
+	_, err := ww.w.Write(h)
+	return err
+}
+
+// Write appends samples in [-1, 1], clipping values outside the range
+func (ww *wavWriter) Write(samples []float64) error {
+	b := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		v := math.Round(s * 32767)
+		if v > 32767 {
+			v = 32767
+		} else if v < -32768 {
+			v = -32768
+		}
+		binary.LittleEndian.PutUint16(b[2*i:], uint16(int16(v)))
+	}
+	ww.samples += uint32(len(samples))
+	_, err := ww.w.Write(b)
+	return err
+}
+
+// Close flushes the data and fixes the RIFF and data chunk sizes
+func (ww *wavWriter) Close() error {
+	if err := ww.w.Flush(); err != nil {
+		ww.f.Close()
+		return err
+	}
+	size := make([]byte, 4)
+	dataBytes := ww.samples * 2
+	binary.LittleEndian.PutUint32(size, 36+dataBytes)
+	if _, err := ww.f.WriteAt(size, 4); err != nil {
+		ww.f.Close()
+		return err
+	}
+	binary.LittleEndian.PutUint32(size, dataBytes)
+	if _, err := ww.f.WriteAt(size, 40); err != nil {
+		ww.f.Close()
+		return err
+	}
+	return ww.f.Close()
+}
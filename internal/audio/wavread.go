@@ -0,0 +1,154 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// wavInfo describes the PCM data of a WAV file
+type wavInfo struct {
+	Rate       int
+	Channels   int
+	Bits       int
+	Float      bool
+	DataOffset int64
+	DataBytes  int64
+}
+
+// Duration returns the length of the PCM data in seconds
+func (w wavInfo) Duration() float64 {
+	frame := int64(w.Channels * w.Bits / 8)
+	if frame == 0 || w.Rate == 0 {
+		return 0
+	}
+	return float64(w.DataBytes/frame) / float64(w.Rate)
+}
+
+var errNotWAV = errors.New("not a PCM WAV file")
+
+// readWAVHeader parses the RIFF header of a PCM (integer or float) WAV file
+func readWAVHeader(r io.ReadSeeker) (wavInfo, error) {
+	var info wavInfo
+	hdr := make([]byte, 12)
+	if _, err := io.ReadFull(r, hdr); err != nil || string(hdr[0:4]) != "RIFF" || string(hdr[8:12]) != "WAVE" {
+		return info, errNotWAV
+	}
+	pos := int64(12)
+	haveFmt := false
+	for {
+		ch := make([]byte, 8)
+		if _, err := io.ReadFull(r, ch); err != nil {
+			return info, errNotWAV
+		}
+		id, size := string(ch[0:4]), int64(binary.LittleEndian.Uint32(ch[4:8]))
+		pos += 8
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return info, errNotWAV
+			}
+			f := make([]byte, size)
+			if _, err := io.ReadFull(r, f); err != nil {
+				return info, errNotWAV
+			}
+			format := binary.LittleEndian.Uint16(f[0:2])
+			if format == 0xFFFE && size >= 26 { // WAVE_FORMAT_EXTENSIBLE, sub format in the GUID
+				format = binary.LittleEndian.Uint16(f[24:26])
+			}
+			info.Channels = int(binary.LittleEndian.Uint16(f[2:4]))
+			info.Rate = int(binary.LittleEndian.Uint32(f[4:8]))
+			info.Bits = int(binary.LittleEndian.Uint16(f[14:16]))
+			switch {
+			case format == 1 && (info.Bits == 16 || info.Bits == 24 || info.Bits == 32):
+			case format == 3 && info.Bits == 32:
+				info.Float = true
+			default:
+				return info, fmt.Errorf("unsupported WAV encoding (format %d, %d bits)", format, info.Bits)
+			}
+			haveFmt = true
+		case "data":
+			if !haveFmt {
+				return info, errNotWAV
+			}
+			info.DataOffset, info.DataBytes = pos, size
+			return info, nil
+		default:
+			if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+				return info, errNotWAV
+			}
+		}
+		pos += size
+		if size%2 == 1 { // chunks are word aligned
+			if _, err := r.Seek(1, io.SeekCurrent); err != nil {
+				return info, errNotWAV
+			}
+			pos++
+		}
+	}
+}
+
+// wavDuration returns the duration of a WAV file from its header
+func wavDuration(path string) (float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := readWAVHeader(f)
+	if err != nil {
+		return 0, err
+	}
+	return info.Duration(), nil
+}
+
+// readWAVMono decodes a PCM WAV file into mono samples in [-1, 1], averaging channels
+func readWAVMono(path string) ([]float64, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	info, err := readWAVHeader(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := f.Seek(info.DataOffset, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	width := info.Bits / 8
+	frameBytes := width * info.Channels
+	frames := info.DataBytes / int64(frameBytes)
+	out := make([]float64, 0, frames)
+	r := bufio.NewReaderSize(io.LimitReader(f, info.DataBytes), 64<<10)
+	buf := make([]byte, frameBytes)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			break // a truncated last frame is dropped
+		}
+		sum := 0.0
+		for c := 0; c < info.Channels; c++ {
+			sum += decodeSample(buf[c*width:(c+1)*width], info)
+		}
+		out = append(out, sum/float64(info.Channels))
+	}
+	return out, info.Rate, nil
+}
+
+func decodeSample(b []byte, info wavInfo) float64 {
+	switch {
+	case info.Float:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case info.Bits == 16:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / 32768.0
+	case info.Bits == 24:
+		v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+		return float64(v) / 8388608.0
+	default:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / 2147483648.0
+	}
+}
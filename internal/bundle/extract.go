@@ -0,0 +1,171 @@
+package bundle
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Limits protect the worker against archive bombs
+type Limits struct {
+	MaxFiles int   // maximum number of extracted files
+	MaxBytes int64 // maximum total uncompressed size
+}
+
+// DefaultLimits fit a daily telephony export
+var DefaultLimits = Limits{MaxFiles: 5000, MaxBytes: 20 << 30}
+
+// IsArchive reports whether a file name looks like a supported bundle
+func IsArchive(name string) bool {
+	return archiveType(name) != ""
+}
+
+func archiveType(name string) string {
+	n := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(n, ".zip"):
+		return "zip"
+	case strings.HasSuffix(n, ".tar.gz"), strings.HasSuffix(n, ".tgz"):
+		return "tgz"
+	case strings.HasSuffix(n, ".tar"):
+		return "tar"
+	}
+	return ""
+}
+
+// Extract unpacks the regular files of a zip/tar/tar.gz archive into destDir and
+// returns their paths. Directory structure is flattened (names are reduced to their
+// base name and made unique), hidden files and macOS metadata are skipped.
+func Extract(archivePath, destDir string, limits Limits) ([]string, error) {
+	x := &extractor{dest: destDir, limits: limits, used: map[string]int{}}
+	var err error
+	switch archiveType(archivePath) {
+	case "zip":
+		err = x.zip(archivePath)
+	case "tgz", "tar":
+		err = x.tar(archivePath, archiveType(archivePath) == "tgz")
+	default:
+		err = fmt.Errorf("unsupported archive %s", filepath.Base(archivePath))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return x.files, nil
+}
+
+type extractor struct {
+	dest   string
+	limits Limits
+	files  []string
+	total  int64
+	used   map[string]int
+}
+
+func (x *extractor) zip(path string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || skip(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = x.write(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) tar(path string, gzipped bool) error {
+	fh, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	var r io.Reader = fh
+	if gzipped {
+		gz, err := gzip.NewReader(fh)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg || skip(hdr.Name) {
+			continue
+		}
+		if err := x.write(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// write copies one entry to destDir, enforcing the limits
+func (x *extractor) write(name string, r io.Reader) error {
+	if x.limits.MaxFiles > 0 && len(x.files) >= x.limits.MaxFiles {
+		return fmt.Errorf("archive has more than %d files", x.limits.MaxFiles)
+	}
+	base := filepath.Base(filepath.Clean("/" + name)) // never escapes destDir
+	// used counts the entries named base, numbering the next one, and marks the
+	// names taken so a generated call_1.wav doesn't overwrite a real one
+	unique := base
+	for x.used[unique] > 0 {
+		ext := filepath.Ext(base)
+		unique = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(base, ext), x.used[base], ext)
+		x.used[base]++
+	}
+	x.used[unique]++
+
+	out := filepath.Join(x.dest, unique)
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	remaining := int64(-1)
+	if x.limits.MaxBytes > 0 {
+		remaining = x.limits.MaxBytes - x.total
+	}
+	var n int64
+	if remaining >= 0 {
+		n, err = io.Copy(f, io.LimitReader(r, remaining+1))
+	} else {
+		n, err = io.Copy(f, r)
+	}
+	f.Close()
+	if err != nil {
+		return err
+	}
+	x.total += n
+	if x.limits.MaxBytes > 0 && x.total > x.limits.MaxBytes {
+		return fmt.Errorf("archive expands to more than %d bytes", x.limits.MaxBytes)
+	}
+	x.files = append(x.files, out)
+	return nil
+}
+
+func skip(name string) bool {
+	base := filepath.Base(name)
+	return strings.HasPrefix(base, ".") || strings.Contains(name, "__MACOSX")
+}
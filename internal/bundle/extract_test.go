@@ -0,0 +1,159 @@
+package bundle
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type entry struct {
+	name, body string
+}
+
+func writeZip(t *testing.T, entries ...entry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e.body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return writeArchive(t, "bundle.zip", buf.Bytes())
+}
+
+func writeTarGz(t *testing.T, entries ...entry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(e.body))
+	}
+	tw.Close()
+	gz.Close()
+	return writeArchive(t, "bundle.tar.gz", buf.Bytes())
+}
+
+func writeArchive(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// extracted maps the base names of the extracted files to their contents
+func extracted(t *testing.T, dest string, files []string) map[string]string {
+	t.Helper()
+	got := map[string]string{}
+	for _, f := range files {
+		if filepath.Dir(f) != dest {
+			t.Errorf("%s extracted outside %s", f, dest)
+		}
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[filepath.Base(f)] = string(b)
+	}
+	return got
+}
+
+func TestExtract(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		archive func(*testing.T, ...entry) string
+		entries []entry
+		want    map[string]string
+	}{
+		{
+			"zip slip", writeZip,
+			[]entry{{"../../evil.wav", "a"}, {"/abs/root.wav", "b"}, {"dir/../../up.wav", "c"}},
+			map[string]string{"evil.wav": "a", "root.wav": "b", "up.wav": "c"},
+		},
+		{
+			"tar slip", writeTarGz,
+			[]entry{{"../../evil.wav", "a"}, {"/abs/root.wav", "b"}},
+			map[string]string{"evil.wav": "a", "root.wav": "b"},
+		},
+		{
+			"duplicates", writeZip,
+			[]entry{{"call.wav", "a"}, {"dir/call.wav", "b"}, {"call_1.wav", "c"}},
+			map[string]string{"call.wav": "a", "call_1.wav": "b", "call_1_1.wav": "c"},
+		},
+		{
+			"generated name taken first", writeTarGz,
+			[]entry{{"call_1.wav", "a"}, {"call.wav", "b"}, {"dir/call.wav", "c"}},
+			map[string]string{"call_1.wav": "a", "call.wav": "b", "call_2.wav": "c"},
+		},
+		{
+			"hidden and metadata skipped", writeZip,
+			[]entry{{".DS_Store", "x"}, {"__MACOSX/._call.wav", "x"}, {"call.wav", "a"}},
+			map[string]string{"call.wav": "a"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dest := t.TempDir()
+			files, err := Extract(tc.archive(t, tc.entries...), dest, DefaultLimits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := extracted(t, dest, files); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("extracted %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	entries := []entry{{"a.wav", "1234"}, {"b.wav", "5678"}, {"c.wav", "9"}}
+	for _, tc := range []struct {
+		name    string
+		limits  Limits
+		wantErr string
+	}{
+		{"within", Limits{MaxFiles: 3, MaxBytes: 9}, ""},
+		{"too many files", Limits{MaxFiles: 2}, "more than 2 files"},
+		{"too many bytes", Limits{MaxBytes: 8}, "more than 8 bytes"},
+		{"one entry too big", Limits{MaxBytes: 3}, "more than 3 bytes"},
+	} {
+		for _, archive := range []func(*testing.T, ...entry) string{writeZip, writeTarGz} {
+			path := archive(t, entries...)
+			t.Run(tc.name+" "+filepath.Base(path), func(t *testing.T) {
+				files, err := Extract(path, t.TempDir(), tc.limits)
+				if tc.wantErr == "" {
+					if err != nil || len(files) != len(entries) {
+						t.Fatalf("files %v, err %v", files, err)
+					}
+					return
+				}
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err %v, want %q", err, tc.wantErr)
+				}
+			})
+		}
+	}
+}
+
+func TestExtractUnsupported(t *testing.T) {
+	if _, err := Extract(writeArchive(t, "calls.rar", nil), t.TempDir(), DefaultLimits); err == nil {
+		t.Fatal("rar extracted")
+	}
+}
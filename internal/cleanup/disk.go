@@ -0,0 +1,134 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/metrics"
+)
+
+var (
+	// ErrDiskFull is returned by DiskGuard.Check while a filesystem of its dirs
+	// is used above the high watermark
+	ErrDiskFull = errors.New("disk usage above the high watermark")
+	// ErrQuotaExceeded is returned by DiskGuard.Check while its dirs hold more
+	// than the quota
+	ErrQuotaExceeded = errors.New("local storage quota exceeded")
+)
+
+// DiskGuard measures the disk usage of a set of directories periodically, so
+// new work can be refused before a filesystem fills up mid-job. Under pressure
+// it sweeps Sweeper once more with AggressiveMaxAge before it reports it.
+type DiskGuard struct {
+	Dirs             []string
+	HighWatermark    float64       // used fraction of a filesystem of Dirs, 0 disables
+	MaxBytes         int64         // quota of the files in Dirs together, 0 disables
+	Sweeper          *Sweeper      // swept under pressure; nil for none
+	AggressiveMaxAge time.Duration // age of the entries removed under pressure
+
+	mu  sync.RWMutex
+	err error // of the last measurement
+}
+
+// Check returns ErrDiskFull or ErrQuotaExceeded as of the last measurement; a
+// nil guard never does
+func (g *DiskGuard) Check() error {
+	if g == nil {
+		return nil
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.err
+}
+
+// Update measures the dirs, cleaning up under pressure, and returns the
+// result of Check
+func (g *DiskGuard) Update() error {
+	err := g.measure()
+	if err != nil && g.Sweeper != nil {
+		s := *g.Sweeper
+		s.MaxAge = g.AggressiveMaxAge
+		n, serr := s.Sweep()
+		if serr != nil {
+			log.Printf("[cleanup] aggressive sweep %v: %v", s.Dirs, serr)
+		}
+		if n > 0 {
+			log.Printf("[cleanup] %v: removed %d entries older than %s", err, n, g.AggressiveMaxAge)
+			err = g.measure()
+		}
+	}
+
+	g.mu.Lock()
+	prev := g.err
+	g.err = err
+	g.mu.Unlock()
+	switch {
+	case err != nil && prev == nil:
+		log.Printf("[cleanup] disk pressure, refusing new work: %v", err)
+	case err == nil && prev != nil:
+		log.Printf("[cleanup] disk pressure over")
+	}
+	return err
+}
+
+func (g *DiskGuard) measure() error {
+	var total int64
+	var full error
+	for _, dir := range g.Dirs {
+		size := dirSize(dir)
+		total += size
+		metrics.LocalStorageBytes.WithLabelValues(dir).Set(float64(size))
+
+		used, ok := diskUsed(dir)
+		if !ok {
+			continue
+		}
+		metrics.DiskUsedRatio.WithLabelValues(dir).Set(used)
+		if g.HighWatermark > 0 && used >= g.HighWatermark && full == nil {
+			full = ErrDiskFull
+		}
+	}
+	if full != nil {
+		return full
+	}
+	if g.MaxBytes > 0 && total > g.MaxBytes {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Run updates the measurement every interval until ctx is cancelled
+func (g *DiskGuard) Run(ctx context.Context, every time.Duration) {
+	g.Update()
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			g.Update()
+		}
+	}
+}
+
+// dirSize returns the size of the files under dir; entries vanishing during
+// the walk are skipped
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,16 @@
+//go:build linux
+
+package cleanup
+
+import "golang.org/x/sys/unix"
+
+// diskUsed returns the used fraction of the filesystem of path, as df reports
+// it: the blocks reserved for root count as used
+func diskUsed(path string) (float64, bool) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil || st.Blocks == 0 {
+		return 0, false
+	}
+	used := st.Blocks - st.Bfree
+	return float64(used) / float64(used+st.Bavail), true
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package cleanup
+
+// diskUsed is not measured outside linux; the quota still applies
+func diskUsed(path string) (float64, bool) {
+	return 0, false
+}
@@ -0,0 +1,118 @@
+package cleanup
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Sweeper removes entries older than MaxAge from a set of directories.
+// Patterns are filepath.Match globs applied to entry names; an empty list matches everything.
+type Sweeper struct {
+	Dirs     []string
+	Patterns []string
+	MaxAge   time.Duration
+}
+
+// Sweep removes expired files and directories and returns how many entries were deleted
+func (s *Sweeper) Sweep() (int, error) {
+	cutoff := time.Now().Add(-s.MaxAge)
+	removed := 0
+	var firstErr error
+	for _, dir := range s.Dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for _, e := range entries {
+			if !s.matches(e.Name()) {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			removed++
+		}
+	}
+	return removed, firstErr
+}
+
+func (s *Sweeper) matches(name string) bool {
+	if len(s.Patterns) == 0 {
+		return true
+	}
+	for _, p := range s.Patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Run sweeps every interval until ctx is cancelled
+func (s *Sweeper) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n, err := s.Sweep()
+			if err != nil {
+				log.Printf("[cleanup] sweep %v: %v", s.Dirs, err)
+			}
+			if n > 0 {
+				log.Printf("[cleanup] removed %d expired entries from %v", n, s.Dirs)
+			}
+		}
+	}
+}
+
+// trashSuffix marks a directory renamed by RemoveDir for deletion
+const trashSuffix = ".trash"
+
+// RemoveDir deletes dir as a whole: it is renamed first, so nothing watching
+// its path sees it half deleted, and a removal cut short leaves a *.trash dir
+// for the sweeper rather than a partial one
+func RemoveDir(dir string) {
+	if dir == "" {
+		return
+	}
+	trash := dir + trashSuffix
+	if err := os.Rename(dir, trash); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[cleanup] rename %s: %v", dir, err)
+			Remove(dir)
+		}
+		return
+	}
+	Remove(trash)
+}
+
+// Remove deletes paths, ignoring ones that are already gone
+func Remove(paths ...string) {
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if err := os.RemoveAll(p); err != nil && !os.IsNotExist(err) {
+			log.Printf("[cleanup] remove %s: %v", p, err)
+		}
+	}
+}
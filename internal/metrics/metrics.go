@@ -43,7 +43,7 @@ var (
 	SNRBefore = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "blinky_snr_before_db",
-			Help: "Estimated SNR (dB) before processing.",
+			Help: "Estimated SNR (dB) before processing: speech vs non-speech frame power.",
 		},
 		[]string{"denoiser"},
 	)
@@ -51,7 +51,7 @@ var (
 	SNRAfter = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "blinky_snr_after_db",
-			Help: "Estimated SNR (dB) after processing.",
+			Help: "Estimated SNR (dB) after processing: speech vs non-speech frame power.",
 		},
 		[]string{"denoiser"},
 	)
@@ -63,6 +63,313 @@ var (
 		},
 		[]string{"denoiser"},
 	)
+
+	// deprecated: peak - RMS (crest factor) reported as SNR before the VAD based
+	// estimate; kept during the dashboard transition
+	SNRPeakRMSBefore = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "blinky_snr_peak_rms_before_db",
+			Help: "Deprecated: peak minus RMS level (dB) before processing, the former SNR estimate.",
+		},
+		[]string{"denoiser"},
+	)
+
+	SNRPeakRMSAfter = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "blinky_snr_peak_rms_after_db",
+			Help: "Deprecated: peak minus RMS level (dB) after processing, the former SNR estimate.",
+		},
+		[]string{"denoiser"},
+	)
+
+	PESQScore = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "blinky_pesq_score",
+			Help:    "PESQ of processed audio against the input, for jobs with quality scoring.",
+			Buckets: prometheus.LinearBuckets(1, 0.25, 15),
+		},
+		[]string{"denoiser"},
+	)
+
+	STOIScore = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "blinky_stoi_score",
+			Help:    "STOI intelligibility of processed audio against the input, for jobs with quality scoring.",
+			Buckets: prometheus.LinearBuckets(0.5, 0.05, 11),
+		},
+		[]string{"denoiser"},
+	)
+
+	MOSScore = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "blinky_mos_score",
+			Help:    "Estimated MOS (1..5) of processed audio, for alerting on perceived quality regressions.",
+			Buckets: prometheus.LinearBuckets(1, 0.25, 17),
+		},
+		[]string{"denoiser"},
+	)
+
+	KeywordHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blinky_keyword_hits_total",
+			Help: "Keyword list matches in transcripts by category.",
+		},
+		[]string{"category"},
+	)
+
+	InputDCOffset = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "blinky_input_dc_offset_dbfs",
+			Help:    "DC offset of inputs, dBFS (-120 when there is none).",
+			Buckets: []float64{-90, -70, -60, -50, -46, -40, -34, -26, -20},
+		},
+	)
+
+	PreflightRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blinky_preflight_rejections_total",
+			Help: "Inputs rejected before processing by rejection code.",
+		},
+		[]string{"code"},
+	)
+
+	InputFormats = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blinky_input_formats_total",
+			Help: "Processed inputs by container and audio codec.",
+		},
+		[]string{"container", "codec"},
+	)
+
+	QualityGate = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blinky_quality_gate_total",
+			Help: "Jobs checked by the quality gate by result (pass, warn, fail).",
+		},
+		[]string{"result"},
+	)
+
+	QualityGateViolations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blinky_quality_gate_violations_total",
+			Help: "Quality gate thresholds missed by outputs by check (snr, noise, loudness).",
+		},
+		[]string{"check"},
+	)
+
+	RetentionPurges = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blinky_retention_purges_total",
+			Help: "Expired jobs handled by the retention purger by result (purged, failed).",
+		},
+		[]string{"result"},
+	)
+
+	ArchivedJobs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blinky_archived_jobs_total",
+			Help: "Jobs handled by the archiver moving objects to cold storage by result (archived, failed).",
+		},
+		[]string{"result"},
+	)
+
+	StorageRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blinky_storage_retries_total",
+			Help: "Retried object storage operations by operation (upload, download, download_part, delete, stat, list, presign, copy).",
+		},
+		[]string{"op"},
+	)
+
+	OrphanedObjects = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "blinky_gc_orphaned_objects",
+			Help: "Stored objects past the grace period that no job records, as of the last garbage collection, by bucket.",
+		},
+		[]string{"bucket"},
+	)
+
+	MissingObjects = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "blinky_gc_missing_objects",
+			Help: "Objects recorded on jobs that are missing from storage, as of the last garbage collection, by bucket.",
+		},
+		[]string{"bucket"},
+	)
+
+	GCDeletedObjects = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "blinky_gc_deleted_objects_total",
+			Help: "Orphaned objects deleted by the garbage collector.",
+		},
+	)
+
+	DiskUsedRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "blinky_disk_used_ratio",
+			Help: "Used fraction of the filesystem of a local storage, work or temp directory.",
+		},
+		[]string{"dir"},
+	)
+
+	LocalStorageBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "blinky_local_storage_bytes",
+			Help: "Size of the files in a local storage, work or temp directory.",
+		},
+		[]string{"dir"},
+	)
+
+	DiskPressureRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blinky_disk_pressure_rejections_total",
+			Help: "Uploads refused under disk pressure by reason (disk_full, quota).",
+		},
+		[]string{"reason"},
+	)
+
+	StorageFailovers = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "blinky_storage_failovers_total",
+			Help: "Switches of uploads to the secondary object storage endpoint.",
+		},
+	)
+
+	StorageFailedOver = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "blinky_storage_failed_over",
+			Help: "1 while uploads to a bucket go to the secondary endpoint, 0 otherwise.",
+		},
+		[]string{"bucket"},
+	)
+
+	DownloadThroughput = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "blinky_storage_download_bytes_per_second",
+			Help:    "Throughput of object downloads (S3/GCS) in bytes per second.",
+			Buckets: prometheus.ExponentialBuckets(256<<10, 2, 12), // 256 KiB/s .. 512 MiB/s
+		},
+	)
+
+	RetentionPurgedObjects = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "blinky_retention_purged_objects_total",
+			Help: "Objects deleted from storage by the retention purger.",
+		},
+	)
+
+	ChecksumMismatches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "blinky_checksum_mismatches_total",
+			Help: "Objects whose content didn't match their checksum by operation (upload, download).",
+		},
+		[]string{"op"},
+	)
+
+	ActiveJobs = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "blinky_worker_active_jobs",
+			Help: "Number of jobs currently being processed by this worker.",
+		},
+	)
+
+	ChildCPUSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "blinky_child_cpu_seconds",
+			Help:    "User+system CPU time of spawned ffmpeg/ffprobe/python processes.",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 14),
+		},
+		[]string{"tool"},
+	)
+
+	ChildMaxRSSBytes = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "blinky_child_max_rss_bytes",
+			Help:    "Peak resident memory of spawned ffmpeg/ffprobe/python processes.",
+			Buckets: prometheus.ExponentialBuckets(8<<20, 2, 10),
+		},
+		[]string{"tool"},
+	)
+
+	ChildLimitErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "blinky_child_limit_errors_total",
+			Help: "Times niceness or memory limits could not be applied to a child process.",
+		},
+	)
+
+	JobPanics = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "blinky_job_panics_total",
+			Help: "Jobs aborted by a recovered panic.",
+		},
+	)
+
+	WorkerPaused = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "blinky_worker_paused",
+			Help: "1 while the worker is paused via audio.control, 0 otherwise.",
+		},
+	)
+
+	DBUp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "blinky_db_up",
+			Help: "1 while the last ping of the database succeeded, 0 otherwise.",
+		},
+	)
+
+	DBPingSeconds = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "blinky_db_ping_seconds",
+			Help:    "Round trip of the periodic database ping.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14), // 0.5 ms .. 4 s
+		},
+	)
+
+	DBPoolConns = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "blinky_db_pool_conns",
+			Help: "Connections of the database pool by state (acquired, idle, constructing) and its maximum (max).",
+		},
+		[]string{"state"},
+	)
+
+	DBPoolWaits = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "blinky_db_pool_waits_total",
+			Help: "Connection acquires that had to wait because every connection of the pool was in use.",
+		},
+	)
+
+	DBPoolWaitSeconds = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "blinky_db_pool_wait_seconds_total",
+			Help: "Time spent waiting for a connection of the pool.",
+		},
+	)
+
+	DBPoolResets = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "blinky_db_pool_resets_total",
+			Help: "Times every pool connection was dropped after failed pings, to reconnect.",
+		},
+	)
+
+	JobPartitions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "blinky_job_partitions",
+			Help: "Monthly partitions of the jobs table by kind (existing, ahead: months after the current one).",
+		},
+		[]string{"kind"},
+	)
+
+	JobPartitionsDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "blinky_job_partitions_dropped_total",
+			Help: "Monthly partitions of the jobs table dropped after their retention.",
+		},
+	)
 )
 
 // Register registers metrics with Prometheus default registry.
@@ -74,6 +381,45 @@ func Register() {
 	prometheus.MustRegister(SNRBefore)
 	prometheus.MustRegister(SNRAfter)
 	prometheus.MustRegister(SNRImprovement)
+	prometheus.MustRegister(SNRPeakRMSBefore)
+	prometheus.MustRegister(SNRPeakRMSAfter)
+	prometheus.MustRegister(PESQScore)
+	prometheus.MustRegister(STOIScore)
+	prometheus.MustRegister(MOSScore)
+	prometheus.MustRegister(KeywordHits)
+	prometheus.MustRegister(InputDCOffset)
+	prometheus.MustRegister(PreflightRejections)
+	prometheus.MustRegister(InputFormats)
+	prometheus.MustRegister(QualityGate)
+	prometheus.MustRegister(QualityGateViolations)
+	prometheus.MustRegister(RetentionPurges)
+	prometheus.MustRegister(RetentionPurgedObjects)
+	prometheus.MustRegister(ArchivedJobs)
+	prometheus.MustRegister(StorageRetries)
+	prometheus.MustRegister(OrphanedObjects)
+	prometheus.MustRegister(MissingObjects)
+	prometheus.MustRegister(GCDeletedObjects)
+	prometheus.MustRegister(DownloadThroughput)
+	prometheus.MustRegister(StorageFailovers)
+	prometheus.MustRegister(StorageFailedOver)
+	prometheus.MustRegister(DiskUsedRatio)
+	prometheus.MustRegister(LocalStorageBytes)
+	prometheus.MustRegister(DiskPressureRejections)
+	prometheus.MustRegister(ChecksumMismatches)
+	prometheus.MustRegister(ActiveJobs)
+	prometheus.MustRegister(WorkerPaused)
+	prometheus.MustRegister(JobPanics)
+	prometheus.MustRegister(ChildCPUSeconds)
+	prometheus.MustRegister(ChildMaxRSSBytes)
+	prometheus.MustRegister(ChildLimitErrors)
+	prometheus.MustRegister(DBUp)
+	prometheus.MustRegister(DBPingSeconds)
+	prometheus.MustRegister(DBPoolConns)
+	prometheus.MustRegister(DBPoolWaits)
+	prometheus.MustRegister(DBPoolWaitSeconds)
+	prometheus.MustRegister(DBPoolResets)
+	prometheus.MustRegister(JobPartitions)
+	prometheus.MustRegister(JobPartitionsDropped)
 }
 
 // ObserveJob records job metrics
@@ -92,3 +438,9 @@ func ObserveJob(denoiser string, duration time.Duration, success bool,
 	SNRAfter.WithLabelValues(denoiser).Set(snrAfter)
 	SNRImprovement.WithLabelValues(denoiser).Set(snrAfter - snrBefore)
 }
+
+// ObservePeakRMS records the deprecated peak - RMS values next to the SNR of ObserveJob
+func ObservePeakRMS(denoiser string, before, after float64) {
+	SNRPeakRMSBefore.WithLabelValues(denoiser).Set(before)
+	SNRPeakRMSAfter.WithLabelValues(denoiser).Set(after)
+}
@@ -0,0 +1,248 @@
+// Package models manages the RNNoise (.rnnn) model files used by the arnndn denoiser:
+// a catalog of known models, download and checksum verification, and lookup by name.
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Model is a catalog entry
+type Model struct {
+	Name        string `json:"name"`
+	File        string `json:"file"`             // file name inside the models dir
+	URL         string `json:"url,omitempty"`    // download source, empty for local-only models
+	SHA256      string `json:"sha256,omitempty"` // expected checksum; empty pins the first download
+	Description string `json:"description,omitempty"`
+}
+
+const rnnoiseModelsURL = "https://raw.githubusercontent.com/GregorR/rnnoise-models/master/"
+
+// DefaultCatalog lists the models published in the GregorR/rnnoise-models
+// repository plus the legacy file name the worker used before the catalog.
+var DefaultCatalog = []Model{
+	{Name: "default", File: "rnnoise-model.rnnn", Description: "legacy model at tools/models/rnnoise-model.rnnn"},
+	{Name: "speech", File: "sh.rnnn", URL: rnnoiseModelsURL + "somnolent-hogwash-2018-09-01/sh.rnnn", Description: "speech, trained on voice with recording noise"},
+	{Name: "general", File: "cb.rnnn", URL: rnnoiseModelsURL + "conjoined-burgers-2018-08-28/cb.rnnn", Description: "voice with general noise"},
+	{Name: "recording", File: "bd.rnnn", URL: rnnoiseModelsURL + "beguiling-drafter-2018-08-30/bd.rnnn", Description: "recording noise only"},
+	{Name: "marathon", File: "mp.rnnn", URL: rnnoiseModelsURL + "marathon-prescription-2018-08-29/mp.rnnn", Description: "general noise, speech preserving"},
+	{Name: "quisling", File: "lq.rnnn", URL: rnnoiseModelsURL + "leavened-quisling-2018-08-31/lq.rnnn", Description: "general noise, aggressive"},
+}
+
+// ErrUnknownModel is returned for names missing from the catalog
+var ErrUnknownModel = errors.New("unknown model")
+
+// manifestFile pins the checksum of models downloaded without a catalog checksum
+const manifestFile = "manifest.json"
+
+// Status is a catalog entry with its local state
+type Status struct {
+	Model
+	Installed bool   `json:"installed"`
+	Path      string `json:"path,omitempty"`
+	Verified  bool   `json:"verified"` // checksum matches the catalog or the pinned download
+}
+
+// Manager resolves model names to verified files in Dir
+type Manager struct {
+	Dir     string
+	catalog map[string]Model
+	client  *http.Client
+	mu      sync.Mutex // serializes downloads and manifest updates
+}
+
+// NewManager returns a manager for dir with the given catalog (DefaultCatalog when nil)
+func NewManager(dir string, catalog []Model) *Manager {
+	if catalog == nil {
+		catalog = DefaultCatalog
+	}
+	m := &Manager{Dir: dir, catalog: map[string]Model{}, client: http.DefaultClient}
+	for _, e := range catalog {
+		m.catalog[e.Name] = e
+	}
+	return m
+}
+
+// Lookup returns the catalog entry of name
+func (m *Manager) Lookup(name string) (Model, error) {
+	e, ok := m.catalog[name]
+	if !ok {
+		return Model{}, fmt.Errorf("%w %q", ErrUnknownModel, name)
+	}
+	return e, nil
+}
+
+// Names returns the catalog model names, sorted
+func (m *Manager) Names() []string {
+	names := make([]string, 0, len(m.catalog))
+	for n := range m.catalog {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List reports every catalog model with its local state
+func (m *Manager) List() []Status {
+	out := make([]Status, 0, len(m.catalog))
+	for _, name := range m.Names() {
+		e := m.catalog[name]
+		st := Status{Model: e}
+		path := filepath.Join(m.Dir, e.File)
+		if _, err := os.Stat(path); err == nil {
+			st.Installed, st.Path = true, path
+			st.Verified = m.Verify(name) == nil
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// Path returns the verified local file of name without downloading it
+func (m *Manager) Path(name string) (string, error) {
+	e, err := m.Lookup(name)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(m.Dir, e.File)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("model %q not installed at %s", name, path)
+	}
+	if err := m.Verify(name); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Ensure returns the verified local file of name, downloading it first when missing
+func (m *Manager) Ensure(ctx context.Context, name string) (string, error) {
+	if path, err := m.Path(name); err == nil {
+		return path, nil
+	}
+	if err := m.Download(ctx, name); err != nil {
+		return "", err
+	}
+	return m.Path(name)
+}
+
+// Verify checks the local file of name against the catalog checksum, or against the
+// checksum pinned at download time. Models with neither are accepted as is.
+func (m *Manager) Verify(name string) error {
+	e, err := m.Lookup(name)
+	if err != nil {
+		return err
+	}
+	want := e.SHA256
+	if want == "" {
+		want = m.readManifest()[name]
+	}
+	if want == "" {
+		return nil
+	}
+	got, err := fileSHA256(filepath.Join(m.Dir, e.File))
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("model %q checksum mismatch: got %s, want %s", name, got, want)
+	}
+	return nil
+}
+
+// Download fetches name from its catalog URL, verifies it and installs it atomically
+func (m *Manager) Download(ctx context.Context, name string) error {
+	e, err := m.Lookup(name)
+	if err != nil {
+		return err
+	}
+	if e.URL == "" {
+		return fmt.Errorf("model %q has no download url", name)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("download model %q: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download model %q: %s", name, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(m.Dir, e.File+".part-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), resp.Body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("download model %q: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if e.SHA256 != "" && sum != e.SHA256 {
+		return fmt.Errorf("model %q checksum mismatch: got %s, want %s", name, sum, e.SHA256)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(m.Dir, e.File)); err != nil {
+		return err
+	}
+	if e.SHA256 == "" {
+		manifest := m.readManifest()
+		manifest[name] = sum
+		if err := m.writeManifest(manifest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) readManifest() map[string]string {
+	out := map[string]string{}
+	b, err := os.ReadFile(filepath.Join(m.Dir, manifestFile))
+	if err == nil {
+		_ = json.Unmarshal(b, &out)
+	}
+	return out
+}
+
+func (m *Manager) writeManifest(manifest map[string]string) error {
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.Dir, manifestFile), b, 0o644)
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
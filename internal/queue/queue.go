@@ -0,0 +1,253 @@
+package queue
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SubjectPrefix is the NATS subject root for audio jobs; the denoise method
+// and the priority are appended to it (see JobMsg.Subject).
+const SubjectPrefix = "audio.jobs"
+
+// ControlSubject carries operator commands for workers (see ControlMsg)
+const ControlSubject = "audio.control"
+
+// Control actions
+const (
+	ActionPause  = "pause"
+	ActionResume = "resume"
+	ActionCancel = "cancel" // stop the in-flight job JobID
+)
+
+// ControlMsg is an operator command; an empty WorkerID targets every worker
+type ControlMsg struct {
+	Action   string `json:"action"`
+	WorkerID string `json:"worker_id,omitempty"`
+	JobID    string `json:"job_id,omitempty"`
+}
+
+// Job priorities, highest first
+const (
+	PriorityRealtime = "realtime"
+	PriorityHigh     = "high"
+	PriorityNormal   = "normal"
+	PriorityBatch    = "batch"
+)
+
+// Priorities lists every priority ordered from most to least urgent
+var Priorities = []string{PriorityRealtime, PriorityHigh, PriorityNormal, PriorityBatch}
+
+// JobMsg is the message published by the API and consumed by workers.
+// InputBucket/InputKey locate the uploaded input in object storage; InputPath is
+// only used as a fallback for messages published before inputs were uploaded.
+type JobMsg struct {
+	ID             string            `json:"id"`
+	InputPath      string            `json:"input_path"`
+	InputBucket    string            `json:"input_bucket,omitempty"`
+	InputKey       string            `json:"input_key,omitempty"`
+	InputSHA256    string            `json:"input_sha256,omitempty"` // checked after the download
+	OutputPath     string            `json:"output_path"`
+	Preset         string            `json:"preset,omitempty"` // named option bundle, see audio.LoadPresets
+	DenoiseMethod  string            `json:"denoise_method"`
+	DenoiseParams  map[string]string `json:"denoise_params,omitempty"` // filter options of the denoise method
+	DenoiseModel   string            `json:"denoise_model,omitempty"`  // RNNoise model name for arnndn, see models.DefaultCatalog
+	OutputFormat   string            `json:"output_format,omitempty"`
+	BitrateKbps    int               `json:"bitrate_kbps,omitempty"`
+	TrimSilence    bool              `json:"trim_silence,omitempty"`
+	TrimThreshold  float64           `json:"trim_threshold_db,omitempty"` // 0 uses the worker default
+	TrimPadding    float64           `json:"trim_padding_sec,omitempty"`  // 0 uses the worker default
+	RemoveGaps     bool              `json:"remove_gaps,omitempty"`
+	MaxGapSec      float64           `json:"max_gap_sec,omitempty"` // 0 uses the worker default
+	NoiseGate      bool              `json:"noise_gate,omitempty"`
+	GateThreshold  float64           `json:"gate_threshold_db,omitempty"` // 0 uses the worker default
+	HighpassHz     int               `json:"highpass_hz,omitempty"`
+	LowpassHz      int               `json:"lowpass_hz,omitempty"`
+	Deesser        bool              `json:"deesser,omitempty"`
+	DeesserLevel   float64           `json:"deesser_intensity,omitempty"` // 0..1, 0 uses the worker default
+	Dereverb       bool              `json:"dereverb,omitempty"`
+	Declip         bool              `json:"declip,omitempty"`
+	DCRemove       bool              `json:"dc_remove,omitempty"`
+	CustomFilter   string            `json:"custom_filter,omitempty"` // validated ffmpeg -af chain
+	CustomMode     string            `json:"custom_filter_mode,omitempty"`
+	ChannelMode    string            `json:"channel_mode,omitempty"`   // "" (mono), "dual" or "split"
+	Tempo          float64           `json:"tempo,omitempty"`          // playback speed, 0 keeps it
+	TempoMode      string            `json:"tempo_mode,omitempty"`     // "rendition" or "main"
+	Spectrogram    bool              `json:"spectrogram,omitempty"`    // render a PNG of the output
+	QualityScores  bool              `json:"quality_scores,omitempty"` // PESQ/STOI of the output against the input
+	Diarize        bool              `json:"diarize,omitempty"`        // speaker-labeled segments of the input
+	Transcribe     bool              `json:"transcribe,omitempty"`     // ASR of the output with word timestamps
+	KeywordList    string            `json:"keyword_list,omitempty"`   // keyword list spotted in the transcript (implies Transcribe)
+	Redact         string            `json:"redact,omitempty"`         // comma separated audio.Redact* sources of a redacted rendition
+	RedactMode     string            `json:"redact_mode,omitempty"`    // audio.RedactBeep or audio.RedactSilence
+	RedactPII      bool              `json:"redact_pii,omitempty"`     // mute spoken card numbers and SSNs in the output (implies Transcribe)
+	Benchmark      []string          `json:"benchmark,omitempty"`      // denoise methods the input is also processed with, for comparison
+	ArchivalCopy   string            `json:"archival_copy,omitempty"`  // FLAC copies kept besides the output, see audio.ParseArchivalCopy
+	Priority       string            `json:"priority,omitempty"`
+	ProcessAfter   *time.Time        `json:"process_after,omitempty"`
+	Kind           string            `json:"kind,omitempty"`         // "" for a recording, KindBundle for an archive
+	AnalyzeOnly    bool              `json:"analyze_only,omitempty"` // measure the input only, no output audio
+	ParentID       string            `json:"parent_id,omitempty"`    // bundle job a child was unpacked from
+	Tenant         string            `json:"tenant,omitempty"`       // customer the job belongs to, see ParseLabel
+	RetentionClass string            `json:"retention_class,omitempty"`
+	CallerRef      string            `json:"caller_ref,omitempty"` // customer reference of the call, see store.EraseQuery
+	Labels         map[string]string `json:"labels,omitempty"`     // of the client, see ParseLabels
+}
+
+// DefaultRetentionClass is the retention class of jobs submitted without one
+const DefaultRetentionClass = "standard"
+
+var labelPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// ParseLabel validates an identifier like a tenant or a retention class:
+// lower case letters, digits, '_' and '-', which are safe as object tag values
+// and key prefixes. Empty is valid.
+func ParseLabel(kind, s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s != "" && !labelPattern.MatchString(s) {
+		return "", fmt.Errorf("invalid %s %q (want up to 63 lower case letters, digits, _ and -)", kind, s)
+	}
+	return s, nil
+}
+
+// Limits of the labels of a job
+const (
+	MaxLabels        = 20
+	maxLabelValueLen = 200
+)
+
+// ParseLabels parses the labels a client attaches to a job, to slice its
+// traffic by: comma separated key=value pairs (campaign=q3,queue=support) or a
+// JSON object of strings. Keys are identifiers as of ParseLabel, values up to
+// 200 bytes of printable text. Empty is valid.
+func ParseLabels(s string) (map[string]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	labels := map[string]string{}
+	if strings.HasPrefix(s, "{") {
+		if err := json.Unmarshal([]byte(s), &labels); err != nil {
+			return nil, fmt.Errorf("invalid labels: %w", err)
+		}
+	} else {
+		for _, pair := range strings.Split(s, ",") {
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid label %q (want key=value)", strings.TrimSpace(pair))
+			}
+			labels[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	if len(labels) > MaxLabels {
+		return nil, fmt.Errorf("more than %d labels", MaxLabels)
+	}
+	for k, v := range labels {
+		if k == "" || !labelPattern.MatchString(k) {
+			return nil, fmt.Errorf("invalid label key %q (want up to 63 lower case letters, digits, _ and -)", k)
+		}
+		if len(v) > maxLabelValueLen || strings.IndexFunc(v, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+			return nil, fmt.Errorf("invalid value of label %s (want up to %d bytes of printable text)", k, maxLabelValueLen)
+		}
+	}
+	return labels, nil
+}
+
+// ObjectTags describes the objects stored for the job, as storage tags; empty
+// values are left out. durationSec is the length of the recording, 0 when unknown.
+func (m JobMsg) ObjectTags(durationSec float64) map[string]string {
+	tags := map[string]string{
+		"job_id":          m.ID,
+		"tenant":          m.Tenant,
+		"denoise_method":  m.DenoiseMethod,
+		"retention_class": m.RetentionClass,
+	}
+	if durationSec > 0 {
+		tags["duration_sec"] = strconv.FormatFloat(durationSec, 'f', 1, 64)
+	}
+	for k, v := range tags {
+		if v == "" {
+			delete(tags, k)
+		}
+	}
+	return tags
+}
+
+// KindBundle marks a job whose input is an archive of recordings
+const KindBundle = "bundle"
+
+// Options returns the processing options of the job, i.e. everything that
+// changes the output, with the identity, location and scheduling fields cleared
+func (m JobMsg) Options() JobMsg {
+	m.ID, m.InputPath, m.InputBucket, m.InputKey, m.InputSHA256, m.OutputPath = "", "", "", "", "", ""
+	m.Priority, m.ProcessAfter, m.ParentID, m.RetentionClass, m.CallerRef, m.Labels = "", nil, "", "", "", nil
+	return m
+}
+
+// OptionsHash fingerprints the Options of the job. The tenant is kept, so that
+// duplicates are only ever found within a tenant.
+func (m JobMsg) OptionsHash() string {
+	b, _ := json.Marshal(m.Options())
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// ParsePriority validates a priority value; empty means normal
+func ParsePriority(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return PriorityNormal, nil
+	}
+	for _, p := range Priorities {
+		if p == s {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown priority %q (want one of %s)", s, strings.Join(Priorities, ", "))
+}
+
+// Rank returns the position of priority in Priorities (0 = most urgent).
+// Unknown or empty priorities rank as normal.
+func Rank(priority string) int {
+	for i, p := range Priorities {
+		if p == priority {
+			return i
+		}
+	}
+	return Rank(PriorityNormal)
+}
+
+// MethodToken normalizes a denoise method into a single NATS subject token
+func MethodToken(method string) string {
+	method = strings.ToLower(strings.TrimSpace(method))
+	if method == "" {
+		return "default"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, method)
+}
+
+// MethodSubject returns the wildcard subject matching every priority of a denoise method
+func MethodSubject(method string) string {
+	return SubjectPrefix + "." + MethodToken(method) + ".*"
+}
+
+// Subject returns the NATS subject the job is published to: audio.jobs.<method>.<priority>
+func (m JobMsg) Subject() string {
+	priority := m.Priority
+	if priority == "" {
+		priority = PriorityNormal
+	}
+	return SubjectPrefix + "." + MethodToken(m.DenoiseMethod) + "." + priority
+}
@@ -0,0 +1,56 @@
+// Package retention holds the policy of how long recordings are kept
+package retention
+
+import "fmt"
+
+// Policy sets how many days after a job finished its objects (original, outputs,
+// renditions) are deleted. A rule for the retention class of the job wins over a
+// rule for its tenant, which wins over DefaultDays. 0 days keeps recordings
+// forever, e.g. for a legal_hold class.
+type Policy struct {
+	DefaultDays int            `yaml:"default_days"`
+	Tenants     map[string]int `yaml:"tenants"` // days by tenant
+	Classes     map[string]int `yaml:"classes"` // days by retention class
+}
+
+// Enabled reports whether any recording ever expires
+func (p Policy) Enabled() bool {
+	if p.DefaultDays > 0 {
+		return true
+	}
+	for _, rules := range []map[string]int{p.Tenants, p.Classes} {
+		for _, days := range rules {
+			if days > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Validate rejects negative retentions
+func (p Policy) Validate() error {
+	if p.DefaultDays < 0 {
+		return fmt.Errorf("default_days %d must not be negative", p.DefaultDays)
+	}
+	for kind, rules := range map[string]map[string]int{"tenants": p.Tenants, "classes": p.Classes} {
+		for name, days := range rules {
+			if days < 0 {
+				return fmt.Errorf("%s: %s: %d days must not be negative", kind, name, days)
+			}
+		}
+	}
+	return nil
+}
+
+// Days returns the retention of the recordings of a tenant in a retention class;
+// 0 keeps them forever
+func (p Policy) Days(tenant, class string) int {
+	if days, ok := p.Classes[class]; ok {
+		return days
+	}
+	if days, ok := p.Tenants[tenant]; ok {
+		return days
+	}
+	return p.DefaultDays
+}
@@ -0,0 +1,26 @@
+package retention
+
+import "fmt"
+
+// Tiering moves the objects of finished jobs to a colder, cheaper storage class
+// some days after the job finished, usually long before their retention ends
+type Tiering struct {
+	AfterDays    int    `yaml:"after_days"`    // 0 disables
+	StorageClass string `yaml:"storage_class"` // e.g. GLACIER or DEEP_ARCHIVE (S3), ARCHIVE (GCS), Archive or Cold (Azure)
+}
+
+// Enabled reports whether objects are ever moved
+func (t Tiering) Enabled() bool {
+	return t.AfterDays > 0
+}
+
+// Validate rejects a negative age and tiering without a storage class
+func (t Tiering) Validate() error {
+	if t.AfterDays < 0 {
+		return fmt.Errorf("after_days %d must not be negative", t.AfterDays)
+	}
+	if t.AfterDays > 0 && t.StorageClass == "" {
+		return fmt.Errorf("after_days needs a storage_class")
+	}
+	return nil
+}
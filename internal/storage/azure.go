@@ -0,0 +1,466 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// azureVersion is the Blob service version requests and SAS tokens are made for
+const azureVersion = "2020-12-06"
+
+// AzureConfig configures the Azure Blob Storage backend
+type AzureConfig struct {
+	Account   string
+	Key       string // base64 account key
+	Container string // must exist
+	Endpoint  string // default https://<account>.blob.core.windows.net; Azurite: http://127.0.0.1:10000/devstoreaccount1
+}
+
+// AzureClient stores objects as block blobs of one container. Requests are
+// authorized with service SAS tokens signed by the account key, the same kind
+// of token PresignedGetURL hands out, so no Shared Key request signing is needed.
+type AzureClient struct {
+	account       string
+	key           []byte
+	container     string
+	endpoint      string
+	PresignExpiry time.Duration
+	http          *http.Client
+}
+
+// NewAzureClient creates an AzureClient
+func NewAzureClient(cfg AzureConfig, presignExpiry time.Duration) (*AzureClient, error) {
+	if cfg.Account == "" || cfg.Key == "" || cfg.Container == "" {
+		return nil, errors.New("azure storage needs an account, a key and a container")
+	}
+	key, err := base64.StdEncoding.DecodeString(cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("azure storage key: %w", err)
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://" + cfg.Account + ".blob.core.windows.net"
+	}
+	return &AzureClient{
+		account:       cfg.Account,
+		key:           key,
+		container:     cfg.Container,
+		endpoint:      strings.TrimRight(endpoint, "/"),
+		PresignExpiry: presignExpiry,
+		http:          &http.Client{},
+	}, nil
+}
+
+// Bucket returns the container objects are stored in
+func (a *AzureClient) Bucket() string {
+	return a.container
+}
+
+// sas returns a service SAS token granting perms on a blob (sr "b") or the
+// container (sr "c") until expiry
+func (a *AzureClient) sas(resource, sr, perms string, expiry time.Duration) url.Values {
+	now := time.Now().UTC()
+	start := now.Add(-5 * time.Minute).Format(time.RFC3339) // tolerate clock skew
+	end := now.Add(expiry).Format(time.RFC3339)
+	// service SAS string-to-sign of version 2020-12-06
+	toSign := strings.Join([]string{
+		perms, start, end,
+		"/blob/" + a.account + "/" + resource,
+		"", "", "", // identifier, IP, protocol
+		azureVersion, sr,
+		"", "", // snapshot time, encryption scope
+		"", "", "", "", "", // response header overrides
+	}, "\n")
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(toSign))
+
+	q := url.Values{}
+	q.Set("sv", azureVersion)
+	q.Set("sr", sr)
+	q.Set("sp", perms)
+	q.Set("st", start)
+	q.Set("se", end)
+	q.Set("sig", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return q
+}
+
+// blobURL returns the URL of objectKey with a SAS token granting perms until expiry
+func (a *AzureClient) blobURL(objectKey, perms string, expiry time.Duration) string {
+	q := a.sas(a.container+"/"+objectKey, "b", perms, expiry)
+	segments := strings.Split(objectKey, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return a.endpoint + "/" + url.PathEscape(a.container) + "/" + strings.Join(segments, "/") + "?" + q.Encode()
+}
+
+// UploadFile uploads a local file as a block blob (a single Put Blob, up to 5000 MiB).
+// The file is hashed first: the service rejects a body that doesn't match its
+// Content-MD5, and the SHA-256 goes into the blob metadata.
+func (a *AzureClient) UploadFile(ctx context.Context, localPath, objectKey, contentType string) (UploadInfo, error) {
+	d, err := fileDigest(localPath)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	var body io.Reader = f
+	if fn := progressFrom(ctx); fn != nil {
+		body = &progressReader{r: f, total: fi.Size(), fn: fn}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, a.blobURL(objectKey, "cwt", 15*time.Minute), body)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	req.ContentLength = fi.Size()
+	req.Header.Set("x-ms-version", azureVersion)
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(d.MD5()))
+	setBlobHeaders(ctx, req, objectKey, uploadContentType(contentType, localPath, objectKey), d.SHA256())
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return UploadInfo{}, azureError("put blob", resp)
+	}
+	return UploadInfo{
+		Key:       objectKey,
+		Size:      fi.Size(),
+		ETag:      strings.Trim(resp.Header.Get("ETag"), `"`),
+		VersionID: resp.Header.Get("x-ms-version-id"),
+		SHA256:    d.SHA256(),
+	}, nil
+}
+
+// azureBlockSize is the size of the blocks a streamed upload is staged in
+const azureBlockSize = 8 << 20
+
+// UploadStream stages r as blocks of azureBlockSize and commits them as one
+// block blob. Blocks of a failed upload are never committed; the service
+// discards them after a week. Every block is sent with its Content-MD5, and the
+// SHA-256 of the whole stream is set as metadata when the blocks are committed.
+func (a *AzureClient) UploadStream(ctx context.Context, r io.Reader, objectKey, contentType string) (UploadInfo, error) {
+	d := newDigest(r)
+	r = d
+	var blockList strings.Builder
+	blockList.WriteString(`<?xml version="1.0" encoding="utf-8"?><BlockList>`)
+	buf := make([]byte, azureBlockSize)
+	var size int64
+	for i := 0; ; i++ {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			// block ids of a blob must all have the same length
+			id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%08d", i)))
+			if err := a.putBlock(ctx, objectKey, id, buf[:n]); err != nil {
+				return UploadInfo{}, err
+			}
+			blockList.WriteString("<Latest>" + id + "</Latest>")
+			size += int64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return UploadInfo{}, err
+		}
+	}
+	blockList.WriteString("</BlockList>")
+
+	body := blockList.String()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, a.blobURL(objectKey, "wt", 15*time.Minute)+"&comp=blocklist", strings.NewReader(body))
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("x-ms-version", azureVersion)
+	req.Header.Set("x-ms-blob-content-md5", base64.StdEncoding.EncodeToString(d.MD5()))
+	setBlobHeaders(ctx, req, objectKey, uploadContentType(contentType, "", objectKey), d.SHA256())
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return UploadInfo{}, azureError("put block list", resp)
+	}
+	return UploadInfo{
+		Key:       objectKey,
+		Size:      size,
+		ETag:      strings.Trim(resp.Header.Get("ETag"), `"`),
+		VersionID: resp.Header.Get("x-ms-version-id"),
+		SHA256:    d.SHA256(),
+	}, nil
+}
+
+// putBlock stages one block of a streamed upload
+func (a *AzureClient) putBlock(ctx context.Context, objectKey, id string, data []byte) error {
+	u := a.blobURL(objectKey, "w", 15*time.Minute) + "&comp=block&blockid=" + url.QueryEscape(id)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-version", azureVersion)
+	sum := md5.Sum(data)
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return azureError("put block", resp)
+	}
+	return nil
+}
+
+// DownloadFile downloads objectKey to localPath
+func (a *AzureClient) DownloadFile(ctx context.Context, objectKey, localPath string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.blobURL(objectKey, "r", 15*time.Minute), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-version", azureVersion)
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return azureError("get blob", resp)
+	}
+	return writeFile(localPath, resp.Body)
+}
+
+// Delete deletes objectKey with its snapshots. Previous versions kept by blob
+// versioning are left to the account's lifecycle management.
+func (a *AzureClient) Delete(ctx context.Context, objectKey string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, a.blobURL(objectKey, "d", 15*time.Minute), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-version", azureVersion)
+	req.Header.Set("x-ms-delete-snapshots", "include")
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNotFound {
+		return azureError("delete blob", resp)
+	}
+	return nil
+}
+
+// PresignedGetURL returns a read-only SAS URL for objectKey valid for PresignExpiry
+func (a *AzureClient) PresignedGetURL(ctx context.Context, objectKey string) (string, error) {
+	return a.blobURL(objectKey, "r", a.PresignExpiry), nil
+}
+
+// StatObject describes objectKey from the properties of the blob
+func (a *AzureClient) StatObject(ctx context.Context, objectKey string) (ObjectInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, a.blobURL(objectKey, "r", 15*time.Minute), nil)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	req.Header.Set("x-ms-version", azureVersion)
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ObjectInfo{}, fmt.Errorf("%s: %w", objectKey, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ObjectInfo{}, azureError("get blob properties", resp)
+	}
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return ObjectInfo{
+		Key:          objectKey,
+		Size:         resp.ContentLength,
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         strings.Trim(resp.Header.Get("ETag"), `"`),
+		VersionID:    resp.Header.Get("x-ms-version-id"),
+		LastModified: modified,
+	}, nil
+}
+
+// PresignedPost is not supported: Blob Storage has no POST uploads, browsers
+// would PUT to a SAS URL instead
+func (a *AzureClient) PresignedPost(ctx context.Context, objectKey string, c PostConditions) (PostPolicy, error) {
+	return PostPolicy{}, fmt.Errorf("presigned POST: %w", ErrUnsupported)
+}
+
+// SetStorageClass sets the access tier of objectKey, e.g. Cool, Cold or Archive.
+// Archived blobs must be rehydrated to Hot or Cool before they can be read.
+func (a *AzureClient) SetStorageClass(ctx context.Context, objectKey, class string) error {
+	u := a.blobURL(objectKey, "w", 15*time.Minute) + "&comp=tier"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-version", azureVersion)
+	req.Header.Set("x-ms-access-tier", class)
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// 202: the tier changes later (rehydration from Archive)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return azureError("set blob tier", resp)
+	}
+	return nil
+}
+
+// azureBlobList is a page of the List Blobs response
+type azureBlobList struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified  string `xml:"Last-Modified"`
+			ContentLength int64  `xml:"Content-Length"`
+			ContentType   string `xml:"Content-Type"`
+			ETag          string `xml:"Etag"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// List calls fn for the blobs under prefix, page by page in the order of the
+// service, which is the byte order of the names
+func (a *AzureClient) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	marker := ""
+	for {
+		q := a.sas(a.container, "c", "l", 15*time.Minute)
+		q.Set("restype", "container")
+		q.Set("comp", "list")
+		q.Set("prefix", prefix)
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.endpoint+"/"+url.PathEscape(a.container)+"?"+q.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("x-ms-version", azureVersion)
+		resp, err := a.http.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := azureError("list blobs", resp)
+			resp.Body.Close()
+			return err
+		}
+		var page azureBlobList
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("azure list blobs: %w", err)
+		}
+		for _, b := range page.Blobs {
+			modified, _ := http.ParseTime(b.Properties.LastModified)
+			if err := fn(ObjectInfo{
+				Key:          b.Name,
+				Size:         b.Properties.ContentLength,
+				ContentType:  b.Properties.ContentType,
+				ETag:         strings.Trim(b.Properties.ETag, `"`),
+				LastModified: modified,
+			}); err != nil {
+				return err
+			}
+		}
+		if page.NextMarker == "" {
+			return nil
+		}
+		marker = page.NextMarker
+	}
+}
+
+// ListVersions is not supported yet: blob versions would need the list blobs
+// call of the container, and Delete leaves them to lifecycle management anyway
+func (a *AzureClient) ListVersions(ctx context.Context, objectKey string) ([]ObjectVersion, error) {
+	return nil, fmt.Errorf("object versions: %w", ErrUnsupported)
+}
+
+// PresignedGetVersionURL is not supported, see ListVersions
+func (a *AzureClient) PresignedGetVersionURL(ctx context.Context, objectKey, versionID string) (string, error) {
+	return "", fmt.Errorf("object versions: %w", ErrUnsupported)
+}
+
+// RestoreVersion is not supported, see ListVersions
+func (a *AzureClient) RestoreVersion(ctx context.Context, objectKey, versionID string) (UploadInfo, error) {
+	return UploadInfo{}, fmt.Errorf("object versions: %w", ErrUnsupported)
+}
+
+// setBlobHeaders sets the content type and disposition, the SHA-256 as metadata
+// and the tags of ctx, as blob index tags and metadata, on a request creating a blob
+func setBlobHeaders(ctx context.Context, req *http.Request, objectKey, contentType, sha256 string) {
+	req.Header.Set("x-ms-blob-content-type", contentType)
+	req.Header.Set("x-ms-blob-content-disposition", contentDisposition(objectKey))
+	if sha256 != "" {
+		req.Header.Set("x-ms-meta-"+MetaSHA256, sha256)
+	}
+	tags := tagsFrom(ctx)
+	if len(tags) == 0 {
+		return
+	}
+	q := url.Values{}
+	for k, v := range tags {
+		q.Set(k, v)
+		req.Header.Set("x-ms-meta-"+k, v)
+	}
+	req.Header.Set("x-ms-tags", q.Encode())
+}
+
+// azureError reads the error code the Blob service returns with a failed request
+func azureError(op string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if code := resp.Header.Get("x-ms-error-code"); code != "" {
+		return fmt.Errorf("azure %s: %s (%s)", op, code, resp.Status)
+	}
+	return fmt.Errorf("azure %s: %s: %s", op, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// writeFile writes r to path through a temporary file, so an interrupted
+// download never leaves a partial file at path
+func writeFile(path string, r io.Reader) error {
+	tmp := path + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
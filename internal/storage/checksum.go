@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"regexp"
+)
+
+// MetaSHA256 is the metadata key uploads of a local file record the SHA-256 of
+// the content under, so an object stays verifiable without the database
+const MetaSHA256 = "sha256"
+
+// ErrChecksumMismatch is wrapped by the errors of uploads and downloads whose
+// content turned out different from what was sent
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// digest hashes what is read through it: SHA-256 for UploadInfo and the job
+// record, MD5 to compare with the ETags and Content-MD5 the services return
+type digest struct {
+	r      io.Reader
+	sha    hash.Hash
+	md5sum hash.Hash
+}
+
+func newDigest(r io.Reader) *digest {
+	return &digest{r: r, sha: sha256.New(), md5sum: md5.New()}
+}
+
+func (d *digest) Read(b []byte) (int, error) {
+	n, err := d.r.Read(b)
+	d.sha.Write(b[:n])
+	d.md5sum.Write(b[:n])
+	return n, err
+}
+
+// SHA256 returns the hex SHA-256 of everything read so far
+func (d *digest) SHA256() string {
+	return hex.EncodeToString(d.sha.Sum(nil))
+}
+
+// MD5 returns the MD5 of everything read so far
+func (d *digest) MD5() []byte {
+	return d.md5sum.Sum(nil)
+}
+
+// fileDigest reads the file at path through a digest
+func fileDigest(path string) (*digest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d := newDigest(f)
+	if _, err := io.Copy(io.Discard, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// FileSHA256 returns the hex SHA-256 of the file at path
+func FileSHA256(path string) (string, error) {
+	d, err := fileDigest(path)
+	if err != nil {
+		return "", err
+	}
+	return d.SHA256(), nil
+}
+
+// md5ETag matches the ETag of an object uploaded in a single PUT, the MD5 of its
+// content; multipart ETags end in -<parts>
+var md5ETag = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// checkETag compares the ETag returned for an upload with the MD5 of what was
+// sent, where the ETag is an MD5; it isn't for multipart uploads and SSE-KMS
+func checkETag(objectKey, etag string, sum []byte) error {
+	if !md5ETag.MatchString(etag) {
+		return nil
+	}
+	if got := hex.EncodeToString(sum); etag != got {
+		return fmt.Errorf("upload of %s: %w: stored MD5 %s, sent %s", objectKey, ErrChecksumMismatch, etag, got)
+	}
+	return nil
+}
+
+// VerifyFile checks the SHA-256 of the file at path against want
+func VerifyFile(path, want string) error {
+	got, err := FileSHA256(path)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%s: %w: sha256 is %s, want %s", path, ErrChecksumMismatch, got, want)
+	}
+	return nil
+}
+
+// DownloadVerified downloads objectKey to localPath and checks the file against
+// the SHA-256 recorded at upload; a file that doesn't match is removed. An
+// empty sha256 skips the check, for objects stored before checksums were kept.
+func DownloadVerified(ctx context.Context, s Storage, objectKey, localPath, sha256 string) error {
+	if err := s.DownloadFile(ctx, objectKey, localPath); err != nil {
+		return err
+	}
+	if sha256 == "" {
+		return nil
+	}
+	if err := VerifyFile(localPath, sha256); err != nil {
+		os.Remove(localPath)
+		return fmt.Errorf("download of %s: %w", objectKey, err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckETag(t *testing.T) {
+	sum := md5.Sum([]byte("audio"))
+	good := hex.EncodeToString(sum[:])
+	for _, tc := range []struct {
+		etag    string
+		wantErr bool
+	}{
+		{good, false},
+		{strings.Repeat("0", 32), true},
+		{good[:32-2] + "-3", false}, // multipart: not an MD5
+		{"", false},
+	} {
+		err := checkETag("a.wav", tc.etag, sum[:])
+		if got := errors.Is(err, ErrChecksumMismatch); got != tc.wantErr {
+			t.Errorf("etag %q: err %v", tc.etag, err)
+		}
+	}
+}
+
+func TestDownloadVerified(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStorage(LocalConfig{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "call.wav")
+	if err := os.WriteFile(src, []byte("RIFF call"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := s.UploadFile(ctx, src, "originals/call.wav", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("RIFF call"))
+	if info.SHA256 != hex.EncodeToString(sum[:]) || info.Size != 9 {
+		t.Errorf("upload info %+v", info)
+	}
+
+	dst := filepath.Join(t.TempDir(), "in.wav")
+	if err := DownloadVerified(ctx, s, "originals/call.wav", dst, info.SHA256); err != nil {
+		t.Errorf("matching download: %v", err)
+	}
+	if err := DownloadVerified(ctx, s, "originals/call.wav", dst, ""); err != nil {
+		t.Errorf("download without a checksum: %v", err)
+	}
+
+	err = DownloadVerified(ctx, s, "originals/call.wav", dst, strings.Repeat("0", 64))
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("mismatch: err %v, want ErrChecksumMismatch", err)
+	}
+	if _, err := os.Stat(dst); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the file that didn't match is kept: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// contentTypes are the MIME types of the files the service stores; the system
+// MIME tables of slim containers often lack the audio ones
+var contentTypes = map[string]string{
+	".wav":  "audio/wav",
+	".flac": "audio/flac",
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".opus": "audio/ogg",
+	".oga":  "audio/ogg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".amr":  "audio/amr",
+	".webm": "audio/webm",
+	".json": "application/json",
+	".png":  "image/png",
+	".vtt":  "text/vtt",
+	".srt":  "application/x-subrip",
+	".zip":  "application/zip",
+	".tar":  "application/x-tar",
+	".gz":   "application/gzip",
+	".tgz":  "application/gzip",
+}
+
+// sniffedTypes map what http.DetectContentType reports to the types above
+var sniffedTypes = map[string]string{
+	"audio/wave":      "audio/wav",
+	"application/ogg": "audio/ogg",
+}
+
+// typeByExtension returns the MIME type of a file or key name from its
+// extension; empty when unknown
+func typeByExtension(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ct, ok := contentTypes[ext]; ok {
+		return ct
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// ContentTypeOf returns the MIME type of a local file from its extension, else
+// from its first bytes; application/octet-stream when neither tells
+func ContentTypeOf(localPath string) string {
+	if ct := typeByExtension(filepath.Base(localPath)); ct != "" {
+		return ct
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	ct, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if mapped, ok := sniffedTypes[ct]; ok {
+		return mapped
+	}
+	if ct == "" {
+		return "application/octet-stream"
+	}
+	return ct
+}
+
+// uploadContentType is the content type an upload is stored with: the one
+// given, else the one of the file, or of the key for streams
+func uploadContentType(contentType, localPath, objectKey string) string {
+	if contentType != "" {
+		return contentType
+	}
+	if localPath != "" {
+		return ContentTypeOf(localPath)
+	}
+	if ct := typeByExtension(objectKey); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// contentDisposition makes browsers save a downloaded object under the last
+// element of its key instead of the name of the presigned URL
+func contentDisposition(objectKey string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(objectKey)})
+}
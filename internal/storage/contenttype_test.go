@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestContentTypeOf(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	wav := []byte("RIFF\x24\x00\x00\x00WAVEfmt \x10\x00\x00\x00\x01\x00\x01\x00")
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{write("call.WAV", nil), "audio/wav"}, // extension, case-insensitive
+		{write("voicemail.opus", nil), "audio/ogg"},
+		{write("calls.tgz", nil), "application/gzip"},
+		{write("upload", wav), "audio/wav"}, // sniffed, mapped from audio/wave
+		{write("notes", []byte("just text")), "text/plain"},
+		{write("blob", []byte{0x00, 0x01, 0x02}), "application/octet-stream"},
+		{filepath.Join(dir, "missing"), "application/octet-stream"},
+	} {
+		if got := ContentTypeOf(tc.path); got != tc.want {
+			t.Errorf("%s: %q, want %q", filepath.Base(tc.path), got, tc.want)
+		}
+	}
+}
+
+func TestUploadContentType(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "call.flac")
+	if err := os.WriteFile(local, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		contentType, localPath, key string
+		want                        string
+	}{
+		{"audio/x-custom", local, "originals/a.wav", "audio/x-custom"}, // given wins
+		{"", local, "originals/a.wav", "audio/flac"},                   // the file's
+		{"", "", "processed/a.mp3", "audio/mpeg"},                      // a stream: the key's
+		{"", "", "processed/a", "application/octet-stream"},
+	} {
+		if got := uploadContentType(tc.contentType, tc.localPath, tc.key); got != tc.want {
+			t.Errorf("%+v: %q, want %q", tc, got, tc.want)
+		}
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	if got, want := contentDisposition("processed/job1/call 1.wav"), `attachment; filename="call 1.wav"`; got != want {
+		t.Errorf("%q, want %q", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// ProgressFunc is told how many of the total bytes of an upload were sent so far.
+// Parallel part uploads call it from several goroutines, one at a time.
+type ProgressFunc func(sent, total int64)
+
+type progressKey struct{}
+
+// WithProgress returns a context whose UploadFile calls report their progress to fn
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+func progressFrom(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn
+}
+
+// progressReader counts the bytes passed through Read, the way minio reports the
+// progress of parts (PutObjectOptions.Progress), or read through it as a wrapper
+// of r. Parts sent again after a failed attempt count again, so sent is capped
+// at total.
+type progressReader struct {
+	r     io.Reader // nil for minio's progress hook
+	total int64
+	fn    ProgressFunc
+
+	mu   sync.Mutex
+	sent int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := len(b), error(nil)
+	if p.r != nil {
+		n, err = p.r.Read(b)
+	}
+	p.mu.Lock()
+	p.sent = min(p.sent+int64(n), p.total)
+	p.fn(p.sent, p.total)
+	p.mu.Unlock()
+	return n, err
+}
+
+// Tags describe an object for bucket lifecycle rules and cost reports. S3 stores
+// them as object tags and user metadata (GCS as metadata only), Azure as blob
+// index tags and metadata; the local backend ignores them. Keys and values must
+// be valid S3 tags and keys valid Azure metadata names, e.g. job_id.
+type Tags map[string]string
+
+type tagsKey struct{}
+
+// WithTags returns a context whose uploads are tagged with tags
+func WithTags(ctx context.Context, tags Tags) context.Context {
+	return context.WithValue(ctx, tagsKey{}, tags)
+}
+
+func tagsFrom(ctx context.Context) Tags {
+	tags, _ := ctx.Value(tagsKey{}).(Tags)
+	return tags
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/metrics"
+)
+
+// Ranged downloads of large objects, defaults of S3Config
+const (
+	defaultDownloadPartSize    = 16 << 20
+	defaultDownloadConcurrency = 4
+)
+
+// DownloadFile downloads objectKey from the bucket to localPath. Objects of more
+// than two download parts are fetched as ranges in parallel, see downloadRanged;
+// smaller ones in one GET.
+func (s *S3Client) DownloadFile(ctx context.Context, objectKey, localPath string) error {
+	start := time.Now()
+	info, err := s.Client.StatObject(ctx, s.bucket, objectKey, minio.StatObjectOptions{})
+	if err != nil {
+		return err
+	}
+	if info.Size > 2*s.downloadPartSize {
+		err = s.downloadRanged(ctx, objectKey, localPath, info)
+	} else {
+		err = s.Client.FGetObject(ctx, s.bucket, objectKey, localPath, minio.GetObjectOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	if secs := time.Since(start).Seconds(); secs > 0 {
+		metrics.DownloadThroughput.Observe(float64(info.Size) / secs)
+	}
+	return nil
+}
+
+// downloadRanged writes the parts of an object into a file of its size, up to
+// downloadConcurrency parts at a time. A part that fails is resumed from the
+// last byte received, with the retry policy of the client; all parts are read
+// from the version of the ETag seen first, so an object replaced in the
+// meantime fails the download instead of mixing two versions.
+func (s *S3Client) downloadRanged(ctx context.Context, objectKey, localPath string, info minio.ObjectInfo) error {
+	tmp := localPath + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(info.Size); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(s.downloadConcurrency)
+	for off := int64(0); off < info.Size; off += s.downloadPartSize {
+		end := min(off+s.downloadPartSize, info.Size) - 1
+		g.Go(func() error {
+			return s.downloadPart(gctx, f, objectKey, info.ETag, off, end)
+		})
+	}
+	err = g.Wait()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, localPath)
+}
+
+// downloadPart writes bytes off..end (inclusive) of objectKey to f at the same
+// offset
+func (s *S3Client) downloadPart(ctx context.Context, f *os.File, objectKey, etag string, off, end int64) error {
+	next := off
+	err := s.retry.do(ctx, "download_part", s.retry.TransferTimeout, func(ctx context.Context) error {
+		opts := minio.GetObjectOptions{}
+		if err := opts.SetMatchETag(etag); err != nil {
+			return err
+		}
+		if err := opts.SetRange(next, end); err != nil {
+			return err
+		}
+		obj, err := s.Client.GetObject(ctx, s.bucket, objectKey, opts)
+		if err != nil {
+			return err
+		}
+		defer obj.Close()
+		n, err := io.Copy(io.NewOffsetWriter(f, next), obj)
+		next += n
+		if err == nil && next != end+1 {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("bytes %d-%d of %s: %w", off, end, objectKey, err)
+	}
+	return nil
+}
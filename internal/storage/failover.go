@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/metrics"
+)
+
+// Endpoints an object can be on, see UploadInfo.Endpoint
+const (
+	EndpointPrimary   = "primary"
+	EndpointSecondary = "secondary"
+)
+
+// FailoverConfig adds a secondary S3 endpoint, e.g. another MinIO site, that
+// takes the uploads while the primary one fails
+type FailoverConfig struct {
+	Endpoint  string        // empty disables failover
+	AccessKey string        // empty uses the credentials of the primary
+	SecretKey string        //
+	Bucket    string        // empty uses the bucket name of the primary
+	After     int           // uploads failing in a row before writes go to the secondary
+	Cooldown  time.Duration // before the primary is tried again
+}
+
+// failoverConfigFromEnv reads S3_SECONDARY_ENDPOINT, S3_SECONDARY_ACCESS_KEY,
+// S3_SECONDARY_SECRET_KEY, S3_SECONDARY_BUCKET, S3_FAILOVER_AFTER and
+// S3_FAILOVER_COOLDOWN_SECS
+func failoverConfigFromEnv() FailoverConfig {
+	c := FailoverConfig{
+		Endpoint:  os.Getenv("S3_SECONDARY_ENDPOINT"),
+		AccessKey: os.Getenv("S3_SECONDARY_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECONDARY_SECRET_KEY"),
+		Bucket:    os.Getenv("S3_SECONDARY_BUCKET"),
+		After:     3,
+		Cooldown:  time.Minute,
+	}
+	if v, err := strconv.Atoi(os.Getenv("S3_FAILOVER_AFTER")); err == nil && v > 0 {
+		c.After = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("S3_FAILOVER_COOLDOWN_SECS")); err == nil && v >= 0 {
+		c.Cooldown = time.Duration(v) * time.Second
+	}
+	return c
+}
+
+// failoverStorage writes to the primary endpoint and fails over to the
+// secondary one: an upload failing on the primary for a transient reason, after
+// the retries, is sent to the secondary, and after After such failures in a row
+// all writes go there for Cooldown. Reads look for the object on the endpoint
+// currently written to first, then on the other one, so objects written during
+// an outage stay readable after it. Objects are recorded with the bucket of
+// the primary and the endpoint of UploadInfo.
+type failoverStorage struct {
+	primary, secondary Storage
+	after              int
+	cooldown           time.Duration
+
+	mu       sync.Mutex
+	failures int       // uploads failed on the primary in a row
+	until    time.Time // writes go to the secondary until then
+}
+
+func newFailoverStorage(primary, secondary Storage, c FailoverConfig) *failoverStorage {
+	return &failoverStorage{primary: primary, secondary: secondary, after: max(c.After, 1), cooldown: c.Cooldown}
+}
+
+func (f *failoverStorage) Bucket() string {
+	return f.primary.Bucket()
+}
+
+// failedOver reports whether writes go to the secondary
+func (f *failoverStorage) failedOver() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.until.IsZero() && time.Now().After(f.until) {
+		f.until = time.Time{}
+		log.Printf("[storage] %s: trying the primary endpoint again", f.Bucket())
+		metrics.StorageFailedOver.WithLabelValues(f.Bucket()).Set(0)
+	}
+	return !f.until.IsZero()
+}
+
+// written records the outcome of a write to the primary; it returns whether
+// the write should go to the secondary instead
+func (f *failoverStorage) written(err error) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		f.failures = 0
+		return false
+	}
+	if !Retryable(err) {
+		return false
+	}
+	f.failures++
+	if f.failures >= f.after && f.until.IsZero() {
+		f.until = time.Now().Add(f.cooldown)
+		f.failures = 0
+		log.Printf("[storage] %s: failing over to the secondary endpoint for %s: %v", f.Bucket(), f.cooldown, err)
+		metrics.StorageFailovers.Inc()
+		metrics.StorageFailedOver.WithLabelValues(f.Bucket()).Set(1)
+	}
+	return true
+}
+
+func (f *failoverStorage) UploadFile(ctx context.Context, localPath, objectKey, contentType string) (UploadInfo, error) {
+	if !f.failedOver() {
+		info, err := f.primary.UploadFile(ctx, localPath, objectKey, contentType)
+		if !f.written(err) || ctx.Err() != nil {
+			info.Endpoint = EndpointPrimary
+			return info, err
+		}
+		log.Printf("[storage] upload %s to the primary endpoint failed, sending it to the secondary: %v", objectKey, err)
+	}
+	info, err := f.secondary.UploadFile(ctx, localPath, objectKey, contentType)
+	info.Endpoint = EndpointSecondary
+	return info, err
+}
+
+// UploadStream can't resend what was read from r; a failure only counts
+// towards failing over the next writes
+func (f *failoverStorage) UploadStream(ctx context.Context, r io.Reader, objectKey, contentType string) (UploadInfo, error) {
+	if f.failedOver() {
+		info, err := f.secondary.UploadStream(ctx, r, objectKey, contentType)
+		info.Endpoint = EndpointSecondary
+		return info, err
+	}
+	info, err := f.primary.UploadStream(ctx, r, objectKey, contentType)
+	f.written(err)
+	info.Endpoint = EndpointPrimary
+	return info, err
+}
+
+// PresignedPost hands out a policy for the endpoint currently written to
+func (f *failoverStorage) PresignedPost(ctx context.Context, objectKey string, c PostConditions) (PostPolicy, error) {
+	if f.failedOver() {
+		return f.secondary.PresignedPost(ctx, objectKey, c)
+	}
+	return f.primary.PresignedPost(ctx, objectKey, c)
+}
+
+// locate returns the endpoint holding objectKey and its description
+func (f *failoverStorage) locate(ctx context.Context, objectKey string) (Storage, ObjectInfo, error) {
+	order := []Storage{f.primary, f.secondary}
+	if f.failedOver() {
+		order[0], order[1] = order[1], order[0]
+	}
+	var firstErr error
+	for _, s := range order {
+		info, err := s.StatObject(ctx, objectKey)
+		if err == nil {
+			return s, info, nil
+		}
+		if !errors.Is(err, ErrNotFound) && !Retryable(err) {
+			return nil, info, err
+		}
+		// a missing object beats an unreachable endpoint as the answer
+		if firstErr == nil || errors.Is(err, ErrNotFound) {
+			firstErr = err
+		}
+	}
+	return nil, ObjectInfo{}, firstErr
+}
+
+func (f *failoverStorage) StatObject(ctx context.Context, objectKey string) (ObjectInfo, error) {
+	_, info, err := f.locate(ctx, objectKey)
+	return info, err
+}
+
+func (f *failoverStorage) DownloadFile(ctx context.Context, objectKey, localPath string) error {
+	s, _, err := f.locate(ctx, objectKey)
+	if err != nil {
+		return err
+	}
+	return s.DownloadFile(ctx, objectKey, localPath)
+}
+
+func (f *failoverStorage) PresignedGetURL(ctx context.Context, objectKey string) (string, error) {
+	s, _, err := f.locate(ctx, objectKey)
+	if err != nil {
+		return "", err
+	}
+	return s.PresignedGetURL(ctx, objectKey)
+}
+
+// Delete removes objectKey from both endpoints
+func (f *failoverStorage) Delete(ctx context.Context, objectKey string) error {
+	err := f.primary.Delete(ctx, objectKey)
+	if serr := f.secondary.Delete(ctx, objectKey); err == nil {
+		err = serr
+	}
+	return err
+}
+
+func (f *failoverStorage) SetStorageClass(ctx context.Context, objectKey, class string) error {
+	s, _, err := f.locate(ctx, objectKey)
+	if err != nil {
+		return err
+	}
+	return s.SetStorageClass(ctx, objectKey, class)
+}
+
+// List lists the primary only, objects written to the secondary are recorded
+// on their jobs
+func (f *failoverStorage) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	return f.primary.List(ctx, prefix, fn)
+}
+
+func (f *failoverStorage) ListVersions(ctx context.Context, objectKey string) ([]ObjectVersion, error) {
+	s, _, err := f.locate(ctx, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.ListVersions(ctx, objectKey)
+}
+
+func (f *failoverStorage) PresignedGetVersionURL(ctx context.Context, objectKey, versionID string) (string, error) {
+	s, _, err := f.locate(ctx, objectKey)
+	if err != nil {
+		return "", err
+	}
+	return s.PresignedGetVersionURL(ctx, objectKey, versionID)
+}
+
+func (f *failoverStorage) RestoreVersion(ctx context.Context, objectKey, versionID string) (UploadInfo, error) {
+	s, _, err := f.locate(ctx, objectKey)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	info, err := s.RestoreVersion(ctx, objectKey, versionID)
+	info.Endpoint = EndpointPrimary
+	if s == f.secondary {
+		info.Endpoint = EndpointSecondary
+	}
+	return info, err
+}
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LocalConfig configures the local filesystem backend
+type LocalConfig struct {
+	Root    string // directory objects are stored under
+	BaseURL string // where Root is served over HTTP (e.g. by nginx); empty hands out file:// URLs
+}
+
+// LocalStorage stores objects as files under a directory. It has no access
+// control of its own: download links are plain URLs under BaseURL.
+type LocalStorage struct {
+	root    string
+	baseURL string
+}
+
+// NewLocalStorage creates the root directory if needed
+func NewLocalStorage(cfg LocalConfig) (*LocalStorage, error) {
+	root, err := filepath.Abs(cfg.Root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{root: root, baseURL: strings.TrimRight(cfg.BaseURL, "/")}, nil
+}
+
+// Bucket returns the root directory
+func (l *LocalStorage) Bucket() string {
+	return l.root
+}
+
+// path maps objectKey to a file under the root, rejecting keys that escape it
+func (l *LocalStorage) path(objectKey string) (string, error) {
+	p := filepath.Join(l.root, filepath.FromSlash(objectKey))
+	if !strings.HasPrefix(p, l.root+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid object key %q", objectKey)
+	}
+	return p, nil
+}
+
+// UploadFile copies a local file into the store
+func (l *LocalStorage) UploadFile(ctx context.Context, localPath, objectKey, contentType string) (UploadInfo, error) {
+	dst, err := l.path(objectKey)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return UploadInfo{}, err
+	}
+	src, err := os.Open(localPath)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	defer src.Close()
+	var r io.Reader = src
+	if fn := progressFrom(ctx); fn != nil {
+		fi, err := src.Stat()
+		if err != nil {
+			return UploadInfo{}, err
+		}
+		r = &progressReader{r: src, total: fi.Size(), fn: fn}
+	}
+	return l.write(dst, objectKey, r)
+}
+
+// UploadStream writes r into the store
+func (l *LocalStorage) UploadStream(ctx context.Context, r io.Reader, objectKey, contentType string) (UploadInfo, error) {
+	dst, err := l.path(objectKey)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return UploadInfo{}, err
+	}
+	return l.write(dst, objectKey, r)
+}
+
+// write stores r at dst, hashing it on the way, and reads the file back to
+// check it holds what was sent
+func (l *LocalStorage) write(dst, objectKey string, r io.Reader) (UploadInfo, error) {
+	d := newDigest(r)
+	if err := writeFile(dst, d); err != nil {
+		return UploadInfo{}, err
+	}
+	if err := VerifyFile(dst, d.SHA256()); err != nil {
+		os.Remove(dst)
+		return UploadInfo{}, fmt.Errorf("upload of %s: %w", objectKey, err)
+	}
+	fi, err := os.Stat(dst)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	return UploadInfo{Key: objectKey, Size: fi.Size(), SHA256: d.SHA256()}, nil
+}
+
+// DownloadFile copies objectKey out of the store to localPath
+func (l *LocalStorage) DownloadFile(ctx context.Context, objectKey, localPath string) error {
+	src, err := l.path(objectKey)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("object %q not found", objectKey)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writeFile(localPath, f)
+}
+
+// Delete removes the file of objectKey
+func (l *LocalStorage) Delete(ctx context.Context, objectKey string) error {
+	p, err := l.path(objectKey)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// PresignedGetURL returns the URL of objectKey under BaseURL, or its file:// URL;
+// the links don't expire
+func (l *LocalStorage) PresignedGetURL(ctx context.Context, objectKey string) (string, error) {
+	p, err := l.path(objectKey)
+	if err != nil {
+		return "", err
+	}
+	if l.baseURL == "" {
+		return (&url.URL{Scheme: "file", Path: filepath.ToSlash(p)}).String(), nil
+	}
+	segments := strings.Split(objectKey, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return l.baseURL + "/" + strings.Join(segments, "/"), nil
+}
+
+// StatObject describes the file of objectKey; the content type is not kept
+func (l *LocalStorage) StatObject(ctx context.Context, objectKey string) (ObjectInfo, error) {
+	p, err := l.path(objectKey)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	fi, err := os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return ObjectInfo{}, fmt.Errorf("%s: %w", objectKey, ErrNotFound)
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Key: objectKey, Size: fi.Size(), LastModified: fi.ModTime()}, nil
+}
+
+// PresignedPost is not supported: nothing serves the directory for uploads
+func (l *LocalStorage) PresignedPost(ctx context.Context, objectKey string, c PostConditions) (PostPolicy, error) {
+	return PostPolicy{}, fmt.Errorf("presigned POST: %w", ErrUnsupported)
+}
+
+// SetStorageClass is not supported: a directory has no storage classes
+func (l *LocalStorage) SetStorageClass(ctx context.Context, objectKey, class string) error {
+	return fmt.Errorf("storage class: %w", ErrUnsupported)
+}
+
+// List calls fn for the files under prefix in the byte order of their keys;
+// leftovers of interrupted writes (.part) are skipped
+func (l *LocalStorage) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	var objs []ObjectInfo
+	err := filepath.WalkDir(l.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(p, ".part") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objs = append(objs, ObjectInfo{Key: key, Size: fi.Size(), LastModified: fi.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// the walk goes by directory, which is not the byte order of whole keys
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Key < objs[j].Key })
+	for _, o := range objs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListVersions is not supported: files are overwritten in place
+func (l *LocalStorage) ListVersions(ctx context.Context, objectKey string) ([]ObjectVersion, error) {
+	return nil, fmt.Errorf("object versions: %w", ErrUnsupported)
+}
+
+// PresignedGetVersionURL is not supported, see ListVersions
+func (l *LocalStorage) PresignedGetVersionURL(ctx context.Context, objectKey, versionID string) (string, error) {
+	return "", fmt.Errorf("object versions: %w", ErrUnsupported)
+}
+
+// RestoreVersion is not supported, see ListVersions
+func (l *LocalStorage) RestoreVersion(ctx context.Context, objectKey, versionID string) (UploadInfo, error) {
+	return UploadInfo{}, fmt.Errorf("object versions: %w", ErrUnsupported)
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/metrics"
+)
+
+// RetryPolicy retries failed S3/GCS operations with exponential backoff and
+// bounds every attempt with a timeout. It comes on top of the retries minio
+// makes of single requests (S3Config.PartRetries), which give up after about a
+// second; the policy rides out outages of several seconds.
+type RetryPolicy struct {
+	Attempts        int           // tries per operation; 1 disables retries
+	BaseDelay       time.Duration // before the second try, doubled for every further one
+	MaxDelay        time.Duration // cap of the backoff
+	OpTimeout       time.Duration // per attempt of stat, delete, presign and copy calls; 0 is none
+	TransferTimeout time.Duration // per attempt of uploads and downloads; 0 is none
+}
+
+// DefaultRetryPolicy is used for settings missing from the environment
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:  5,
+	BaseDelay: 500 * time.Millisecond,
+	MaxDelay:  15 * time.Second,
+	OpTimeout: 30 * time.Second,
+}
+
+// retryPolicyFromEnv reads S3_RETRY_ATTEMPTS, S3_RETRY_BASE_MS, S3_RETRY_MAX_MS,
+// S3_OP_TIMEOUT_SECS and S3_TRANSFER_TIMEOUT_SECS
+func retryPolicyFromEnv() RetryPolicy {
+	p := DefaultRetryPolicy
+	if v, err := strconv.Atoi(os.Getenv("S3_RETRY_ATTEMPTS")); err == nil && v > 0 {
+		p.Attempts = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("S3_RETRY_BASE_MS")); err == nil && v >= 0 {
+		p.BaseDelay = time.Duration(v) * time.Millisecond
+	}
+	if v, err := strconv.Atoi(os.Getenv("S3_RETRY_MAX_MS")); err == nil && v >= 0 {
+		p.MaxDelay = time.Duration(v) * time.Millisecond
+	}
+	if v, err := strconv.Atoi(os.Getenv("S3_OP_TIMEOUT_SECS")); err == nil && v >= 0 {
+		p.OpTimeout = time.Duration(v) * time.Second
+	}
+	if v, err := strconv.Atoi(os.Getenv("S3_TRANSFER_TIMEOUT_SECS")); err == nil && v >= 0 {
+		p.TransferTimeout = time.Duration(v) * time.Second
+	}
+	return p
+}
+
+// backoff returns the delay before try n (1 is the first retry)
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxDelay)
+}
+
+// do runs fn until it succeeds, fails for good or the attempts are used up.
+// Every attempt gets a context with timeout; an attempt timing out is retried
+// as long as ctx itself is alive.
+func (p RetryPolicy) do(ctx context.Context, op string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	for try := 1; ; try++ {
+		actx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			actx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		err := fn(actx)
+		cancel()
+		if err == nil || try >= p.Attempts || ctx.Err() != nil || !Retryable(err) {
+			return err
+		}
+		delay := p.backoff(try)
+		metrics.StorageRetries.WithLabelValues(op).Inc()
+		log.Printf("[storage] %s failed (try %d of %d), retrying in %s: %v", op, try, p.Attempts, delay, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// fatalCodes are S3 error codes no retry will fix
+var fatalCodes = map[string]bool{
+	"AccessDenied":          true,
+	"InvalidAccessKeyId":    true,
+	"SignatureDoesNotMatch": true,
+	"NoSuchBucket":          true,
+	"NoSuchKey":             true,
+	"NoSuchVersion":         true,
+	"InvalidArgument":       true,
+	"InvalidObjectState":    true, // archived, needs a restore
+	"EntityTooLarge":        true,
+	"MethodNotAllowed":      true,
+	"NotImplemented":        true,
+}
+
+// Retryable reports whether err is transient: network errors, timeouts of an
+// attempt, throttling and server errors of the service, and uploads whose ETag
+// didn't match what was sent. Missing objects, denied access, invalid requests
+// and cancelled contexts are not.
+func Retryable(err error) bool {
+	switch {
+	case err == nil, errors.Is(err, context.Canceled), errors.Is(err, ErrNotFound), errors.Is(err, ErrUnsupported):
+		return false
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrChecksumMismatch):
+		return true
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return true
+	}
+	var resp minio.ErrorResponse
+	if errors.As(err, &resp) {
+		if fatalCodes[resp.Code] {
+			return false
+		}
+		return resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests ||
+			resp.StatusCode >= 500 || resp.Code == "SlowDown" || resp.Code == "RequestTimeout" || resp.Code == "UnexpectedEOF"
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
+
+// retryStorage applies a RetryPolicy to the operations of an S3/GCS client.
+// Streamed uploads are not retried as a whole, their reader can't be replayed;
+// minio retries their parts.
+type retryStorage struct {
+	Storage
+	policy RetryPolicy
+}
+
+func withRetry(s Storage, p RetryPolicy) Storage {
+	if p.Attempts < 1 {
+		p.Attempts = 1
+	}
+	return &retryStorage{Storage: s, policy: p}
+}
+
+func (r *retryStorage) UploadFile(ctx context.Context, localPath, objectKey, contentType string) (UploadInfo, error) {
+	var info UploadInfo
+	err := r.policy.do(ctx, "upload", r.policy.TransferTimeout, func(ctx context.Context) (err error) {
+		info, err = r.Storage.UploadFile(ctx, localPath, objectKey, contentType)
+		return err
+	})
+	return info, err
+}
+
+// DownloadFile retries downloads; the ranges of large objects are resumed on
+// their own first, see S3Client.downloadRanged
+func (r *retryStorage) DownloadFile(ctx context.Context, objectKey, localPath string) error {
+	return r.policy.do(ctx, "download", r.policy.TransferTimeout, func(ctx context.Context) error {
+		return r.Storage.DownloadFile(ctx, objectKey, localPath)
+	})
+}
+
+func (r *retryStorage) Delete(ctx context.Context, objectKey string) error {
+	return r.policy.do(ctx, "delete", r.policy.OpTimeout, func(ctx context.Context) error {
+		return r.Storage.Delete(ctx, objectKey)
+	})
+}
+
+func (r *retryStorage) PresignedGetURL(ctx context.Context, objectKey string) (string, error) {
+	var u string
+	err := r.policy.do(ctx, "presign", r.policy.OpTimeout, func(ctx context.Context) (err error) {
+		u, err = r.Storage.PresignedGetURL(ctx, objectKey)
+		return err
+	})
+	return u, err
+}
+
+func (r *retryStorage) StatObject(ctx context.Context, objectKey string) (ObjectInfo, error) {
+	var info ObjectInfo
+	err := r.policy.do(ctx, "stat", r.policy.OpTimeout, func(ctx context.Context) (err error) {
+		info, err = r.Storage.StatObject(ctx, objectKey)
+		return err
+	})
+	return info, err
+}
+
+func (r *retryStorage) PresignedPost(ctx context.Context, objectKey string, c PostConditions) (PostPolicy, error) {
+	var p PostPolicy
+	err := r.policy.do(ctx, "presign", r.policy.OpTimeout, func(ctx context.Context) (err error) {
+		p, err = r.Storage.PresignedPost(ctx, objectKey, c)
+		return err
+	})
+	return p, err
+}
+
+func (r *retryStorage) ListVersions(ctx context.Context, objectKey string) ([]ObjectVersion, error) {
+	var versions []ObjectVersion
+	err := r.policy.do(ctx, "list", r.policy.OpTimeout, func(ctx context.Context) (err error) {
+		versions, err = r.Storage.ListVersions(ctx, objectKey)
+		return err
+	})
+	return versions, err
+}
+
+func (r *retryStorage) PresignedGetVersionURL(ctx context.Context, objectKey, versionID string) (string, error) {
+	var u string
+	err := r.policy.do(ctx, "presign", r.policy.OpTimeout, func(ctx context.Context) (err error) {
+		u, err = r.Storage.PresignedGetVersionURL(ctx, objectKey, versionID)
+		return err
+	})
+	return u, err
+}
+
+func (r *retryStorage) RestoreVersion(ctx context.Context, objectKey, versionID string) (UploadInfo, error) {
+	var info UploadInfo
+	err := r.policy.do(ctx, "copy", r.policy.OpTimeout, func(ctx context.Context) (err error) {
+		info, err = r.Storage.RestoreVersion(ctx, objectKey, versionID)
+		return err
+	})
+	return info, err
+}
+
+func (r *retryStorage) SetStorageClass(ctx context.Context, objectKey, class string) error {
+	return r.policy.do(ctx, "copy", r.policy.OpTimeout, func(ctx context.Context) error {
+		return r.Storage.SetStorageClass(ctx, objectKey, class)
+	})
+}
@@ -3,19 +3,32 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 )
 
-// S3Client wraps minio client + bucket config
+// S3Client wraps minio client + bucket config. It serves MinIO, AWS S3 and
+// Google Cloud Storage (XML API with HMAC keys).
 type S3Client struct {
 	Client        *minio.Client
-	Bucket        string
+	bucket        string
 	PresignExpiry time.Duration
+	partSize      uint64 // 0: minio picks one from the object size
+	concurrency   uint
+	sse           encrypt.ServerSide // nil: the bucket default applies
+	noTagging     bool               // GCS has no object tagging
+
+	downloadPartSize    int64
+	downloadConcurrency int
+	retry               RetryPolicy // of the parts of ranged downloads
 }
 
 // S3Config holds configuration (Endpoint can be "localhost:9000" or "http://localhost:9000")
@@ -24,10 +37,55 @@ type S3Config struct {
 	AccessKey   string
 	SecretKey   string
 	Bucket      string
-	UseSSL      bool // optional override: if true/false, it forces Secure. If false and Endpoint has scheme, scheme takes precedence.
+	UseSSL      bool   // optional override: if true/false, it forces Secure. If false and Endpoint has scheme, scheme takes precedence.
+	Region      string // optional, e.g. "eu-west-1" for AWS S3; looked up when empty
 	PresignSecs int
+
+	// multipart uploads of large outputs; zero values keep the minio defaults
+	PartSize    uint64 // bytes per part, at least 5 MiB
+	Concurrency uint   // parts uploaded in parallel
+	PartRetries int    // attempts per part (and every other request) before giving up
+
+	Retry RetryPolicy // retries of whole operations, on top of PartRetries
+
+	// ranged downloads of large objects; zero values use the defaults
+	DownloadPartSize    int64 // bytes per range
+	DownloadConcurrency int   // ranges fetched in parallel
+
+	// server-side encryption requested on every upload
+	SSE      string // "" (bucket default), SSEAES256 or SSEKMS
+	KMSKeyID string // SSEKMS only; empty uses the account's default aws/s3 key
+
+	NoTagging bool // the service has no object tagging (GCS); Tags go to user metadata only
+
+	Failover FailoverConfig // secondary endpoint taking the uploads while this one fails
 }
 
+// Server-side encryption of S3Config.SSE
+const (
+	SSEAES256 = "AES256"  // SSE-S3, keys managed by the service
+	SSEKMS    = "aws:kms" // SSE-KMS
+)
+
+// serverSide returns the encryption of cfg for minio, nil for the bucket default
+func (cfg S3Config) serverSide() (encrypt.ServerSide, error) {
+	switch cfg.SSE {
+	case "":
+		if cfg.KMSKeyID != "" {
+			return nil, fmt.Errorf("a KMS key id needs SSE %s", SSEKMS)
+		}
+		return nil, nil
+	case SSEAES256:
+		return encrypt.NewSSE(), nil
+	case SSEKMS:
+		return encrypt.NewSSEKMS(cfg.KMSKeyID, nil)
+	}
+	return nil, fmt.Errorf("unknown SSE %q (want %s or %s)", cfg.SSE, SSEAES256, SSEKMS)
+}
+
+// minPartSize is the smallest part S3 accepts, except for the last one
+const minPartSize = 5 << 20
+
 // normalizeEndpoint accepts either "localhost:9000" or "http://localhost:9000" (with or without trailing slash)
 // and returns host:port and secure flag. It strips any path component and ignores trailing slashes.
 func normalizeEndpoint(raw string, cfgUseSSL bool) (endpointHost string, secure bool, err error) {
@@ -68,12 +126,21 @@ func NewS3Client(cfg S3Config) (*S3Client, error) {
 	if err != nil {
 		return nil, err
 	}
+	if cfg.PartSize > 0 && cfg.PartSize < minPartSize {
+		return nil, fmt.Errorf("part size %d is below the S3 minimum of %d bytes", cfg.PartSize, minPartSize)
+	}
+	sse, err := cfg.serverSide()
+	if err != nil {
+		return nil, err
+	}
 
 	// If cfg.UseSSL was explicitly set to true or false, preserve it (normalizeEndpoint uses it when no scheme provided)
 	// Create minio client using host:port (no path, no scheme)
 	minioClient, err := minio.New(endpointHost, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
-		Secure: secure,
+		Creds:      credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:     secure,
+		Region:     cfg.Region,
+		MaxRetries: cfg.PartRetries,
 	})
 	if err != nil {
 		return nil, err
@@ -86,36 +153,322 @@ func NewS3Client(cfg S3Config) (*S3Client, error) {
 		return nil, err
 	}
 	if !exists {
-		if err := minioClient.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{}); err != nil {
+		if err := minioClient.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
 			return nil, err
 		}
 	}
 
+	if cfg.DownloadPartSize <= 0 {
+		cfg.DownloadPartSize = defaultDownloadPartSize
+	}
+	if cfg.DownloadConcurrency <= 0 {
+		cfg.DownloadConcurrency = defaultDownloadConcurrency
+	}
+	if cfg.Retry.Attempts < 1 {
+		cfg.Retry.Attempts = 1
+	}
+
 	exp := time.Duration(cfg.PresignSecs) * time.Second
 	return &S3Client{
 		Client:        minioClient,
-		Bucket:        cfg.Bucket,
+		bucket:        cfg.Bucket,
 		PresignExpiry: exp,
+		partSize:      cfg.PartSize,
+		concurrency:   cfg.Concurrency,
+		sse:           sse,
+		noTagging:     cfg.NoTagging,
+
+		downloadPartSize:    cfg.DownloadPartSize,
+		downloadConcurrency: cfg.DownloadConcurrency,
+		retry:               cfg.Retry,
 	}, nil
 }
 
-// UploadFile uploads a local file to S3 and returns upload info
-func (s *S3Client) UploadFile(ctx context.Context, localPath, objectKey, contentType string) (minio.UploadInfo, error) {
-	info, err := s.Client.FPutObject(ctx, s.Bucket, objectKey, localPath, minio.PutObjectOptions{
-		ContentType: contentType,
-	})
+// Bucket returns the bucket objects are stored in
+func (s *S3Client) Bucket() string {
+	return s.bucket
+}
+
+// putOptions are the options every upload shares: content type and disposition,
+// encryption and the tags of ctx, as object tags and user metadata. A non-empty
+// sha256 is added to the metadata under MetaSHA256.
+func (s *S3Client) putOptions(ctx context.Context, objectKey, contentType, sha256 string) minio.PutObjectOptions {
+	opts := minio.PutObjectOptions{
+		ContentType:          contentType,
+		ContentDisposition:   contentDisposition(objectKey),
+		ServerSideEncryption: s.sse,
+	}
+	tags := tagsFrom(ctx)
+	if len(tags) > 0 && !s.noTagging {
+		opts.UserTags = tags
+	}
+	if len(tags) > 0 || sha256 != "" {
+		opts.UserMetadata = make(map[string]string, len(tags)+1)
+		for k, v := range tags {
+			opts.UserMetadata[k] = v
+		}
+		if sha256 != "" {
+			opts.UserMetadata[MetaSHA256] = sha256
+		}
+	}
+	return opts
+}
+
+// checkETag compares the ETag of an upload with the MD5 of what was sent; the
+// ETags of SSE-KMS objects are no MD5s
+func (s *S3Client) checkETag(objectKey, etag string, sum []byte) error {
+	if s.sse != nil && s.sse.Type() == encrypt.KMS {
+		return nil
+	}
+	return checkETag(objectKey, etag, sum)
+}
+
+// UploadFile uploads a local file to S3 and returns upload info. Files above the
+// part size go up as multipart uploads, parts in parallel; the progress func of
+// ctx (WithProgress) sees the bytes of every part sent. The file is hashed
+// first; its SHA-256 goes into the object metadata and the ETag of a single
+// part upload is checked against its MD5.
+func (s *S3Client) UploadFile(ctx context.Context, localPath, objectKey, contentType string) (UploadInfo, error) {
+	d, err := fileDigest(localPath)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	opts := s.putOptions(ctx, objectKey, uploadContentType(contentType, localPath, objectKey), d.SHA256())
+	opts.PartSize = s.partSize
+	opts.NumThreads = s.concurrency
+	if fn := progressFrom(ctx); fn != nil {
+		fi, err := os.Stat(localPath)
+		if err != nil {
+			return UploadInfo{}, err
+		}
+		opts.Progress = &progressReader{total: fi.Size(), fn: fn}
+	}
+	info, err := s.Client.FPutObject(ctx, s.bucket, objectKey, localPath, opts)
 	if err != nil {
-		return minio.UploadInfo{}, err
+		return UploadInfo{}, err
 	}
-	return info, nil
+	if err := s.checkETag(objectKey, info.ETag, d.MD5()); err != nil {
+		return UploadInfo{}, err
+	}
+	return UploadInfo{Key: info.Key, Size: info.Size, ETag: info.ETag, VersionID: info.VersionID, SHA256: d.SHA256()}, nil
 }
 
-// PresignedGetURL returns a presigned GET URL for the objectKey valid for PresignExpiry
+// streamPartSize is the multipart part size of streamed uploads, buffered in
+// memory one part at a time; minio's default for unknown sizes is far larger
+const streamPartSize = 16 << 20
+
+// UploadStream uploads r as a multipart upload, aborted when reading r fails.
+// The checksum is only known at the end, too late for the object metadata.
+func (s *S3Client) UploadStream(ctx context.Context, r io.Reader, objectKey, contentType string) (UploadInfo, error) {
+	partSize := s.partSize
+	if partSize == 0 {
+		partSize = streamPartSize
+	}
+	opts := s.putOptions(ctx, objectKey, uploadContentType(contentType, "", objectKey), "")
+	opts.PartSize = partSize
+	d := newDigest(r)
+	info, err := s.Client.PutObject(ctx, s.bucket, objectKey, d, -1, opts)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	if err := s.checkETag(objectKey, info.ETag, d.MD5()); err != nil {
+		return UploadInfo{}, err
+	}
+	return UploadInfo{Key: info.Key, Size: info.Size, ETag: info.ETag, VersionID: info.VersionID, SHA256: d.SHA256()}, nil
+}
+
+// Delete removes every version of objectKey, so that nothing of it is left in
+// versioned buckets either (a plain delete would only add a delete marker)
+func (s *S3Client) Delete(ctx context.Context, objectKey string) error {
+	for obj := range s.Client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: objectKey, WithVersions: true}) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		if obj.Key != objectKey {
+			continue // another object under the same prefix
+		}
+		if err := s.Client.RemoveObject(ctx, s.bucket, objectKey, minio.RemoveObjectOptions{VersionID: obj.VersionID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PresignedGetURL returns a presigned GET URL for the objectKey valid for PresignExpiry.
+// SSE-S3 and SSE-KMS objects are decrypted by the service for any authorized
+// request, so the links need no encryption headers; they are SigV4 signed, which
+// SSE-KMS requires.
 func (s *S3Client) PresignedGetURL(ctx context.Context, objectKey string) (string, error) {
 	params := url.Values{}
-	u, err := s.Client.PresignedGetObject(ctx, s.Bucket, objectKey, s.PresignExpiry, params)
+	u, err := s.Client.PresignedGetObject(ctx, s.bucket, objectKey, s.PresignExpiry, params)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// StatObject describes objectKey
+func (s *S3Client) StatObject(ctx context.Context, objectKey string) (ObjectInfo, error) {
+	info, err := s.Client.StatObject(ctx, s.bucket, objectKey, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return ObjectInfo{}, fmt.Errorf("%s: %w", objectKey, ErrNotFound)
+		}
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{
+		Key:          info.Key,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+		VersionID:    info.VersionID,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+// List calls fn for the latest versions of the objects under prefix, in the
+// byte order of their keys
+func (s *S3Client) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	ctx, cancel := context.WithCancel(ctx) // stops the listing when fn fails
+	defer cancel()
+	for obj := range s.Client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		if err := fn(ObjectInfo{
+			Key:          obj.Key,
+			Size:         obj.Size,
+			ContentType:  obj.ContentType,
+			ETag:         obj.ETag,
+			LastModified: obj.LastModified,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListVersions lists the versions and delete markers of objectKey; an
+// unversioned bucket has one version "null"
+func (s *S3Client) ListVersions(ctx context.Context, objectKey string) ([]ObjectVersion, error) {
+	var out []ObjectVersion
+	for obj := range s.Client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: objectKey, WithVersions: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		if obj.Key != objectKey {
+			continue // another object under the same prefix
+		}
+		out = append(out, ObjectVersion{
+			VersionID:    obj.VersionID,
+			Size:         obj.Size,
+			ETag:         obj.ETag,
+			LastModified: obj.LastModified,
+			IsLatest:     obj.IsLatest,
+			DeleteMarker: obj.IsDeleteMarker,
+		})
+	}
+	return out, nil
+}
+
+// PresignedGetVersionURL returns a presigned GET URL for one version of objectKey
+func (s *S3Client) PresignedGetVersionURL(ctx context.Context, objectKey, versionID string) (string, error) {
+	params := url.Values{"versionId": {versionID}}
+	u, err := s.Client.PresignedGetObject(ctx, s.bucket, objectKey, s.PresignExpiry, params)
 	if err != nil {
 		return "", err
 	}
 	return u.String(), nil
 }
+
+// RestoreVersion copies a version of objectKey onto it with its metadata; the
+// SHA-256 of the result is the one recorded with the version
+func (s *S3Client) RestoreVersion(ctx context.Context, objectKey, versionID string) (UploadInfo, error) {
+	info, err := s.Client.StatObject(ctx, s.bucket, objectKey, minio.StatObjectOptions{VersionID: versionID})
+	if err != nil {
+		if code := minio.ToErrorResponse(err).Code; code == "NoSuchKey" || code == "NoSuchVersion" {
+			return UploadInfo{}, fmt.Errorf("%s version %s: %w", objectKey, versionID, ErrNotFound)
+		}
+		return UploadInfo{}, err
+	}
+	dst := minio.CopyDestOptions{Bucket: s.bucket, Object: objectKey, Encryption: s.sse}
+	src := minio.CopySrcOptions{Bucket: s.bucket, Object: objectKey, VersionID: versionID}
+	copied, err := s.Client.CopyObject(ctx, dst, src)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	var sha string
+	for k, v := range info.UserMetadata {
+		if strings.EqualFold(k, MetaSHA256) {
+			sha = v
+		}
+	}
+	return UploadInfo{Key: objectKey, Size: info.Size, ETag: copied.ETag, VersionID: copied.VersionID, SHA256: sha}, nil
+}
+
+// SetStorageClass copies objectKey onto itself in the storage class, keeping
+// its content type, metadata, tags and encryption; objects up to 5 GiB, the
+// limit of a single copy. In versioned buckets the version copied from is
+// deleted, so that no copy in the old class is left behind.
+func (s *S3Client) SetStorageClass(ctx context.Context, objectKey, class string) error {
+	info, err := s.Client.StatObject(ctx, s.bucket, objectKey, minio.StatObjectOptions{})
+	if err != nil {
+		return err
+	}
+	if info.StorageClass == class {
+		return nil
+	}
+	meta := make(map[string]string, len(info.UserMetadata)+1)
+	for k, v := range info.UserMetadata {
+		meta[k] = v
+	}
+	meta["X-Amz-Storage-Class"] = class
+	dst := minio.CopyDestOptions{
+		Bucket:             s.bucket,
+		Object:             objectKey,
+		Encryption:         s.sse,
+		ContentType:        info.ContentType,
+		ContentDisposition: info.Metadata.Get("Content-Disposition"),
+		UserMetadata:       meta,
+		ReplaceMetadata:    true,
+	}
+	src := minio.CopySrcOptions{Bucket: s.bucket, Object: objectKey, VersionID: info.VersionID}
+	copied, err := s.Client.CopyObject(ctx, dst, src)
+	if err != nil {
+		return err
+	}
+	if info.VersionID != "" && copied.VersionID != "" && copied.VersionID != info.VersionID {
+		return s.Client.RemoveObject(ctx, s.bucket, objectKey, minio.RemoveObjectOptions{VersionID: info.VersionID})
+	}
+	return nil
+}
+
+// PresignedPost returns a POST policy for exactly objectKey, with the content
+// type and size range of c and the encryption of the client
+func (s *S3Client) PresignedPost(ctx context.Context, objectKey string, c PostConditions) (PostPolicy, error) {
+	expires := time.Now().UTC().Add(c.Expires)
+	p := minio.NewPostPolicy()
+	if err := p.SetBucket(s.bucket); err != nil {
+		return PostPolicy{}, err
+	}
+	if err := p.SetKey(objectKey); err != nil {
+		return PostPolicy{}, err
+	}
+	if err := p.SetExpires(expires); err != nil {
+		return PostPolicy{}, err
+	}
+	if err := p.SetContentType(c.ContentType); err != nil {
+		return PostPolicy{}, err
+	}
+	if err := p.SetContentLengthRange(1, c.MaxBytes); err != nil {
+		return PostPolicy{}, err
+	}
+	if s.sse != nil {
+		p.SetEncryption(s.sse)
+	}
+	u, fields, err := s.Client.PresignedPostPolicy(ctx, p)
+	if err != nil {
+		return PostPolicy{}, err
+	}
+	return PostPolicy{URL: u.String(), Fields: fields, Expires: expires}, nil
+}
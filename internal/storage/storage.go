@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Storage is the object store holding job inputs and outputs
+type Storage interface {
+	// Bucket names where objects go (S3/GCS bucket, Azure container, local root),
+	// as recorded on jobs
+	Bucket() string
+	// UploadFile stores a local file under objectKey, reporting its progress to
+	// the func of ctx, see WithProgress. An empty contentType is detected from
+	// the file, see ContentTypeOf; downloads are offered under the last element
+	// of objectKey (Content-Disposition).
+	UploadFile(ctx context.Context, localPath, objectKey, contentType string) (UploadInfo, error)
+	// UploadStream stores everything read from r, of unknown length, under
+	// objectKey; nothing is stored when reading r fails. An empty contentType is
+	// taken from the extension of objectKey.
+	UploadStream(ctx context.Context, r io.Reader, objectKey, contentType string) (UploadInfo, error)
+	DownloadFile(ctx context.Context, objectKey, localPath string) error
+	// Delete removes objectKey for good, with every version of it where the
+	// backend keeps them; a missing object is not an error
+	Delete(ctx context.Context, objectKey string) error
+	// PresignedGetURL returns a time-limited download link for objectKey
+	PresignedGetURL(ctx context.Context, objectKey string) (string, error)
+	// StatObject describes objectKey; ErrNotFound when there is none
+	StatObject(ctx context.Context, objectKey string) (ObjectInfo, error)
+	// PresignedPost returns a form for a browser to upload objectKey straight
+	// to the bucket; ErrUnsupported by backends without POST policies
+	PresignedPost(ctx context.Context, objectKey string, c PostConditions) (PostPolicy, error)
+	// SetStorageClass moves objectKey to another storage class (Azure: access
+	// tier) in place; ErrUnsupported by backends without classes
+	SetStorageClass(ctx context.Context, objectKey, class string) error
+	// List calls fn for every object under prefix in the byte order of the keys,
+	// stopping at the first error of fn
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+	// ListVersions returns the versions of objectKey kept by a versioned bucket,
+	// newest first; ErrUnsupported by backends without versions
+	ListVersions(ctx context.Context, objectKey string) ([]ObjectVersion, error)
+	// PresignedGetVersionURL returns a time-limited download link for one
+	// version of objectKey
+	PresignedGetVersionURL(ctx context.Context, objectKey, versionID string) (string, error)
+	// RestoreVersion copies a version of objectKey over it, making its content
+	// the latest version again; the versions in between are kept
+	RestoreVersion(ctx context.Context, objectKey, versionID string) (UploadInfo, error)
+}
+
+// ObjectVersion is one version of an object in a versioned bucket
+type ObjectVersion struct {
+	VersionID    string    `json:"version_id"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"last_modified"`
+	IsLatest     bool      `json:"is_latest"`
+	DeleteMarker bool      `json:"delete_marker,omitempty"`
+}
+
+// RestoreRequired reports whether objects in the storage class can only be read
+// after a restore: S3 GLACIER and DEEP_ARCHIVE, the Azure Archive tier. GCS
+// classes, S3 GLACIER_IR and the Azure Cool and Cold tiers stay readable.
+func RestoreRequired(class string) bool {
+	switch class {
+	case "GLACIER", "DEEP_ARCHIVE", "Archive":
+		return true
+	}
+	return false
+}
+
+var (
+	ErrNotFound    = errors.New("object not found")                     // wrapped by StatObject
+	ErrUnsupported = errors.New("not supported by the storage backend") // wrapped by optional operations
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	VersionID    string
+	LastModified time.Time
+}
+
+// PostConditions restrict what a browser may upload with a PostPolicy
+type PostConditions struct {
+	ContentType string        // exact Content-Type of the upload
+	MaxBytes    int64         // largest accepted size; uploads must not be empty
+	Expires     time.Duration // how long the policy can be used
+}
+
+// PostPolicy is a signed form for one upload: POST the Fields and then the
+// file (as the last field, "file") as multipart/form-data to URL
+type PostPolicy struct {
+	URL     string            `json:"url"`
+	Fields  map[string]string `json:"fields"`
+	Expires time.Time         `json:"expires_at"`
+}
+
+// UploadInfo describes a stored object
+type UploadInfo struct {
+	Key       string
+	Size      int64
+	ETag      string
+	VersionID string // empty when the bucket is not versioned
+	SHA256    string // hex SHA-256 of the content sent
+	Endpoint  string // EndpointPrimary or EndpointSecondary with failover, else empty
+}
+
+// Backends selectable with STORAGE_BACKEND
+const (
+	BackendS3    = "s3"    // MinIO or AWS S3 (default)
+	BackendGCS   = "gcs"   // Google Cloud Storage through its S3 compatible XML API
+	BackendAzure = "azure" // Azure Blob Storage
+	BackendLocal = "local" // a directory, for single-host setups and development
+)
+
+// Config selects and configures the storage backend
+type Config struct {
+	Backend     string
+	S3          S3Config // s3 and gcs
+	Azure       AzureConfig
+	Local       LocalConfig
+	PresignSecs int       // lifetime of presigned URLs, all backends
+	CDN         CDNConfig // download links through a CDN instead
+}
+
+// ConfigFromEnv reads the storage configuration shared by the API and the
+// worker from the environment, see the README for the variables
+func ConfigFromEnv() Config {
+	presign := 60 * 60 * 24 * 7
+	if v, err := strconv.Atoi(os.Getenv("S3_PRESIGN_SECS")); err == nil {
+		presign = v
+	}
+	partMB, _ := strconv.Atoi(os.Getenv("S3_PART_SIZE_MB"))
+	concurrency, _ := strconv.Atoi(os.Getenv("S3_UPLOAD_CONCURRENCY"))
+	retries, _ := strconv.Atoi(os.Getenv("S3_PART_RETRIES"))
+	dlPartMB, _ := strconv.Atoi(os.Getenv("S3_DOWNLOAD_PART_MB"))
+	dlConcurrency, _ := strconv.Atoi(os.Getenv("S3_DOWNLOAD_CONCURRENCY"))
+	cfg := Config{
+		Backend:     env("STORAGE_BACKEND", BackendS3),
+		PresignSecs: presign,
+		S3: S3Config{
+			Endpoint:    env("S3_ENDPOINT", "http://localhost:9000"),
+			AccessKey:   env("S3_ACCESS_KEY", "miniouser"),
+			SecretKey:   env("S3_SECRET_KEY", "miniopass"),
+			Bucket:      env("S3_BUCKET", "call-audio-bucket"),
+			Region:      os.Getenv("S3_REGION"),
+			PartSize:    uint64(max(partMB, 0)) << 20,
+			Concurrency: uint(max(concurrency, 0)),
+			PartRetries: retries,
+			Retry:       retryPolicyFromEnv(),
+
+			DownloadPartSize:    int64(max(dlPartMB, 0)) << 20,
+			DownloadConcurrency: max(dlConcurrency, 0),
+			SSE:                 os.Getenv("S3_SSE"),
+			KMSKeyID:            os.Getenv("S3_SSE_KMS_KEY_ID"),
+			Failover:            failoverConfigFromEnv(),
+		},
+		Azure: AzureConfig{
+			Account:   os.Getenv("AZURE_STORAGE_ACCOUNT"),
+			Key:       os.Getenv("AZURE_STORAGE_KEY"),
+			Container: env("AZURE_CONTAINER", "call-audio"),
+			Endpoint:  os.Getenv("AZURE_BLOB_ENDPOINT"),
+		},
+		Local: LocalConfig{
+			Root:    env("LOCAL_STORAGE_DIR", "storage/objects"),
+			BaseURL: os.Getenv("LOCAL_STORAGE_BASE_URL"),
+		},
+		CDN: CDNConfig{
+			Domain:         os.Getenv("CDN_DOMAIN"),
+			KeyPairID:      os.Getenv("CDN_KEY_PAIR_ID"),
+			PrivateKeyPath: os.Getenv("CDN_PRIVATE_KEY_PATH"),
+		},
+	}
+	if cfg.Backend == BackendGCS {
+		cfg.S3.Endpoint = env("GCS_ENDPOINT", "https://storage.googleapis.com")
+		cfg.S3.AccessKey = os.Getenv("GCS_HMAC_ACCESS_ID")
+		cfg.S3.SecretKey = os.Getenv("GCS_HMAC_SECRET")
+		cfg.S3.Bucket = env("GCS_BUCKET", cfg.S3.Bucket)
+	}
+	return cfg
+}
+
+// New connects the configured backend, handing out CDN links when cfg.CDN has a domain
+func New(cfg Config) (Storage, error) {
+	s, err := newBackend(cfg)
+	if err != nil || cfg.CDN.Domain == "" {
+		return s, err
+	}
+	return newCDNStorage(s, cfg.CDN, time.Duration(cfg.PresignSecs)*time.Second)
+}
+
+func newBackend(cfg Config) (Storage, error) {
+	expiry := time.Duration(cfg.PresignSecs) * time.Second
+	switch cfg.Backend {
+	case "", BackendS3, BackendGCS:
+		if cfg.Backend == BackendGCS && cfg.S3.SSE != "" {
+			return nil, errors.New("S3 server-side encryption headers are not supported by gcs, which encrypts at rest anyway; set a default KMS key on the bucket instead")
+		}
+		s3cfg := cfg.S3
+		s3cfg.PresignSecs = cfg.PresignSecs
+		s3cfg.NoTagging = s3cfg.NoTagging || cfg.Backend == BackendGCS
+		c, err := NewS3Client(s3cfg)
+		if err != nil {
+			return nil, err
+		}
+		primary := withRetry(c, s3cfg.Retry)
+		fo := s3cfg.Failover
+		if fo.Endpoint == "" {
+			return primary, nil
+		}
+		sec := s3cfg
+		sec.Endpoint = fo.Endpoint
+		if fo.AccessKey != "" {
+			sec.AccessKey, sec.SecretKey = fo.AccessKey, fo.SecretKey
+		}
+		if fo.Bucket != "" {
+			sec.Bucket = fo.Bucket
+		}
+		c2, err := NewS3Client(sec)
+		if err != nil {
+			return nil, fmt.Errorf("secondary endpoint: %w", err)
+		}
+		return newFailoverStorage(primary, withRetry(c2, s3cfg.Retry), fo), nil
+	case BackendAzure:
+		return NewAzureClient(cfg.Azure, expiry)
+	case BackendLocal:
+		return NewLocalStorage(cfg.Local)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q (want %s, %s, %s or %s)", cfg.Backend, BackendS3, BackendGCS, BackendAzure, BackendLocal)
+}
+
+func env(k, d string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return d
+}
+
+// OriginalKey is the object key the unprocessed input of a job is stored
+// under; the extension of the uploaded file is kept so ffmpeg and the bundle
+// unpacker can tell the format
+func OriginalKey(jobID, filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if strings.HasSuffix(strings.ToLower(filename), ".tar.gz") {
+		ext = ".tar.gz"
+	}
+	return "originals/" + jobID + ext
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// TenantConfig places the objects of one tenant: in a bucket of its own,
+// optionally with its own credentials, and/or under an enforced key prefix
+type TenantConfig struct {
+	Bucket       string `yaml:"bucket"`         // S3/GCS bucket, Azure container or local directory; empty shares the default one
+	Prefix       string `yaml:"prefix"`         // put before every key, e.g. "acme/"
+	AccessKeyEnv string `yaml:"access_key_env"` // environment variables holding S3/GCS credentials for Bucket;
+	SecretKeyEnv string `yaml:"secret_key_env"` // empty uses the default credentials
+}
+
+// Placement is where the objects of a tenant go. Keys passed to its Storage
+// are used as they are; new keys must go through Key to get the prefix.
+type Placement struct {
+	Storage
+	Prefix string
+}
+
+// KeyDirs are the directories the service stores objects under, after the
+// prefix of a placement
+var KeyDirs = []string{"originals/", "processed/", "previews/", "spectrograms/", "transcripts/", "archive/", "uploads/"}
+
+// Key returns the object key of key for the tenant
+func (p Placement) Key(key string) string {
+	return p.Prefix + key
+}
+
+// Router hands out the storage of a tenant for new objects and the storage
+// serving a bucket for objects recorded on jobs
+type Router struct {
+	def     Storage
+	tenants map[string]Placement
+}
+
+// NewRouter connects the default backend of cfg and one more client for every
+// tenant with a bucket of its own. Tenants share the backend and endpoint of cfg.
+func NewRouter(cfg Config, tenants map[string]TenantConfig) (*Router, error) {
+	def, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r := &Router{def: def, tenants: make(map[string]Placement, len(tenants))}
+	// sorted so that of tenants sharing a bucket the same client always serves it
+	names := make([]string, 0, len(tenants))
+	for name := range tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p, err := r.placement(cfg, tenants[name])
+		if err != nil {
+			return nil, fmt.Errorf("storage of tenant %s: %w", name, err)
+		}
+		r.tenants[name] = p
+	}
+	return r, nil
+}
+
+func (r *Router) placement(cfg Config, tc TenantConfig) (Placement, error) {
+	prefix := strings.Trim(tc.Prefix, "/")
+	if prefix != "" {
+		if path.Clean(prefix) != prefix || strings.HasPrefix(prefix, "..") {
+			return Placement{}, fmt.Errorf("invalid prefix %q", tc.Prefix)
+		}
+		prefix += "/"
+	}
+	if (tc.AccessKeyEnv == "") != (tc.SecretKeyEnv == "") {
+		return Placement{}, fmt.Errorf("access_key_env and secret_key_env go together")
+	}
+	if tc.AccessKeyEnv != "" && cfg.Backend != "" && cfg.Backend != BackendS3 && cfg.Backend != BackendGCS {
+		return Placement{}, fmt.Errorf("own credentials need the %s or %s backend", BackendS3, BackendGCS)
+	}
+	// another client for the default bucket only makes sense with other credentials
+	if (tc.Bucket == "" || tc.Bucket == r.def.Bucket()) && tc.AccessKeyEnv == "" {
+		return Placement{Storage: r.def, Prefix: prefix}, nil
+	}
+
+	if tc.Bucket != "" {
+		cfg.S3.Bucket, cfg.Azure.Container, cfg.Local.Root = tc.Bucket, tc.Bucket, tc.Bucket
+		cfg.S3.Failover.Bucket = "" // same name on the secondary endpoint
+		cfg.CDN = CDNConfig{}       // the CDN serves the default bucket
+	}
+	if tc.AccessKeyEnv != "" {
+		cfg.S3.AccessKey, cfg.S3.SecretKey = os.Getenv(tc.AccessKeyEnv), os.Getenv(tc.SecretKeyEnv)
+		if cfg.S3.AccessKey == "" || cfg.S3.SecretKey == "" {
+			return Placement{}, fmt.Errorf("%s or %s is not set", tc.AccessKeyEnv, tc.SecretKeyEnv)
+		}
+	}
+	s, err := New(cfg)
+	if err != nil {
+		return Placement{}, err
+	}
+	return Placement{Storage: s, Prefix: prefix}, nil
+}
+
+// For returns the placement of new objects of tenant; tenants without a
+// configuration get the default storage without prefix
+func (r *Router) For(tenant string) Placement {
+	if p, ok := r.tenants[tenant]; ok {
+		return p
+	}
+	return Placement{Storage: r.def}
+}
+
+// Placements returns the default placement followed by those of the tenants
+// by name, once per storage and prefix
+func (r *Router) Placements() []Placement {
+	out := []Placement{{Storage: r.def}}
+	names := make([]string, 0, len(r.tenants))
+	for name := range r.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := r.tenants[name]
+		dup := false
+		for _, q := range out {
+			if q.Bucket() == p.Bucket() && q.Prefix == p.Prefix {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// ForBucket returns the storage serving bucket, as recorded with an object on a
+// job; empty is the default bucket. The default storage serves its bucket,
+// of several tenants with the same bucket the first by name.
+func (r *Router) ForBucket(bucket string) (Storage, error) {
+	if bucket == "" || bucket == r.def.Bucket() {
+		return r.def, nil
+	}
+	names := make([]string, 0, len(r.tenants))
+	for name := range r.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if s := r.tenants[name].Storage; s.Bucket() == bucket {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("bucket %s is not served by this instance", bucket)
+}
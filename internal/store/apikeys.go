@@ -0,0 +1,185 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Scopes of API keys
+const (
+	ScopeRead  = "jobs:read"  // job status, reports, events, downloads
+	ScopeWrite = "jobs:write" // submit, upload and cancel jobs
+	ScopeAdmin = "admin"      // workers, purges, erasure and API keys
+)
+
+// Scopes lists every scope
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// ErrInvalidKey is returned by AuthenticateAPIKey for unknown, revoked and
+// expired keys
+var ErrInvalidKey = errors.New("invalid API key")
+
+// keyPrefix starts every API key, so leaked keys are easy to search for
+const keyPrefix = "bk_"
+
+// APIKey describes an API key; the secret is only returned when it is created
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	TenantID   *string    `json:"tenant_id,omitempty"` // nil for operator keys
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// HasScope reports whether the key grants scope; admin grants every scope
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope) || slices.Contains(k.Scopes, ScopeAdmin)
+}
+
+// ParseScopes validates a comma separated list of scopes
+func ParseScopes(s string) ([]string, error) {
+	var out []string
+	for _, sc := range strings.Split(s, ",") {
+		sc = strings.TrimSpace(sc)
+		if sc == "" {
+			continue
+		}
+		if !slices.Contains(Scopes, sc) {
+			return nil, fmt.Errorf("unknown scope %q (want %s)", sc, strings.Join(Scopes, ", "))
+		}
+		if !slices.Contains(out, sc) {
+			out = append(out, sc)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no scopes")
+	}
+	return out, nil
+}
+
+// hashKey returns the hex SHA-256 of an API key. Keys carry 256 random bits,
+// a slow password hash would add nothing.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// splitKey returns the prefix of a key: bk_<prefix>_<secret>
+func splitKey(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, keyPrefix)
+	if !ok {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(rest, "_")
+	return prefix, ok && prefix != "" && secret != ""
+}
+
+// CreateAPIKey creates a key for tenant (empty for an operator key; a scoped
+// store creates keys of its own tenant only) and returns it with the key
+// itself, which is not stored and can't be shown again
+func (s *DB) CreateAPIKey(ctx context.Context, tenant, name string, scopes []string, expiresAt *time.Time) (*APIKey, string, error) {
+	if s.tenant != "" {
+		tenant = s.tenant
+	}
+	b := make([]byte, 38)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	prefix := hex.EncodeToString(b[:6])
+	key := keyPrefix + prefix + "_" + base64.RawURLEncoding.EncodeToString(b[6:])
+
+	k := APIKey{ID: uuid.New()}
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO api_keys (id, tenant_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7)
+		RETURNING tenant_id, name, prefix, scopes, created_at, expires_at, last_used_at, revoked_at
+	`, k.ID, tenant, name, prefix, hashKey(key), scopes, expiresAt).Scan(
+		&k.TenantID, &k.Name, &k.Prefix, &k.Scopes, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt)
+	if err != nil {
+		return nil, "", err
+	}
+	return &k, key, nil
+}
+
+// ListAPIKeys returns the keys of the scoped tenant, or every key when
+// unscoped, newest first; revoked keys are listed too
+func (s *DB) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, tenant_id, name, prefix, scopes, created_at, expires_at, last_used_at, revoked_at
+		FROM api_keys WHERE ($1 = '' OR tenant_id = $1)
+		ORDER BY created_at DESC
+	`, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []APIKey{}
+	for rows.Next() {
+		var k APIKey
+		if err := rows.Scan(&k.ID, &k.TenantID, &k.Name, &k.Prefix, &k.Scopes, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+// RevokeAPIKey revokes a key of the scoped tenant; it returns false when there
+// is no such key or it was revoked already
+func (s *DB) RevokeAPIKey(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE api_keys SET revoked_at=now() WHERE id=$1 AND revoked_at IS NULL AND ($2 = '' OR tenant_id = $2)
+	`, id, s.tenant)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// AuthenticateAPIKey returns the key matching key, unless it is revoked or
+// expired, and records its use; last_used_at is updated at most once a minute
+// to spare the table a write per request
+func (s *DB) AuthenticateAPIKey(ctx context.Context, key string) (*APIKey, error) {
+	prefix, ok := splitKey(key)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	var k APIKey
+	var hash string
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, tenant_id, name, prefix, key_hash, scopes, created_at, expires_at, last_used_at, revoked_at
+		FROM api_keys WHERE prefix=$1
+	`, prefix).Scan(&k.ID, &k.TenantID, &k.Name, &k.Prefix, &hash, &k.Scopes, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashKey(key))) != 1 ||
+		k.RevokedAt != nil || (k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)) {
+		return nil, ErrInvalidKey
+	}
+	if k.LastUsedAt == nil || time.Since(*k.LastUsedAt) > time.Minute {
+		if _, err := s.pool.Exec(ctx, `UPDATE api_keys SET last_used_at=now() WHERE id=$1`, k.ID); err != nil {
+			return nil, err
+		}
+	}
+	return &k, nil
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Batch kinds
+const (
+	BatchSubmission = "submission" // jobs submitted one by one with batch_id
+	BatchBundle     = "bundle"     // children of an unpacked archive, the batch id is the bundle job's
+	BatchComparison = "comparison" // the same recording processed with different options
+)
+
+// BatchKinds lists the kinds of batches clients create; bundle batches are
+// created by the workers
+var BatchKinds = []string{BatchSubmission, BatchComparison}
+
+// Batch groups related jobs
+type Batch struct {
+	ID        uuid.UUID  `json:"id"`
+	Kind      string     `json:"kind"`
+	Name      *string    `json:"name,omitempty"`
+	Tenant    *string    `json:"tenant,omitempty"`
+	OwnerID   *uuid.UUID `json:"owner_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NewBatch describes a batch to insert with CreateBatch
+type NewBatch struct {
+	ID      uuid.UUID // random when nil
+	Kind    string
+	Name    string
+	Tenant  string
+	OwnerID *uuid.UUID
+}
+
+// CreateBatch inserts a batch, with the tenant of a scoped store unless nb has
+// one. Creating a batch with the ID of an existing one returns that one, so
+// a bundle unpacked again keeps its batch.
+func (s *DB) CreateBatch(ctx context.Context, nb NewBatch) (*Batch, error) {
+	if nb.ID == uuid.Nil {
+		nb.ID = uuid.New()
+	}
+	if nb.Tenant == "" {
+		nb.Tenant = s.tenant
+	}
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO batches (id, kind, name, tenant, owner_id) VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+		ON CONFLICT (id) DO NOTHING
+	`, nb.ID, nb.Kind, nb.Name, nb.Tenant, nb.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetBatch(ctx, nb.ID)
+}
+
+// GetBatch returns a batch of the scoped tenant
+func (s *DB) GetBatch(ctx context.Context, id uuid.UUID) (*Batch, error) {
+	var b Batch
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, kind, name, tenant, owner_id, created_at FROM batches WHERE id=$1 AND ($2 = '' OR tenant = $2)
+	`, id, s.tenant).Scan(&b.ID, &b.Kind, &b.Name, &b.Tenant, &b.OwnerID, &b.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// BatchStatusCounts returns the number of jobs of a batch per status
+func (s *DB) BatchStatusCounts(ctx context.Context, id uuid.UUID) (map[string]int, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT status, count(*) FROM audio_jobs WHERE batch_id=$1 AND ($2 = '' OR tenant = $2) GROUP BY status
+	`, id, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+// AggregateStatus sums up the job counts per status of a batch or bundle:
+// processing while any job is pending, then completed_with_errors when any
+// failed or was cancelled, completed_with_warnings, or done. A batch without
+// jobs is empty.
+func AggregateStatus(counts map[string]int) string {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	pending := counts["scheduled"] + counts["queued"] + counts["processing"]
+	switch {
+	case total == 0:
+		return "empty"
+	case pending > 0:
+		return "processing"
+	case counts["failed"]+counts["cancelled"] > 0:
+		return "completed_with_errors"
+	case counts["completed_with_warnings"] > 0:
+		return "completed_with_warnings"
+	default:
+		return "done"
+	}
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EraseQuery selects the jobs of an erase request: all jobs with CallerRef, or
+// the job JobID, with the children of bundles among them. A non-empty Tenant,
+// or the tenant of a scoped store, restricts the request to the jobs of that
+// tenant.
+type EraseQuery struct {
+	CallerRef string
+	JobID     uuid.UUID
+	Tenant    string
+}
+
+// ErasableJob is a job matched by an erase request
+type ErasableJob struct {
+	ID       uuid.UUID `json:"job_id"`
+	Status   string    `json:"status"`
+	Tenant   string    `json:"tenant,omitempty"`
+	Erased   bool      `json:"erased"` // by an earlier request
+	Finished bool      `json:"-"`
+}
+
+// FindErasable returns the jobs of an erase request
+func (s *DB) FindErasable(ctx context.Context, q EraseQuery) ([]ErasableJob, error) {
+	if q.CallerRef == "" && q.JobID == uuid.Nil {
+		return nil, errors.New("erase query needs a caller_ref or a job id")
+	}
+	if s.tenant != "" {
+		q.Tenant = s.tenant
+	}
+	rows, err := s.pool.Query(ctx, `
+		WITH matched AS (
+			SELECT id FROM audio_jobs
+			WHERE (($1 <> '' AND caller_ref = $1) OR id = $2)
+			  AND ($3 = '' OR tenant = $3)
+		)
+		SELECT id, status, COALESCE(tenant, ''), erased_at IS NOT NULL,
+		       status IN ('done', 'completed_with_warnings', 'failed', 'cancelled', 'expanded')
+		FROM audio_jobs
+		WHERE id IN (SELECT id FROM matched) OR parent_id IN (SELECT id FROM matched)
+		ORDER BY created_at
+	`, q.CallerRef, q.JobID, q.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ErasableJob
+	for rows.Next() {
+		var j ErasableJob
+		if err := rows.Scan(&j.ID, &j.Status, &j.Tenant, &j.Erased, &j.Finished); err != nil {
+			return nil, err
+		}
+		out = append(out, j)
+	}
+	return out, rows.Err()
+}
+
+// EraseJob scrubs a job whose objects were deleted, in one transaction: the
+// file names, the job message, analysis results, probe metadata, the
+// fingerprint, the output records and the details of the job events go, the
+// job is marked erased and purged, and the deleted objects are recorded in the
+// erase audit. Loudness, duration and the other numbers without personal data
+// stay for the statistics.
+func (s *DB) EraseJob(ctx context.Context, eraseID uuid.UUID, callerRef string, j ErasableJob, objects []StoredObject) error {
+	if objects == nil {
+		objects = []StoredObject{}
+	}
+	objs, err := json.Marshal(objects)
+	if err != nil {
+		return err
+	}
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE audio_jobs SET
+			input_path='', output_path='', payload=NULL, error_msg=NULL, last_error=NULL, analysis_json=NULL, media_info=NULL,
+			content_hash=NULL, caller_ref=NULL, labels=NULL, language=NULL, language_confidence=NULL, keyword_hits=NULL,
+			s3_key=NULL, s3_version_id=NULL, output_sha256=NULL, original_key=NULL, original_sha256=NULL,
+			erased_at=now(), purged_at=COALESCE(purged_at, now())
+		WHERE id=$1
+	`, j.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM job_outputs WHERE job_id=$1`, j.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM job_fingerprints WHERE job_id=$1`, j.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM transcripts WHERE job_id=$1`, j.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE job_events SET detail=NULL WHERE job_id=$1`, j.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO erase_audit (erase_id, caller_ref, job_id, tenant, objects) VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), $5)
+	`, eraseID, callerRef, j.ID, j.Tenant, objs); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// EraseRecord is a job scrubbed by an erase request
+type EraseRecord struct {
+	EraseID   uuid.UUID      `json:"erase_id"`
+	CallerRef *string        `json:"caller_ref,omitempty"`
+	JobID     uuid.UUID      `json:"job_id"`
+	Tenant    *string        `json:"tenant,omitempty"`
+	Objects   []StoredObject `json:"objects"`
+	ErasedAt  time.Time      `json:"erased_at"`
+}
+
+// ListErasures returns the erase audit of one request
+func (s *DB) ListErasures(ctx context.Context, eraseID uuid.UUID) ([]EraseRecord, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT erase_id, caller_ref, job_id, tenant, objects, erased_at
+		FROM erase_audit WHERE erase_id=$1 AND ($2 = '' OR tenant = $2) ORDER BY id
+	`, eraseID, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []EraseRecord
+	for rows.Next() {
+		var r EraseRecord
+		var objs []byte
+		if err := rows.Scan(&r.EraseID, &r.CallerRef, &r.JobID, &r.Tenant, &objs, &r.ErasedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(objs, &r.Objects); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
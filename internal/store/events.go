@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Job events, the transitions recorded in job_events
+const (
+	EventQueued      = "queued"
+	EventScheduled   = "scheduled"
+	EventClaimed     = "claimed"
+	EventDenoiseDone = "denoise_done"
+	EventUploaded    = "uploaded"
+	EventFinished    = "finished"
+	EventFailed      = "failed"
+	EventRetried     = "retried" // back to queued after its worker died
+	EventCancelled   = "cancelled"
+)
+
+// JobEvent is one transition of a job
+type JobEvent struct {
+	Event     string    `json:"event"`
+	Detail    *string   `json:"detail,omitempty"`
+	WorkerID  *string   `json:"worker_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddJobEvent records an event of a job; the store records the status changes
+// it makes itself, workers add the steps in between
+func (s *DB) AddJobEvent(ctx context.Context, id uuid.UUID, event, detail, workerID string) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO job_events (job_id, event, detail, worker_id) VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''))
+	`, id, event, detail, workerID)
+	return err
+}
+
+// ListJobEvents returns the events of a job, oldest first
+func (s *DB) ListJobEvents(ctx context.Context, id uuid.UUID) ([]JobEvent, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT event, detail, worker_id, created_at FROM job_events
+		WHERE job_id=$1 AND ($2 = '' OR EXISTS (SELECT 1 FROM audio_jobs WHERE id=$1 AND tenant=$2))
+		ORDER BY id
+	`, id, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []JobEvent{}
+	for rows.Next() {
+		var e JobEvent
+		if err := rows.Scan(&e.Event, &e.Detail, &e.WorkerID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// ErrConflict is matched by the TransitionError of a job that is not in a
+// status it may move from, e.g. a cancelled job a worker finishes
+var ErrConflict = errors.New("job status conflict")
+
+// legalFrom lists the statuses a job may move to a status from, for the
+// transitions guarded by transition; ClaimJob, RetryJob, CancelJob and the
+// janitor and scheduler queries guard theirs in their own statements
+var legalFrom = map[string][]string{
+	"processing":              {"queued"},
+	"done":                    {"processing"},
+	"completed_with_warnings": {"processing"},
+	"expanded":                {"processing"},
+	"failed":                  {"scheduled", "queued", "processing"}, // the API fails jobs whose input upload failed
+}
+
+// CanTransition tells whether a job in status from may move to status to by
+// one of the guarded updates
+func CanTransition(from, to string) bool {
+	return slices.Contains(legalFrom[to], from)
+}
+
+// TransitionError is returned for a job that is not in a status it may move
+// to To from
+type TransitionError struct {
+	ID   uuid.UUID
+	From string // status of the job
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("job %s is %s, can't move to %s", e.ID, e.From, e.To)
+}
+
+// Is makes errors.Is(err, ErrConflict) true
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// transition moves a job to status to, updating it with set, which may use the
+// detail as $2, and records event for it in the same statement. A job not in
+// one of the legalFrom statuses is left alone with a TransitionError;
+// pgx.ErrNoRows when there is no such job.
+func (s *DB) transition(ctx context.Context, id uuid.UUID, to, event, detail, set string) error {
+	tag, err := s.pool.Exec(ctx, `
+		WITH job AS (
+			UPDATE audio_jobs SET status=$4, `+set+` WHERE id=$1 AND status = ANY($5)
+			RETURNING id, worker_id
+		)
+		INSERT INTO job_events (job_id, event, detail, worker_id) SELECT id, $3, NULLIF($2, ''), worker_id FROM job
+	`, id, detail, event, to, legalFrom[to])
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return s.conflict(ctx, id, to)
+	}
+	return nil
+}
+
+// conflict returns the error of a guarded update of a job to status to that
+// changed nothing: a TransitionError, or pgx.ErrNoRows when there is no such job
+func (s *DB) conflict(ctx context.Context, id uuid.UUID, to string) error {
+	var from string
+	err := s.pool.QueryRow(ctx, `SELECT status FROM audio_jobs WHERE id=$1`, id).Scan(&from)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return pgx.ErrNoRows
+	}
+	if err != nil {
+		return err
+	}
+	return &TransitionError{ID: id, From: from, To: to}
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCanTransition(t *testing.T) {
+	statuses := []string{"scheduled", "queued", "processing", "done", "completed_with_warnings", "expanded", "failed", "cancelled"}
+	legal := map[[2]string]bool{
+		{"queued", "processing"}:                  true,
+		{"processing", "done"}:                    true,
+		{"processing", "completed_with_warnings"}: true,
+		{"processing", "expanded"}:                true,
+		{"scheduled", "failed"}:                   true,
+		{"queued", "failed"}:                      true,
+		{"processing", "failed"}:                  true,
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			if got, want := CanTransition(from, to), legal[[2]string{from, to}]; got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestTransitionError(t *testing.T) {
+	id := uuid.New()
+	err := fmt.Errorf("finish: %w", &TransitionError{ID: id, From: "cancelled", To: "done"})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("%v doesn't match ErrConflict", err)
+	}
+	var te *TransitionError
+	if !errors.As(err, &te) || te.From != "cancelled" || te.To != "done" {
+		t.Errorf("%v: transition error %+v", err, te)
+	}
+	if want := "finish: job " + id.String() + " is cancelled, can't move to done"; err.Error() != want {
+		t.Errorf("message %q, want %q", err, want)
+	}
+	if errors.Is(errors.New("other"), ErrConflict) {
+		t.Error("any error matches ErrConflict")
+	}
+}
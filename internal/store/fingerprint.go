@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/google/uuid"
+)
+
+// Fingerprint is the stored acoustic fingerprint of a job input
+type Fingerprint struct {
+	JobID       uuid.UUID
+	DurationSec float64
+	Print       []uint32
+}
+
+// SaveFingerprint stores the acoustic fingerprint of a job input
+func (s *DB) SaveFingerprint(ctx context.Context, id uuid.UUID, durationSec float64, fp []uint32) error {
+	b := make([]byte, 4*len(fp))
+	for i, v := range fp {
+		binary.LittleEndian.PutUint32(b[4*i:], v)
+	}
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO job_fingerprints (job_id, duration_sec, fingerprint) VALUES ($1, $2, $3)
+		ON CONFLICT (job_id) DO UPDATE SET duration_sec=$2, fingerprint=$3
+	`, id, durationSec, b)
+	return err
+}
+
+// FingerprintCandidates returns the fingerprints of the latest other jobs whose
+// input duration is within tolerance of durationSec, newest first
+func (s *DB) FingerprintCandidates(ctx context.Context, id uuid.UUID, durationSec, tolerance float64, limit int) ([]Fingerprint, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT job_id, duration_sec, fingerprint
+		FROM job_fingerprints
+		WHERE job_id <> $1 AND duration_sec BETWEEN $2 AND $3
+		ORDER BY created_at DESC
+		LIMIT $4
+	`, id, durationSec-tolerance, durationSec+tolerance, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Fingerprint
+	for rows.Next() {
+		var f Fingerprint
+		var b []byte
+		if err := rows.Scan(&f.JobID, &f.DurationSec, &b); err != nil {
+			return nil, err
+		}
+		f.Print = make([]uint32, len(b)/4)
+		for i := range f.Print {
+			f.Print[i] = binary.LittleEndian.Uint32(b[4*i:])
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// SetDuplicateOf flags a job as a duplicate ingestion of the recording of another job
+func (s *DB) SetDuplicateOf(ctx context.Context, id, of uuid.UUID) error {
+	_, err := s.pool.Exec(ctx, `UPDATE audio_jobs SET duplicate_of=$2 WHERE id=$1`, id, of)
+	return err
+}
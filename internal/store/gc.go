@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ObjectRef is an object key recorded on a job
+type ObjectRef struct {
+	Key   string
+	JobID uuid.UUID
+}
+
+// ObjectRefs streams the object keys recorded on jobs whose objects were not
+// purged or erased
+type ObjectRefs struct {
+	rows pgx.Rows
+	refs []ObjectRef // of ObjectRefsOf, when rows is nil
+	err  error
+}
+
+// ObjectRefsOf returns a cursor over refs, for stores that keep them in memory;
+// refs must be ordered like DB.ObjectRefs orders them
+func ObjectRefsOf(refs []ObjectRef) *ObjectRefs {
+	return &ObjectRefs{refs: refs}
+}
+
+// ObjectRefs returns the keys under prefix that jobs recorded in one of buckets,
+// ordered by their bytes like object listings; objects written to a secondary
+// endpoint are left out, listings cover the primary one. The cursor holds a connection
+// until it is exhausted or closed.
+func (s *DB) ObjectRefs(ctx context.Context, buckets []string, prefix string) (*ObjectRefs, error) {
+	rows, err := s.pool.Query(ctx, `
+		WITH refs AS (
+			SELECT id AS job_id, s3_bucket AS bucket, s3_key AS key FROM audio_jobs
+			WHERE purged_at IS NULL AND s3_key IS NOT NULL AND s3_endpoint IS DISTINCT FROM 'secondary'
+			UNION ALL
+			SELECT id, original_bucket, original_key FROM audio_jobs
+			WHERE purged_at IS NULL AND original_key IS NOT NULL AND original_endpoint IS DISTINCT FROM 'secondary'
+			UNION ALL
+			SELECT o.job_id, o.s3_bucket, o.s3_key FROM job_outputs o JOIN audio_jobs j ON j.id = o.job_id
+			WHERE j.purged_at IS NULL AND o.endpoint IS DISTINCT FROM 'secondary'
+		)
+		SELECT key, job_id FROM refs
+		WHERE COALESCE(bucket, '') = ANY($1) AND starts_with(key, $2)
+		ORDER BY key COLLATE "C"
+	`, buckets, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectRefs{rows: rows}, nil
+}
+
+// Next returns the next key; false at the end or on an error, see Err
+func (c *ObjectRefs) Next() (ObjectRef, bool) {
+	if c.rows == nil {
+		if len(c.refs) == 0 {
+			return ObjectRef{}, false
+		}
+		r := c.refs[0]
+		c.refs = c.refs[1:]
+		return r, true
+	}
+	if c.err != nil || !c.rows.Next() {
+		return ObjectRef{}, false
+	}
+	var r ObjectRef
+	if c.err = c.rows.Scan(&r.Key, &r.JobID); c.err != nil {
+		c.rows.Close()
+		return ObjectRef{}, false
+	}
+	return r, true
+}
+
+// Err returns the error that ended the cursor
+func (c *ObjectRefs) Err() error {
+	if c.err != nil || c.rows == nil {
+		return c.err
+	}
+	return c.rows.Err()
+}
+
+// Close releases the connection of the cursor
+func (c *ObjectRefs) Close() {
+	if c.rows == nil {
+		c.refs = nil
+		return
+	}
+	c.rows.Close()
+}
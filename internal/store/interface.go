@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/audio"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/retention"
+)
+
+// Store keeps the jobs and everything recorded about them. DB keeps them in
+// Postgres; the API and the workers take a Store so that they can run against
+// storetest.Fake in tests, without a database.
+type Store interface {
+	// ForTenant returns a store seeing the jobs of tenant only, see DB.ForTenant
+	ForTenant(tenant string) Store
+	Tenant() string
+
+	// jobs
+	CreateJob(ctx context.Context, nj NewJob) (uuid.UUID, error)
+	GetJob(ctx context.Context, id uuid.UUID) (*Job, error)
+	ListJobs(ctx context.Context, f JobFilter) ([]JobSummary, error)
+	FindDoneByHash(ctx context.Context, contentHash, optionsHash string) (*Job, error)
+	ChildStatusCounts(ctx context.Context, parentID uuid.UUID) (map[string]int, error)
+	ChildJobs(ctx context.Context, parentID uuid.UUID) ([]ChildJob, error)
+	ListDeadAirJobs(ctx context.Context, minPct float64, labels map[string]string, limit int) ([]DeadAirJob, error)
+	JobStatsBetween(ctx context.Context, since, until time.Time) (*Stats, error)
+
+	// job lifecycle
+	SetPayload(ctx context.Context, id uuid.UUID, payload []byte) error
+	SetStarted(ctx context.Context, id uuid.UUID) error
+	ClaimJob(ctx context.Context, id uuid.UUID, workerID string) (bool, error)
+	ClaimNextJob(ctx context.Context, workerID string, methods, exclude []string) (*ClaimedJob, error)
+	Heartbeat(ctx context.Context, id uuid.UUID, workerID string) error
+	UpdateProgress(ctx context.Context, id uuid.UUID, progress int) error
+	SetFinished(ctx context.Context, id uuid.UUID) error
+	SetFinishedWithWarnings(ctx context.Context, id uuid.UUID, msg string) error
+	SetRejected(ctx context.Context, id uuid.UUID, code, msg string) error
+	SetFailed(ctx context.Context, id uuid.UUID, msg string) error
+	RetryJob(ctx context.Context, id uuid.UUID, msg string, backoff time.Duration) (bool, error)
+	SetExpanded(ctx context.Context, id uuid.UUID) error
+	CancelJob(ctx context.Context, id uuid.UUID) (bool, error)
+	RequeueOrphaned(ctx context.Context, staleAfter time.Duration) ([]RequeuedJob, error)
+	ReleaseDueJobs(ctx context.Context, limit int) ([]RequeuedJob, error)
+	AddJobEvent(ctx context.Context, id uuid.UUID, event, detail, workerID string) error
+	ListJobEvents(ctx context.Context, id uuid.UUID) ([]JobEvent, error)
+
+	// results
+	UpdateJobStorage(ctx context.Context, id uuid.UUID, bucket, key, versionID, sha256, endpoint string) error
+	SetOriginal(ctx context.Context, id uuid.UUID, bucket, key, sha256, endpoint string) error
+	AddJobOutput(ctx context.Context, o JobOutput) error
+	ListJobOutputs(ctx context.Context, jobID uuid.UUID) ([]JobOutput, error)
+	UpdateJobMetadata(ctx context.Context, id uuid.UUID, duration float64, loudness *audio.Loudness, noiseLevel float64, denoiseMethod string) error
+	MergeJobAnalysis(ctx context.Context, id uuid.UUID, analysis map[string]interface{}) error
+	SetQuality(ctx context.Context, id uuid.UUID, q Quality, loudnessBefore *audio.Loudness) error
+	SetJobMOS(ctx context.Context, id uuid.UUID, mos float64) error
+	SetJobLanguage(ctx context.Context, id uuid.UUID, language string, confidence float64) error
+	SetAnswerClass(ctx context.Context, id uuid.UUID, class string) error
+	SetEchoScore(ctx context.Context, id uuid.UUID, score float64) error
+	SetMediaInfo(ctx context.Context, id uuid.UUID, info interface{}) error
+	SetKeywordHits(ctx context.Context, id uuid.UUID, hits int) error
+	SetTalkTime(ctx context.Context, id uuid.UUID, t TalkTime) error
+	SaveTranscript(ctx context.Context, jobID uuid.UUID, tr *audio.Transcript) error
+	GetTranscript(ctx context.Context, jobID uuid.UUID) (*StoredTranscript, error)
+	SaveFingerprint(ctx context.Context, id uuid.UUID, durationSec float64, fp []uint32) error
+	FingerprintCandidates(ctx context.Context, id uuid.UUID, durationSec, tolerance float64, limit int) ([]Fingerprint, error)
+	SetDuplicateOf(ctx context.Context, id, of uuid.UUID) error
+
+	// batches
+	CreateBatch(ctx context.Context, nb NewBatch) (*Batch, error)
+	GetBatch(ctx context.Context, id uuid.UUID) (*Batch, error)
+	BatchStatusCounts(ctx context.Context, id uuid.UUID) (map[string]int, error)
+
+	// retention, archiving, erasure and garbage collection
+	ExpiredJobs(ctx context.Context, p retention.Policy, limit int) ([]ExpiredJob, error)
+	MarkPurged(ctx context.Context, j ExpiredJob, reason string) (bool, error)
+	ListPurges(ctx context.Context, since time.Time, limit int) ([]PurgeRecord, error)
+	ArchivableJobs(ctx context.Context, days, limit int) ([]ArchivableJob, error)
+	MarkArchived(ctx context.Context, id uuid.UUID, class string) (bool, error)
+	JobObjects(ctx context.Context, id uuid.UUID) ([]StoredObject, error)
+	FindErasable(ctx context.Context, q EraseQuery) ([]ErasableJob, error)
+	EraseJob(ctx context.Context, eraseID uuid.UUID, callerRef string, j ErasableJob, objects []StoredObject) error
+	ListErasures(ctx context.Context, eraseID uuid.UUID) ([]EraseRecord, error)
+	ObjectRefs(ctx context.Context, buckets []string, prefix string) (*ObjectRefs, error)
+	OriginalInUse(ctx context.Context, bucket, key string) (bool, error)
+
+	// partitions of audio_jobs
+	JobsPartitioned(ctx context.Context) (bool, error)
+	Partitions(ctx context.Context) ([]Partition, error)
+	CreatePartitions(ctx context.Context, from time.Time, ahead int) ([]string, error)
+	DropPartitions(ctx context.Context, cutoff time.Time) (dropped, kept []string, err error)
+
+	// tenants, users and API keys
+	CreateTenant(ctx context.Context, id, name string) (*Tenant, error)
+	GetTenant(ctx context.Context, id string) (*Tenant, error)
+	ListTenants(ctx context.Context) ([]Tenant, error)
+	SetTenantDisabled(ctx context.Context, id string, disabled bool) error
+	CreateUser(ctx context.Context, tenantID, email, name, role string) (*User, error)
+	GetUser(ctx context.Context, id uuid.UUID) (*User, error)
+	ListUsers(ctx context.Context, tenantID string) ([]User, error)
+	SetUserDisabled(ctx context.Context, id uuid.UUID, disabled bool) error
+	CreateAPIKey(ctx context.Context, tenant, name string, scopes []string, expiresAt *time.Time) (*APIKey, string, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) (bool, error)
+	AuthenticateAPIKey(ctx context.Context, key string) (*APIKey, error)
+
+	// workers
+	RegisterWorker(ctx context.Context, id, hostname, version string, concurrency int) error
+	WorkerHeartbeat(ctx context.Context, id string) error
+	SetWorkerStatus(ctx context.Context, id, status string) error
+	ListWorkers(ctx context.Context, aliveWithin time.Duration) ([]WorkerRecord, error)
+}
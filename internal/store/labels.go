@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobFilter selects the jobs of ListJobs; zero fields match every job
+type JobFilter struct {
+	Status  string
+	Labels  map[string]string // jobs carrying all of these labels
+	Since   time.Time         // created at or after
+	BatchID *uuid.UUID
+	Limit   int
+}
+
+// JobSummary is a job found by ListJobs
+type JobSummary struct {
+	ID          uuid.UUID         `json:"id"`
+	Status      string            `json:"status"`
+	Priority    string            `json:"priority"`
+	Kind        string            `json:"kind"`
+	ParentID    *uuid.UUID        `json:"parent_id,omitempty"`
+	BatchID     *uuid.UUID        `json:"batch_id,omitempty"`
+	Tenant      *string           `json:"tenant,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	DurationSec *float64          `json:"duration_sec,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	FinishedAt  *time.Time        `json:"finished_at,omitempty"`
+}
+
+// labelsFilter encodes labels for a containment (@>) match, nil without labels,
+// which the queries read as no filter
+func labelsFilter(labels map[string]string) ([]byte, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(labels)
+}
+
+// ListJobs returns the jobs of the scoped tenant matching f, newest first. The
+// labels are matched with the GIN index on audio_jobs.labels.
+func (s *DB) ListJobs(ctx context.Context, f JobFilter) ([]JobSummary, error) {
+	labels, err := labelsFilter(f.Labels)
+	if err != nil {
+		return nil, err
+	}
+	var since *time.Time
+	if !f.Since.IsZero() {
+		since = &f.Since
+	}
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, status, priority, kind, parent_id, batch_id, tenant, labels, duration_sec, created_at, finished_at
+		FROM audio_jobs
+		WHERE ($1 = '' OR status = $1) AND ($2::jsonb IS NULL OR labels @> $2::jsonb)
+		  AND ($3::timestamptz IS NULL OR created_at >= $3) AND ($5 = '' OR tenant = $5)
+		  AND ($6::uuid IS NULL OR batch_id = $6)
+		ORDER BY created_at DESC
+		LIMIT $4
+	`, f.Status, labels, since, f.Limit, s.tenant, f.BatchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []JobSummary{}
+	for rows.Next() {
+		var j JobSummary
+		if err := rows.Scan(&j.ID, &j.Status, &j.Priority, &j.Kind, &j.ParentID, &j.BatchID, &j.Tenant, &j.Labels, &j.DurationSec,
+			&j.CreatedAt, &j.FinishedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, j)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// migrateLock is the advisory lock serializing Migrate between the API and
+// workers starting at the same time
+const migrateLock = 0x626c696e6b79 // "blinky"
+
+// Migrate applies the *.sql files of fsys not yet recorded in schema_migrations,
+// in the order of their names, each in its own transaction, and returns the
+// names applied. Migrations are written to be idempotent (IF NOT EXISTS), so a
+// database created before schema_migrations existed gets every one of them
+// applied once without harm.
+func (s *DB) Migrate(ctx context.Context, fsys fs.FS) ([]string, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrateLock); err != nil {
+		return nil, err
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrateLock)
+
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+		)
+	`); err != nil {
+		return nil, err
+	}
+	rows, err := conn.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	done := map[string]bool{}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		done[v] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var applied []string
+	for _, name := range names {
+		version := strings.TrimSuffix(path.Base(name), ".sql")
+		if done[version] {
+			continue
+		}
+		sql, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return applied, err
+		}
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return applied, err
+		}
+		// no arguments: the simple protocol, which runs every statement of the file
+		if _, err := tx.Exec(ctx, string(sql)); err != nil {
+			tx.Rollback(ctx)
+			return applied, fmt.Errorf("migration %s: %w", version, err)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+			tx.Rollback(ctx)
+			return applied, fmt.Errorf("migration %s: %w", version, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return applied, fmt.Errorf("migration %s: %w", version, err)
+		}
+		applied = append(applied, version)
+	}
+	return applied, nil
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobOutput is an additional output file of a job (per-party audio, renditions, ...)
+type JobOutput struct {
+	JobID       uuid.UUID `json:"job_id"`
+	Name        string    `json:"name"`
+	S3Bucket    string    `json:"s3_bucket"`
+	S3Key       string    `json:"s3_key"`
+	S3Version   *string   `json:"s3_version_id,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	SHA256      string    `json:"sha256,omitempty"`
+	Endpoint    string    `json:"endpoint,omitempty"` // primary or secondary with S3 failover
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AddJobOutput records an uploaded additional output, replacing a previous one with the same name
+func (s *DB) AddJobOutput(ctx context.Context, o JobOutput) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO job_outputs (job_id, name, s3_bucket, s3_key, s3_version_id, content_type, sha256, endpoint, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), now())
+		ON CONFLICT (job_id, name) DO UPDATE SET s3_bucket=$3, s3_key=$4, s3_version_id=$5,
+			content_type=NULLIF($6, ''), sha256=NULLIF($7, ''), endpoint=NULLIF($8, ''), created_at=now()
+	`, o.JobID, o.Name, o.S3Bucket, o.S3Key, o.S3Version, o.ContentType, o.SHA256, o.Endpoint)
+	return err
+}
+
+// ListJobOutputs returns the additional outputs of a job ordered by name
+func (s *DB) ListJobOutputs(ctx context.Context, jobID uuid.UUID) ([]JobOutput, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT job_id, name, s3_bucket, s3_key, s3_version_id, COALESCE(content_type, ''), COALESCE(sha256, ''), COALESCE(endpoint, ''), created_at
+		FROM job_outputs
+		WHERE job_id=$1 AND ($2 = '' OR EXISTS (SELECT 1 FROM audio_jobs WHERE id=$1 AND tenant=$2))
+		ORDER BY name
+	`, jobID, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []JobOutput
+	for rows.Next() {
+		var o JobOutput
+		if err := rows.Scan(&o.JobID, &o.Name, &o.S3Bucket, &o.S3Key, &o.S3Version, &o.ContentType, &o.SHA256, &o.Endpoint, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// partitionLock is the advisory lock serializing partition maintenance between
+// workers
+const partitionLock = 0x626c696e6b7970 // "blinkyp"
+
+// partitionPrefix starts the names of the monthly partitions of audio_jobs:
+// audio_jobs_YYYY_MM
+const partitionPrefix = "audio_jobs_"
+
+// Partition is a monthly partition of audio_jobs
+type Partition struct {
+	Name  string    `json:"name"`
+	Month time.Time `json:"month"` // first instant of the month, UTC
+	Rows  int64     `json:"rows"`  // estimate of the planner
+}
+
+// partitionMonth returns the month of a partition name; false for the default
+// partition and tables not named by CreatePartitions
+func partitionMonth(name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, partitionPrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	m, err := time.Parse("2006_01", suffix)
+	return m, err == nil
+}
+
+// JobsPartitioned reports whether audio_jobs is a partitioned table, which it
+// is from migration 040 on; the other partition methods fail before
+func (s *DB) JobsPartitioned(ctx context.Context) (bool, error) {
+	var ok bool
+	err := s.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = 'audio_jobs'::regclass)
+	`).Scan(&ok)
+	return ok, err
+}
+
+// Partitions returns the monthly partitions of audio_jobs, oldest first (the
+// names sort like their months)
+func (s *DB) Partitions(ctx context.Context) ([]Partition, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT c.relname, GREATEST(c.reltuples, 0)::bigint
+		FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'audio_jobs'::regclass
+		ORDER BY c.relname
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Partition
+	for rows.Next() {
+		var p Partition
+		if err := rows.Scan(&p.Name, &p.Rows); err != nil {
+			return nil, err
+		}
+		var ok bool
+		if p.Month, ok = partitionMonth(p.Name); ok {
+			out = append(out, p)
+		}
+	}
+	return out, rows.Err()
+}
+
+// CreatePartitions makes sure audio_jobs has the partitions of the month of
+// from and the ahead months after it, and returns the names of those it
+// created. Jobs of these months that landed in the default partition move to
+// their own. It does nothing while another worker maintains the partitions.
+func (s *DB) CreatePartitions(ctx context.Context, from time.Time, ahead int) ([]string, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	var locked bool
+	if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, partitionLock).Scan(&locked); err != nil || !locked {
+		return nil, err
+	}
+
+	first := time.Date(from.UTC().Year(), from.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	var created []string
+	for i := 0; i <= ahead; i++ {
+		m := first.AddDate(0, i, 0)
+		var ok bool
+		if err := tx.QueryRow(ctx, `SELECT audio_jobs_create_partition($1::date)`, m).Scan(&ok); err != nil {
+			return nil, fmt.Errorf("partition %s: %w", m.Format("2006-01"), err)
+		}
+		if ok {
+			created = append(created, partitionPrefix+m.Format("2006_01"))
+		}
+	}
+	return created, tx.Commit(ctx)
+}
+
+// DropPartitions drops the partitions of audio_jobs whose month ended by
+// cutoff, with the events, outputs, transcripts and fingerprints of their jobs.
+// It returns the names dropped and those kept: a partition holding a job whose
+// objects were neither purged nor erased stays, dropping it would leave the
+// objects in storage with no job pointing at them. It does nothing while
+// another worker maintains the partitions.
+func (s *DB) DropPartitions(ctx context.Context, cutoff time.Time) (dropped, kept []string, err error) {
+	parts, err := s.Partitions(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+	var locked bool
+	if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, partitionLock).Scan(&locked); err != nil || !locked {
+		return nil, nil, err
+	}
+
+	for _, p := range parts {
+		if p.Month.AddDate(0, 1, 0).After(cutoff) {
+			break
+		}
+		ok, err := dropPartition(ctx, tx, p.Name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("partition %s: %w", p.Name, err)
+		}
+		if ok {
+			dropped = append(dropped, p.Name)
+		} else {
+			kept = append(kept, p.Name)
+		}
+	}
+	return dropped, kept, tx.Commit(ctx)
+}
+
+// dropPartition drops one partition and the rows of its jobs in other tables;
+// false when it holds jobs not yet purged
+func dropPartition(ctx context.Context, tx pgx.Tx, name string) (bool, error) {
+	part := pgx.Identifier{name}.Sanitize()
+	// taken before the check, so no job of the partition changes in between
+	if _, err := tx.Exec(ctx, `LOCK TABLE `+part+` IN SHARE MODE`); err != nil {
+		return false, err
+	}
+	var unpurged bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM `+part+` WHERE purged_at IS NULL)`).Scan(&unpurged); err != nil {
+		return false, err
+	}
+	if unpurged {
+		return false, nil
+	}
+	for _, table := range []string{"job_events", "job_outputs", "transcripts", "job_fingerprints"} {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE job_id IN (SELECT id FROM `+part+`)`); err != nil {
+			return false, err
+		}
+	}
+	_, err := tx.Exec(ctx, `DROP TABLE `+part)
+	return err == nil, err
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/metrics"
+)
+
+// PoolConfig tunes the connection pool; zero fields keep the pgxpool defaults
+// or the pool_* parameters of the connection string
+type PoolConfig struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration // of idle connections, by pgxpool
+	PingEvery         time.Duration // of Monitor; 0 disables it
+}
+
+// resetAfterFailures is how many pings in a row must fail before Monitor drops
+// every connection of the pool
+const resetAfterFailures = 3
+
+// PoolConfigFromEnv reads the pool configuration shared by the API and the
+// worker from the environment, see the README for the variables
+func PoolConfigFromEnv() PoolConfig {
+	conns := func(k string) int32 {
+		n, _ := strconv.ParseInt(os.Getenv(k), 10, 32)
+		return int32(max(n, 0))
+	}
+	duration := func(k string, d time.Duration) time.Duration {
+		if v, err := time.ParseDuration(os.Getenv(k)); err == nil {
+			return v
+		}
+		return d
+	}
+	return PoolConfig{
+		MaxConns:          conns("DB_MAX_CONNS"),
+		MinConns:          conns("DB_MIN_CONNS"),
+		MaxConnLifetime:   duration("DB_MAX_CONN_LIFETIME", 0),
+		MaxConnIdleTime:   duration("DB_MAX_CONN_IDLE_TIME", 0),
+		HealthCheckPeriod: duration("DB_HEALTH_CHECK_PERIOD", 0),
+		PingEvery:         duration("DB_PING_INTERVAL", 15*time.Second),
+	}
+}
+
+// Monitor pings the database every interval until ctx is done and publishes
+// the result and the pool statistics as metrics. After resetAfterFailures
+// failed pings in a row the pool drops all its connections, so that the next
+// queries reconnect, e.g. to a failed over primary, rather than reuse
+// connections to a server that is gone.
+func (s *DB) Monitor(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	var failures int
+	var last poolCounts
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, min(every, 5*time.Second))
+		start := time.Now()
+		err := s.pool.Ping(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		metrics.DBPingSeconds.Observe(time.Since(start).Seconds())
+		switch {
+		case err == nil:
+			if failures > 0 {
+				log.Printf("db: reachable again after %d failed pings", failures)
+			}
+			failures = 0
+			metrics.DBUp.Set(1)
+		default:
+			failures++
+			metrics.DBUp.Set(0)
+			log.Printf("db: ping failed (%d in a row): %v", failures, err)
+			if failures%resetAfterFailures == 0 {
+				log.Printf("db: dropping all pool connections to reconnect")
+				s.pool.Reset()
+				metrics.DBPoolResets.Inc()
+			}
+		}
+		last = s.publishPoolStats(last)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// poolCounts are the cumulative counters of the pool statistics at the last
+// publishing, to count the increase since
+type poolCounts struct {
+	emptyAcquires int64
+	emptyWait     time.Duration
+}
+
+func (s *DB) publishPoolStats(last poolCounts) poolCounts {
+	st := s.pool.Stat()
+	metrics.DBPoolConns.WithLabelValues("acquired").Set(float64(st.AcquiredConns()))
+	metrics.DBPoolConns.WithLabelValues("idle").Set(float64(st.IdleConns()))
+	metrics.DBPoolConns.WithLabelValues("constructing").Set(float64(st.ConstructingConns()))
+	metrics.DBPoolConns.WithLabelValues("max").Set(float64(st.MaxConns()))
+	cur := poolCounts{emptyAcquires: st.EmptyAcquireCount(), emptyWait: st.EmptyAcquireWaitTime()}
+	if d := cur.emptyAcquires - last.emptyAcquires; d > 0 {
+		metrics.DBPoolWaits.Add(float64(d))
+	}
+	if d := cur.emptyWait - last.emptyWait; d > 0 {
+		metrics.DBPoolWaitSeconds.Add(d.Seconds())
+	}
+	return cur
+}
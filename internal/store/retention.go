@@ -0,0 +1,226 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/retention"
+)
+
+// StoredObject locates an object kept for a job
+type StoredObject struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+}
+
+// ExpiredJob is a finished job past its retention, with the objects stored for it
+type ExpiredJob struct {
+	ID             uuid.UUID
+	Tenant         string
+	RetentionClass string
+	Days           int // retention that expired the job
+	FinishedAt     time.Time
+	Objects        []StoredObject
+}
+
+// Reason names the retention rule that expired the job, for the purge audit
+func (j ExpiredJob) Reason(p retention.Policy) string {
+	if _, ok := p.Classes[j.RetentionClass]; ok {
+		return fmt.Sprintf("retention_class %s: %d days", j.RetentionClass, j.Days)
+	}
+	if _, ok := p.Tenants[j.Tenant]; ok {
+		return fmt.Sprintf("tenant %s: %d days", j.Tenant, j.Days)
+	}
+	return fmt.Sprintf("default: %d days", j.Days)
+}
+
+// ExpiredJobs returns up to limit finished, not yet purged jobs whose retention
+// under p has passed, longest expired first
+func (s *DB) ExpiredJobs(ctx context.Context, p retention.Policy, limit int) ([]ExpiredJob, error) {
+	classes, _ := json.Marshal(p.Classes)
+	tenants, _ := json.Marshal(p.Tenants)
+	rows, err := s.pool.Query(ctx, `
+		WITH j AS (
+			SELECT id, COALESCE(tenant, '') AS tenant, COALESCE(retention_class, '') AS retention_class,
+			       COALESCE(finished_at, created_at) AS finished_at,
+			       COALESCE(($1::jsonb ->> retention_class)::int, ($2::jsonb ->> tenant)::int, $3::int) AS days
+			FROM audio_jobs
+			WHERE purged_at IS NULL
+			  AND status IN ('done', 'completed_with_warnings', 'failed', 'cancelled', 'expanded')
+		)
+		SELECT id, tenant, retention_class, finished_at, days
+		FROM j
+		WHERE days > 0 AND finished_at < now() - make_interval(days => days)
+		ORDER BY finished_at + make_interval(days => days)
+		LIMIT $4
+	`, string(classes), string(tenants), p.DefaultDays, limit)
+	if err != nil {
+		return nil, err
+	}
+	var out []ExpiredJob
+	for rows.Next() {
+		var j ExpiredJob
+		if err := rows.Scan(&j.ID, &j.Tenant, &j.RetentionClass, &j.FinishedAt, &j.Days); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		out = append(out, j)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range out {
+		if out[i].Objects, err = s.JobObjects(ctx, out[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// JobObjects returns the objects stored for a job: its output, its original and
+// its additional outputs
+func (s *DB) JobObjects(ctx context.Context, id uuid.UUID) ([]StoredObject, error) {
+	var s3Bucket, s3Key, origBucket, origKey *string
+	err := s.pool.QueryRow(ctx, `
+		SELECT s3_bucket, s3_key, original_bucket, original_key FROM audio_jobs WHERE id=$1 AND ($2 = '' OR tenant = $2)
+	`, id, s.tenant).Scan(&s3Bucket, &s3Key, &origBucket, &origKey)
+	if err != nil {
+		return nil, err
+	}
+	var objs []StoredObject
+	if s3Bucket != nil && s3Key != nil {
+		objs = append(objs, StoredObject{Bucket: *s3Bucket, Key: *s3Key})
+	}
+	if origBucket != nil && origKey != nil {
+		objs = append(objs, StoredObject{Bucket: *origBucket, Key: *origKey})
+	}
+	outputs, err := s.ListJobOutputs(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range outputs {
+		objs = append(objs, StoredObject{Bucket: o.S3Bucket, Key: o.S3Key})
+	}
+	return objs, nil
+}
+
+// ArchivableJob is a finished job whose objects are due for cold storage
+type ArchivableJob struct {
+	ID      uuid.UUID
+	Objects []StoredObject
+}
+
+// ArchivableJobs returns up to limit finished jobs, neither archived nor purged,
+// that finished more than days ago, oldest first
+func (s *DB) ArchivableJobs(ctx context.Context, days, limit int) ([]ArchivableJob, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id FROM audio_jobs
+		WHERE archived_at IS NULL AND purged_at IS NULL
+		  AND status IN ('done', 'completed_with_warnings', 'failed', 'cancelled', 'expanded')
+		  AND COALESCE(finished_at, created_at) < now() - make_interval(days => $1)
+		ORDER BY COALESCE(finished_at, created_at)
+		LIMIT $2
+	`, days, limit)
+	if err != nil {
+		return nil, err
+	}
+	var out []ArchivableJob
+	for rows.Next() {
+		var j ArchivableJob
+		if err := rows.Scan(&j.ID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		out = append(out, j)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range out {
+		if out[i].Objects, err = s.JobObjects(ctx, out[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// MarkArchived records that the objects of a job were moved to class. It
+// returns false when the job was archived (or purged) already.
+func (s *DB) MarkArchived(ctx context.Context, id uuid.UUID, class string) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE audio_jobs SET archived=TRUE, archived_at=now(), storage_class=$2
+		WHERE id=$1 AND archived_at IS NULL AND purged_at IS NULL
+	`, id, class)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// MarkPurged marks a job purged and records its deleted objects in the purge
+// audit, in one transaction. It returns false when the job was purged already,
+// e.g. by another worker.
+func (s *DB) MarkPurged(ctx context.Context, j ExpiredJob, reason string) (bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `UPDATE audio_jobs SET purged_at=now() WHERE id=$1 AND purged_at IS NULL`, j.ID)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	for _, o := range j.Objects {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO purge_audit (job_id, tenant, bucket, object_key, reason) VALUES ($1, NULLIF($2, ''), $3, $4, $5)
+		`, j.ID, j.Tenant, o.Bucket, o.Key, reason); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit(ctx)
+}
+
+// PurgeRecord is an object deleted by the retention purger
+type PurgeRecord struct {
+	JobID     uuid.UUID `json:"job_id"`
+	Tenant    *string   `json:"tenant,omitempty"`
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	Reason    string    `json:"reason"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// ListPurges returns the purge audit since a point in time, newest first
+func (s *DB) ListPurges(ctx context.Context, since time.Time, limit int) ([]PurgeRecord, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT job_id, tenant, bucket, object_key, reason, deleted_at
+		FROM purge_audit WHERE deleted_at >= $1 AND ($3 = '' OR tenant = $3)
+		ORDER BY deleted_at DESC, id DESC
+		LIMIT $2
+	`, since, limit, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PurgeRecord
+	for rows.Next() {
+		var r PurgeRecord
+		if err := rows.Scan(&r.JobID, &r.Tenant, &r.Bucket, &r.Key, &r.Reason, &r.DeletedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// JobStats aggregates the jobs of a day and denoiser, or of the whole period
+type JobStats struct {
+	Day           *time.Time `json:"day,omitempty"` // UTC; nil for the period
+	DenoiseMethod string     `json:"denoise_method,omitempty"`
+	Jobs          int        `json:"jobs"`
+	Succeeded     int        `json:"succeeded"` // done or completed_with_warnings
+	Failed        int        `json:"failed"`
+	// SuccessRate is Succeeded over the finished jobs, nil before any finished;
+	// cancelled jobs and jobs still queued or processing don't count
+	SuccessRate *float64 `json:"success_rate,omitempty"`
+	// processing time of the succeeded jobs, from claim to finish
+	P50Sec *float64 `json:"p50_processing_sec,omitempty"`
+	P95Sec *float64 `json:"p95_processing_sec,omitempty"`
+	// AvgSNRImprovementDB is the mean SNR after minus before of the jobs with
+	// both measured
+	AvgSNRImprovementDB *float64 `json:"avg_snr_improvement_db,omitempty"`
+}
+
+// Stats is the result of JobStatsBetween
+type Stats struct {
+	Since  time.Time  `json:"since"`
+	Until  time.Time  `json:"until"`
+	Totals JobStats   `json:"totals"`
+	Days   []JobStats `json:"days"` // newest day first, by denoiser
+}
+
+// denoiserKey is the denoiser of a job as in ClaimNextJob: the one it ran with,
+// else the one requested, else default
+const denoiserKey = `COALESCE(NULLIF(denoise_method, ''), NULLIF(lower(payload->>'denoise_method'), ''), 'default')`
+
+// JobStatsBetween aggregates the audio jobs of the scoped tenant created in
+// [since, until), per UTC day and denoiser and for the whole period. Bundles
+// are left out, their children count.
+func (s *DB) JobStatsBetween(ctx context.Context, since, until time.Time) (*Stats, error) {
+	rows, err := s.pool.Query(ctx, `
+		WITH j AS (
+			SELECT (created_at AT TIME ZONE 'UTC')::date AS day, `+denoiserKey+` AS method, status,
+			       extract(epoch FROM finished_at - started_at) AS processing_sec,
+			       snr_gain_db AS snr_gain
+			FROM audio_jobs
+			WHERE kind = 'audio' AND created_at >= $1 AND created_at < $2 AND ($3 = '' OR tenant = $3)
+		)
+		SELECT day, method, GROUPING(day, method) = 0,
+		       count(*),
+		       count(*) FILTER (WHERE status IN ('done', 'completed_with_warnings')),
+		       count(*) FILTER (WHERE status = 'failed'),
+		       percentile_cont(0.5) WITHIN GROUP (ORDER BY processing_sec) FILTER (WHERE status IN ('done', 'completed_with_warnings')),
+		       percentile_cont(0.95) WITHIN GROUP (ORDER BY processing_sec) FILTER (WHERE status IN ('done', 'completed_with_warnings')),
+		       avg(snr_gain)
+		FROM j
+		GROUP BY GROUPING SETS ((day, method), ())
+		ORDER BY day DESC NULLS FIRST, method
+	`, since, until, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	st := Stats{Since: since, Until: until, Days: []JobStats{}}
+	for rows.Next() {
+		var js JobStats
+		var day *time.Time
+		var method *string
+		var grouped bool
+		if err := rows.Scan(&day, &method, &grouped, &js.Jobs, &js.Succeeded, &js.Failed, &js.P50Sec, &js.P95Sec, &js.AvgSNRImprovementDB); err != nil {
+			return nil, err
+		}
+		if n := js.Succeeded + js.Failed; n > 0 {
+			rate := float64(js.Succeeded) / float64(n)
+			js.SuccessRate = &rate
+		}
+		if !grouped {
+			st.Totals = js
+			continue
+		}
+		js.Day = day
+		if method != nil {
+			js.DenoiseMethod = *method
+		}
+		st.Days = append(st.Days, js)
+	}
+	return &st, rows.Err()
+}
@@ -3,83 +3,237 @@ package store
 import (
 	"context"
 	"database/sql"
-
-	// "fmt"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/audio"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // Job represents a processing job record with storage/metadata fields
 type Job struct {
-	ID            uuid.UUID       `json:"id"`
-	InputPath     string          `json:"input_path"`
-	OutputPath    string          `json:"output_path"`
-	Status        string          `json:"status"`
-	Progress      int             `json:"progress"`
-	ErrorMsg      *string         `json:"error_msg,omitempty"`
-	S3Bucket      *string         `json:"s3_bucket,omitempty"`
-	S3Key         *string         `json:"s3_key,omitempty"`
-	S3Version     *string         `json:"s3_version_id,omitempty"`
-	Duration      *float64        `json:"duration_sec,omitempty"`
-	Loudness      sql.NullString  `json:"loudness_json,omitempty"`
-	NoiseLevel    sql.NullFloat64 `json:"noise_level,omitempty"`
-	DenoiseMethod *string         `json:"denoise_method,omitempty"`
-	CreatedAt     time.Time       `json:"created_at"`
-	StartedAt     *time.Time      `json:"started_at,omitempty"`
-	FinishedAt    *time.Time      `json:"finished_at,omitempty"`
-}
-
-type Store struct {
-	pool *pgxpool.Pool
-}
-
-func New(connStr string) (*Store, error) {
+	ID               uuid.UUID         `json:"id"`
+	InputPath        string            `json:"input_path"`
+	OutputPath       string            `json:"output_path"`
+	Status           string            `json:"status"` // scheduled | queued | processing | done | completed_with_warnings | failed | cancelled | expanded (bundles)
+	Progress         int               `json:"progress"`
+	Priority         string            `json:"priority"`
+	Kind             string            `json:"kind"`
+	ParentID         *uuid.UUID        `json:"parent_id,omitempty"`
+	BatchID          *uuid.UUID        `json:"batch_id,omitempty"`
+	ErrorMsg         *string           `json:"error_msg,omitempty"`
+	ErrorCode        *string           `json:"error_code,omitempty"` // why the input was rejected, see audio.PreflightError
+	Attempts         int               `json:"attempts"`             // times a worker claimed the job
+	MaxAttempts      int               `json:"max_attempts"`
+	LastError        *string           `json:"last_error,omitempty"`    // of the latest failed attempt, also when it is retried
+	NextRetryAt      *time.Time        `json:"next_retry_at,omitempty"` // of a job waiting to be retried
+	S3Bucket         *string           `json:"s3_bucket,omitempty"`
+	S3Key            *string           `json:"s3_key,omitempty"`
+	S3Version        *string           `json:"s3_version_id,omitempty"`
+	OutputSHA256     *string           `json:"output_sha256,omitempty"`
+	S3Endpoint       *string           `json:"s3_endpoint,omitempty"`     // primary or secondary with S3 failover
+	OriginalBucket   *string           `json:"original_bucket,omitempty"` // unprocessed input, kept for reprocessing and audit
+	OriginalKey      *string           `json:"original_key,omitempty"`
+	OriginalSHA256   *string           `json:"original_sha256,omitempty"`
+	OriginalEndpoint *string           `json:"original_endpoint,omitempty"`
+	Tenant           *string           `json:"tenant,omitempty"`
+	OwnerID          *uuid.UUID        `json:"owner_id,omitempty"` // user who submitted the job
+	RetentionClass   *string           `json:"retention_class,omitempty"`
+	PurgedAt         *time.Time        `json:"purged_at,omitempty"`     // objects deleted by the retention purger
+	ArchivedAt       *time.Time        `json:"archived_at,omitempty"`   // objects moved to StorageClass by the archiver
+	StorageClass     *string           `json:"storage_class,omitempty"` // of archived objects
+	CallerRef        *string           `json:"caller_ref,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`    // of the client, see queue.ParseLabels
+	ErasedAt         *time.Time        `json:"erased_at,omitempty"` // recording and personal data deleted on request
+	Duration         *float64          `json:"duration_sec,omitempty"`
+	Loudness         *audio.Loudness   `json:"loudness,omitempty"` // of the output, see the accessors below
+	LoudnessBefore   *audio.Loudness   `json:"loudness_before,omitempty"`
+	Quality          *Quality          `json:"quality,omitempty"`
+	SNRGain          *float64          `json:"snr_gain_db,omitempty"` // SNR of the output minus the input's, when both were measured
+	NoiseLevel       sql.NullFloat64   `json:"noise_level,omitempty"`
+	Analysis         json.RawMessage   `json:"analysis,omitempty"`
+	MOS              *float64          `json:"mos,omitempty"` // estimated MOS of the output, 1..5
+	Talk             *TalkTime         `json:"talk,omitempty"`
+	Language         *string           `json:"language,omitempty"` // detected spoken language, ISO 639-1
+	LanguageConf     *float64          `json:"language_confidence,omitempty"`
+	KeywordHits      *int              `json:"keyword_hits,omitempty"` // matches of the job's keyword list, see analysis.keywords
+	AnswerClass      *string           `json:"answer_class,omitempty"` // human, machine or unknown, see analysis.answer
+	EchoScore        *float64          `json:"echo_score,omitempty"`   // 0..1, see analysis.echo
+	DuplicateOf      *uuid.UUID        `json:"duplicate_of,omitempty"` // earlier job with the same recording
+	MediaInfo        json.RawMessage   `json:"media_info,omitempty"`   // ffprobe metadata of the input (audio.MediaInfo)
+	DenoiseMethod    *string           `json:"denoise_method,omitempty"`
+	WorkerID         *string           `json:"worker_id,omitempty"`
+	HeartbeatAt      *time.Time        `json:"heartbeat_at,omitempty"`
+	ContentHash      *string           `json:"content_hash,omitempty"`
+	ProcessAfter     *time.Time        `json:"process_after,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+	StartedAt        *time.Time        `json:"started_at,omitempty"`
+	FinishedAt       *time.Time        `json:"finished_at,omitempty"`
+}
+
+// IntegratedLUFS returns the integrated loudness of the output; false when it
+// was not measured
+func (j *Job) IntegratedLUFS() (float64, bool) {
+	if j.Loudness == nil {
+		return 0, false
+	}
+	return j.Loudness.Integrated, true
+}
+
+// TruePeakDBTP returns the true peak of the output; false when it was not measured
+func (j *Job) TruePeakDBTP() (float64, bool) {
+	if j.Loudness == nil {
+		return 0, false
+	}
+	return j.Loudness.TruePeak, true
+}
+
+// LRA returns the loudness range of the output in LU; false when it was not measured
+func (j *Job) LRA() (float64, bool) {
+	if j.Loudness == nil {
+		return 0, false
+	}
+	return j.Loudness.LRA, true
+}
+
+// DB is the Store kept in Postgres
+type DB struct {
+	pool   *pgxpool.Pool
+	tenant string // see ForTenant
+}
+
+var _ Store = (*DB)(nil)
+
+// New connects to the database of connStr with a pool tuned by pc
+func New(connStr string, pc PoolConfig) (*DB, error) {
+	cfg, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, err
+	}
+	if pc.MaxConns > 0 {
+		cfg.MaxConns = pc.MaxConns
+	}
+	if pc.MinConns > 0 {
+		cfg.MinConns = min(pc.MinConns, cfg.MaxConns)
+	}
+	if pc.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = pc.MaxConnLifetime
+	}
+	if pc.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = pc.MaxConnIdleTime
+	}
+	if pc.HealthCheckPeriod > 0 {
+		cfg.HealthCheckPeriod = pc.HealthCheckPeriod
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	pool, err := pgxpool.New(ctx, connStr)
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
-	return &Store{pool: pool}, nil
+	return &DB{pool: pool}, nil
 }
 
-func (s *Store) Close() {
+func (s *DB) Close() {
 	s.pool.Close()
 }
 
-func (s *Store) CreateJob(ctx context.Context, inputPath, outputPath string) (uuid.UUID, error) {
+// NewJob describes a job to insert with CreateJob
+type NewJob struct {
+	InputPath      string
+	OutputPath     string
+	Priority       string
+	ProcessAfter   *time.Time // when set in the future the job is created as scheduled
+	ContentHash    string     // sha256 of the uploaded input
+	OptionsHash    string     // fingerprint of the processing options (queue.JobMsg.OptionsHash)
+	Kind           string     // audio (default) or bundle
+	ParentID       *uuid.UUID // set for jobs unpacked from a bundle
+	BatchID        *uuid.UUID // see CreateBatch
+	Tenant         string
+	RetentionClass string // see retention.Policy
+	CallerRef      string
+	OwnerID        *uuid.UUID // user who submitted the job
+	Labels         map[string]string
+}
+
+// CreateJob inserts a job, with the tenant of a scoped store unless nj has
+// one; a tenant seen for the first time is added to tenants
+func (s *DB) CreateJob(ctx context.Context, nj NewJob) (uuid.UUID, error) {
 	id := uuid.New()
+	status := "queued"
+	if nj.Kind == "" {
+		nj.Kind = "audio"
+	}
+	if nj.Tenant == "" {
+		nj.Tenant = s.tenant
+	}
+	if nj.ProcessAfter != nil && nj.ProcessAfter.After(time.Now()) {
+		status = "scheduled"
+	}
+	var labels []byte // NULL without labels
+	if len(nj.Labels) > 0 {
+		var err error
+		if labels, err = json.Marshal(nj.Labels); err != nil {
+			return uuid.Nil, err
+		}
+	}
 	_, err := s.pool.Exec(ctx, `
-		INSERT INTO audio_jobs (id, input_path, output_path, status, created_at)
-		VALUES ($1, $2, $3, 'queued', now())
-	`, id, inputPath, outputPath)
+		WITH job AS (
+			INSERT INTO audio_jobs (id, input_path, output_path, status, priority, process_after, content_hash, options_hash,
+			                        kind, parent_id, tenant, retention_class, caller_ref, owner_id, labels, batch_id, created_at)
+			VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'normal'), $6, NULLIF($7, ''), NULLIF($8, ''), $9, $10, NULLIF($11, ''), NULLIF($12, ''), NULLIF($13, ''), $14, $15::jsonb, $16, now())
+			RETURNING id, status
+		), tenant AS (
+			INSERT INTO tenants (id) SELECT $11 WHERE $11 <> '' ON CONFLICT DO NOTHING
+		)
+		INSERT INTO job_events (job_id, event) SELECT id, status FROM job
+	`, id, nj.InputPath, nj.OutputPath, status, nj.Priority, nj.ProcessAfter, nj.ContentHash, nj.OptionsHash,
+		nj.Kind, nj.ParentID, nj.Tenant, nj.RetentionClass, nj.CallerRef, nj.OwnerID, labels, nj.BatchID)
 	if err != nil {
 		return uuid.Nil, err
 	}
 	return id, nil
 }
 
-func (s *Store) GetJob(ctx context.Context, id uuid.UUID) (*Job, error) {
+func (s *DB) GetJob(ctx context.Context, id uuid.UUID) (*Job, error) {
 	row := s.pool.QueryRow(ctx, `
-		SELECT id, input_path, output_path, status, progress, error_msg, created_at, started_at, finished_at,
-		       s3_bucket, s3_key, s3_version_id, duration_sec, loudness_json, noise_level, denoise_method
-		FROM audio_jobs WHERE id=$1
-	`, id)
+		SELECT id, input_path, output_path, status, progress, priority, kind, parent_id, error_msg, error_code, process_after, created_at, started_at, finished_at,
+		       s3_bucket, s3_key, s3_version_id, duration_sec, loudness, noise_level, denoise_method,
+		       worker_id, heartbeat_at, content_hash, analysis_json, mos_score,
+		       talk_sec, dead_air_pct, longest_silence_sec, agent_talk_sec, customer_talk_sec, overtalk_pct, interruptions,
+		       language, language_confidence, keyword_hits, answer_class, echo_score, duplicate_of, media_info,
+		       original_bucket, original_key, tenant, retention_class, purged_at, original_sha256, output_sha256,
+		       caller_ref, erased_at, archived_at, storage_class, s3_endpoint, original_endpoint,
+		       attempts, max_attempts, last_error, next_retry_at, owner_id, labels,
+		       loudness_before, quality, snr_gain_db, batch_id
+		FROM audio_jobs WHERE id=$1 AND ($2 = '' OR tenant = $2)
+	`, id, s.tenant)
 
 	var j Job
 	var errMsg *string
 	var s3Bucket, s3Key, s3Version *string
 	var duration sql.NullFloat64
-	var loudnessJSON sql.NullString
+	var loudnessJSON []byte
 	var noiseLevel sql.NullFloat64
 	var denoiseMethod *string
+	var talkSec, deadAirPct, longestSilence *float64
+	var talk TalkTime
 
 	err := row.Scan(
-		&j.ID, &j.InputPath, &j.OutputPath, &j.Status, &j.Progress, &errMsg,
-		&j.CreatedAt, &j.StartedAt, &j.FinishedAt,
+		&j.ID, &j.InputPath, &j.OutputPath, &j.Status, &j.Progress, &j.Priority, &j.Kind, &j.ParentID, &errMsg, &j.ErrorCode,
+		&j.ProcessAfter, &j.CreatedAt, &j.StartedAt, &j.FinishedAt,
 		&s3Bucket, &s3Key, &s3Version, &duration, &loudnessJSON, &noiseLevel, &denoiseMethod,
+		&j.WorkerID, &j.HeartbeatAt, &j.ContentHash, &j.Analysis, &j.MOS,
+		&talkSec, &deadAirPct, &longestSilence, &talk.AgentTalkSec, &talk.CustomerTalkSec, &talk.OvertalkPct, &talk.Interruptions,
+		&j.Language, &j.LanguageConf, &j.KeywordHits, &j.AnswerClass, &j.EchoScore, &j.DuplicateOf, &j.MediaInfo,
+		&j.OriginalBucket, &j.OriginalKey, &j.Tenant, &j.RetentionClass, &j.PurgedAt, &j.OriginalSHA256, &j.OutputSHA256,
+		&j.CallerRef, &j.ErasedAt, &j.ArchivedAt, &j.StorageClass, &j.S3Endpoint, &j.OriginalEndpoint,
+		&j.Attempts, &j.MaxAttempts, &j.LastError, &j.NextRetryAt, &j.OwnerID, &j.Labels,
+		&j.LoudnessBefore, &j.Quality, &j.SNRGain, &j.BatchID,
 	)
 	if err != nil {
 		return nil, err
@@ -92,45 +246,500 @@ func (s *Store) GetJob(ctx context.Context, id uuid.UUID) (*Job, error) {
 		val := duration.Float64
 		j.Duration = &val
 	}
-	j.Loudness = loudnessJSON
+	if loudnessJSON != nil {
+		if err := json.Unmarshal(loudnessJSON, &j.Loudness); err != nil {
+			return nil, fmt.Errorf("decode loudness: %w", err)
+		}
+	}
 	j.NoiseLevel = noiseLevel
 	j.DenoiseMethod = denoiseMethod
+	if talkSec != nil && deadAirPct != nil && longestSilence != nil {
+		talk.TalkSec, talk.DeadAirPct, talk.LongestSilenceSec = *talkSec, *deadAirPct, *longestSilence
+		j.Talk = &talk
+	}
 
 	return &j, nil
 }
 
-func (s *Store) SetStarted(ctx context.Context, id uuid.UUID) error {
-	_, err := s.pool.Exec(ctx, `UPDATE audio_jobs SET status='processing', started_at=now() WHERE id=$1`, id)
+// FindDoneByHash returns the most recent finished job for the same input content and
+// processing options, or nil when there is none. A scoped store only finds the
+// jobs of its tenant.
+func (s *DB) FindDoneByHash(ctx context.Context, contentHash, optionsHash string) (*Job, error) {
+	var id uuid.UUID
+	err := s.pool.QueryRow(ctx, `
+		SELECT id FROM audio_jobs
+		WHERE content_hash=$1 AND options_hash=$2 AND status IN ('done', 'completed_with_warnings')
+		  AND ($3 = '' OR tenant = $3)
+		ORDER BY finished_at DESC
+		LIMIT 1
+	`, contentHash, optionsHash, s.tenant).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.GetJob(ctx, id)
+}
+
+// SetStarted moves a queued job to processing; a TransitionError when it is
+// not queued
+func (s *DB) SetStarted(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE audio_jobs SET status='processing', started_at=now() WHERE id=$1 AND status = ANY($2)
+	`, id, legalFrom["processing"])
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return s.conflict(ctx, id, "processing")
+	}
+	return nil
+}
+
+// ClaimJob moves a queued job to processing on behalf of workerID and counts the
+// attempt. It returns false when the job is not queued anymore (another worker
+// got it first).
+func (s *DB) ClaimJob(ctx context.Context, id uuid.UUID, workerID string) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		WITH claimed AS (
+			UPDATE audio_jobs SET status='processing', started_at=now(), worker_id=$2, heartbeat_at=now(),
+			                      attempts=attempts+1, next_retry_at=NULL
+			WHERE id=$1 AND status='queued'
+			RETURNING id, attempts
+		)
+		INSERT INTO job_events (job_id, event, detail, worker_id) SELECT id, 'claimed', 'attempt ' || attempts, $2 FROM claimed
+	`, id, workerID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ClaimedJob is a job claimed by ClaimNextJob, with the message to process
+type ClaimedJob struct {
+	ID       uuid.UUID
+	Payload  []byte
+	Attempts int
+}
+
+// ClaimNextJob claims the most urgent, then oldest, queued job for workerID
+// straight from the table, for workers polling Postgres instead of receiving
+// jobs from NATS. Rows locked by a concurrent claim are skipped rather than
+// waited for, so any number of workers can poll without taking the same job.
+// methods limits the claim to these denoise methods (queue.MethodToken, nil
+// for any), exclude skips methods; nil is returned when no job is waiting.
+func (s *DB) ClaimNextJob(ctx context.Context, workerID string, methods, exclude []string) (*ClaimedJob, error) {
+	var cj ClaimedJob
+	err := s.pool.QueryRow(ctx, `
+		WITH next AS (
+			SELECT id FROM audio_jobs
+			WHERE status='queued' AND payload IS NOT NULL
+			  AND ($2::text[] IS NULL OR COALESCE(NULLIF(lower(payload->>'denoise_method'), ''), 'default') = ANY($2))
+			  AND NOT (COALESCE(NULLIF(lower(payload->>'denoise_method'), ''), 'default') = ANY($3))
+			ORDER BY priority_rank, created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE audio_jobs j SET status='processing', started_at=now(), worker_id=$1, heartbeat_at=now(),
+			                        attempts=j.attempts+1, next_retry_at=NULL
+			FROM next
+			WHERE j.id = next.id
+			RETURNING j.id, j.payload, j.attempts
+		), events AS (
+			INSERT INTO job_events (job_id, event, detail, worker_id) SELECT id, 'claimed', 'attempt ' || attempts, $1 FROM claimed
+		)
+		SELECT id, payload, attempts FROM claimed
+	`, workerID, methods, append([]string{}, exclude...)).Scan(&cj.ID, &cj.Payload, &cj.Attempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cj, nil
+}
+
+// Heartbeat refreshes heartbeat_at for a job still owned by workerID
+func (s *DB) Heartbeat(ctx context.Context, id uuid.UUID, workerID string) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE audio_jobs SET heartbeat_at=now() WHERE id=$1 AND worker_id=$2 AND status='processing'
+	`, id, workerID)
 	return err
 }
 
-func (s *Store) UpdateProgress(ctx context.Context, id uuid.UUID, progress int) error {
-	_, err := s.pool.Exec(ctx, `UPDATE audio_jobs SET progress=$2 WHERE id=$1`, id, progress)
+// SetPayload stores the job message so the job can be republished later
+func (s *DB) SetPayload(ctx context.Context, id uuid.UUID, payload []byte) error {
+	_, err := s.pool.Exec(ctx, `UPDATE audio_jobs SET payload=$2 WHERE id=$1`, id, payload)
 	return err
 }
 
-func (s *Store) SetFinished(ctx context.Context, id uuid.UUID) error {
-	_, err := s.pool.Exec(ctx, `UPDATE audio_jobs SET status='done', progress=100, finished_at=now() WHERE id=$1`, id)
+// RequeuedJob is a job put (back) to queued by RequeueOrphaned or ReleaseDueJobs
+type RequeuedJob struct {
+	ID       uuid.UUID
+	WorkerID string
+	Payload  []byte
+}
+
+// RequeueOrphaned resets processing jobs whose worker has not heartbeated for staleAfter
+// back to queued and returns them, most urgent first, so the caller can republish their payload.
+// Jobs that used up their attempts are failed instead: a recording that kills
+// every worker taking it (OOM, crash) must not go round forever.
+func (s *DB) RequeueOrphaned(ctx context.Context, staleAfter time.Duration) ([]RequeuedJob, error) {
+	rows, err := s.pool.Query(ctx, `
+		WITH stale AS (
+			SELECT id, worker_id, attempts < max_attempts AS again FROM audio_jobs
+			WHERE status='processing' AND COALESCE(heartbeat_at, started_at) < now() - make_interval(secs => $1)
+			FOR UPDATE SKIP LOCKED
+		), requeued AS (
+			UPDATE audio_jobs j SET status='queued', progress=0, started_at=NULL, worker_id=NULL, heartbeat_at=NULL,
+			                        last_error='worker stopped heartbeating'
+			FROM stale old
+			WHERE j.id = old.id AND old.again
+			RETURNING j.id, old.worker_id, j.payload, j.priority_rank, j.created_at
+		), failed AS (
+			UPDATE audio_jobs j SET status='failed', error_msg='worker stopped heartbeating on every attempt',
+			                        last_error='worker stopped heartbeating', finished_at=now()
+			FROM stale old
+			WHERE j.id = old.id AND NOT old.again
+			RETURNING j.id, old.worker_id
+		), events AS (
+			INSERT INTO job_events (job_id, event, detail, worker_id)
+			SELECT id, 'retried', 'worker stopped heartbeating', worker_id FROM requeued
+			UNION ALL
+			SELECT id, 'failed', 'worker stopped heartbeating on every attempt', worker_id FROM failed
+		)
+		SELECT id, worker_id, payload FROM requeued
+		ORDER BY priority_rank, created_at
+	`, staleAfter.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []RequeuedJob
+	for rows.Next() {
+		var rj RequeuedJob
+		var workerID *string
+		if err := rows.Scan(&rj.ID, &workerID, &rj.Payload); err != nil {
+			return nil, err
+		}
+		if workerID != nil {
+			rj.WorkerID = *workerID
+		}
+		out = append(out, rj)
+	}
+	return out, rows.Err()
+}
+
+func (s *DB) UpdateProgress(ctx context.Context, id uuid.UUID, progress int) error {
+	_, err := s.pool.Exec(ctx, `UPDATE audio_jobs SET progress=$2 WHERE id=$1`, id, progress)
 	return err
 }
 
-func (s *Store) SetFailed(ctx context.Context, id uuid.UUID, msg string) error {
-	_, err := s.pool.Exec(ctx, `UPDATE audio_jobs SET status='failed', error_msg=$2, finished_at=now() WHERE id=$1`, id, msg)
+// SetFinished marks a processing job done; a TransitionError when it is not
+// processing anymore, e.g. cancelled meanwhile
+func (s *DB) SetFinished(ctx context.Context, id uuid.UUID) error {
+	return s.transition(ctx, id, "done", EventFinished, "", `progress=100, finished_at=now()`)
+}
+
+// SetFinishedWithWarnings marks a job whose output was delivered but missed the
+// quality gate; msg lists the reasons
+func (s *DB) SetFinishedWithWarnings(ctx context.Context, id uuid.UUID, msg string) error {
+	return s.transition(ctx, id, "completed_with_warnings", EventFinished, msg, `progress=100, error_msg=$2, finished_at=now()`)
+}
+
+// SetRejected fails a job whose input didn't pass the preflight, with the
+// rejection code next to the message; a TransitionError when the job reached
+// a final status already
+func (s *DB) SetRejected(ctx context.Context, id uuid.UUID, code, msg string) error {
+	tag, err := s.pool.Exec(ctx, `
+		WITH job AS (
+			UPDATE audio_jobs SET status='failed', error_code=$2, error_msg=$3, last_error=$3, finished_at=now()
+			WHERE id=$1 AND status = ANY($4)
+			RETURNING id, worker_id
+		)
+		INSERT INTO job_events (job_id, event, detail, worker_id) SELECT id, 'failed', $2 || ': ' || $3, worker_id FROM job
+	`, id, code, msg, legalFrom["failed"])
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return s.conflict(ctx, id, "failed")
+	}
+	return nil
+}
+
+// SetFailed fails a job that has not reached a final status; a
+// TransitionError when it has, e.g. it was cancelled or finished already
+func (s *DB) SetFailed(ctx context.Context, id uuid.UUID, msg string) error {
+	return s.transition(ctx, id, "failed", EventFailed, msg, `error_msg=$2, last_error=$2, finished_at=now()`)
+}
+
+// RetryJob schedules another attempt of a processing job that failed for a
+// transient reason: it goes back to scheduled with process_after and
+// next_retry_at set to now plus backoff, doubled for every attempt after the
+// first, and the scheduler queues it again then. It returns false, leaving
+// the job alone, when the job used up its attempts or is not processing
+// anymore.
+func (s *DB) RetryJob(ctx context.Context, id uuid.UUID, msg string, backoff time.Duration) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		WITH retried AS (
+			UPDATE audio_jobs j SET status='scheduled', progress=0, started_at=NULL, worker_id=NULL, heartbeat_at=NULL,
+			                        last_error=$2, next_retry_at=now() + make_interval(secs => $3 * power(2, GREATEST(j.attempts - 1, 0))),
+			                        process_after=now() + make_interval(secs => $3 * power(2, GREATEST(j.attempts - 1, 0)))
+			FROM (SELECT id, worker_id FROM audio_jobs WHERE id=$1 FOR UPDATE) old
+			WHERE j.id = old.id AND j.status='processing' AND j.attempts < j.max_attempts
+			RETURNING j.id, old.worker_id, j.next_retry_at
+		)
+		INSERT INTO job_events (job_id, event, detail, worker_id)
+		SELECT id, 'retried', $2 || ' (next try at ' || next_retry_at || ')', worker_id FROM retried
+	`, id, msg, backoff.Seconds())
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// SetExpanded marks a bundle job whose archive has been unpacked into child jobs
+func (s *DB) SetExpanded(ctx context.Context, id uuid.UUID) error {
+	return s.transition(ctx, id, "expanded", EventFinished, "expanded", `progress=100, finished_at=now()`)
+}
+
+// ChildStatusCounts returns the number of child jobs of parentID per status
+func (s *DB) ChildStatusCounts(ctx context.Context, parentID uuid.UUID) (map[string]int, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT status, count(*) FROM audio_jobs WHERE parent_id=$1 AND ($2 = '' OR tenant = $2) GROUP BY status
+	`, parentID, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+// ChildJob is a child of a bundle, found by ChildJobs
+type ChildJob struct {
+	ID          uuid.UUID
+	InputPath   string
+	Status      string
+	OriginalKey *string // nil until its file is uploaded
+}
+
+// ChildJobs returns the children unpacked from the bundle job parentID so far
+func (s *DB) ChildJobs(ctx context.Context, parentID uuid.UUID) ([]ChildJob, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, input_path, status, original_key FROM audio_jobs
+		WHERE parent_id=$1 AND ($2 = '' OR tenant = $2)
+		ORDER BY created_at
+	`, parentID, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ChildJob
+	for rows.Next() {
+		var c ChildJob
+		if err := rows.Scan(&c.ID, &c.InputPath, &c.Status, &c.OriginalKey); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// CancelJob marks a job cancelled unless it already reached a final state.
+// It returns false when the job was done, failed or cancelled already.
+func (s *DB) CancelJob(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		WITH job AS (
+			UPDATE audio_jobs SET status='cancelled', finished_at=now()
+			WHERE id=$1 AND status IN ('scheduled', 'queued', 'processing') AND ($2 = '' OR tenant = $2)
+			RETURNING id, worker_id
+		)
+		INSERT INTO job_events (job_id, event, worker_id) SELECT id, 'cancelled', worker_id FROM job
+	`, id, s.tenant)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// UpdateJobStorage sets s3 bucket/key/version, the checksum and, with S3
+// failover, the endpoint of the output of a job
+func (s *DB) UpdateJobStorage(ctx context.Context, id uuid.UUID, bucket, key, versionID, sha256, endpoint string) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE audio_jobs SET s3_bucket=$2, s3_key=$3, s3_version_id=$4, output_sha256=NULLIF($5, ''), s3_endpoint=NULLIF($6, '')
+		WHERE id=$1
+	`, id, bucket, key, versionID, sha256, endpoint)
 	return err
 }
 
-// UpdateJobStorage sets s3 bucket/key/version for a job
-func (s *Store) UpdateJobStorage(ctx context.Context, id uuid.UUID, bucket, key, versionID string) error {
+// SetOriginal records where the unprocessed input of a job is stored and its
+// checksum; endpoint is the one of storage.UploadInfo, empty without failover
+func (s *DB) SetOriginal(ctx context.Context, id uuid.UUID, bucket, key, sha256, endpoint string) error {
 	_, err := s.pool.Exec(ctx, `
-		UPDATE audio_jobs SET s3_bucket=$2, s3_key=$3, s3_version_id=$4 WHERE id=$1
-	`, id, bucket, key, versionID)
+		UPDATE audio_jobs SET original_bucket=$2, original_key=$3, original_sha256=NULLIF($4, ''), original_endpoint=NULLIF($5, '')
+		WHERE id=$1
+	`, id, bucket, key, sha256, endpoint)
 	return err
 }
 
-// UpdateJobMetadata sets duration and loudness json
-func (s *Store) UpdateJobMetadata(ctx context.Context, id uuid.UUID, duration float64, loudnessJSON string, noiseLevel float64, denoiseMethod string) error {
+// UpdateJobMetadata sets duration and loudness; a nil loudness clears it
+func (s *DB) UpdateJobMetadata(ctx context.Context, id uuid.UUID, duration float64, loudness *audio.Loudness, noiseLevel float64, denoiseMethod string) error {
+	var loudnessJSON []byte
+	if loudness != nil {
+		b, err := json.Marshal(loudness)
+		if err != nil {
+			return err
+		}
+		loudnessJSON = b
+	}
 	_, err := s.pool.Exec(ctx, `
-		UPDATE audio_jobs SET duration_sec=$2, loudness_json=$3, noise_level=$4, denoise_method=$5 WHERE id=$1
+		UPDATE audio_jobs SET duration_sec=$2, loudness=$3::jsonb, noise_level=$4, denoise_method=$5 WHERE id=$1
 	`, id, duration, loudnessJSON, noiseLevel, denoiseMethod)
 	return err
 }
+
+// MergeJobAnalysis adds the given analysis results to analysis_json, replacing
+// existing entries with the same key
+func (s *DB) MergeJobAnalysis(ctx context.Context, id uuid.UUID, analysis map[string]interface{}) error {
+	b, err := json.Marshal(analysis)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(ctx, `
+		UPDATE audio_jobs SET analysis_json = COALESCE(analysis_json, '{}'::jsonb) || $2::jsonb WHERE id=$1
+	`, id, string(b))
+	return err
+}
+
+// Quality are the signal quality measurements of the input and output of a job
+type Quality struct {
+	Before *audio.QualityMetrics `json:"before,omitempty"`
+	After  *audio.QualityMetrics `json:"after,omitempty"` // nil for analyze-only jobs
+}
+
+// SetQuality stores the quality measurements and input loudness of a job in
+// their own columns, to query and graph them per job. An SNR of 0, not enough
+// speech to measure, is stored as NULL.
+func (s *DB) SetQuality(ctx context.Context, id uuid.UUID, q Quality, loudnessBefore *audio.Loudness) error {
+	snr := func(m *audio.QualityMetrics) *float64 {
+		if m == nil || m.SNR == 0 {
+			return nil
+		}
+		return &m.SNR
+	}
+	var loudnessJSON []byte
+	if loudnessBefore != nil {
+		b, err := json.Marshal(loudnessBefore)
+		if err != nil {
+			return err
+		}
+		loudnessJSON = b
+	}
+	qualityJSON, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(ctx, `
+		UPDATE audio_jobs SET quality=$2::jsonb, loudness_before=$3::jsonb, snr_before_db=$4, snr_after_db=$5 WHERE id=$1
+	`, id, qualityJSON, loudnessJSON, snr(q.Before), snr(q.After))
+	return err
+}
+
+// SetJobMOS stores the estimated MOS of the job output
+func (s *DB) SetJobMOS(ctx context.Context, id uuid.UUID, mos float64) error {
+	_, err := s.pool.Exec(ctx, `UPDATE audio_jobs SET mos_score=$2 WHERE id=$1`, id, mos)
+	return err
+}
+
+// SetJobLanguage stores the detected spoken language of the job input
+func (s *DB) SetJobLanguage(ctx context.Context, id uuid.UUID, language string, confidence float64) error {
+	_, err := s.pool.Exec(ctx, `UPDATE audio_jobs SET language=$2, language_confidence=$3 WHERE id=$1`, id, language, confidence)
+	return err
+}
+
+// SetAnswerClass stores whether a person or a machine answered the call
+func (s *DB) SetAnswerClass(ctx context.Context, id uuid.UUID, class string) error {
+	_, err := s.pool.Exec(ctx, `UPDATE audio_jobs SET answer_class=$2 WHERE id=$1`, id, class)
+	return err
+}
+
+// SetEchoScore stores the echo estimate of the job input
+func (s *DB) SetEchoScore(ctx context.Context, id uuid.UUID, score float64) error {
+	_, err := s.pool.Exec(ctx, `UPDATE audio_jobs SET echo_score=$2 WHERE id=$1`, id, score)
+	return err
+}
+
+// SetMediaInfo stores the probed format metadata of the job input
+func (s *DB) SetMediaInfo(ctx context.Context, id uuid.UUID, info interface{}) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(ctx, `UPDATE audio_jobs SET media_info=$2::jsonb WHERE id=$1`, id, string(b))
+	return err
+}
+
+// SetKeywordHits stores how many keyword list matches the job transcript has
+func (s *DB) SetKeywordHits(ctx context.Context, id uuid.UUID, hits int) error {
+	_, err := s.pool.Exec(ctx, `UPDATE audio_jobs SET keyword_hits=$2 WHERE id=$1`, id, hits)
+	return err
+}
+
+// ReleaseDueJobs moves scheduled jobs whose process_after has passed to queued
+// and returns them, most urgent first, so the caller can publish their payload.
+// When more than limit are due the most urgent go first, in the order they
+// became due.
+func (s *DB) ReleaseDueJobs(ctx context.Context, limit int) ([]RequeuedJob, error) {
+	rows, err := s.pool.Query(ctx, `
+		WITH released AS (
+			UPDATE audio_jobs SET status='queued'
+			WHERE id IN (
+				SELECT id FROM audio_jobs
+				WHERE status='scheduled' AND process_after <= now()
+				ORDER BY priority_rank, process_after
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, payload, priority_rank, process_after
+		), events AS (
+			INSERT INTO job_events (job_id, event, detail) SELECT id, 'queued', 'process_after reached' FROM released
+		)
+		SELECT id, payload FROM released
+		ORDER BY priority_rank, process_after
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []RequeuedJob
+	for rows.Next() {
+		var rj RequeuedJob
+		if err := rows.Scan(&rj.ID, &rj.Payload); err != nil {
+			return nil, err
+		}
+		out = append(out, rj)
+	}
+	return out, rows.Err()
+}
+
+// OriginalInUse tells whether a job keeps its original in bucket under key
+func (s *DB) OriginalInUse(ctx context.Context, bucket, key string) (bool, error) {
+	var used bool
+	err := s.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM audio_jobs WHERE original_bucket=$1 AND original_key=$2)
+	`, bucket, key).Scan(&used)
+	return used, err
+}
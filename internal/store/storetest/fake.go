@@ -0,0 +1,668 @@
+// Package storetest provides an in-memory store.Store for tests of the API and
+// the workers that need no database
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/audio"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/queue"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// Fake keeps jobs with their events, outputs, transcripts and fingerprints,
+// batches, the purge and erase audits, partitions, tenants, users, API keys and
+// workers in memory, following store.DB: claims in priority then FIFO order,
+// retries, legal status transitions, cancellation and tenant scoping. Like
+// store.DB, it returns pgx.ErrNoRows for jobs that are not found.
+type Fake struct {
+	d      *data
+	tenant string
+}
+
+var _ store.Store = (*Fake)(nil)
+
+type data struct {
+	mu          sync.Mutex
+	seq         int
+	jobs        map[uuid.UUID]*job
+	events      map[uuid.UUID][]store.JobEvent
+	outputs     map[uuid.UUID]map[string]store.JobOutput
+	transcripts map[uuid.UUID]*store.StoredTranscript
+	batches     map[uuid.UUID]*store.Batch
+
+	fingerprints  map[uuid.UUID]*fingerprint
+	purges        []store.PurgeRecord
+	erasures      []store.EraseRecord
+	partitions    map[time.Time]bool // months of the partitions of audio_jobs
+	unpartitioned bool               // audio_jobs as before migration 040
+	tenants       map[string]*store.Tenant
+	users         map[uuid.UUID]*store.User
+	keys          map[string]*store.APIKey // by the key itself
+	workers       map[string]*store.WorkerRecord
+}
+
+// job is a job of the fake with what store.Job doesn't show
+type job struct {
+	store.Job
+	seq         int // creation order, for FIFO among jobs created in the same instant
+	optionsHash string
+	payload     []byte
+}
+
+// NewFake returns an empty, unscoped Fake
+func NewFake() *Fake {
+	return &Fake{d: &data{
+		jobs:        map[uuid.UUID]*job{},
+		events:      map[uuid.UUID][]store.JobEvent{},
+		outputs:     map[uuid.UUID]map[string]store.JobOutput{},
+		transcripts: map[uuid.UUID]*store.StoredTranscript{},
+		batches:     map[uuid.UUID]*store.Batch{},
+
+		fingerprints: map[uuid.UUID]*fingerprint{},
+		partitions:   map[time.Time]bool{},
+		tenants:      map[string]*store.Tenant{},
+		users:        map[uuid.UUID]*store.User{},
+		keys:         map[string]*store.APIKey{},
+		workers:      map[string]*store.WorkerRecord{},
+	}}
+}
+
+// ForTenant returns a fake seeing the jobs of tenant only, sharing the data
+func (f *Fake) ForTenant(tenant string) store.Store {
+	c := *f
+	c.tenant = tenant
+	return &c
+}
+
+func (f *Fake) Tenant() string {
+	return f.tenant
+}
+
+// Events returns the events recorded for a job, whatever its tenant
+func (f *Fake) Events(id uuid.UUID) []store.JobEvent {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	return slices.Clone(f.d.events[id])
+}
+
+// Edit applies fn to a job, whatever its tenant, for tests to set up what no
+// store method sets, e.g. a job that finished long ago; pgx.ErrNoRows when
+// there is no such job
+func (f *Fake) Edit(id uuid.UUID, fn func(j *store.Job)) error {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	j, ok := f.d.jobs[id]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	fn(&j.Job)
+	return nil
+}
+
+// visible tells whether the scoped tenant sees j
+func (f *Fake) visible(j *job) bool {
+	return f.tenant == "" || (j.Tenant != nil && *j.Tenant == f.tenant)
+}
+
+// get returns a job the scoped tenant sees; the caller holds the lock
+func (f *Fake) get(id uuid.UUID) (*job, error) {
+	j, ok := f.d.jobs[id]
+	if !ok || !f.visible(j) {
+		return nil, pgx.ErrNoRows
+	}
+	return j, nil
+}
+
+// event records an event of j; the caller holds the lock
+func (f *Fake) event(j *job, event, detail string) {
+	e := store.JobEvent{Event: event, WorkerID: j.WorkerID, CreatedAt: time.Now()}
+	if detail != "" {
+		e.Detail = &detail
+	}
+	f.d.events[j.ID] = append(f.d.events[j.ID], e)
+}
+
+// update applies fn to a job and records event for it; pgx.ErrNoRows when
+// there is no such job, as from DB.transition
+func (f *Fake) update(id uuid.UUID, event, detail string, fn func(j *job)) error {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	j, ok := f.d.jobs[id]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	fn(j)
+	if event != "" {
+		f.event(j, event, detail)
+	}
+	return nil
+}
+
+// transition moves a job to status to, applying fn, and records event for it;
+// like DB.transition, a store.TransitionError when the job is not in a status
+// it may move to to from
+func (f *Fake) transition(id uuid.UUID, to, event, detail string, fn func(j *job)) error {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	j, ok := f.d.jobs[id]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	if !store.CanTransition(j.Status, to) {
+		return &store.TransitionError{ID: id, From: j.Status, To: to}
+	}
+	j.Status = to
+	fn(j)
+	if event != "" {
+		f.event(j, event, detail)
+	}
+	return nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// nonEmpty is NULLIF(s, ”)
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func (f *Fake) CreateJob(ctx context.Context, nj store.NewJob) (uuid.UUID, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	if nj.Kind == "" {
+		nj.Kind = "audio"
+	}
+	if nj.Tenant == "" {
+		nj.Tenant = f.tenant
+	}
+	if nj.Priority == "" {
+		nj.Priority = queue.PriorityNormal
+	}
+	now := time.Now()
+	f.d.seq++
+	j := &job{
+		Job: store.Job{
+			ID:             uuid.New(),
+			InputPath:      nj.InputPath,
+			OutputPath:     nj.OutputPath,
+			Status:         "queued",
+			Priority:       nj.Priority,
+			Kind:           nj.Kind,
+			ParentID:       nj.ParentID,
+			BatchID:        nj.BatchID,
+			MaxAttempts:    3,
+			Tenant:         nonEmpty(nj.Tenant),
+			OwnerID:        nj.OwnerID,
+			RetentionClass: nonEmpty(nj.RetentionClass),
+			CallerRef:      nonEmpty(nj.CallerRef),
+			Labels:         maps.Clone(nj.Labels),
+			ContentHash:    nonEmpty(nj.ContentHash),
+			ProcessAfter:   nj.ProcessAfter,
+			CreatedAt:      now,
+		},
+		seq:         f.d.seq,
+		optionsHash: nj.OptionsHash,
+	}
+	if nj.ProcessAfter != nil && nj.ProcessAfter.After(now) {
+		j.Status = "scheduled"
+	}
+	f.d.jobs[j.ID] = j
+	f.event(j, j.Status, "")
+	return j.ID, nil
+}
+
+func (f *Fake) GetJob(ctx context.Context, id uuid.UUID) (*store.Job, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	j, err := f.get(id)
+	if err != nil {
+		return nil, err
+	}
+	out := j.Job
+	return &out, nil
+}
+
+func (f *Fake) ListJobs(ctx context.Context, q store.JobFilter) ([]store.JobSummary, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var jobs []*job
+	for _, j := range f.d.jobs {
+		switch {
+		case !f.visible(j),
+			q.Status != "" && j.Status != q.Status,
+			!q.Since.IsZero() && j.CreatedAt.Before(q.Since),
+			q.BatchID != nil && (j.BatchID == nil || *j.BatchID != *q.BatchID):
+			continue
+		}
+		if !containsLabels(j.Labels, q.Labels) {
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	slices.SortFunc(jobs, func(a, b *job) int { return b.seq - a.seq })
+	if q.Limit > 0 && len(jobs) > q.Limit {
+		jobs = jobs[:q.Limit]
+	}
+	out := []store.JobSummary{}
+	for _, j := range jobs {
+		out = append(out, store.JobSummary{
+			ID: j.ID, Status: j.Status, Priority: j.Priority, Kind: j.Kind, ParentID: j.ParentID, BatchID: j.BatchID,
+			Tenant: j.Tenant, Labels: j.Labels, DurationSec: j.Duration, CreatedAt: j.CreatedAt, FinishedAt: j.FinishedAt,
+		})
+	}
+	return out, nil
+}
+
+// containsLabels is labels @> want
+func containsLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *Fake) FindDoneByHash(ctx context.Context, contentHash, optionsHash string) (*store.Job, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var found *job
+	for _, j := range f.d.jobs {
+		if !f.visible(j) || j.ContentHash == nil || *j.ContentHash != contentHash || j.optionsHash != optionsHash ||
+			(j.Status != "done" && j.Status != "completed_with_warnings") {
+			continue
+		}
+		if found == nil || j.FinishedAt.After(*found.FinishedAt) {
+			found = j
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+	out := found.Job
+	return &out, nil
+}
+
+func (f *Fake) ChildStatusCounts(ctx context.Context, parentID uuid.UUID) (map[string]int, error) {
+	return f.countBy(func(j *job) bool { return j.ParentID != nil && *j.ParentID == parentID }), nil
+}
+
+func (f *Fake) ChildJobs(ctx context.Context, parentID uuid.UUID) ([]store.ChildJob, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var out []store.ChildJob
+	for _, j := range f.d.jobs {
+		if f.visible(j) && j.ParentID != nil && *j.ParentID == parentID {
+			out = append(out, store.ChildJob{ID: j.ID, InputPath: j.InputPath, Status: j.Status, OriginalKey: j.OriginalKey})
+		}
+	}
+	slices.SortFunc(out, func(a, b store.ChildJob) int { return f.d.jobs[a.ID].seq - f.d.jobs[b.ID].seq })
+	return out, nil
+}
+
+func (f *Fake) countBy(match func(j *job) bool) map[string]int {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	counts := map[string]int{}
+	for _, j := range f.d.jobs {
+		if f.visible(j) && match(j) {
+			counts[j.Status]++
+		}
+	}
+	return counts
+}
+
+func (f *Fake) SetPayload(ctx context.Context, id uuid.UUID, payload []byte) error {
+	return f.update(id, "", "", func(j *job) { j.payload = slices.Clone(payload) })
+}
+
+func (f *Fake) SetStarted(ctx context.Context, id uuid.UUID) error {
+	return f.transition(id, "processing", "", "", func(j *job) { j.StartedAt = ptr(time.Now()) })
+}
+
+// claim moves j to processing for workerID; the caller holds the lock
+func (f *Fake) claim(j *job, workerID string) {
+	now := time.Now()
+	j.Status, j.StartedAt, j.WorkerID, j.HeartbeatAt, j.NextRetryAt = "processing", &now, &workerID, &now, nil
+	j.Attempts++
+	f.event(j, store.EventClaimed, "attempt "+strconv.Itoa(j.Attempts))
+}
+
+func (f *Fake) ClaimJob(ctx context.Context, id uuid.UUID, workerID string) (bool, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	j, ok := f.d.jobs[id]
+	if !ok || j.Status != "queued" {
+		return false, nil
+	}
+	f.claim(j, workerID)
+	return true, nil
+}
+
+// ClaimNextJob claims the most urgent, then oldest, queued job with a payload
+// whose denoise method is in methods (nil for any) and not in exclude
+func (f *Fake) ClaimNextJob(ctx context.Context, workerID string, methods, exclude []string) (*store.ClaimedJob, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var next *job
+	for _, j := range f.d.jobs {
+		if j.Status != "queued" || j.payload == nil {
+			continue
+		}
+		var p struct {
+			DenoiseMethod string `json:"denoise_method"`
+		}
+		json.Unmarshal(j.payload, &p)
+		method := strings.ToLower(p.DenoiseMethod)
+		if method == "" {
+			method = "default"
+		}
+		if (methods != nil && !slices.Contains(methods, method)) || slices.Contains(exclude, method) {
+			continue
+		}
+		if next == nil || queue.Rank(j.Priority) < queue.Rank(next.Priority) ||
+			(queue.Rank(j.Priority) == queue.Rank(next.Priority) && j.seq < next.seq) {
+			next = j
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+	f.claim(next, workerID)
+	return &store.ClaimedJob{ID: next.ID, Payload: next.payload, Attempts: next.Attempts}, nil
+}
+
+func (f *Fake) Heartbeat(ctx context.Context, id uuid.UUID, workerID string) error {
+	return f.update(id, "", "", func(j *job) {
+		if j.Status == "processing" && j.WorkerID != nil && *j.WorkerID == workerID {
+			j.HeartbeatAt = ptr(time.Now())
+		}
+	})
+}
+
+func (f *Fake) UpdateProgress(ctx context.Context, id uuid.UUID, progress int) error {
+	return f.update(id, "", "", func(j *job) { j.Progress = progress })
+}
+
+func (f *Fake) SetFinished(ctx context.Context, id uuid.UUID) error {
+	return f.transition(id, "done", store.EventFinished, "", func(j *job) {
+		j.Progress, j.FinishedAt = 100, ptr(time.Now())
+	})
+}
+
+func (f *Fake) SetFinishedWithWarnings(ctx context.Context, id uuid.UUID, msg string) error {
+	return f.transition(id, "completed_with_warnings", store.EventFinished, msg, func(j *job) {
+		j.Progress, j.ErrorMsg, j.FinishedAt = 100, &msg, ptr(time.Now())
+	})
+}
+
+func (f *Fake) SetRejected(ctx context.Context, id uuid.UUID, code, msg string) error {
+	return f.transition(id, "failed", store.EventFailed, code+": "+msg, func(j *job) {
+		j.ErrorCode, j.ErrorMsg, j.LastError, j.FinishedAt = &code, &msg, &msg, ptr(time.Now())
+	})
+}
+
+func (f *Fake) SetFailed(ctx context.Context, id uuid.UUID, msg string) error {
+	return f.transition(id, "failed", store.EventFailed, msg, func(j *job) {
+		j.ErrorMsg, j.LastError, j.FinishedAt = &msg, &msg, ptr(time.Now())
+	})
+}
+
+// RetryJob schedules another attempt of a processing job with attempts left,
+// backoff doubled for every attempt after the first
+func (f *Fake) RetryJob(ctx context.Context, id uuid.UUID, msg string, backoff time.Duration) (bool, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	j, ok := f.d.jobs[id]
+	if !ok || j.Status != "processing" || j.Attempts >= j.MaxAttempts {
+		return false, nil
+	}
+	next := time.Now().Add(backoff * time.Duration(math.Pow(2, float64(max(j.Attempts-1, 0)))))
+	f.event(j, store.EventRetried, msg+" (next try at "+next.Format(time.RFC3339)+")")
+	j.Status, j.Progress, j.StartedAt, j.WorkerID, j.HeartbeatAt = "scheduled", 0, nil, nil, nil
+	j.LastError, j.NextRetryAt, j.ProcessAfter = &msg, &next, &next
+	return true, nil
+}
+
+func (f *Fake) SetExpanded(ctx context.Context, id uuid.UUID) error {
+	return f.transition(id, "expanded", store.EventFinished, "expanded", func(j *job) {
+		j.Progress, j.FinishedAt = 100, ptr(time.Now())
+	})
+}
+
+func (f *Fake) CancelJob(ctx context.Context, id uuid.UUID) (bool, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	j, err := f.get(id)
+	if err != nil || !slices.Contains([]string{"scheduled", "queued", "processing"}, j.Status) {
+		return false, nil
+	}
+	j.Status, j.FinishedAt = "cancelled", ptr(time.Now())
+	f.event(j, store.EventCancelled, "")
+	return true, nil
+}
+
+// RequeueOrphaned puts processing jobs without a heartbeat for staleAfter
+// back to queued, or fails them when they used up their attempts
+func (f *Fake) RequeueOrphaned(ctx context.Context, staleAfter time.Duration) ([]store.RequeuedJob, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var requeued []*job
+	var workers []string
+	for _, j := range f.d.jobs {
+		last := j.HeartbeatAt
+		if last == nil {
+			last = j.StartedAt
+		}
+		if j.Status != "processing" || last == nil || time.Since(*last) < staleAfter {
+			continue
+		}
+		const reason = "worker stopped heartbeating"
+		if j.Attempts >= j.MaxAttempts {
+			j.Status, j.ErrorMsg, j.LastError, j.FinishedAt = "failed", ptr(reason+" on every attempt"), ptr(reason), ptr(time.Now())
+			f.event(j, store.EventFailed, reason+" on every attempt")
+			continue
+		}
+		f.event(j, store.EventRetried, reason)
+		requeued = append(requeued, j)
+		workers = append(workers, deref(j.WorkerID))
+		j.Status, j.Progress, j.StartedAt, j.WorkerID, j.HeartbeatAt, j.LastError = "queued", 0, nil, nil, nil, ptr(reason)
+	}
+	return f.inOrder(requeued, workers), nil
+}
+
+// ReleaseDueJobs queues up to limit scheduled jobs whose process_after passed
+func (f *Fake) ReleaseDueJobs(ctx context.Context, limit int) ([]store.RequeuedJob, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var due []*job
+	for _, j := range f.d.jobs {
+		if j.Status == "scheduled" && j.ProcessAfter != nil && !j.ProcessAfter.After(time.Now()) {
+			due = append(due, j)
+		}
+	}
+	out := f.inOrder(due, nil)
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	for _, rj := range out {
+		j := f.d.jobs[rj.ID]
+		j.Status = "queued"
+		f.event(j, store.EventQueued, "process_after reached")
+	}
+	return out, nil
+}
+
+// inOrder returns jobs most urgent, then oldest first, with the workers they
+// were taken from; the caller holds the lock
+func (f *Fake) inOrder(jobs []*job, workers []string) []store.RequeuedJob {
+	out := make([]store.RequeuedJob, len(jobs))
+	for i, j := range jobs {
+		out[i] = store.RequeuedJob{ID: j.ID, Payload: j.payload}
+		if workers != nil {
+			out[i].WorkerID = workers[i]
+		}
+	}
+	slices.SortStableFunc(out, func(a, b store.RequeuedJob) int {
+		ja, jb := f.d.jobs[a.ID], f.d.jobs[b.ID]
+		if ra, rb := queue.Rank(ja.Priority), queue.Rank(jb.Priority); ra != rb {
+			return ra - rb
+		}
+		return ja.seq - jb.seq
+	})
+	return out
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func (f *Fake) AddJobEvent(ctx context.Context, id uuid.UUID, event, detail, workerID string) error {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	f.d.events[id] = append(f.d.events[id], store.JobEvent{Event: event, Detail: nonEmpty(detail), WorkerID: nonEmpty(workerID), CreatedAt: time.Now()})
+	return nil
+}
+
+func (f *Fake) ListJobEvents(ctx context.Context, id uuid.UUID) ([]store.JobEvent, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	if _, err := f.get(id); err != nil {
+		return []store.JobEvent{}, nil
+	}
+	return append([]store.JobEvent{}, f.d.events[id]...), nil
+}
+
+func (f *Fake) UpdateJobStorage(ctx context.Context, id uuid.UUID, bucket, key, versionID, sha256, endpoint string) error {
+	return f.update(id, "", "", func(j *job) {
+		j.S3Bucket, j.S3Key, j.S3Version, j.OutputSHA256, j.S3Endpoint = &bucket, &key, nonEmpty(versionID), nonEmpty(sha256), nonEmpty(endpoint)
+	})
+}
+
+func (f *Fake) SetOriginal(ctx context.Context, id uuid.UUID, bucket, key, sha256, endpoint string) error {
+	return f.update(id, "", "", func(j *job) {
+		j.OriginalBucket, j.OriginalKey, j.OriginalSHA256, j.OriginalEndpoint = &bucket, &key, nonEmpty(sha256), nonEmpty(endpoint)
+	})
+}
+
+func (f *Fake) AddJobOutput(ctx context.Context, o store.JobOutput) error {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	if f.d.outputs[o.JobID] == nil {
+		f.d.outputs[o.JobID] = map[string]store.JobOutput{}
+	}
+	o.CreatedAt = time.Now()
+	f.d.outputs[o.JobID][o.Name] = o
+	return nil
+}
+
+func (f *Fake) ListJobOutputs(ctx context.Context, jobID uuid.UUID) ([]store.JobOutput, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	if _, err := f.get(jobID); err != nil {
+		return nil, nil
+	}
+	var out []store.JobOutput
+	for _, name := range slices.Sorted(maps.Keys(f.d.outputs[jobID])) {
+		out = append(out, f.d.outputs[jobID][name])
+	}
+	return out, nil
+}
+
+func (f *Fake) UpdateJobMetadata(ctx context.Context, id uuid.UUID, duration float64, loudness *audio.Loudness, noiseLevel float64, denoiseMethod string) error {
+	return f.update(id, "", "", func(j *job) {
+		j.Duration, j.Loudness, j.DenoiseMethod = &duration, loudness, &denoiseMethod
+		j.NoiseLevel.Float64, j.NoiseLevel.Valid = noiseLevel, true
+	})
+}
+
+// MergeJobAnalysis adds the analysis results to the job's, replacing entries
+// with the same key
+func (f *Fake) MergeJobAnalysis(ctx context.Context, id uuid.UUID, analysis map[string]interface{}) error {
+	var err error
+	if uerr := f.update(id, "", "", func(j *job) {
+		merged := map[string]json.RawMessage{}
+		if j.Analysis != nil {
+			if err = json.Unmarshal(j.Analysis, &merged); err != nil {
+				return
+			}
+		}
+		for k, v := range analysis {
+			if merged[k], err = json.Marshal(v); err != nil {
+				return
+			}
+		}
+		j.Analysis, err = json.Marshal(merged)
+	}); uerr != nil {
+		return uerr
+	}
+	return err
+}
+
+func (f *Fake) SaveTranscript(ctx context.Context, jobID uuid.UUID, tr *audio.Transcript) error {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	f.d.transcripts[jobID] = &store.StoredTranscript{JobID: jobID, CreatedAt: time.Now(), Transcript: *tr}
+	return nil
+}
+
+func (f *Fake) GetTranscript(ctx context.Context, jobID uuid.UUID) (*store.StoredTranscript, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	t, ok := f.d.transcripts[jobID]
+	if _, err := f.get(jobID); err != nil || !ok {
+		return nil, pgx.ErrNoRows
+	}
+	out := *t
+	return &out, nil
+}
+
+func (f *Fake) CreateBatch(ctx context.Context, nb store.NewBatch) (*store.Batch, error) {
+	if nb.ID == uuid.Nil {
+		nb.ID = uuid.New()
+	}
+	if nb.Tenant == "" {
+		nb.Tenant = f.tenant
+	}
+	f.d.mu.Lock()
+	if _, ok := f.d.batches[nb.ID]; !ok {
+		f.d.batches[nb.ID] = &store.Batch{ID: nb.ID, Kind: nb.Kind, Name: nonEmpty(nb.Name), Tenant: nonEmpty(nb.Tenant), OwnerID: nb.OwnerID, CreatedAt: time.Now()}
+	}
+	f.d.mu.Unlock()
+	return f.GetBatch(ctx, nb.ID)
+}
+
+func (f *Fake) GetBatch(ctx context.Context, id uuid.UUID) (*store.Batch, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	b, ok := f.d.batches[id]
+	if !ok || (f.tenant != "" && deref(b.Tenant) != f.tenant) {
+		return nil, pgx.ErrNoRows
+	}
+	out := *b
+	return &out, nil
+}
+
+func (f *Fake) BatchStatusCounts(ctx context.Context, id uuid.UUID) (map[string]int, error) {
+	return f.countBy(func(j *job) bool { return j.BatchID != nil && *j.BatchID == id }), nil
+}
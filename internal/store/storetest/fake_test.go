@@ -0,0 +1,101 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+func TestTransitions(t *testing.T) {
+	ctx := context.Background()
+	statuses := []string{"scheduled", "queued", "processing", "done", "completed_with_warnings", "expanded", "failed", "cancelled"}
+	updates := []struct {
+		to     string
+		update func(st *Fake, id uuid.UUID) error
+	}{
+		{"processing", func(st *Fake, id uuid.UUID) error { return st.SetStarted(ctx, id) }},
+		{"done", func(st *Fake, id uuid.UUID) error { return st.SetFinished(ctx, id) }},
+		{"completed_with_warnings", func(st *Fake, id uuid.UUID) error { return st.SetFinishedWithWarnings(ctx, id, "low snr") }},
+		{"expanded", func(st *Fake, id uuid.UUID) error { return st.SetExpanded(ctx, id) }},
+		{"failed", func(st *Fake, id uuid.UUID) error { return st.SetFailed(ctx, id, "boom") }},
+		{"failed", func(st *Fake, id uuid.UUID) error { return st.SetRejected(ctx, id, "too_long", "too long") }},
+	}
+	for _, u := range updates {
+		for _, from := range statuses {
+			st := NewFake()
+			id, err := st.CreateJob(ctx, store.NewJob{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			st.Edit(id, func(j *store.Job) { j.Status = from })
+
+			err = u.update(st, id)
+			j, _ := st.GetJob(ctx, id)
+			if store.CanTransition(from, u.to) {
+				if err != nil || j.Status != u.to {
+					t.Errorf("%s -> %s: status %s, err %v", from, u.to, j.Status, err)
+				}
+				continue
+			}
+			var te *store.TransitionError
+			if !errors.As(err, &te) || !errors.Is(err, store.ErrConflict) {
+				t.Errorf("%s -> %s: err %v, want a TransitionError", from, u.to, err)
+			} else if te.ID != id || te.From != from || te.To != u.to {
+				t.Errorf("%s -> %s: %+v", from, u.to, te)
+			}
+			if j.Status != from {
+				t.Errorf("%s -> %s refused but the job is %s", from, u.to, j.Status)
+			}
+		}
+
+		if err := u.update(NewFake(), uuid.New()); !errors.Is(err, pgx.ErrNoRows) {
+			t.Errorf("unknown job -> %s: err %v, want pgx.ErrNoRows", u.to, err)
+		}
+	}
+}
+
+func TestForTenant(t *testing.T) {
+	ctx := context.Background()
+	st := NewFake()
+	acme, globex := st.ForTenant("acme"), st.ForTenant("globex")
+	id, err := acme.CreateJob(ctx, store.NewJob{ContentHash: "h", OptionsHash: "o"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Edit(id, func(j *store.Job) { j.Status = "done" })
+	batch, err := acme.CreateBatch(ctx, store.NewBatch{Kind: store.BatchSubmission})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, s := range map[string]store.Store{"acme": acme, "unscoped": st} {
+		j, err := s.GetJob(ctx, id)
+		if err != nil || j.Tenant == nil || *j.Tenant != "acme" {
+			t.Errorf("%s: job %+v, %v, want the job of acme", name, j, err)
+		}
+		if found, _ := s.FindDoneByHash(ctx, "h", "o"); found == nil || found.ID != id {
+			t.Errorf("%s: dedupe found %+v", name, found)
+		}
+		if b, err := s.GetBatch(ctx, batch.ID); err != nil || b.Tenant == nil || *b.Tenant != "acme" {
+			t.Errorf("%s: batch %+v, %v, want the batch of acme", name, b, err)
+		}
+	}
+
+	if _, err := globex.GetJob(ctx, id); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("job of acme for globex: err %v, want pgx.ErrNoRows", err)
+	}
+	if jobs, _ := globex.ListJobs(ctx, store.JobFilter{}); len(jobs) != 0 {
+		t.Errorf("globex lists %d jobs of acme", len(jobs))
+	}
+	if found, _ := globex.FindDoneByHash(ctx, "h", "o"); found != nil {
+		t.Errorf("globex dedupes against the job of acme")
+	}
+	if _, err := globex.GetBatch(ctx, batch.ID); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("batch of acme for globex: err %v, want pgx.ErrNoRows", err)
+	}
+}
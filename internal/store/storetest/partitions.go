@@ -0,0 +1,107 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// month is the first instant of the UTC month of t
+func month(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func partitionName(m time.Time) string {
+	return "audio_jobs_" + m.Format("2006_01")
+}
+
+// SetPartitioned makes the jobs table partitioned or not, as before migration
+// 040; a new fake is partitioned
+func (f *Fake) SetPartitioned(partitioned bool) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	f.d.unpartitioned = !partitioned
+}
+
+func (f *Fake) JobsPartitioned(ctx context.Context) (bool, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	return !f.d.unpartitioned, nil
+}
+
+// Partitions returns the monthly partitions, oldest first, with the exact
+// number of jobs created in their month
+func (f *Fake) Partitions(ctx context.Context) ([]store.Partition, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var out []store.Partition
+	for _, m := range slices.SortedFunc(maps.Keys(f.d.partitions), time.Time.Compare) {
+		p := store.Partition{Name: partitionName(m), Month: m}
+		for _, j := range f.d.jobs {
+			if month(j.CreatedAt).Equal(m) {
+				p.Rows++
+			}
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// CreatePartitions fails like store.DB while the table is not partitioned
+func (f *Fake) CreatePartitions(ctx context.Context, from time.Time, ahead int) ([]string, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	if f.d.unpartitioned {
+		return nil, errors.New("function audio_jobs_create_partition(date) does not exist")
+	}
+	var created []string
+	for i := 0; i <= ahead; i++ {
+		m := month(from).AddDate(0, i, 0)
+		if !f.d.partitions[m] {
+			f.d.partitions[m] = true
+			created = append(created, partitionName(m))
+		}
+	}
+	return created, nil
+}
+
+// DropPartitions drops the partitions whose month ended by cutoff, with their
+// jobs and what is recorded about them, unless they hold a job not yet purged
+func (f *Fake) DropPartitions(ctx context.Context, cutoff time.Time) (dropped, kept []string, err error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	for _, m := range slices.SortedFunc(maps.Keys(f.d.partitions), time.Time.Compare) {
+		if m.AddDate(0, 1, 0).After(cutoff) {
+			break
+		}
+		var ids []uuid.UUID
+		unpurged := false
+		for _, j := range f.d.jobs {
+			if month(j.CreatedAt).Equal(m) {
+				ids = append(ids, j.ID)
+				unpurged = unpurged || j.PurgedAt == nil
+			}
+		}
+		if unpurged {
+			kept = append(kept, partitionName(m))
+			continue
+		}
+		for _, id := range ids {
+			delete(f.d.jobs, id)
+			delete(f.d.events, id)
+			delete(f.d.outputs, id)
+			delete(f.d.transcripts, id)
+			delete(f.d.fingerprints, id)
+		}
+		delete(f.d.partitions, m)
+		dropped = append(dropped, partitionName(m))
+	}
+	return dropped, kept, nil
+}
@@ -0,0 +1,128 @@
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/audio"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// fingerprint is a stored fingerprint with its save order, for newest first
+type fingerprint struct {
+	store.Fingerprint
+	seq int
+}
+
+// SetQuality stores the quality measurements; like DB.SetQuality, the SNR gain
+// is only known when both SNRs were measured
+func (f *Fake) SetQuality(ctx context.Context, id uuid.UUID, q store.Quality, loudnessBefore *audio.Loudness) error {
+	return f.update(id, "", "", func(j *job) {
+		j.Quality, j.LoudnessBefore, j.SNRGain = &q, loudnessBefore, nil
+		if q.Before != nil && q.After != nil && q.Before.SNR != 0 && q.After.SNR != 0 {
+			j.SNRGain = ptr(q.After.SNR - q.Before.SNR)
+		}
+	})
+}
+
+func (f *Fake) SetJobMOS(ctx context.Context, id uuid.UUID, mos float64) error {
+	return f.update(id, "", "", func(j *job) { j.MOS = &mos })
+}
+
+func (f *Fake) SetJobLanguage(ctx context.Context, id uuid.UUID, language string, confidence float64) error {
+	return f.update(id, "", "", func(j *job) { j.Language, j.LanguageConf = &language, &confidence })
+}
+
+func (f *Fake) SetAnswerClass(ctx context.Context, id uuid.UUID, class string) error {
+	return f.update(id, "", "", func(j *job) { j.AnswerClass = &class })
+}
+
+func (f *Fake) SetEchoScore(ctx context.Context, id uuid.UUID, score float64) error {
+	return f.update(id, "", "", func(j *job) { j.EchoScore = &score })
+}
+
+func (f *Fake) SetMediaInfo(ctx context.Context, id uuid.UUID, info interface{}) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return f.update(id, "", "", func(j *job) { j.MediaInfo = b })
+}
+
+func (f *Fake) SetKeywordHits(ctx context.Context, id uuid.UUID, hits int) error {
+	return f.update(id, "", "", func(j *job) { j.KeywordHits = &hits })
+}
+
+func (f *Fake) SetTalkTime(ctx context.Context, id uuid.UUID, t store.TalkTime) error {
+	return f.update(id, "", "", func(j *job) { j.Talk = &t })
+}
+
+// ListDeadAirJobs returns the jobs with at least minPct percent dead air
+// carrying all of labels, worst then newest first
+func (f *Fake) ListDeadAirJobs(ctx context.Context, minPct float64, labels map[string]string, limit int) ([]store.DeadAirJob, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var jobs []*job
+	for _, j := range f.d.jobs {
+		if f.visible(j) && j.Talk != nil && j.Talk.DeadAirPct >= minPct && containsLabels(j.Labels, labels) {
+			jobs = append(jobs, j)
+		}
+	}
+	slices.SortFunc(jobs, func(a, b *job) int {
+		if a.Talk.DeadAirPct != b.Talk.DeadAirPct {
+			if a.Talk.DeadAirPct > b.Talk.DeadAirPct {
+				return -1
+			}
+			return 1
+		}
+		return b.seq - a.seq
+	})
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	var out []store.DeadAirJob
+	for _, j := range jobs {
+		out = append(out, store.DeadAirJob{ID: j.ID, Status: j.Status, CreatedAt: j.CreatedAt, DurationSec: j.Duration, TalkTime: *j.Talk})
+	}
+	return out, nil
+}
+
+func (f *Fake) SaveFingerprint(ctx context.Context, id uuid.UUID, durationSec float64, fp []uint32) error {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	f.d.seq++
+	f.d.fingerprints[id] = &fingerprint{
+		Fingerprint: store.Fingerprint{JobID: id, DurationSec: durationSec, Print: slices.Clone(fp)},
+		seq:         f.d.seq,
+	}
+	return nil
+}
+
+// FingerprintCandidates returns the fingerprints of other jobs whose input
+// duration is within tolerance of durationSec, newest first
+func (f *Fake) FingerprintCandidates(ctx context.Context, id uuid.UUID, durationSec, tolerance float64, limit int) ([]store.Fingerprint, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var fps []*fingerprint
+	for _, fp := range f.d.fingerprints {
+		if fp.JobID != id && fp.DurationSec >= durationSec-tolerance && fp.DurationSec <= durationSec+tolerance {
+			fps = append(fps, fp)
+		}
+	}
+	slices.SortFunc(fps, func(a, b *fingerprint) int { return b.seq - a.seq })
+	if len(fps) > limit {
+		fps = fps[:limit]
+	}
+	var out []store.Fingerprint
+	for _, fp := range fps {
+		out = append(out, store.Fingerprint{JobID: fp.JobID, DurationSec: fp.DurationSec, Print: slices.Clone(fp.Print)})
+	}
+	return out, nil
+}
+
+func (f *Fake) SetDuplicateOf(ctx context.Context, id, of uuid.UUID) error {
+	return f.update(id, "", "", func(j *job) { j.DuplicateOf = &of })
+}
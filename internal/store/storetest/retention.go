@@ -0,0 +1,261 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/retention"
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// final tells whether a job is in a status it never leaves
+func (j *job) final() bool {
+	return slices.Contains([]string{"done", "completed_with_warnings", "failed", "cancelled", "expanded"}, j.Status)
+}
+
+// finished is COALESCE(finished_at, created_at)
+func (j *job) finished() time.Time {
+	if j.FinishedAt != nil {
+		return *j.FinishedAt
+	}
+	return j.CreatedAt
+}
+
+// objects returns the objects stored for j; the caller holds the lock
+func (f *Fake) objects(j *job) []store.StoredObject {
+	var objs []store.StoredObject
+	if j.S3Bucket != nil && j.S3Key != nil {
+		objs = append(objs, store.StoredObject{Bucket: *j.S3Bucket, Key: *j.S3Key})
+	}
+	if j.OriginalBucket != nil && j.OriginalKey != nil {
+		objs = append(objs, store.StoredObject{Bucket: *j.OriginalBucket, Key: *j.OriginalKey})
+	}
+	outputs := f.d.outputs[j.ID]
+	for _, name := range slices.Sorted(maps.Keys(outputs)) {
+		objs = append(objs, store.StoredObject{Bucket: outputs[name].S3Bucket, Key: outputs[name].S3Key})
+	}
+	return objs
+}
+
+// ExpiredJobs returns up to limit finished, not yet purged jobs whose retention
+// under p has passed, longest expired first
+func (f *Fake) ExpiredJobs(ctx context.Context, p retention.Policy, limit int) ([]store.ExpiredJob, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var out []store.ExpiredJob
+	for _, j := range f.d.jobs {
+		if j.PurgedAt != nil || !j.final() {
+			continue
+		}
+		days, ok := p.Classes[deref(j.RetentionClass)]
+		if !ok {
+			if days, ok = p.Tenants[deref(j.Tenant)]; !ok {
+				days = p.DefaultDays
+			}
+		}
+		if days <= 0 || !j.finished().Before(time.Now().AddDate(0, 0, -days)) {
+			continue
+		}
+		out = append(out, store.ExpiredJob{
+			ID: j.ID, Tenant: deref(j.Tenant), RetentionClass: deref(j.RetentionClass), Days: days,
+			FinishedAt: j.finished(), Objects: f.objects(j),
+		})
+	}
+	slices.SortFunc(out, func(a, b store.ExpiredJob) int {
+		return a.FinishedAt.AddDate(0, 0, a.Days).Compare(b.FinishedAt.AddDate(0, 0, b.Days))
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (f *Fake) MarkPurged(ctx context.Context, ej store.ExpiredJob, reason string) (bool, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	j, ok := f.d.jobs[ej.ID]
+	if !ok || j.PurgedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	j.PurgedAt = &now
+	for _, o := range ej.Objects {
+		f.d.purges = append(f.d.purges, store.PurgeRecord{
+			JobID: ej.ID, Tenant: nonEmpty(ej.Tenant), Bucket: o.Bucket, Key: o.Key, Reason: reason, DeletedAt: now,
+		})
+	}
+	return true, nil
+}
+
+// ListPurges returns the purge audit since a point in time, newest first
+func (f *Fake) ListPurges(ctx context.Context, since time.Time, limit int) ([]store.PurgeRecord, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var out []store.PurgeRecord
+	for i := len(f.d.purges) - 1; i >= 0 && len(out) < limit; i-- {
+		r := f.d.purges[i]
+		if !r.DeletedAt.Before(since) && (f.tenant == "" || deref(r.Tenant) == f.tenant) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// ArchivableJobs returns up to limit finished jobs, neither archived nor purged,
+// that finished more than days ago, oldest first
+func (f *Fake) ArchivableJobs(ctx context.Context, days, limit int) ([]store.ArchivableJob, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var jobs []*job
+	for _, j := range f.d.jobs {
+		if j.ArchivedAt == nil && j.PurgedAt == nil && j.final() && j.finished().Before(time.Now().AddDate(0, 0, -days)) {
+			jobs = append(jobs, j)
+		}
+	}
+	slices.SortFunc(jobs, func(a, b *job) int { return a.finished().Compare(b.finished()) })
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	var out []store.ArchivableJob
+	for _, j := range jobs {
+		out = append(out, store.ArchivableJob{ID: j.ID, Objects: f.objects(j)})
+	}
+	return out, nil
+}
+
+func (f *Fake) MarkArchived(ctx context.Context, id uuid.UUID, class string) (bool, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	j, ok := f.d.jobs[id]
+	if !ok || j.ArchivedAt != nil || j.PurgedAt != nil {
+		return false, nil
+	}
+	j.ArchivedAt, j.StorageClass = ptr(time.Now()), &class
+	return true, nil
+}
+
+func (f *Fake) JobObjects(ctx context.Context, id uuid.UUID) ([]store.StoredObject, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	j, err := f.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return f.objects(j), nil
+}
+
+// FindErasable returns the jobs with q.CallerRef, or the job q.JobID, and their
+// children, oldest first
+func (f *Fake) FindErasable(ctx context.Context, q store.EraseQuery) ([]store.ErasableJob, error) {
+	if q.CallerRef == "" && q.JobID == uuid.Nil {
+		return nil, errors.New("erase query needs a caller_ref or a job id")
+	}
+	if f.tenant != "" {
+		q.Tenant = f.tenant
+	}
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	matched := map[uuid.UUID]bool{}
+	for _, j := range f.d.jobs {
+		if ((q.CallerRef != "" && deref(j.CallerRef) == q.CallerRef) || j.ID == q.JobID) &&
+			(q.Tenant == "" || deref(j.Tenant) == q.Tenant) {
+			matched[j.ID] = true
+		}
+	}
+	var jobs []*job
+	for _, j := range f.d.jobs {
+		if matched[j.ID] || (j.ParentID != nil && matched[*j.ParentID]) {
+			jobs = append(jobs, j)
+		}
+	}
+	slices.SortFunc(jobs, func(a, b *job) int { return a.seq - b.seq })
+	var out []store.ErasableJob
+	for _, j := range jobs {
+		out = append(out, store.ErasableJob{ID: j.ID, Status: j.Status, Tenant: deref(j.Tenant), Erased: j.ErasedAt != nil, Finished: j.final()})
+	}
+	return out, nil
+}
+
+// EraseJob scrubs a job as DB.EraseJob does and records it in the erase audit
+func (f *Fake) EraseJob(ctx context.Context, eraseID uuid.UUID, callerRef string, ej store.ErasableJob, objects []store.StoredObject) error {
+	if objects == nil {
+		objects = []store.StoredObject{}
+	}
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	if j, ok := f.d.jobs[ej.ID]; ok {
+		now := time.Now()
+		j.InputPath, j.OutputPath, j.payload, j.ErrorMsg, j.LastError, j.Analysis, j.MediaInfo = "", "", nil, nil, nil, nil, nil
+		j.ContentHash, j.CallerRef, j.Labels, j.Language, j.LanguageConf, j.KeywordHits = nil, nil, nil, nil, nil, nil
+		j.S3Key, j.S3Version, j.OutputSHA256, j.OriginalKey, j.OriginalSHA256 = nil, nil, nil, nil, nil
+		j.ErasedAt = &now
+		if j.PurgedAt == nil {
+			j.PurgedAt = &now
+		}
+	}
+	delete(f.d.outputs, ej.ID)
+	delete(f.d.fingerprints, ej.ID)
+	delete(f.d.transcripts, ej.ID)
+	for i := range f.d.events[ej.ID] {
+		f.d.events[ej.ID][i].Detail = nil
+	}
+	f.d.erasures = append(f.d.erasures, store.EraseRecord{
+		EraseID: eraseID, CallerRef: nonEmpty(callerRef), JobID: ej.ID, Tenant: nonEmpty(ej.Tenant),
+		Objects: slices.Clone(objects), ErasedAt: time.Now(),
+	})
+	return nil
+}
+
+func (f *Fake) ListErasures(ctx context.Context, eraseID uuid.UUID) ([]store.EraseRecord, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var out []store.EraseRecord
+	for _, r := range f.d.erasures {
+		if r.EraseID == eraseID && (f.tenant == "" || deref(r.Tenant) == f.tenant) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// ObjectRefs returns the keys under prefix that jobs not purged recorded in
+// one of buckets, on the primary endpoint, ordered by their bytes
+func (f *Fake) ObjectRefs(ctx context.Context, buckets []string, prefix string) (*store.ObjectRefs, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var refs []store.ObjectRef
+	add := func(j *job, bucket, key, endpoint *string) {
+		if key != nil && deref(endpoint) != "secondary" && slices.Contains(buckets, deref(bucket)) && strings.HasPrefix(*key, prefix) {
+			refs = append(refs, store.ObjectRef{Key: *key, JobID: j.ID})
+		}
+	}
+	for _, j := range f.d.jobs {
+		if j.PurgedAt != nil {
+			continue
+		}
+		add(j, j.S3Bucket, j.S3Key, j.S3Endpoint)
+		add(j, j.OriginalBucket, j.OriginalKey, j.OriginalEndpoint)
+		for _, o := range f.d.outputs[j.ID] {
+			add(j, &o.S3Bucket, &o.S3Key, &o.Endpoint)
+		}
+	}
+	slices.SortFunc(refs, func(a, b store.ObjectRef) int { return strings.Compare(a.Key, b.Key) })
+	return store.ObjectRefsOf(refs), nil
+}
+
+func (f *Fake) OriginalInUse(ctx context.Context, bucket, key string) (bool, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	for _, j := range f.d.jobs {
+		if deref(j.OriginalBucket) == bucket && deref(j.OriginalKey) == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
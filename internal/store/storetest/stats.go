@@ -0,0 +1,113 @@
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// denoiser is the denoiser of a job as in ClaimNextJob: the one it ran with,
+// else the one requested, else default
+func (j *job) denoiser() string {
+	if j.DenoiseMethod != nil && *j.DenoiseMethod != "" {
+		return *j.DenoiseMethod
+	}
+	var p struct {
+		DenoiseMethod string `json:"denoise_method"`
+	}
+	json.Unmarshal(j.payload, &p)
+	if m := strings.ToLower(p.DenoiseMethod); m != "" {
+		return m
+	}
+	return "default"
+}
+
+// JobStatsBetween aggregates the audio jobs created in [since, until) per UTC
+// day and denoiser, newest day first, and for the whole period
+func (f *Fake) JobStatsBetween(ctx context.Context, since, until time.Time) (*store.Stats, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	type group struct {
+		day    time.Time
+		method string
+	}
+	groups := map[group][]*job{}
+	var all []*job
+	for _, j := range f.d.jobs {
+		if !f.visible(j) || j.Kind != "audio" || j.CreatedAt.Before(since) || !j.CreatedAt.Before(until) {
+			continue
+		}
+		c := j.CreatedAt.UTC()
+		g := group{time.Date(c.Year(), c.Month(), c.Day(), 0, 0, 0, 0, time.UTC), j.denoiser()}
+		groups[g] = append(groups[g], j)
+		all = append(all, j)
+	}
+
+	st := store.Stats{Since: since, Until: until, Totals: aggregate(all), Days: []store.JobStats{}}
+	keys := make([]group, 0, len(groups))
+	for g := range groups {
+		keys = append(keys, g)
+	}
+	slices.SortFunc(keys, func(a, b group) int {
+		if c := b.day.Compare(a.day); c != 0 {
+			return c
+		}
+		return strings.Compare(a.method, b.method)
+	})
+	for _, g := range keys {
+		js := aggregate(groups[g])
+		js.Day, js.DenoiseMethod = ptr(g.day), g.method
+		st.Days = append(st.Days, js)
+	}
+	return &st, nil
+}
+
+// aggregate computes the JobStats of jobs as the query of DB.JobStatsBetween
+func aggregate(jobs []*job) store.JobStats {
+	var js store.JobStats
+	var secs, gains []float64
+	for _, j := range jobs {
+		js.Jobs++
+		switch j.Status {
+		case "done", "completed_with_warnings":
+			js.Succeeded++
+			if j.StartedAt != nil && j.FinishedAt != nil {
+				secs = append(secs, j.FinishedAt.Sub(*j.StartedAt).Seconds())
+			}
+		case "failed":
+			js.Failed++
+		}
+		if j.SNRGain != nil {
+			gains = append(gains, *j.SNRGain)
+		}
+	}
+	if n := js.Succeeded + js.Failed; n > 0 {
+		js.SuccessRate = ptr(float64(js.Succeeded) / float64(n))
+	}
+	js.P50Sec, js.P95Sec = percentile(secs, 0.5), percentile(secs, 0.95)
+	if len(gains) > 0 {
+		var sum float64
+		for _, g := range gains {
+			sum += g
+		}
+		js.AvgSNRImprovementDB = ptr(sum / float64(len(gains)))
+	}
+	return js
+}
+
+// percentile is percentile_cont: linear interpolation between the closest
+// ranks; nil without values
+func percentile(values []float64, p float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	v := slices.Sorted(slices.Values(values))
+	pos := p * float64(len(v)-1)
+	lo, hi := int(math.Floor(pos)), int(math.Ceil(pos))
+	return ptr(v[lo] + (v[hi]-v[lo])*(pos-float64(lo)))
+}
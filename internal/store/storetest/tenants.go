@@ -0,0 +1,209 @@
+package storetest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+// CreateTenant adds a tenant, or updates the name of an existing one
+func (f *Fake) CreateTenant(ctx context.Context, id, name string) (*store.Tenant, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	t, ok := f.d.tenants[id]
+	if !ok {
+		t = &store.Tenant{ID: id, CreatedAt: time.Now()}
+		f.d.tenants[id] = t
+	}
+	if name != "" {
+		t.Name = &name
+	}
+	out := *t
+	return &out, nil
+}
+
+func (f *Fake) GetTenant(ctx context.Context, id string) (*store.Tenant, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	t, ok := f.d.tenants[id]
+	if !ok || (f.tenant != "" && id != f.tenant) {
+		return nil, pgx.ErrNoRows
+	}
+	out := *t
+	return &out, nil
+}
+
+func (f *Fake) ListTenants(ctx context.Context) ([]store.Tenant, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	out := []store.Tenant{}
+	for _, t := range f.d.tenants {
+		if f.tenant == "" || t.ID == f.tenant {
+			out = append(out, *t)
+		}
+	}
+	slices.SortFunc(out, func(a, b store.Tenant) int { return strings.Compare(a.ID, b.ID) })
+	return out, nil
+}
+
+func (f *Fake) SetTenantDisabled(ctx context.Context, id string, disabled bool) error {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	t, ok := f.d.tenants[id]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	t.DisabledAt = disabledAt(t.DisabledAt, disabled)
+	return nil
+}
+
+// disabledAt is CASE WHEN disabled THEN COALESCE(at, now()) END
+func disabledAt(at *time.Time, disabled bool) *time.Time {
+	if !disabled {
+		return nil
+	}
+	if at == nil {
+		return ptr(time.Now())
+	}
+	return at
+}
+
+// CreateUser adds a user to a tenant, which is created if needed; emails are
+// unique across tenants, compared without case
+func (f *Fake) CreateUser(ctx context.Context, tenantID, email, name, role string) (*store.User, error) {
+	if f.tenant != "" {
+		tenantID = f.tenant
+	}
+	if role == "" {
+		role = store.RoleMember
+	}
+	email = strings.TrimSpace(email)
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	for _, u := range f.d.users {
+		if strings.EqualFold(u.Email, email) {
+			return nil, fmt.Errorf("user %s exists already", email)
+		}
+	}
+	if _, ok := f.d.tenants[tenantID]; !ok {
+		f.d.tenants[tenantID] = &store.Tenant{ID: tenantID, CreatedAt: time.Now()}
+	}
+	u := &store.User{ID: uuid.New(), TenantID: tenantID, Email: email, Name: nonEmpty(name), Role: role, CreatedAt: time.Now()}
+	f.d.users[u.ID] = u
+	out := *u
+	return &out, nil
+}
+
+func (f *Fake) GetUser(ctx context.Context, id uuid.UUID) (*store.User, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	u, ok := f.d.users[id]
+	if !ok || (f.tenant != "" && u.TenantID != f.tenant) {
+		return nil, pgx.ErrNoRows
+	}
+	out := *u
+	return &out, nil
+}
+
+// ListUsers returns the users of a tenant ordered by email; empty tenantID
+// lists the users of the scoped tenant, or of all tenants when unscoped
+func (f *Fake) ListUsers(ctx context.Context, tenantID string) ([]store.User, error) {
+	if f.tenant != "" {
+		tenantID = f.tenant
+	}
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	out := []store.User{}
+	for _, u := range f.d.users {
+		if tenantID == "" || u.TenantID == tenantID {
+			out = append(out, *u)
+		}
+	}
+	slices.SortFunc(out, func(a, b store.User) int { return strings.Compare(strings.ToLower(a.Email), strings.ToLower(b.Email)) })
+	return out, nil
+}
+
+func (f *Fake) SetUserDisabled(ctx context.Context, id uuid.UUID, disabled bool) error {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	u, ok := f.d.users[id]
+	if !ok || (f.tenant != "" && u.TenantID != f.tenant) {
+		return pgx.ErrNoRows
+	}
+	u.DisabledAt = disabledAt(u.DisabledAt, disabled)
+	return nil
+}
+
+// CreateAPIKey creates a key of the form bk_<prefix>_<secret> for tenant, or
+// for the scoped tenant
+func (f *Fake) CreateAPIKey(ctx context.Context, tenant, name string, scopes []string, expiresAt *time.Time) (*store.APIKey, string, error) {
+	if f.tenant != "" {
+		tenant = f.tenant
+	}
+	b := make([]byte, 38)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	prefix := hex.EncodeToString(b[:6])
+	key := "bk_" + prefix + "_" + base64.RawURLEncoding.EncodeToString(b[6:])
+	k := &store.APIKey{
+		ID: uuid.New(), TenantID: nonEmpty(tenant), Name: name, Prefix: prefix,
+		Scopes: slices.Clone(scopes), CreatedAt: time.Now(), ExpiresAt: expiresAt,
+	}
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	f.d.keys[key] = k
+	out := *k
+	return &out, key, nil
+}
+
+// ListAPIKeys returns the keys of the scoped tenant, or every key when
+// unscoped, newest first
+func (f *Fake) ListAPIKeys(ctx context.Context) ([]store.APIKey, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	out := []store.APIKey{}
+	for _, k := range f.d.keys {
+		if f.tenant == "" || deref(k.TenantID) == f.tenant {
+			out = append(out, *k)
+		}
+	}
+	slices.SortFunc(out, func(a, b store.APIKey) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out, nil
+}
+
+func (f *Fake) RevokeAPIKey(ctx context.Context, id uuid.UUID) (bool, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	for _, k := range f.d.keys {
+		if k.ID == id && k.RevokedAt == nil && (f.tenant == "" || deref(k.TenantID) == f.tenant) {
+			k.RevokedAt = ptr(time.Now())
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AuthenticateAPIKey returns the key matching key, unless it is revoked or
+// expired; store.ErrInvalidKey otherwise
+func (f *Fake) AuthenticateAPIKey(ctx context.Context, key string) (*store.APIKey, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	k, ok := f.d.keys[key]
+	if !ok || k.RevokedAt != nil || (k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)) {
+		return nil, store.ErrInvalidKey
+	}
+	k.LastUsedAt = ptr(time.Now())
+	out := *k
+	return &out, nil
+}
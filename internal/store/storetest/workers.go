@@ -0,0 +1,60 @@
+package storetest
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/store"
+)
+
+func (f *Fake) RegisterWorker(ctx context.Context, id, hostname, version string, concurrency int) error {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	now := time.Now()
+	f.d.workers[id] = &store.WorkerRecord{
+		ID: id, Hostname: hostname, Version: version, Concurrency: concurrency, Status: "running", StartedAt: now, HeartbeatAt: now,
+	}
+	return nil
+}
+
+func (f *Fake) WorkerHeartbeat(ctx context.Context, id string) error {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	if w, ok := f.d.workers[id]; ok {
+		w.HeartbeatAt = time.Now()
+	}
+	return nil
+}
+
+func (f *Fake) SetWorkerStatus(ctx context.Context, id, status string) error {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	if w, ok := f.d.workers[id]; ok {
+		w.Status, w.HeartbeatAt = status, time.Now()
+	}
+	return nil
+}
+
+// ListWorkers returns the registered workers with the jobs they are
+// processing, most recently seen first
+func (f *Fake) ListWorkers(ctx context.Context, aliveWithin time.Duration) ([]store.WorkerRecord, error) {
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	var out []store.WorkerRecord
+	for _, w := range f.d.workers {
+		wr := *w
+		wr.Alive = wr.Status != "stopped" && time.Since(wr.HeartbeatAt) <= aliveWithin
+		wr.ActiveJobs = []uuid.UUID{}
+		for _, j := range f.d.jobs {
+			if j.Status == "processing" && deref(j.WorkerID) == wr.ID {
+				wr.ActiveJobs = append(wr.ActiveJobs, j.ID)
+			}
+		}
+		out = append(out, wr)
+	}
+	slices.SortFunc(out, func(a, b store.WorkerRecord) int { return b.HeartbeatAt.Compare(a.HeartbeatAt) })
+	return out, nil
+}
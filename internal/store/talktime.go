@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TalkTime are the talk-time analytics columns of a job
+type TalkTime struct {
+	TalkSec           float64  `json:"talk_sec"`
+	DeadAirPct        float64  `json:"dead_air_pct"`
+	LongestSilenceSec float64  `json:"longest_silence_sec"`
+	AgentTalkSec      *float64 `json:"agent_talk_sec,omitempty"`    // stereo calls only
+	CustomerTalkSec   *float64 `json:"customer_talk_sec,omitempty"` // stereo calls only
+	OvertalkPct       *float64 `json:"overtalk_pct,omitempty"`      // stereo calls only
+	Interruptions     *int     `json:"interruptions,omitempty"`     // stereo calls only
+}
+
+// SetTalkTime stores the talk-time analytics of a job
+func (s *DB) SetTalkTime(ctx context.Context, id uuid.UUID, t TalkTime) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE audio_jobs
+		SET talk_sec=$2, dead_air_pct=$3, longest_silence_sec=$4, agent_talk_sec=$5, customer_talk_sec=$6,
+		    overtalk_pct=$7, interruptions=$8
+		WHERE id=$1
+	`, id, t.TalkSec, t.DeadAirPct, t.LongestSilenceSec, t.AgentTalkSec, t.CustomerTalkSec, t.OvertalkPct, t.Interruptions)
+	return err
+}
+
+// DeadAirJob is a job found by ListDeadAirJobs
+type DeadAirJob struct {
+	ID          uuid.UUID `json:"id"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	DurationSec *float64  `json:"duration_sec,omitempty"`
+	TalkTime
+}
+
+// ListDeadAirJobs returns jobs with at least minPct percent dead air carrying
+// all of labels, worst first
+func (s *DB) ListDeadAirJobs(ctx context.Context, minPct float64, labels map[string]string, limit int) ([]DeadAirJob, error) {
+	filter, err := labelsFilter(labels)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, status, created_at, duration_sec, talk_sec, dead_air_pct, longest_silence_sec, agent_talk_sec, customer_talk_sec,
+		       overtalk_pct, interruptions
+		FROM audio_jobs
+		WHERE dead_air_pct >= $1 AND ($3 = '' OR tenant = $3) AND ($4::jsonb IS NULL OR labels @> $4::jsonb)
+		ORDER BY dead_air_pct DESC, created_at DESC
+		LIMIT $2
+	`, minPct, limit, s.tenant, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []DeadAirJob
+	for rows.Next() {
+		var j DeadAirJob
+		if err := rows.Scan(&j.ID, &j.Status, &j.CreatedAt, &j.DurationSec, &j.TalkSec, &j.DeadAirPct,
+			&j.LongestSilenceSec, &j.AgentTalkSec, &j.CustomerTalkSec, &j.OvertalkPct, &j.Interruptions); err != nil {
+			return nil, err
+		}
+		out = append(out, j)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,191 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// User roles
+const (
+	RoleAdmin  = "admin"  // manages the users and keys of the tenant
+	RoleMember = "member" // submits and reads jobs
+)
+
+// Tenant is a customer whose jobs, users and objects are kept apart from the
+// others'. Its ID is the tenant label recorded on jobs.
+type Tenant struct {
+	ID         string     `json:"id"`
+	Name       *string    `json:"name,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+}
+
+// User is a person submitting jobs for a tenant
+type User struct {
+	ID         uuid.UUID  `json:"id"`
+	TenantID   string     `json:"tenant_id"`
+	Email      string     `json:"email"`
+	Name       *string    `json:"name,omitempty"`
+	Role       string     `json:"role"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+}
+
+// ForTenant returns a store whose job queries only see the jobs of tenant:
+// jobs of other tenants are not found, lists leave them out, and jobs created
+// without a tenant get this one. An empty tenant sees every job, as the
+// workers and operators do.
+func (s *DB) ForTenant(tenant string) Store {
+	c := *s
+	c.tenant = tenant
+	return &c
+}
+
+// Tenant returns the tenant the store is scoped to, empty when unscoped
+func (s *DB) Tenant() string {
+	return s.tenant
+}
+
+// CreateTenant adds a tenant, or updates the name of an existing one
+func (s *DB) CreateTenant(ctx context.Context, id, name string) (*Tenant, error) {
+	var t Tenant
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO tenants (id, name) VALUES ($1, NULLIF($2, ''))
+		ON CONFLICT (id) DO UPDATE SET name=COALESCE(EXCLUDED.name, tenants.name)
+		RETURNING id, name, created_at, disabled_at
+	`, id, name).Scan(&t.ID, &t.Name, &t.CreatedAt, &t.DisabledAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTenant returns a tenant; a scoped store only its own
+func (s *DB) GetTenant(ctx context.Context, id string) (*Tenant, error) {
+	var t Tenant
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, name, created_at, disabled_at FROM tenants WHERE id=$1 AND ($2 = '' OR id = $2)
+	`, id, s.tenant).Scan(&t.ID, &t.Name, &t.CreatedAt, &t.DisabledAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTenants returns the tenants ordered by id; a scoped store only its own
+func (s *DB) ListTenants(ctx context.Context) ([]Tenant, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, name, created_at, disabled_at FROM tenants
+		WHERE ($1 = '' OR id = $1)
+		ORDER BY id
+	`, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Tenant{}
+	for rows.Next() {
+		var t Tenant
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt, &t.DisabledAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// SetTenantDisabled disables or re-enables a tenant
+func (s *DB) SetTenantDisabled(ctx context.Context, id string, disabled bool) error {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE tenants SET disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, now()) END WHERE id=$1
+	`, id, disabled)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// CreateUser adds a user to a tenant, which is created if needed; a scoped
+// store adds it to its own. Emails are unique across tenants, compared without
+// case.
+func (s *DB) CreateUser(ctx context.Context, tenantID, email, name, role string) (*User, error) {
+	if s.tenant != "" {
+		tenantID = s.tenant
+	}
+	if role == "" {
+		role = RoleMember
+	}
+	u := User{ID: uuid.New()}
+	err := s.pool.QueryRow(ctx, `
+		WITH tenant AS (
+			INSERT INTO tenants (id) VALUES ($2) ON CONFLICT DO NOTHING
+		)
+		INSERT INTO users (id, tenant_id, email, name, role) VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		RETURNING tenant_id, email, name, role, created_at, disabled_at
+	`, u.ID, tenantID, strings.TrimSpace(email), name, role).Scan(&u.TenantID, &u.Email, &u.Name, &u.Role, &u.CreatedAt, &u.DisabledAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetUser returns a user of the scoped tenant
+func (s *DB) GetUser(ctx context.Context, id uuid.UUID) (*User, error) {
+	var u User
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, tenant_id, email, name, role, created_at, disabled_at FROM users
+		WHERE id=$1 AND ($2 = '' OR tenant_id = $2)
+	`, id, s.tenant).Scan(&u.ID, &u.TenantID, &u.Email, &u.Name, &u.Role, &u.CreatedAt, &u.DisabledAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// ListUsers returns the users of a tenant ordered by email; empty tenantID
+// lists the users of the scoped tenant, or of all tenants when unscoped
+func (s *DB) ListUsers(ctx context.Context, tenantID string) ([]User, error) {
+	if s.tenant != "" {
+		tenantID = s.tenant
+	}
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, tenant_id, email, name, role, created_at, disabled_at FROM users
+		WHERE ($1 = '' OR tenant_id = $1)
+		ORDER BY lower(email)
+	`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.TenantID, &u.Email, &u.Name, &u.Role, &u.CreatedAt, &u.DisabledAt); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// SetUserDisabled disables or re-enables a user of the scoped tenant
+func (s *DB) SetUserDisabled(ctx context.Context, id uuid.UUID, disabled bool) error {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE users SET disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, now()) END
+		WHERE id=$1 AND ($3 = '' OR tenant_id = $3)
+	`, id, disabled, s.tenant)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
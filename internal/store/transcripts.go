@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Bahadou-Badr/Blinky-call-audio-processing-service/internal/audio"
+)
+
+// StoredTranscript is the transcript of a job as kept in transcripts
+type StoredTranscript struct {
+	JobID     uuid.UUID `json:"job_id"`
+	CreatedAt time.Time `json:"created_at"`
+	audio.Transcript
+}
+
+// SaveTranscript stores the transcript of a job, replacing an earlier one
+func (s *DB) SaveTranscript(ctx context.Context, jobID uuid.UUID, tr *audio.Transcript) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO transcripts (job_id, language, text, segments, words, model)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, NULLIF($6, ''))
+		ON CONFLICT (job_id) DO UPDATE SET language=EXCLUDED.language, text=EXCLUDED.text, segments=EXCLUDED.segments,
+		                                   words=EXCLUDED.words, model=EXCLUDED.model, created_at=now()
+	`, jobID, tr.Language, tr.Text, tr.Segments, tr.Words, tr.Model)
+	return err
+}
+
+// GetTranscript returns the transcript of a job of the scoped tenant;
+// pgx.ErrNoRows when the job has none
+func (s *DB) GetTranscript(ctx context.Context, jobID uuid.UUID) (*StoredTranscript, error) {
+	t := StoredTranscript{JobID: jobID}
+	err := s.pool.QueryRow(ctx, `
+		SELECT COALESCE(language, ''), text, segments, words, COALESCE(model, ''), created_at FROM transcripts
+		WHERE job_id=$1 AND ($2 = '' OR EXISTS (SELECT 1 FROM audio_jobs WHERE id=$1 AND tenant=$2))
+	`, jobID, s.tenant).Scan(&t.Language, &t.Text, &t.Segments, &t.Words, &t.Model, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkerRecord is a row of the workers table plus the jobs it is processing
+type WorkerRecord struct {
+	ID          string      `json:"id"`
+	Hostname    string      `json:"hostname"`
+	Version     string      `json:"version"`
+	Concurrency int         `json:"concurrency"`
+	Status      string      `json:"status"`
+	StartedAt   time.Time   `json:"started_at"`
+	HeartbeatAt time.Time   `json:"heartbeat_at"`
+	Alive       bool        `json:"alive"`
+	ActiveJobs  []uuid.UUID `json:"active_jobs"`
+}
+
+// RegisterWorker inserts or refreshes the registry entry of a starting worker
+func (s *DB) RegisterWorker(ctx context.Context, id, hostname, version string, concurrency int) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO workers (id, hostname, version, concurrency, status, started_at, heartbeat_at)
+		VALUES ($1, $2, $3, $4, 'running', now(), now())
+		ON CONFLICT (id) DO UPDATE SET hostname=$2, version=$3, concurrency=$4,
+			status='running', started_at=now(), heartbeat_at=now()
+	`, id, hostname, version, concurrency)
+	return err
+}
+
+// WorkerHeartbeat refreshes heartbeat_at of a registered worker
+func (s *DB) WorkerHeartbeat(ctx context.Context, id string) error {
+	_, err := s.pool.Exec(ctx, `UPDATE workers SET heartbeat_at=now() WHERE id=$1`, id)
+	return err
+}
+
+// SetWorkerStatus records the worker state (running, paused, stopped)
+func (s *DB) SetWorkerStatus(ctx context.Context, id, status string) error {
+	_, err := s.pool.Exec(ctx, `UPDATE workers SET status=$2, heartbeat_at=now() WHERE id=$1`, id, status)
+	return err
+}
+
+// ListWorkers returns registered workers, most recently seen first. A worker is alive
+// when it is not stopped and heartbeated within aliveWithin.
+func (s *DB) ListWorkers(ctx context.Context, aliveWithin time.Duration) ([]WorkerRecord, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT w.id, w.hostname, w.version, w.concurrency, w.status, w.started_at, w.heartbeat_at,
+		       w.status <> 'stopped' AND w.heartbeat_at >= now() - make_interval(secs => $1),
+		       COALESCE(array_agg(j.id) FILTER (WHERE j.id IS NOT NULL), '{}')
+		FROM workers w
+		LEFT JOIN audio_jobs j ON j.worker_id = w.id AND j.status = 'processing'
+		GROUP BY w.id
+		ORDER BY w.heartbeat_at DESC
+	`, aliveWithin.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []WorkerRecord
+	for rows.Next() {
+		var wr WorkerRecord
+		if err := rows.Scan(&wr.ID, &wr.Hostname, &wr.Version, &wr.Concurrency, &wr.Status,
+			&wr.StartedAt, &wr.HeartbeatAt, &wr.Alive, &wr.ActiveJobs); err != nil {
+			return nil, err
+		}
+		out = append(out, wr)
+	}
+	return out, rows.Err()
+}
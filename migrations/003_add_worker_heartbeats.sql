@@ -0,0 +1,6 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS worker_id TEXT,
+  ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMP WITH TIME ZONE,
+  ADD COLUMN IF NOT EXISTS payload JSONB; -- job message as published, used to requeue
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_heartbeat_at ON audio_jobs(status, heartbeat_at);
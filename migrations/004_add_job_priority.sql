@@ -0,0 +1,4 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS priority TEXT NOT NULL DEFAULT 'normal'; -- realtime | high | normal | batch
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_priority ON audio_jobs(status, priority, created_at);
@@ -0,0 +1,4 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS process_after TIMESTAMP WITH TIME ZONE DEFAULT NULL; -- set for status 'scheduled'
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_process_after ON audio_jobs(process_after) WHERE status = 'scheduled';
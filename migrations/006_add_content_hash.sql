@@ -0,0 +1,5 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS content_hash TEXT,  -- sha256 of the uploaded input
+  ADD COLUMN IF NOT EXISTS options_hash TEXT;  -- fingerprint of the processing options
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_content_hash ON audio_jobs(content_hash, options_hash);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS workers (
+    id TEXT PRIMARY KEY,
+    hostname TEXT NOT NULL,
+    version TEXT NOT NULL,
+    concurrency INT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'running', -- running | stopped
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    heartbeat_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_workers_heartbeat_at ON workers(heartbeat_at);
@@ -0,0 +1,5 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS parent_id UUID DEFAULT NULL, -- bundle job this job was unpacked from
+  ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'audio'; -- audio | bundle
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_parent_id ON audio_jobs(parent_id);
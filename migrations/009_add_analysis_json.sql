@@ -0,0 +1,2 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS analysis_json JSONB DEFAULT NULL; -- analysis results keyed by name (speech, ...)
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS job_outputs (
+    job_id UUID NOT NULL,
+    name TEXT NOT NULL, -- agent | customer | ... (the main output stays on audio_jobs)
+    s3_bucket TEXT NOT NULL,
+    s3_key TEXT NOT NULL,
+    s3_version_id TEXT,
+    content_type TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    PRIMARY KEY (job_id, name)
+);
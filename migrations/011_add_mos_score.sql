@@ -0,0 +1,2 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS mos_score DOUBLE PRECISION DEFAULT NULL; -- non-intrusive MOS (1..5) of the output
@@ -0,0 +1,8 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS talk_sec DOUBLE PRECISION DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS dead_air_pct DOUBLE PRECISION DEFAULT NULL, -- silences of 2s+ over the duration, 0..100
+  ADD COLUMN IF NOT EXISTS longest_silence_sec DOUBLE PRECISION DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS agent_talk_sec DOUBLE PRECISION DEFAULT NULL,    -- stereo calls only
+  ADD COLUMN IF NOT EXISTS customer_talk_sec DOUBLE PRECISION DEFAULT NULL; -- stereo calls only
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_dead_air ON audio_jobs (dead_air_pct) WHERE dead_air_pct IS NOT NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS language TEXT DEFAULT NULL, -- detected spoken language, ISO 639-1
+  ADD COLUMN IF NOT EXISTS language_confidence DOUBLE PRECISION DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_language ON audio_jobs (language) WHERE language IS NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS keyword_hits INTEGER DEFAULT NULL; -- keyword list matches in the transcript, details in analysis_json
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_keyword_hits ON audio_jobs (keyword_hits) WHERE keyword_hits > 0;
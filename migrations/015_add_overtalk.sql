@@ -0,0 +1,3 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS overtalk_pct DOUBLE PRECISION DEFAULT NULL, -- both parties speaking, 0..100 of the duration; stereo calls only
+  ADD COLUMN IF NOT EXISTS interruptions INTEGER DEFAULT NULL;         -- overtalk stretches of 0.5s+; stereo calls only
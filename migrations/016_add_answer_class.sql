@@ -0,0 +1,4 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS answer_class TEXT DEFAULT NULL; -- human, machine (voicemail/answering machine) or unknown
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_answer_class ON audio_jobs (answer_class);
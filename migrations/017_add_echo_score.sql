@@ -0,0 +1,4 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS echo_score DOUBLE PRECISION DEFAULT NULL; -- 0 (no echo found) .. 1, from the input
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_echo_score ON audio_jobs (echo_score) WHERE echo_score > 0;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS job_fingerprints (
+    job_id UUID PRIMARY KEY,
+    duration_sec DOUBLE PRECISION NOT NULL, -- of the fingerprinted input
+    fingerprint BYTEA NOT NULL,             -- little endian uint32 sub-fingerprints
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_fingerprints_duration ON job_fingerprints (duration_sec);
+
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS duplicate_of UUID DEFAULT NULL; -- earlier job with the same recording (acoustic fingerprint match)
@@ -0,0 +1,4 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS media_info JSONB DEFAULT NULL; -- ffprobe metadata of the input: container, codec, bit_rate, sample_rate, channels, channel_layout, ...
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_media_codec ON audio_jobs ((media_info->>'container'), (media_info->>'codec')) WHERE media_info IS NOT NULL;
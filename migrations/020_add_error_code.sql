@@ -0,0 +1,4 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS error_code TEXT DEFAULT NULL; -- machine readable reason of a rejected input, e.g. truncated, not_audio (see audio.PreflightError)
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_error_code ON audio_jobs (error_code) WHERE error_code IS NOT NULL;
@@ -0,0 +1,25 @@
+-- loudness of the output with stable field names (audio.Loudness), replacing the
+-- loudness_json maps keyed by loudnorm's input_i/input_tp/input_lra/output_i
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS loudness JSONB DEFAULT NULL;
+
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'audio_jobs' AND column_name = 'loudness_json') THEN
+    UPDATE audio_jobs SET loudness = jsonb_strip_nulls(jsonb_build_object(
+        'integrated_lufs', l->'input_i',
+        'true_peak_dbtp', l->'input_tp',
+        'lra_lu', l->'input_lra',
+        'output_integrated_lufs', l->'output_i'))
+    FROM (
+      SELECT id AS job_id,
+             CASE jsonb_typeof(loudness_json) WHEN 'string' THEN (loudness_json #>> '{}')::jsonb ELSE loudness_json END AS l
+      FROM audio_jobs WHERE loudness_json IS NOT NULL
+    ) old
+    WHERE audio_jobs.id = old.job_id AND jsonb_typeof(old.l) = 'object' AND old.l ? 'input_i';
+
+    ALTER TABLE audio_jobs DROP COLUMN loudness_json;
+  END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_loudness_i ON audio_jobs (((loudness->>'integrated_lufs')::double precision)) WHERE loudness IS NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS original_bucket TEXT DEFAULT NULL, -- where the unprocessed input is kept, see storage.OriginalKey
+  ADD COLUMN IF NOT EXISTS original_key TEXT DEFAULT NULL;
@@ -0,0 +1,20 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS tenant TEXT DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS retention_class TEXT DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS purged_at TIMESTAMP WITH TIME ZONE DEFAULT NULL; -- objects deleted by the retention purger
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_unpurged ON audio_jobs(finished_at) WHERE purged_at IS NULL;
+
+-- one row per object deleted by the retention purger
+CREATE TABLE IF NOT EXISTS purge_audit (
+    id BIGSERIAL PRIMARY KEY,
+    job_id UUID NOT NULL,
+    tenant TEXT,
+    bucket TEXT NOT NULL,
+    object_key TEXT NOT NULL,
+    reason TEXT NOT NULL, -- the rule that expired the job, e.g. "retention_class short: 30 days"
+    deleted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_purge_audit_deleted_at ON purge_audit(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_purge_audit_job_id ON purge_audit(job_id);
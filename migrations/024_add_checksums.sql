@@ -0,0 +1,6 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS original_sha256 TEXT DEFAULT NULL, -- hex sha256 of the stored objects, checked on download
+  ADD COLUMN IF NOT EXISTS output_sha256 TEXT DEFAULT NULL;
+
+ALTER TABLE job_outputs
+  ADD COLUMN IF NOT EXISTS sha256 TEXT DEFAULT NULL;
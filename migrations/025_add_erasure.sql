@@ -0,0 +1,18 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS caller_ref TEXT DEFAULT NULL,  -- customer reference of the call (e.g. its id in the dialer), the key of erase requests
+  ADD COLUMN IF NOT EXISTS erased_at TIMESTAMP WITH TIME ZONE DEFAULT NULL; -- recording and personal data deleted on request
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_caller_ref ON audio_jobs (caller_ref) WHERE caller_ref IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS erase_audit (
+    id BIGSERIAL PRIMARY KEY,
+    erase_id UUID NOT NULL,   -- one erase request, spanning all its jobs
+    caller_ref TEXT,          -- as requested; NULL for requests by job id
+    job_id UUID NOT NULL,
+    tenant TEXT,
+    objects JSONB NOT NULL,   -- [{"bucket": ..., "key": ...}] deleted from storage
+    erased_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_erase_audit_erase_id ON erase_audit (erase_id);
+CREATE INDEX IF NOT EXISTS idx_erase_audit_job_id ON erase_audit (job_id);
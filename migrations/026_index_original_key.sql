@@ -0,0 +1,2 @@
+-- uploads registered with /submit upload_key are looked up by key
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_original_key ON audio_jobs (original_bucket, original_key) WHERE original_key IS NOT NULL;
@@ -0,0 +1,7 @@
+-- objects moved to a cold storage class by the worker's archiver; the archived
+-- flag of 002 is set along
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP WITH TIME ZONE DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS storage_class TEXT DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_unarchived ON audio_jobs(finished_at) WHERE archived_at IS NULL AND purged_at IS NULL;
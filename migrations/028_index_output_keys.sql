@@ -0,0 +1,3 @@
+-- the object garbage collector looks up the keys recorded on jobs by bucket
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_s3_key ON audio_jobs (s3_bucket, s3_key) WHERE s3_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_job_outputs_s3_key ON job_outputs (s3_bucket, s3_key);
@@ -0,0 +1,6 @@
+-- endpoint (primary | secondary) holding each object with S3 failover, NULL without
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS s3_endpoint TEXT DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS original_endpoint TEXT DEFAULT NULL;
+
+ALTER TABLE job_outputs ADD COLUMN IF NOT EXISTS endpoint TEXT DEFAULT NULL;
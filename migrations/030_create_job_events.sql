@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS job_events (
+    id BIGSERIAL PRIMARY KEY,
+    job_id UUID NOT NULL,
+    event TEXT NOT NULL,   -- queued | scheduled | claimed | denoise_done | uploaded | finished | failed | retried | cancelled
+    detail TEXT,
+    worker_id TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_events_job_id ON job_events (job_id, id);
@@ -0,0 +1,5 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0,     -- times a worker claimed the job
+  ADD COLUMN IF NOT EXISTS max_attempts INT NOT NULL DEFAULT 3, -- after which a transient failure or a dead worker fails the job
+  ADD COLUMN IF NOT EXISTS last_error TEXT DEFAULT NULL,        -- of the latest failed attempt, kept across retries
+  ADD COLUMN IF NOT EXISTS next_retry_at TIMESTAMP WITH TIME ZONE DEFAULT NULL;
@@ -0,0 +1,2 @@
+-- claiming the oldest queued job (ClaimNextJob) without scanning finished ones
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_queued ON audio_jobs (created_at) WHERE status = 'queued';
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS tenants (
+    id TEXT PRIMARY KEY, -- the tenant label of jobs (X-Tenant-ID)
+    name TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    disabled_at TIMESTAMP WITH TIME ZONE DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id UUID PRIMARY KEY,
+    tenant_id TEXT NOT NULL,
+    email TEXT NOT NULL,
+    name TEXT,
+    role TEXT NOT NULL DEFAULT 'member', -- admin | member
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    disabled_at TIMESTAMP WITH TIME ZONE DEFAULT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (lower(email));
+CREATE INDEX IF NOT EXISTS idx_users_tenant_id ON users (tenant_id);
+
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS owner_id UUID DEFAULT NULL; -- user who submitted the job
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_tenant ON audio_jobs (tenant, created_at);
+
+-- the tenants jobs were submitted for so far
+INSERT INTO tenants (id) SELECT DISTINCT tenant FROM audio_jobs WHERE tenant IS NOT NULL ON CONFLICT DO NOTHING;
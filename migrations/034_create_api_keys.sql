@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY,
+    tenant_id TEXT,             -- NULL for operator keys, which see every tenant
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,       -- public part of the key, to look it up
+    key_hash TEXT NOT NULL,     -- hex SHA-256 of the whole key; the key itself is never stored
+    scopes TEXT[] NOT NULL,     -- jobs:read | jobs:write | admin
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    expires_at TIMESTAMP WITH TIME ZONE DEFAULT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE DEFAULT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE DEFAULT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_prefix ON api_keys (prefix);
+CREATE INDEX IF NOT EXISTS idx_api_keys_tenant_id ON api_keys (tenant_id);
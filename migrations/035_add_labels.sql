@@ -0,0 +1,4 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS labels JSONB DEFAULT NULL; -- key/value labels of the client, e.g. {"campaign": "q3"}
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_labels ON audio_jobs USING GIN (labels jsonb_path_ops);
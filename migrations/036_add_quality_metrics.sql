@@ -0,0 +1,16 @@
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS quality JSONB DEFAULT NULL,                -- audio.QualityMetrics of the input and output: {"before": ..., "after": ...}
+  ADD COLUMN IF NOT EXISTS loudness_before JSONB DEFAULT NULL,        -- audio.Loudness of the input, loudness is of the output
+  ADD COLUMN IF NOT EXISTS snr_before_db DOUBLE PRECISION DEFAULT NULL, -- NULL when not measured (too little speech)
+  ADD COLUMN IF NOT EXISTS snr_after_db DOUBLE PRECISION DEFAULT NULL;
+
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS snr_gain_db DOUBLE PRECISION GENERATED ALWAYS AS (snr_after_db - snr_before_db) STORED;
+
+-- jobs processed before: the same measurements kept in analysis_json
+UPDATE audio_jobs SET
+  quality = analysis_json->'quality',
+  loudness_before = analysis_json->'loudness'->'before',
+  snr_before_db = NULLIF((analysis_json->'quality'->'before'->>'snr')::float8, 0),
+  snr_after_db = NULLIF((analysis_json->'quality'->'after'->>'snr')::float8, 0)
+WHERE quality IS NULL AND analysis_json ? 'quality';
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS transcripts (
+    job_id UUID PRIMARY KEY,
+    language TEXT,          -- as detected by the ASR, ISO 639-1
+    text TEXT NOT NULL,
+    segments JSONB,         -- [{"start", "end", "text"}]
+    words JSONB,            -- [{"word", "start", "end", "probability"}]
+    model TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
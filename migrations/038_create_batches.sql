@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS batches (
+    id UUID PRIMARY KEY,   -- the bundle job for bundles
+    kind TEXT NOT NULL,    -- submission | bundle | comparison
+    name TEXT,
+    tenant TEXT,
+    owner_id UUID,         -- user who created the batch
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS batch_id UUID DEFAULT NULL; -- batch the job belongs to, see batches
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_batch_id ON audio_jobs (batch_id) WHERE batch_id IS NOT NULL;
+
+-- bundles unpacked before: one batch per bundle, holding its children
+INSERT INTO batches (id, kind, tenant, owner_id, created_at)
+SELECT id, 'bundle', tenant, owner_id, created_at FROM audio_jobs WHERE kind = 'bundle'
+ON CONFLICT DO NOTHING;
+
+UPDATE audio_jobs SET batch_id = parent_id WHERE parent_id IS NOT NULL AND batch_id IS NULL;
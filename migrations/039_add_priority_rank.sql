@@ -0,0 +1,14 @@
+-- normalize jobs created without a priority
+UPDATE audio_jobs SET priority = 'normal' WHERE priority NOT IN ('realtime', 'high', 'normal', 'batch');
+
+-- position of priority in queue.Priorities, 0 most urgent, for claiming in
+-- priority then FIFO order from an index
+ALTER TABLE audio_jobs
+  ADD COLUMN IF NOT EXISTS priority_rank SMALLINT
+    GENERATED ALWAYS AS (CASE priority WHEN 'realtime' THEN 0 WHEN 'high' THEN 1 WHEN 'batch' THEN 3 ELSE 2 END) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_queued_priority ON audio_jobs (priority_rank, created_at) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_scheduled ON audio_jobs (process_after) WHERE status = 'scheduled';
+
+-- superseded by idx_audio_jobs_queued_priority
+DROP INDEX IF EXISTS idx_audio_jobs_queued;
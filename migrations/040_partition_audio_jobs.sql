@@ -0,0 +1,126 @@
+-- audio_jobs partitioned by month of created_at (UTC), see store.CreatePartitions
+-- and store.DropPartitions. Partitions are named audio_jobs_YYYY_MM; rows
+-- outside of them land in audio_jobs_default.
+--
+-- Downtime: an existing table is converted by copying every row into the new
+-- one, in the single transaction the migration runs in. audio_jobs is locked
+-- (ACCESS EXCLUSIVE) until it commits, so the API and the workers wait on every
+-- job query meanwhile, for as long as copying every job and building the
+-- indexes below takes. Stop the API and the workers, or run it in a quiet moment.
+--
+-- Uniqueness: the primary key of a partitioned table must hold the partition
+-- key, so it becomes (id, created_at) and the database no longer enforces that
+-- id alone is unique. Every partition keeps a unique index on id
+-- (audio_jobs_YYYY_MM_id_key), so a job id is unique within its month; ids come
+-- from gen_random_uuid()/uuid.New() and nothing may insert a chosen id, which is
+-- what keeps them unique across months.
+
+-- creates the partition of the month of m, moving the rows of that month out
+-- of audio_jobs_default; false when it exists already
+CREATE OR REPLACE FUNCTION audio_jobs_create_partition(m DATE) RETURNS BOOLEAN AS $$
+DECLARE
+    month_start DATE := date_trunc('month', m)::date;
+    part TEXT := 'audio_jobs_' || to_char(month_start, 'YYYY_MM');
+    lo TIMESTAMPTZ := month_start::timestamp AT TIME ZONE 'UTC';
+    hi TIMESTAMPTZ := (month_start + interval '1 month')::timestamp AT TIME ZONE 'UTC';
+    cols TEXT;
+    stray BOOLEAN := false;
+BEGIN
+    IF to_regclass(part) IS NOT NULL THEN
+        RETURN false;
+    END IF;
+    IF to_regclass('audio_jobs_default') IS NOT NULL THEN
+        EXECUTE 'SELECT EXISTS (SELECT 1 FROM audio_jobs_default WHERE created_at >= $1 AND created_at < $2)'
+            INTO stray USING lo, hi;
+    END IF;
+    IF NOT stray THEN
+        EXECUTE format('CREATE TABLE %I PARTITION OF audio_jobs FOR VALUES FROM (%L) TO (%L)', part, lo, hi);
+        EXECUTE format('CREATE UNIQUE INDEX %I ON %I (id)', part || '_id_key', part);
+        RETURN true;
+    END IF;
+
+    -- a partition can't be added while the default one holds rows of its range
+    SELECT string_agg(quote_ident(attname), ', ' ORDER BY attnum) INTO cols
+    FROM pg_attribute
+    WHERE attrelid = 'audio_jobs'::regclass AND attnum > 0 AND NOT attisdropped AND attgenerated = '';
+    ALTER TABLE audio_jobs DETACH PARTITION audio_jobs_default;
+    EXECUTE format('CREATE TABLE %I PARTITION OF audio_jobs FOR VALUES FROM (%L) TO (%L)', part, lo, hi);
+    EXECUTE format('CREATE UNIQUE INDEX %I ON %I (id)', part || '_id_key', part);
+    EXECUTE format('WITH moved AS (DELETE FROM audio_jobs_default WHERE created_at >= %L AND created_at < %L RETURNING %s) '
+                   'INSERT INTO audio_jobs (%s) SELECT %s FROM moved', lo, hi, cols, cols, cols);
+    ALTER TABLE audio_jobs ATTACH PARTITION audio_jobs_default DEFAULT;
+    RETURN true;
+END
+$$ LANGUAGE plpgsql;
+
+DO $$
+DECLARE
+    cols TEXT;
+    m DATE;
+BEGIN
+    IF (SELECT relkind FROM pg_class WHERE oid = 'audio_jobs'::regclass) = 'p' THEN
+        RETURN;
+    END IF;
+
+    -- the partition key can't be NULL
+    UPDATE audio_jobs SET created_at = COALESCE(started_at, finished_at, now()) WHERE created_at IS NULL;
+
+    ALTER TABLE audio_jobs RENAME TO audio_jobs_unpartitioned;
+    CREATE TABLE audio_jobs (LIKE audio_jobs_unpartitioned INCLUDING DEFAULTS INCLUDING GENERATED INCLUDING STORAGE INCLUDING COMMENTS)
+        PARTITION BY RANGE (created_at);
+    ALTER TABLE audio_jobs ALTER COLUMN created_at SET NOT NULL;
+    CREATE TABLE audio_jobs_default PARTITION OF audio_jobs DEFAULT;
+    CREATE UNIQUE INDEX audio_jobs_default_id_key ON audio_jobs_default (id);
+
+    -- every month with jobs, and the next three
+    FOR m IN
+        SELECT generate_series(
+            date_trunc('month', COALESCE((SELECT min(created_at) FROM audio_jobs_unpartitioned), now()) AT TIME ZONE 'UTC'),
+            date_trunc('month', now() AT TIME ZONE 'UTC') + interval '3 months',
+            interval '1 month')::date
+    LOOP
+        PERFORM audio_jobs_create_partition(m);
+    END LOOP;
+
+    SELECT string_agg(quote_ident(attname), ', ' ORDER BY attnum) INTO cols
+    FROM pg_attribute
+    WHERE attrelid = 'audio_jobs_unpartitioned'::regclass AND attnum > 0 AND NOT attisdropped AND attgenerated = '';
+    EXECUTE format('INSERT INTO audio_jobs (%s) SELECT %s FROM audio_jobs_unpartitioned', cols, cols);
+    DROP TABLE audio_jobs_unpartitioned;
+END
+$$;
+
+-- (id, created_at), see Uniqueness above
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'audio_jobs'::regclass AND contype = 'p') THEN
+        ALTER TABLE audio_jobs ADD PRIMARY KEY (id, created_at);
+    END IF;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_status ON audio_jobs (status);
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_s3_key ON audio_jobs (s3_bucket, s3_key) WHERE s3_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_expires_at ON audio_jobs (expires_at);
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_duration_sec ON audio_jobs (duration_sec);
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_heartbeat_at ON audio_jobs (status, heartbeat_at);
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_priority ON audio_jobs (status, priority, created_at);
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_content_hash ON audio_jobs (content_hash, options_hash);
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_parent_id ON audio_jobs (parent_id);
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_dead_air ON audio_jobs (dead_air_pct) WHERE dead_air_pct IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_language ON audio_jobs (language) WHERE language IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_keyword_hits ON audio_jobs (keyword_hits) WHERE keyword_hits > 0;
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_answer_class ON audio_jobs (answer_class);
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_echo_score ON audio_jobs (echo_score) WHERE echo_score > 0;
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_media_codec ON audio_jobs ((media_info->>'container'), (media_info->>'codec')) WHERE media_info IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_error_code ON audio_jobs (error_code) WHERE error_code IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_loudness_i ON audio_jobs (((loudness->>'integrated_lufs')::double precision)) WHERE loudness IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_unpurged ON audio_jobs (finished_at) WHERE purged_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_caller_ref ON audio_jobs (caller_ref) WHERE caller_ref IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_original_key ON audio_jobs (original_bucket, original_key) WHERE original_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_unarchived ON audio_jobs (finished_at) WHERE archived_at IS NULL AND purged_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_tenant ON audio_jobs (tenant, created_at);
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_labels ON audio_jobs USING GIN (labels jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_batch_id ON audio_jobs (batch_id) WHERE batch_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_queued_priority ON audio_jobs (priority_rank, created_at) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS idx_audio_jobs_scheduled ON audio_jobs (process_after) WHERE status = 'scheduled';
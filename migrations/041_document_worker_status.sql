@@ -0,0 +1,2 @@
+-- workers.status gained 'paused' with the pause/resume control messages
+COMMENT ON COLUMN workers.status IS 'running | paused | stopped';
@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations of the database schema, applied
+// in the order of their file names by store.Migrate
+package migrations
+
+import "embed"
+
+// FS holds the NNN_name.sql files of this directory
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,48 @@
+"""
+tools/diarize_pyannote.py
+
+Usage:
+  python tools/diarize_pyannote.py [--num-speakers 2] input.wav
+
+Speaker diarization with pyannote.audio. Prints the speaker turns as JSON:
+
+  {"segments": [{"speaker": "SPEAKER_00", "start": 0.52, "end": 3.18}, ...]}
+
+Needs ``pip install pyannote.audio`` and a Hugging Face token with access to the
+pipeline in HF_TOKEN. The pipeline name can be changed with PYANNOTE_PIPELINE.
+Worker setup: -diarizer "cmd:python3 tools/diarize_pyannote.py --num-speakers 2"
+"""
+import argparse
+import json
+import os
+
+from pyannote.audio import Pipeline
+
+
+def main():
+    p = argparse.ArgumentParser()
+    p.add_argument("--num-speakers", dest="num_speakers", type=int, default=None,
+                   help="known speaker count (2 for a call), improves accuracy")
+    p.add_argument("infile", help="input audio path")
+    args = p.parse_args()
+
+    if not os.path.isfile(args.infile):
+        print("input not found:", args.infile)
+        raise SystemExit(2)
+
+    name = os.environ.get("PYANNOTE_PIPELINE", "pyannote/speaker-diarization-3.1")
+    pipeline = Pipeline.from_pretrained(name, use_auth_token=os.environ.get("HF_TOKEN"))
+    kwargs = {}
+    if args.num_speakers:
+        kwargs["num_speakers"] = args.num_speakers
+    diarization = pipeline(args.infile, **kwargs)
+
+    segments = [
+        {"speaker": speaker, "start": round(turn.start, 3), "end": round(turn.end, 3)}
+        for turn, _, speaker in diarization.itertracks(yield_label=True)
+    ]
+    print(json.dumps({"segments": segments}))
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,74 @@
+"""
+tools/quality_score.py
+
+Usage:
+  python tools/quality_score.py --ref reference.wav --deg processed.wav
+
+Computes intrusive objective quality scores of a processed recording against its
+reference (the signal before denoising) and prints them as one JSON object:
+
+  {"pesq": 2.91, "pesq_mode": "wb", "stoi": 0.87, "estoi": 0.74}
+
+Both files must be time aligned, mono and at the same sample rate (the worker
+converts them to 16 kHz mono first). PESQ needs the ``pesq`` package, STOI the
+``pystoi`` package; a missing package leaves its score out.
+"""
+import argparse
+import json
+import os
+
+import numpy as np
+import soundfile as sf
+
+
+def load_wav(path):
+    data, sr = sf.read(path, dtype="float32")
+    if data.ndim > 1:
+        data = np.mean(data, axis=1)
+    return data, sr
+
+
+def main():
+    p = argparse.ArgumentParser()
+    p.add_argument("--ref", required=True, help="reference wav (before denoising)")
+    p.add_argument("--deg", required=True, help="processed wav")
+    args = p.parse_args()
+
+    for path in (args.ref, args.deg):
+        if not os.path.isfile(path):
+            print("input not found:", path)
+            raise SystemExit(2)
+
+    ref, sr = load_wav(args.ref)
+    deg, dsr = load_wav(args.deg)
+    if sr != dsr:
+        print("sample rate mismatch:", sr, dsr)
+        raise SystemExit(2)
+    n = min(len(ref), len(deg))
+    ref, deg = ref[:n], deg[:n]
+
+    scores = {}
+    try:
+        from pesq import pesq
+
+        mode = "wb" if sr == 16000 else "nb"
+        scores["pesq"] = float(pesq(sr, ref, deg, mode))
+        scores["pesq_mode"] = mode
+    except ImportError:
+        pass
+    try:
+        from pystoi import stoi
+
+        scores["stoi"] = float(stoi(ref, deg, sr, extended=False))
+        scores["estoi"] = float(stoi(ref, deg, sr, extended=True))
+    except ImportError:
+        pass
+
+    if not scores:
+        print("neither pesq nor pystoi is installed")
+        raise SystemExit(3)
+    print(json.dumps(scores))
+
+
+if __name__ == "__main__":
+    main()
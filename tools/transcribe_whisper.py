@@ -0,0 +1,53 @@
+"""
+tools/transcribe_whisper.py
+
+Usage:
+  python tools/transcribe_whisper.py input.wav
+
+Transcribes a recording with faster-whisper and prints the transcript with word
+timestamps as JSON:
+
+  {"language": "en", "model": "small", "text": "...",
+   "segments": [{"start": 0.0, "end": 2.1, "text": "..."}],
+   "words": [{"word": "hello", "start": 0.1, "end": 0.4, "probability": 0.98}]}
+
+Needs ``pip install faster-whisper``. ASR_MODEL (default "small"), ASR_DEVICE
+(default "cpu") and ASR_LANGUAGE (default: detect) configure the model.
+Worker setup: -asr "cmd:python3 tools/transcribe_whisper.py"
+"""
+import argparse
+import json
+import os
+
+from faster_whisper import WhisperModel
+
+
+def main():
+    p = argparse.ArgumentParser()
+    p.add_argument("infile", help="input audio path")
+    args = p.parse_args()
+
+    if not os.path.isfile(args.infile):
+        print("input not found:", args.infile)
+        raise SystemExit(2)
+
+    name = os.environ.get("ASR_MODEL", "small")
+    model = WhisperModel(name, device=os.environ.get("ASR_DEVICE", "cpu"), compute_type="int8")
+    segments, info = model.transcribe(args.infile, word_timestamps=True, vad_filter=True,
+                                      language=os.environ.get("ASR_LANGUAGE") or None)
+
+    out = {"language": info.language, "model": name, "segments": [], "words": []}
+    texts = []
+    for seg in segments:
+        text = seg.text.strip()
+        texts.append(text)
+        out["segments"].append({"start": round(seg.start, 3), "end": round(seg.end, 3), "text": text})
+        for w in seg.words or []:
+            out["words"].append({"word": w.word.strip(), "start": round(w.start, 3),
+                                 "end": round(w.end, 3), "probability": round(float(w.probability), 4)})
+    out["text"] = " ".join(texts)
+    print(json.dumps(out))
+
+
+if __name__ == "__main__":
+    main()